@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 确认链接的有效期，过期的链接定期清理
+const ackLinkTTL = 7 * 24 * time.Hour
+
+func init() {
+	httpMux.HandleFunc("GET /ack/{id}", handleAck)
+}
+
+// 尚未使用的确认链接
+type pendingAck struct {
+	Hash        uint64
+	Fingerprint string
+	Created     time.Time
+}
+
+// 确认链接与已确认的指纹
+var acks = struct {
+	sync.Mutex
+	pending map[string]pendingAck // 按告警ID索引
+	until   map[uint64]time.Time  // 指纹确认后不再通知，直到该时间
+}{pending: make(map[string]pendingAck), until: make(map[uint64]time.Time)}
+
+// 为告警生成一次性的确认链接
+func newAckURL(entry *SlowQueryEntry) string {
+	var b [16]byte
+	rand.Read(b[:])
+	id := hex.EncodeToString(b[:])
+	now := time.Now()
+
+	acks.Lock()
+	defer acks.Unlock()
+	for key, p := range acks.pending {
+		if now.Sub(p.Created) > ackLinkTTL {
+			delete(acks.pending, key)
+		}
+	}
+	acks.pending[id] = pendingAck{Hash: entry.Hash, Fingerprint: entry.Fingerprint, Created: now}
+	return strings.TrimRight(ackCallbackURL, "/") + "/ack/" + id
+}
+
+// 确认告警，返回被确认的指纹，链接无效或已使用时返回 false
+func acknowledge(id string, now time.Time) (pendingAck, bool) {
+	acks.Lock()
+	defer acks.Unlock()
+	p, ok := acks.pending[id]
+	if !ok || now.Sub(p.Created) > ackLinkTTL {
+		return pendingAck{}, false
+	}
+	delete(acks.pending, id)
+	acks.until[p.Hash] = now.Add(ackSuppressWindow)
+	return p, true
+}
+
+// 判断该指纹是否已被确认且仍在 --ackSuppressWindow 内
+func suppressedByAck(hash uint64, now time.Time) bool {
+	acks.Lock()
+	defer acks.Unlock()
+	until, ok := acks.until[hash]
+	if !ok {
+		return false
+	}
+	if now.After(until) {
+		delete(acks.until, hash)
+		return false
+	}
+	return true
+}
+
+// 处理确认链接，comment 参数记录在日志中
+func handleAck(w http.ResponseWriter, r *http.Request) {
+	p, ok := acknowledge(r.PathValue("id"), time.Now())
+	if !ok {
+		http.Error(w, "确认链接无效或已使用", http.StatusNotFound)
+		return
+	}
+	comment := r.URL.Query().Get("comment")
+	logf(levelInfo, "告警已确认: 指纹 %x，来自 %s，备注: %q", p.Hash, r.RemoteAddr, comment)
+	fmt.Fprintf(w, "告警已确认，%s 内不再通知该SQL指纹:\n%s\n", ackSuppressWindow, p.Fingerprint)
+}
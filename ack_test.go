@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcknowledgeAlert(t *testing.T) {
+	server := httptest.NewServer(httpMux)
+	defer server.Close()
+
+	prevURL, prevWindow := ackCallbackURL, ackSuppressWindow
+	t.Cleanup(func() { ackCallbackURL, ackSuppressWindow = prevURL, prevWindow })
+	ackCallbackURL, ackSuppressWindow = server.URL+"/", time.Hour
+
+	entry := &SlowQueryEntry{Hash: 7, Fingerprint: "select * from orders where id = ?"}
+	url := newAckURL(entry)
+	if !strings.HasPrefix(url, server.URL+"/ack/") {
+		t.Fatalf("确认链接 = %s", url)
+	}
+	if suppressedByAck(entry.Hash, time.Now()) {
+		t.Fatal("确认之前不应抑制告警")
+	}
+
+	resp, err := http.Get(url + "?comment=looking")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("确认请求返回 %s", resp.Status)
+	}
+	if !suppressedByAck(entry.Hash, time.Now()) {
+		t.Error("确认之后应抑制告警")
+	}
+	if suppressedByAck(entry.Hash, time.Now().Add(2*time.Hour)) {
+		t.Error("超过 --ackSuppressWindow 后不应再抑制告警")
+	}
+
+	// 确认链接只能使用一次
+	resp, err = http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("重复使用确认链接返回 %s，want 404", resp.Status)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// 开始判断异常前每个维度至少需要的样本数，样本过少时标准差没有意义
+const anomalyMinSamples = 30
+
+// 使用 Welford 在线算法维护的查询时间均值与方差
+type rollingStats struct {
+	count int64
+	mean  float64
+	m2    float64 // 与均值之差的平方和
+}
+
+// 加入一个样本
+func (s *rollingStats) add(x float64) {
+	s.count++
+	delta := x - s.mean
+	s.mean += delta / float64(s.count)
+	s.m2 += delta * (x - s.mean)
+}
+
+// 样本标准差
+func (s *rollingStats) stddev() float64 {
+	if s.count < 2 {
+		return 0
+	}
+	return math.Sqrt(s.m2 / float64(s.count-1))
+}
+
+// 按数据库、按用户统计的查询时间
+var anomalyStats = struct {
+	sync.Mutex
+	byKey map[string]*rollingStats
+}{byKey: make(map[string]*rollingStats)}
+
+// 一次异常判断的结果
+type anomalyFinding struct {
+	Dimension string  // 数据库 或 用户
+	Name      string  // 数据库名或用户名
+	Mean      float64 // 该维度的历史平均查询时间，单位：秒
+	Stddev    float64 // 该维度的历史查询时间标准差，单位：秒
+}
+
+// 判断查询时间是否明显偏离该数据库、该用户的历史水平，判断后把本次查询加入统计
+func detectAnomalies(entry *SlowQueryEntry) []anomalyFinding {
+	dimensions := []struct{ dimension, name string }{
+		{"数据库", entry.Database},
+		{"用户", entry.User},
+	}
+
+	anomalyStats.Lock()
+	defer anomalyStats.Unlock()
+
+	var findings []anomalyFinding
+	for _, d := range dimensions {
+		if d.name == "" {
+			continue
+		}
+		key := d.dimension + ":" + d.name
+		stats, ok := anomalyStats.byKey[key]
+		if !ok {
+			stats = &rollingStats{}
+			anomalyStats.byKey[key] = stats
+		}
+
+		// 先判断再加入样本，避免异常值本身拉高均值和标准差
+		if stats.count >= anomalyMinSamples && entry.QueryTime >= anomalyMinTime.Seconds() {
+			if limit := stats.mean + anomalySigmas*stats.stddev(); entry.QueryTime > limit {
+				findings = append(findings, anomalyFinding{Dimension: d.dimension, Name: d.name, Mean: stats.mean, Stddev: stats.stddev()})
+			}
+		}
+		stats.add(entry.QueryTime)
+	}
+	return findings
+}
+
+// 生成异常慢查询告警，与阈值告警使用不同的标题
+func buildAnomalyNotification(entry *SlowQueryEntry, findings []anomalyFinding) *notification {
+	n := buildSlowQueryNotification(entry)
+	n.Title = "异常慢查询"
+	for _, f := range findings {
+		n.Fields = append(n.Fields, notificationField{
+			Label:     f.Dimension + " " + f.Name + " 历史水平",
+			Value:     fmt.Sprintf("平均 %.3f 秒，标准差 %.3f 秒，本次超过 %.1f 倍标准差", f.Mean, f.Stddev, anomalySigmas),
+			Highlight: true,
+		})
+	}
+	return n
+}
+
+// 发送异常慢查询告警，测试中可以替换为模拟实现
+var anomalyNotifier = func(targets []webhookTarget, entry *SlowQueryEntry, findings []anomalyFinding) (int, error) {
+	return deliverNotification(targets, buildAnomalyNotification(entry, findings))
+}
+
+// 检查并发送异常慢查询告警，同一指纹的异常告警与阈值告警分别计算冷却时间
+func checkAnomaly(entry *SlowQueryEntry) {
+	findings := detectAnomalies(entry)
+	if len(findings) == 0 {
+		return
+	}
+	if shouldSuppressByCooldown(computeQueryHash("anomaly:"+entry.Fingerprint), time.Now()) {
+		logf(levelDebug, "指纹 %x 的异常告警处于冷却期内，不发送通知", entry.Hash)
+		return
+	}
+	logf(levelInfo, "检测到异常慢查询: 查询时间 %.3f 秒", entry.QueryTime)
+	anomalyNotifier(routeTargets(entry), entry, findings)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRollingStats(t *testing.T) {
+	var s rollingStats
+	for _, x := range []float64{2, 4, 4, 4, 5, 5, 7, 9} {
+		s.add(x)
+	}
+	if s.mean != 5 {
+		t.Errorf("mean = %v, want 5", s.mean)
+	}
+	if want := math.Sqrt(32.0 / 7); math.Abs(s.stddev()-want) > 1e-9 {
+		t.Errorf("stddev = %v, want %v", s.stddev(), want)
+	}
+}
+
+func TestDetectAnomalies(t *testing.T) {
+	anomalyStats.byKey = make(map[string]*rollingStats)
+	t.Cleanup(func() { anomalyStats.byKey = make(map[string]*rollingStats) })
+
+	for i := 0; i < anomalyMinSamples; i++ {
+		entry := &SlowQueryEntry{Database: "shop", User: "app", QueryTime: 0.2 + float64(i%3)*0.01}
+		if findings := detectAnomalies(entry); len(findings) != 0 {
+			t.Fatalf("样本不足时不应判断为异常: %+v", findings)
+		}
+	}
+
+	// 同一数据库的其他用户样本不足，只有数据库维度判断为异常
+	findings := detectAnomalies(&SlowQueryEntry{Database: "shop", User: "report", QueryTime: 1.5})
+	if len(findings) != 1 || findings[0].Dimension != "数据库" || findings[0].Name != "shop" {
+		t.Fatalf("findings = %+v, want 数据库 shop", findings)
+	}
+
+	// 低于 --anomalyMinTime 时即使偏离历史水平也不告警
+	prev := anomalyMinTime
+	t.Cleanup(func() { anomalyMinTime = prev })
+	anomalyMinTime = 2 * time.Second
+	if findings := detectAnomalies(&SlowQueryEntry{Database: "shop", User: "app", QueryTime: 1.9}); len(findings) != 0 {
+		t.Fatalf("低于最小查询时间时不应判断为异常: %+v", findings)
+	}
+}
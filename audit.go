@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// 审计日志中的一条记录：每条通知的发送结果
+type auditRecord struct {
+	Time        time.Time `json:"time"`
+	Title       string    `json:"title"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	URLs        []string  `json:"urls"`
+	Sent        int       `json:"sent"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// --auditLog 打开的文件，logrotate 轮转后通过 SIGUSR1 / SIGUSR2 重新打开
+var auditLog struct {
+	sync.Mutex
+	file *os.File
+}
+
+// 打开审计日志文件，文件已打开时先关闭
+func openAuditLog(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	auditLog.Lock()
+	defer auditLog.Unlock()
+	if auditLog.file != nil {
+		auditLog.file.Close()
+	}
+	auditLog.file = file
+	return nil
+}
+
+// 关闭审计日志文件
+func closeAuditLog() {
+	auditLog.Lock()
+	defer auditLog.Unlock()
+	if auditLog.file != nil {
+		auditLog.file.Close()
+		auditLog.file = nil
+	}
+}
+
+// logrotate 已把旧文件改名，关闭旧的文件句柄并在原路径打开新文件
+func rotateAuditLog() {
+	if err := openAuditLog(auditLogFile); err != nil {
+		logf(levelError, "无法重新打开审计日志 %s: %v", auditLogFile, err)
+		return
+	}
+	logf(levelInfo, "Audit log rotated")
+}
+
+// 记录一条通知的发送结果，未设置 --auditLog 时不记录
+func writeAuditRecord(targets []webhookTarget, n *notification, sent int, sendErr error) {
+	auditLog.Lock()
+	defer auditLog.Unlock()
+	if auditLog.file == nil {
+		return
+	}
+	record := auditRecord{
+		Time:        time.Now(),
+		Title:       n.Title,
+		Fingerprint: n.Fingerprint,
+		URLs:        webhookTargetURLs(targets),
+		Sent:        sent,
+	}
+	if sendErr != nil {
+		record.Error = sendErr.Error()
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	if _, err := auditLog.file.Write(append(data, '\n')); err != nil {
+		logf(levelWarn, "无法写入审计日志: %v", err)
+	}
+}
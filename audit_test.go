@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAuditLogRotate(t *testing.T) {
+	dir := t.TempDir()
+	prevFile := auditLogFile
+	t.Cleanup(func() {
+		closeAuditLog()
+		auditLogFile = prevFile
+	})
+	auditLogFile = filepath.Join(dir, "audit.log")
+	if err := openAuditLog(auditLogFile); err != nil {
+		t.Fatal(err)
+	}
+
+	targets := []webhookTarget{{URL: "https://example.com/hook"}}
+	writeAuditRecord(targets, &notification{Title: "慢查询警告", Fingerprint: "select ?"}, 1, nil)
+	// 模拟 logrotate：改名后发送信号重新打开
+	if err := os.Rename(auditLogFile, auditLogFile+".1"); err != nil {
+		t.Fatal(err)
+	}
+	rotateAuditLog()
+	writeAuditRecord(targets, &notification{Title: "慢查询警告"}, 0, errors.New("HTTP 502"))
+
+	rotated, err := os.ReadFile(auditLogFile + ".1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(rotated), `"fingerprint":"select ?"`) || strings.Count(string(rotated), "\n") != 1 {
+		t.Errorf("轮转前的记录: %s", rotated)
+	}
+	current, err := os.ReadFile(auditLogFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(current), `"sent":0,"error":"HTTP 502"`) {
+		t.Errorf("轮转后的记录应写入新文件: %s", current)
+	}
+}
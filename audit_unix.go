@@ -0,0 +1,18 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// 监听 SIGUSR1 / SIGUSR2 信号，logrotate 轮转后重新打开审计日志
+func handleAuditLogSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	for range signals {
+		rotateAuditLog()
+	}
+}
@@ -0,0 +1,6 @@
+//go:build windows
+
+package main
+
+// Windows 没有 SIGUSR1 / SIGUSR2，轮转审计日志需要重启进程
+func handleAuditLogSignals() {}
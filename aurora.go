@@ -0,0 +1,42 @@
+package main
+
+import (
+	"regexp"
+	"sync"
+)
+
+// Aurora MySQL 在慢查询日志中附加的元数据行
+var auroraVersionPattern = regexp.MustCompile(`(?i)^#\s*aurora_version:\s*(\S+)`)
+var xidPattern = regexp.MustCompile(`(?i)^#.*\bxid:\s*(\d+)`)
+
+// Aurora 的 # User@Host: user[user] @ ip-10-0-1-1.us-east-1.compute.internal [10.0.1.1]，同时记录了VPC内的主机名与地址
+var auroraUserHostPattern = regexp.MustCompile(`# User@Host:\s*\S+\s*@\s*(\S+)\s+\[([0-9A-Fa-f.:]+)\]`)
+
+// 最近一次在日志中看到的 Aurora 版本，只有部分条目带有 # aurora_version:
+var auroraVersion = struct {
+	sync.Mutex
+	last string
+}{}
+
+// --awsAurora 时地址作为主机，VPC内的主机名作为客户端主机名
+func parseAuroraUserHost(entry *SlowQueryEntry, line string) {
+	matches := auroraUserHostPattern.FindStringSubmatch(line)
+	if matches == nil {
+		return
+	}
+	entry.Host = matches[2]
+	if entry.ClientHostname == "" {
+		entry.ClientHostname = matches[1]
+	}
+}
+
+// 没有 # aurora_version: 的条目沿用最近一次看到的版本，使每条告警都带有版本信息
+func fillAuroraVersion(entry *SlowQueryEntry) {
+	auroraVersion.Lock()
+	defer auroraVersion.Unlock()
+	if entry.AuroraVersion != "" {
+		auroraVersion.last = entry.AuroraVersion
+		return
+	}
+	entry.AuroraVersion = auroraVersion.last
+}
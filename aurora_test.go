@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestAuroraSlowLog(t *testing.T) {
+	var alerted []*SlowQueryEntry
+	prevNotifier, prevAurora := alertNotifier, awsAurora
+	t.Cleanup(func() {
+		alertNotifier, awsAurora = prevNotifier, prevAurora
+		auroraVersion.last = ""
+	})
+	alertNotifier = func(targets []webhookTarget, entry *SlowQueryEntry) (int, error) {
+		alerted = append(alerted, entry)
+		return 1, nil
+	}
+	awsAurora = true
+
+	processSlowQuery(fixtureLines(`
+# Time: 2024-03-10T08:15:42.000000Z
+# User@Host: app[app] @ ip-10-0-1-1.us-east-1.compute.internal [10.0.1.1]  Id:    49
+# aurora_version: 3.04.0
+# xid: 12345
+# Query_time: 1.500000  Lock_time: 0.000100 Rows_sent: 1  Rows_examined: 100
+SELECT 1;`), nil)
+	processSlowQuery(fixtureLines(`
+# Time: 2024-03-10T08:15:43.000000Z
+# User@Host: app[app] @ ip-10-0-1-2.us-east-1.compute.internal [10.0.1.2]  Id:    50
+# Query_time: 2.500000  Lock_time: 0.000100 Rows_sent: 1  Rows_examined: 100
+SELECT 2;`), nil)
+
+	if len(alerted) != 2 {
+		t.Fatalf("实际告警 %d 条", len(alerted))
+	}
+	first, second := alerted[0], alerted[1]
+	if first.Host != "10.0.1.1" || first.ClientHostname != "ip-10-0-1-1.us-east-1.compute.internal" {
+		t.Errorf("Host = %q, ClientHostname = %q", first.Host, first.ClientHostname)
+	}
+	if first.User != "app" || first.XID != 12345 || first.SQL != "SELECT 1;" {
+		t.Errorf("entry = %+v", first)
+	}
+	if second.AuroraVersion != "3.04.0" {
+		t.Errorf("没有 # aurora_version: 的条目应沿用最近一次的版本，实际 %q", second.AuroraVersion)
+	}
+	if field, ok := slowQueryField("auroraVersion", second); !ok || field.Value != "3.04.0" {
+		t.Errorf("auroraVersion 字段 = %+v, %v", field, ok)
+	}
+}
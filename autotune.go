@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// 取 since 之后执行的慢查询的查询时间
+func recentQueryTimes(entries [][]string, since time.Time) []float64 {
+	var queryTimes []float64
+	for _, lines := range entries {
+		entry, err := logParser.Parse(lines)
+		if err != nil {
+			continue
+		}
+		if start := queryStartTime(entry); start.IsZero() || start.Before(since) {
+			continue
+		}
+		queryTimes = append(queryTimes, entry.QueryTime)
+	}
+	return queryTimes
+}
+
+// 按 --autoTunePercentile 计算阈值，并限制在 --autoTuneMinThreshold 与 --autoTuneMaxThreshold 之间
+func tunedThreshold(queryTimes []float64) float64 {
+	sort.Float64s(queryTimes)
+	threshold := percentile(queryTimes, autoTunePercentile)
+	if threshold < autoTuneMinThreshold {
+		threshold = autoTuneMinThreshold
+	}
+	if autoTuneMaxThreshold > 0 && threshold > autoTuneMaxThreshold {
+		threshold = autoTuneMaxThreshold
+	}
+	return threshold
+}
+
+// 根据最近 --autoTuneWindowHours 小时的慢查询日志重新计算 slowQueryThreshold
+func autoTuneThresholdOnce(now time.Time) error {
+	entries, err := readLogEntries(slowLogFile)
+	if err != nil {
+		return fmt.Errorf("无法读取慢查询日志 %s: %w", slowLogFile, err)
+	}
+	window := time.Duration(autoTuneWindowHours) * time.Hour
+	queryTimes := recentQueryTimes(entries, now.Add(-window))
+	if len(queryTimes) == 0 {
+		logf(levelInfo, "最近 %d 小时没有慢查询，保持慢查询阈值 %.2f 秒", autoTuneWindowHours, slowQueryThreshold)
+		return nil
+	}
+	slowQueryThreshold = tunedThreshold(queryTimes)
+	logf(levelInfo, "已根据最近 %d 小时的 %d 条慢查询将慢查询阈值调整为 %.2f 秒（P%g）",
+		autoTuneWindowHours, len(queryTimes), slowQueryThreshold, autoTunePercentile)
+	return nil
+}
+
+// 按 --autoTuneInterval 定期重新计算阈值
+func runAutoTune(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		if err := autoTuneThresholdOnce(now); err != nil {
+			logf(levelWarn, "自动调整慢查询阈值失败: %v", err)
+		}
+	}
+}
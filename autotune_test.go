@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAutoTuneThresholdOnce(t *testing.T) {
+	prevFile, prevThreshold := slowLogFile, slowQueryThreshold
+	prevWindow, prevPercentile, prevMin, prevMax := autoTuneWindowHours, autoTunePercentile, autoTuneMinThreshold, autoTuneMaxThreshold
+	t.Cleanup(func() {
+		slowLogFile, slowQueryThreshold = prevFile, prevThreshold
+		autoTuneWindowHours, autoTunePercentile, autoTuneMinThreshold, autoTuneMaxThreshold = prevWindow, prevPercentile, prevMin, prevMax
+	})
+	autoTuneWindowHours, autoTunePercentile, autoTuneMinThreshold, autoTuneMaxThreshold = 24, 95, 0.1, 60
+
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	var b strings.Builder
+	writeEntry := func(at time.Time, queryTime float64) {
+		fmt.Fprintf(&b, "# Time: %s\n# User@Host: app[app] @ localhost []  Id: 1\n", at.Format("2006-01-02T15:04:05.000000Z"))
+		fmt.Fprintf(&b, "# Query_time: %f  Lock_time: 0.0 Rows_sent: 1  Rows_examined: 1\nSELECT %d;\n", queryTime, b.Len())
+	}
+	// 窗口之外的查询不参与统计
+	writeEntry(now.Add(-48*time.Hour), 100)
+	for i := 1; i <= 100; i++ {
+		writeEntry(now.Add(-time.Duration(i)*time.Minute), float64(i)/10)
+	}
+	slowLogFile = filepath.Join(t.TempDir(), "slow.log")
+	if err := os.WriteFile(slowLogFile, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := autoTuneThresholdOnce(now); err != nil {
+		t.Fatal(err)
+	}
+	if slowQueryThreshold != 9.5 {
+		t.Errorf("slowQueryThreshold = %v, want 9.5", slowQueryThreshold)
+	}
+
+	autoTuneMaxThreshold = 5
+	if err := autoTuneThresholdOnce(now); err != nil {
+		t.Fatal(err)
+	}
+	if slowQueryThreshold != 5 {
+		t.Errorf("超过 --autoTuneMaxThreshold 时应取上限，slowQueryThreshold = %v", slowQueryThreshold)
+	}
+}
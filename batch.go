@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+func init() {
+	subcommands["batch"] = runBatch
+}
+
+// 报告中按总耗时、出现次数列出的指纹个数
+const batchTopN = 20
+
+// 单个SQL指纹的统计
+type batchFingerprint struct {
+	Hash        string  `json:"hash"`
+	Fingerprint string  `json:"fingerprint"`
+	Count       int     `json:"count"`
+	TotalTime   float64 `json:"totalTime"`
+	AvgTime     float64 `json:"avgTime"`
+	MaxTime     float64 `json:"maxTime"`
+	SlowestSQL  string  `json:"slowestSQL"`
+	Database    string  `json:"database"`
+}
+
+// 按数据库或用户统计的慢查询
+type batchGroup struct {
+	Name         string  `json:"name"`
+	Queries      int     `json:"queries"`
+	TotalTime    float64 `json:"totalTime"`
+	AvgTime      float64 `json:"avgTime"`
+	MaxTime      float64 `json:"maxTime"`
+	Fingerprints int     `json:"fingerprints"`
+
+	hashes map[uint64]bool
+}
+
+// 一个小时内的慢查询
+type batchBucket struct {
+	Hour      time.Time `json:"hour"`
+	Queries   int       `json:"queries"`
+	TotalTime float64   `json:"totalTime"`
+}
+
+// batch 子命令输出的报告
+type batchReport struct {
+	Input              string              `json:"input"`
+	From               *time.Time          `json:"from,omitempty"`
+	To                 *time.Time          `json:"to,omitempty"`
+	GeneratedAt        time.Time           `json:"generatedAt"`
+	TotalQueries       int                 `json:"totalQueries"`
+	TotalTime          float64             `json:"totalTime"`
+	UniqueFingerprints int                 `json:"uniqueFingerprints"`
+	ParseErrors        int                 `json:"parseErrors"`
+	TopByTotalTime     []*batchFingerprint `json:"topByTotalTime"`
+	TopByCount         []*batchFingerprint `json:"topByCount"`
+	Databases          []*batchGroup       `json:"databases"`
+	Users              []*batchGroup       `json:"users"`
+	Hourly             []*batchBucket      `json:"hourly"`
+
+	ptDigest *ptDigest // 设置 --ptDigestOutput 时按 pt-query-digest 格式的统计
+}
+
+// 汇总一批慢查询
+type batchAggregator struct {
+	fingerprints map[uint64]*batchFingerprint
+	databases    map[string]*batchGroup
+	users        map[string]*batchGroup
+	hours        map[time.Time]*batchBucket
+	report       batchReport
+}
+
+func newBatchAggregator() *batchAggregator {
+	return &batchAggregator{
+		fingerprints: make(map[uint64]*batchFingerprint),
+		databases:    make(map[string]*batchGroup),
+		users:        make(map[string]*batchGroup),
+		hours:        make(map[time.Time]*batchBucket),
+	}
+}
+
+// 计入数据库或用户的统计
+func addToBatchGroup(groups map[string]*batchGroup, name string, entry *SlowQueryEntry) {
+	g, ok := groups[name]
+	if !ok {
+		g = &batchGroup{Name: name, hashes: make(map[uint64]bool)}
+		groups[name] = g
+	}
+	g.Queries++
+	g.TotalTime += entry.QueryTime
+	g.MaxTime = max(g.MaxTime, entry.QueryTime)
+	g.hashes[entry.Hash] = true
+}
+
+func (a *batchAggregator) add(entry *SlowQueryEntry) {
+	a.report.TotalQueries++
+	a.report.TotalTime += entry.QueryTime
+
+	fp, ok := a.fingerprints[entry.Hash]
+	if !ok {
+		fp = &batchFingerprint{Hash: fmt.Sprintf("%016x", entry.Hash), Fingerprint: entry.Fingerprint, Database: entry.Database}
+		a.fingerprints[entry.Hash] = fp
+	}
+	fp.Count++
+	fp.TotalTime += entry.QueryTime
+	if entry.QueryTime >= fp.MaxTime {
+		fp.MaxTime, fp.SlowestSQL = entry.QueryTime, entry.SQL
+	}
+
+	addToBatchGroup(a.databases, entry.Database, entry)
+	addToBatchGroup(a.users, entry.User, entry)
+
+	if at := queryStartTime(entry); !at.IsZero() {
+		hour := at.Truncate(time.Hour)
+		b, ok := a.hours[hour]
+		if !ok {
+			b = &batchBucket{Hour: hour}
+			a.hours[hour] = b
+		}
+		b.Queries++
+		b.TotalTime += entry.QueryTime
+	}
+}
+
+// 按总耗时从高到低排列统计结果
+func sortedBatchGroups(groups map[string]*batchGroup) []*batchGroup {
+	sorted := make([]*batchGroup, 0, len(groups))
+	for _, g := range groups {
+		g.AvgTime = g.TotalTime / float64(g.Queries)
+		g.Fingerprints = len(g.hashes)
+		sorted = append(sorted, g)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].TotalTime != sorted[j].TotalTime {
+			return sorted[i].TotalTime > sorted[j].TotalTime
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+// 按 less 排序后取前 batchTopN 个指纹
+func topBatchFingerprints(all []*batchFingerprint, less func(a, b *batchFingerprint) bool) []*batchFingerprint {
+	sorted := append([]*batchFingerprint(nil), all...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if less(sorted[i], sorted[j]) {
+			return true
+		}
+		if less(sorted[j], sorted[i]) {
+			return false
+		}
+		return sorted[i].Hash < sorted[j].Hash
+	})
+	if len(sorted) > batchTopN {
+		sorted = sorted[:batchTopN]
+	}
+	return sorted
+}
+
+// 生成报告
+func (a *batchAggregator) finish() *batchReport {
+	all := make([]*batchFingerprint, 0, len(a.fingerprints))
+	for _, fp := range a.fingerprints {
+		fp.AvgTime = fp.TotalTime / float64(fp.Count)
+		all = append(all, fp)
+	}
+	r := &a.report
+	r.UniqueFingerprints = len(all)
+	r.TopByTotalTime = topBatchFingerprints(all, func(a, b *batchFingerprint) bool { return a.TotalTime > b.TotalTime })
+	r.TopByCount = topBatchFingerprints(all, func(a, b *batchFingerprint) bool { return a.Count > b.Count })
+	r.Databases = sortedBatchGroups(a.databases)
+	r.Users = sortedBatchGroups(a.users)
+
+	r.Hourly = make([]*batchBucket, 0, len(a.hours))
+	for _, b := range a.hours {
+		r.Hourly = append(r.Hourly, b)
+	}
+	sort.Slice(r.Hourly, func(i, j int) bool { return r.Hourly[i].Hour.Before(r.Hourly[j].Hour) })
+	return r
+}
+
+// 统计日志条目，只计入 [from, to) 内的慢查询，from 或 to 为零值时不限制
+// 没有任何 # 行的条目是文件开头的启动信息，不计为解析错误
+func buildBatchReport(entries [][]string, from, to time.Time) *batchReport {
+	a := newBatchAggregator()
+	var pt *ptDigest
+	if ptDigestOutput != "" {
+		pt = newPTDigest()
+	}
+	for _, lines := range entries {
+		entry, err := logParser.Parse(lines)
+		if err != nil {
+			for _, line := range lines {
+				if isMetadataLine(line) {
+					logf(levelDebug, "%v", err)
+					a.report.ParseErrors++
+					break
+				}
+			}
+			continue
+		}
+		if !from.IsZero() || !to.IsZero() {
+			at := queryStartTime(entry)
+			if at.IsZero() || at.Before(from) || (!to.IsZero() && !at.Before(to)) {
+				continue
+			}
+		}
+		a.add(entry)
+		if pt != nil {
+			pt.add(entry)
+		}
+	}
+	report := a.finish()
+	report.ptDigest = pt
+	return report
+}
+
+// batch 子命令：离线统计慢查询日志并输出JSON报告，不发送通知；有解析错误时以状态码 1 退出
+func runBatch(args []string) error {
+	var input, output, fromValue, toValue string
+	flags := pflag.NewFlagSet("batch", pflag.ContinueOnError)
+	flags.StringVar(&input, "input", "", "要统计的慢查询日志文件")
+	flags.StringVar(&output, "output", "", "JSON报告的输出文件")
+	flags.StringVar(&fromValue, "from", "", "只统计该时间及之后的慢查询，例如 2024-01-01")
+	flags.StringVar(&toValue, "to", "", "只统计该时间之前的慢查询，例如 2024-01-02")
+	flags.AddFlagSet(pflag.CommandLine)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if input == "" || output == "" {
+		return fmt.Errorf("用法: %s batch --input <日志文件> --output <报告文件> [--from <时间>] [--to <时间>]", programName)
+	}
+	if err := configureSQLKeywords(); err != nil {
+		return err
+	}
+	if err := configureInputFormat(); err != nil {
+		return err
+	}
+	var from, to time.Time
+	var err error
+	if fromValue != "" {
+		if from, err = parseTimestamp(fromValue); err != nil {
+			return fmt.Errorf("--from 参数无效: %w", err)
+		}
+	}
+	if toValue != "" {
+		if to, err = parseTimestamp(toValue); err != nil {
+			return fmt.Errorf("--to 参数无效: %w", err)
+		}
+	}
+
+	entries, err := readLogEntries(input)
+	if err != nil {
+		return fmt.Errorf("无法读取慢查询日志 %s: %w", input, err)
+	}
+	report := buildBatchReport(entries, from, to)
+	report.Input, report.GeneratedAt = input, time.Now()
+	if !from.IsZero() {
+		report.From = &from
+	}
+	if !to.IsZero() {
+		report.To = &to
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(output, append(data, '\n')); err != nil {
+		return fmt.Errorf("无法写入报告 %s: %w", output, err)
+	}
+	if report.ptDigest != nil {
+		if err := writePTDigestFile(ptDigestOutput, report.ptDigest, report.GeneratedAt); err != nil {
+			return err
+		}
+	}
+	logf(levelInfo, "已统计 %d 条慢查询、%d 个SQL指纹", report.TotalQueries, report.UniqueFingerprints)
+	if report.ParseErrors > 0 {
+		return fmt.Errorf("%d 个日志条目解析失败", report.ParseErrors)
+	}
+	return nil
+}
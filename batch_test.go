@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBuildBatchReport(t *testing.T) {
+	entries, err := readLogEntries("testdata/slow.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries = append(entries, fixtureLines(`
+# User@Host: app[app] @ localhost []
+SELECT 1;`))
+
+	r := buildBatchReport(entries, time.Time{}, time.Time{})
+	if r.TotalQueries != 3 || r.UniqueFingerprints != 3 || r.ParseErrors != 1 {
+		t.Fatalf("TotalQueries = %d, UniqueFingerprints = %d, ParseErrors = %d", r.TotalQueries, r.UniqueFingerprints, r.ParseErrors)
+	}
+	if r.TopByTotalTime[0].MaxTime != 5.5 || r.TopByTotalTime[0].Fingerprint != "update orders set status = ? where created_at < ?" {
+		t.Errorf("TopByTotalTime[0] = %+v", r.TopByTotalTime[0])
+	}
+	if len(r.Users) != 2 || r.Users[0].Name != "report" || r.Users[1].Queries != 2 {
+		t.Errorf("Users = %+v", r.Users)
+	}
+	if len(r.Hourly) != 1 || r.Hourly[0].Queries != 3 || !r.Hourly[0].Hour.Equal(time.Date(2024, 3, 10, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("Hourly = %+v", r.Hourly)
+	}
+
+	// 只统计 [from, to) 内的慢查询
+	from := time.Date(2024, 3, 10, 8, 15, 43, 0, time.UTC)
+	r = buildBatchReport(entries, from, from.Add(time.Second))
+	if r.TotalQueries != 1 || r.TopByCount[0].SlowestSQL != "SELECT * FROM users WHERE id = 7;" {
+		t.Errorf("时间范围内的慢查询 = %d, %+v", r.TotalQueries, r.TopByCount)
+	}
+}
+
+func TestRunBatch(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "report.json")
+	if err := runBatch([]string{"--input", "testdata/slow.log", "--output", output, "--from", "2024-03-10", "--to", "2024-03-11"}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r batchReport
+	if err := json.Unmarshal(data, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.TotalQueries != 3 || r.ParseErrors != 0 || r.From == nil || len(r.Databases) != 2 {
+		t.Errorf("报告 = %+v", r)
+	}
+}
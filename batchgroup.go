@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 合并告警的通知中最多列出的查询条数
+const batchMaxRows = 20
+
+// --batchGroupBy 的可选值
+var batchGroupByKeys = []string{"fingerprint", "database", "user", "none"}
+
+// 按 --batchGroupBy 取告警的分组值，none 时所有告警合并为一组
+func batchGroupValue(entry *SlowQueryEntry) string {
+	switch batchGroupBy {
+	case "fingerprint":
+		return entry.Fingerprint
+	case "database":
+		return entry.Database
+	case "user":
+		return entry.User
+	}
+	return ""
+}
+
+// 一个 --batchInterval 窗口内同一分组的告警
+type alertBatch struct {
+	value   string // 分组值，例如数据库名称
+	first   time.Time
+	entries []*SlowQueryEntry
+}
+
+// 正在等待窗口结束的分组；不同实例、发往不同地址的告警不合并
+var alertBatches = struct {
+	sync.Mutex
+	byKey map[string]*alertBatch
+}{byKey: make(map[string]*alertBatch)}
+
+// 检查 --batchGroupBy，不能与 --groupingWindow 同时使用
+func configureBatchGroupBy() error {
+	if batchGroupBy == "" {
+		return nil
+	}
+	batchGroupBy = strings.ToLower(strings.TrimSpace(batchGroupBy))
+	valid := false
+	for _, key := range batchGroupByKeys {
+		valid = valid || key == batchGroupBy
+	}
+	if !valid {
+		return fmt.Errorf("--batchGroupBy %q 无效，可选值: %s", batchGroupBy, strings.Join(batchGroupByKeys, ", "))
+	}
+	if groupingWindow > 0 {
+		return fmt.Errorf("--batchGroupBy 不能与 --groupingWindow 同时使用")
+	}
+	if batchInterval <= 0 {
+		return fmt.Errorf("使用 --batchGroupBy 时 --batchInterval 必须大于 0")
+	}
+	return nil
+}
+
+// 把告警加入分组，分组的第一条告警开始一个 --batchInterval 窗口，窗口结束时发送
+func addToAlertBatch(entry *SlowQueryEntry) {
+	value := batchGroupValue(entry)
+	key := strings.Join(append([]string{instanceName(entry.Instance), value}, webhookTargetURLs(routeTargets(entry))...), "\x00")
+
+	alertBatches.Lock()
+	defer alertBatches.Unlock()
+	b, ok := alertBatches.byKey[key]
+	if !ok {
+		b = &alertBatch{value: value, first: time.Now()}
+		alertBatches.byKey[key] = b
+		time.AfterFunc(batchInterval, func() { flushAlertBatch(key) })
+	}
+	b.entries = append(b.entries, entry)
+}
+
+// 窗口结束，去掉处于冷却期的指纹后发送合并告警；只剩一条时发送普通告警
+func flushAlertBatch(key string) {
+	alertBatches.Lock()
+	b := alertBatches.byKey[key]
+	delete(alertBatches.byKey, key)
+	alertBatches.Unlock()
+	if b == nil {
+		return
+	}
+
+	now := time.Now()
+	suppressed := make(map[uint64]bool)
+	entries := b.entries[:0]
+	for _, entry := range b.entries {
+		if _, seen := suppressed[entry.Hash]; !seen {
+			suppressed[entry.Hash] = shouldSuppressByCooldown(entry.Hash, now)
+		}
+		if !suppressed[entry.Hash] {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) == 0 {
+		logf(levelDebug, "分组 %q 中的指纹都处于冷却期内，不发送合并告警", b.value)
+		return
+	}
+	b.entries = entries
+
+	slowest := entries[0]
+	for _, entry := range entries {
+		if entry.QueryTime > slowest.QueryTime {
+			slowest = entry
+		}
+	}
+	prepareAlert(slowest)
+	if len(entries) == 1 {
+		alertNotifier(routeTargets(slowest), slowest)
+		return
+	}
+	logf(levelInfo, "发送合并告警: 分组 %q 在 %s 内有 %d 条慢查询", b.value, batchInterval, len(entries))
+	sendEntryNotification(routeTargets(slowest), slowest, buildBatchNotification(b, slowest))
+}
+
+// 退出前发送所有尚未结束窗口的分组
+func flushAllAlertBatches() {
+	alertBatches.Lock()
+	keys := make([]string, 0, len(alertBatches.byKey))
+	for key := range alertBatches.byKey {
+		keys = append(keys, key)
+	}
+	alertBatches.Unlock()
+	for _, key := range keys {
+		flushAlertBatch(key)
+	}
+}
+
+// 合并告警的标题，例如 数据库 `payments` 在最近 30s 内有 7 条慢查询
+func batchTitle(b *alertBatch) string {
+	suffix := fmt.Sprintf("在最近 %s 内有 %d 条慢查询", batchInterval, len(b.entries))
+	switch batchGroupBy {
+	case "fingerprint":
+		return "同一SQL指纹" + suffix
+	case "database":
+		return fmt.Sprintf("数据库 `%s` %s", b.value, suffix)
+	case "user":
+		return fmt.Sprintf("用户 `%s` %s", b.value, suffix)
+	}
+	return strings.TrimPrefix(suffix, "在")
+}
+
+// 生成合并告警：在最慢一次的单条告警上加上窗口内的查询列表，字段、执行计划与复制延迟等取最慢的一次
+func buildBatchNotification(b *alertBatch, slowest *SlowQueryEntry) *notification {
+	table := notificationTable{
+		Title:   "慢查询列表",
+		Columns: []string{"时间", "查询时间", "数据库", "用户@主机", "SQL"},
+	}
+	for i, entry := range b.entries {
+		if i == batchMaxRows {
+			break
+		}
+		table.Rows = append(table.Rows, []string{
+			formatDisplayTime(queryStartTime(entry)),
+			fmt.Sprintf("%.2fs", entry.QueryTime),
+			entry.Database,
+			entry.User + "@" + entry.Host,
+			digestSQL(entry.SQL),
+		})
+	}
+	n := buildSlowQueryNotification(slowest)
+	n.Title = batchTitle(b)
+	if slowest.Migration != nil {
+		n.Title = "[MIGRATION ALERT] " + n.Title
+	}
+	n.Fields = append([]notificationField{
+		{Label: "慢查询条数", Value: fmt.Sprintf("%d 条", len(b.entries)), Highlight: true},
+		{Label: "最长查询时间", Value: fmt.Sprintf("%.2f 秒", slowest.QueryTime), Highlight: true},
+		{Label: "时间范围", Value: formatDisplayTime(b.first) + " ~ " + formatDisplayTime(time.Now())},
+	}, n.Fields...)
+	n.Tables = append([]notificationTable{table}, n.Tables...)
+	if hidden := len(b.entries) - batchMaxRows; hidden > 0 {
+		n.Notes = append(n.Notes, fmt.Sprintf("* 另有 %d 条慢查询未列出", hidden))
+	}
+	n.Notes = append(n.Notes, "* 查询时间、主机等字段与 SQL 取窗口内最慢的一次")
+	return n
+}
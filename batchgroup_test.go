@@ -0,0 +1,121 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"mysql-slow-sql-webhook/testutil"
+)
+
+func TestAlertBatchByDatabase(t *testing.T) {
+	server := testutil.NewMockWebhookServer(t)
+
+	prevGroupBy, prevInterval, prevTargets := batchGroupBy, batchInterval, webhookDestinations
+	t.Cleanup(func() { batchGroupBy, batchInterval, webhookDestinations = prevGroupBy, prevInterval, prevTargets })
+	batchGroupBy, batchInterval = "database", 100*time.Millisecond
+	webhookDestinations = []webhookTarget{{URL: server.WeChatURL(), Timeout: 5 * time.Second}}
+	if err := configureBatchGroupBy(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, sql := range []string{"SELECT * FROM charges WHERE id = 1", "SELECT * FROM refunds WHERE id = 2", "UPDATE charges SET status = 'paid'"} {
+		addToAlertBatch(&SlowQueryEntry{
+			Hash: uint64(100 + i), Fingerprint: normalizeQuery(sql), SQL: sql,
+			QueryTime: float64(i + 1), Database: "payments", User: "app", Host: "10.0.0.1",
+		})
+	}
+	addToAlertBatch(&SlowQueryEntry{Hash: 200, Fingerprint: "select ?", SQL: "SELECT 1", QueryTime: 1, Database: "analytics"})
+
+	if !server.WaitForN(2, 5*time.Second) {
+		t.Fatal("窗口结束后应发送合并告警")
+	}
+	time.Sleep(50 * time.Millisecond)
+	received := server.Received()
+	if len(received) != 2 {
+		t.Fatalf("期望每个数据库各收到 1 条告警，实际收到 %d 条", len(received))
+	}
+	var batched string
+	for _, r := range received {
+		if strings.Contains(string(r.Raw), "payments") {
+			batched = string(r.Raw)
+		}
+	}
+	for _, want := range []string{"数据库 `payments` 在最近 100ms 内有 3 条慢查询", "SELECT * FROM refunds WHERE id = 2", "3.00 秒"} {
+		if !strings.Contains(batched, want) {
+			t.Errorf("合并告警中缺少 %q: %s", want, batched)
+		}
+	}
+
+	batchGroupBy = "table"
+	if err := configureBatchGroupBy(); err == nil {
+		t.Error("无效的 --batchGroupBy 应返回错误")
+	}
+}
+
+func TestBatchNotificationSlowestDetails(t *testing.T) {
+	prevGroupBy := batchGroupBy
+	t.Cleanup(func() { batchGroupBy = prevGroupBy })
+	batchGroupBy = "database"
+
+	slowest := &SlowQueryEntry{
+		Hash: 1, SQL: "SELECT * FROM charges", QueryTime: 9, Database: "payments",
+		ReplicationLag: 45 * time.Second, InnoDBRecLockWaits: 3,
+		PlanChange:  &planChange{Baseline: queryPlan{{Table: "charges", Type: "ref", Rows: 10}}, Current: queryPlan{{Table: "charges", Type: "ALL", Rows: 50000}}},
+		ExtraFields: map[string]string{"service": "billing"},
+	}
+	b := &alertBatch{value: "payments", first: time.Now(), entries: []*SlowQueryEntry{{Hash: 2, SQL: "SELECT 1", QueryTime: 1, Database: "payments"}, slowest}}
+	n := buildBatchNotification(b, slowest)
+	if !slices.Contains(n.Notes, replicationLagNote) {
+		t.Errorf("合并告警中缺少复制延迟提示: %v", n.Notes)
+	}
+	if len(n.Plan) == 0 {
+		t.Error("合并告警中缺少执行计划变化")
+	}
+	if len(n.Tables) != 2 || n.Tables[1].Title != lockWaitTitle {
+		t.Errorf("合并告警中缺少行锁等待表: %+v", n.Tables)
+	}
+	for _, label := range []string{"复制延迟", "service"} {
+		if !slices.ContainsFunc(n.Fields, func(f notificationField) bool { return f.Label == label }) {
+			t.Errorf("合并告警中缺少字段 %s: %+v", label, n.Fields)
+		}
+	}
+}
+
+func TestAlertBatchUsesSingleAlertPath(t *testing.T) {
+	server := testutil.NewMockWebhookServer(t)
+	oncall := testutil.NewMockWebhookServer(t)
+	prevGroupBy, prevInterval, prevTargets, prevCritical, prevAck, prevLimit, prevMinRows := batchGroupBy, batchInterval, webhookDestinations, criticalThreshold, ackCallbackURL, alertOnMissingLimit, missingLimitMinRows
+	prevTarget, prevAfter := escalationTarget, escalationAfter
+	t.Cleanup(func() {
+		batchGroupBy, batchInterval, webhookDestinations, criticalThreshold, ackCallbackURL, alertOnMissingLimit, missingLimitMinRows = prevGroupBy, prevInterval, prevTargets, prevCritical, prevAck, prevLimit, prevMinRows
+		escalationTarget, escalationAfter = prevTarget, prevAfter
+	})
+	batchGroupBy, batchInterval = "database", time.Hour
+	webhookDestinations = []webhookTarget{{URL: server.WeChatURL(), Timeout: 5 * time.Second}}
+	criticalThreshold, ackCallbackURL, alertOnMissingLimit, missingLimitMinRows = 10, "https://alerts.example.com", true, 100
+	escalationTarget, escalationAfter = &webhookTarget{URL: oncall.WeChatURL(), Timeout: 5 * time.Second}, 0
+
+	slowest := &SlowQueryEntry{Hash: 301, SQL: "SELECT * FROM refunds", QueryTime: 12, RowsSent: 5000, Database: "billing"}
+	b := &alertBatch{value: "billing", first: time.Now(), entries: []*SlowQueryEntry{{Hash: 302, SQL: "SELECT 1", QueryTime: 1, Database: "billing"}, slowest}}
+	n := buildBatchNotification(b, slowest)
+	if n.Severity != "critical" || !slices.Contains(n.Notes, missingLimitNote) {
+		t.Errorf("合并告警应包含单条告警的级别与提示: %+v", n)
+	}
+	if !slices.ContainsFunc(n.Fields, func(f notificationField) bool { return f.Label == "确认告警" }) {
+		t.Errorf("合并告警中缺少确认链接: %+v", n.Fields)
+	}
+
+	// 合并告警与单条告警一样按告警次数升级
+	for _, entry := range b.entries {
+		addToAlertBatch(entry)
+	}
+	flushAllAlertBatches()
+	if !server.WaitForN(1, 5*time.Second) || !oncall.WaitForN(1, 5*time.Second) {
+		t.Fatal("合并告警应发送到推送地址并升级")
+	}
+	if body := string(oncall.Received()[0].Raw); !strings.Contains(body, "[ESCALATED] 数据库 `billing`") {
+		t.Errorf("升级告警: %s", body)
+	}
+}
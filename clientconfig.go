@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// --webhookClientConfig 文件中的HTTP客户端配置，未设置的字段使用默认值
+// 请求超时始终由 --webhookTimeout 与地址单独配置的超时决定；
+// 每个地址的空闲连接数由 --webhookConcurrency 决定；
+// --webhookTLSSkipVerify 与 --webhookCACert 和这里的TLS配置同时生效
+type WebhookClientConfig struct {
+	MaxIdleConns    int      // 所有地址的最大空闲连接数，默认 100
+	MaxConnsPerHost int      // 每个地址的最大连接数，0 表示不限制
+	IdleConnTimeout string   // 空闲连接的保留时间，例如 90s
+	TLSMinVersion   string   // 最低TLS版本：1.0、1.1、1.2、1.3
+	TLSMaxVersion   string   // 最高TLS版本
+	TLSCipherSuites []string // 允许的加密套件名称，例如 TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256，只对 TLS 1.2 及以下版本生效
+
+	idleConnTimeout time.Duration
+	tlsMinVersion   uint16
+	tlsMaxVersion   uint16
+	cipherSuites    []uint16
+}
+
+// 通过 --webhookClientConfig 加载的配置，nil 表示使用默认值
+var webhookClientSettings *WebhookClientConfig
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// 解析TLS版本，接受 1.2 与 TLS1.2 两种写法
+func parseTLSVersion(value string) (uint16, error) {
+	name := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(value)), "TLS")
+	if version, ok := tlsVersions[strings.TrimSpace(name)]; ok {
+		return version, nil
+	}
+	return 0, fmt.Errorf("未知的TLS版本 %q，可选值: 1.0, 1.1, 1.2, 1.3", value)
+}
+
+// 按名称查找加密套件，包括不安全的套件
+func lookupCipherSuite(name string) (uint16, bool) {
+	for _, suites := range [][]*tls.CipherSuite{tls.CipherSuites(), tls.InsecureCipherSuites()} {
+		for _, suite := range suites {
+			if suite.Name == name {
+				return suite.ID, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// 读取并校验 --webhookClientConfig 文件，未知的字段与加密套件都视为错误
+func loadWebhookClientConfig(path string) (*WebhookClientConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取Webhook客户端配置文件 %s: %w", path, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	cfg := &WebhookClientConfig{}
+	if err := decoder.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("Webhook客户端配置文件 %s 格式错误: %w", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("Webhook客户端配置文件 %s 无效: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// 校验配置并解析时间、TLS版本与加密套件
+func (c *WebhookClientConfig) validate() error {
+	if c.MaxIdleConns < 0 {
+		return fmt.Errorf("MaxIdleConns 不能为负数: %d", c.MaxIdleConns)
+	}
+	if c.MaxConnsPerHost < 0 {
+		return fmt.Errorf("MaxConnsPerHost 不能为负数: %d", c.MaxConnsPerHost)
+	}
+	if c.IdleConnTimeout != "" {
+		d, err := time.ParseDuration(c.IdleConnTimeout)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("IdleConnTimeout 无效: %q", c.IdleConnTimeout)
+		}
+		c.idleConnTimeout = d
+	}
+	var err error
+	if c.TLSMinVersion != "" {
+		if c.tlsMinVersion, err = parseTLSVersion(c.TLSMinVersion); err != nil {
+			return fmt.Errorf("TLSMinVersion: %w", err)
+		}
+	}
+	if c.TLSMaxVersion != "" {
+		if c.tlsMaxVersion, err = parseTLSVersion(c.TLSMaxVersion); err != nil {
+			return fmt.Errorf("TLSMaxVersion: %w", err)
+		}
+	}
+	if c.tlsMinVersion != 0 && c.tlsMaxVersion != 0 && c.tlsMinVersion > c.tlsMaxVersion {
+		return fmt.Errorf("TLSMinVersion %s 高于 TLSMaxVersion %s", c.TLSMinVersion, c.TLSMaxVersion)
+	}
+	c.cipherSuites = nil
+	for _, name := range c.TLSCipherSuites {
+		id, ok := lookupCipherSuite(strings.TrimSpace(name))
+		if !ok {
+			return fmt.Errorf("未知的加密套件 %q", name)
+		}
+		c.cipherSuites = append(c.cipherSuites, id)
+	}
+	return nil
+}
+
+// 把配置应用到Webhook客户端的 http.Transport
+func (c *WebhookClientConfig) apply(transport *http.Transport) {
+	if c.MaxIdleConns > 0 {
+		transport.MaxIdleConns = c.MaxIdleConns
+	}
+	transport.MaxConnsPerHost = c.MaxConnsPerHost
+	if c.idleConnTimeout > 0 {
+		transport.IdleConnTimeout = c.idleConnTimeout
+	}
+	if c.tlsMinVersion == 0 && c.tlsMaxVersion == 0 && len(c.cipherSuites) == 0 {
+		return
+	}
+	tlsConfig := &tls.Config{}
+	if transport.TLSClientConfig != nil {
+		tlsConfig = transport.TLSClientConfig.Clone()
+	}
+	tlsConfig.MinVersion = c.tlsMinVersion
+	tlsConfig.MaxVersion = c.tlsMaxVersion
+	tlsConfig.CipherSuites = c.cipherSuites
+	transport.TLSClientConfig = tlsConfig
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeClientConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "client.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadWebhookClientConfig(t *testing.T) {
+	cfg, err := loadWebhookClientConfig(writeClientConfig(t, `{
+  "MaxIdleConns": 20,
+  "MaxConnsPerHost": 4,
+  "IdleConnTimeout": "30s",
+  "TLSMinVersion": "1.2",
+  "TLSMaxVersion": "TLS1.3",
+  "TLSCipherSuites": ["TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transport := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	cfg.apply(transport)
+	if transport.MaxIdleConns != 20 || transport.MaxConnsPerHost != 4 || transport.IdleConnTimeout != 30*time.Second {
+		t.Errorf("连接配置 = %d, %d, %s", transport.MaxIdleConns, transport.MaxConnsPerHost, transport.IdleConnTimeout)
+	}
+	tlsConfig := transport.TLSClientConfig
+	if tlsConfig.MinVersion != tls.VersionTLS12 || tlsConfig.MaxVersion != tls.VersionTLS13 {
+		t.Errorf("TLS版本 = %x ~ %x", tlsConfig.MinVersion, tlsConfig.MaxVersion)
+	}
+	if len(tlsConfig.CipherSuites) != 1 || tlsConfig.CipherSuites[0] != tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 {
+		t.Errorf("CipherSuites = %v", tlsConfig.CipherSuites)
+	}
+	if !tlsConfig.InsecureSkipVerify {
+		t.Errorf("应保留 --webhookTLSSkipVerify 的设置")
+	}
+}
+
+func TestLoadWebhookClientConfigInvalid(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{`{"TLSCipherSuites": ["TLS_NO_SUCH_SUITE"]}`, "未知的加密套件"},
+		{`{"TLSMinVersion": "1.4"}`, "未知的TLS版本"},
+		{`{"TLSMinVersion": "1.3", "TLSMaxVersion": "1.2"}`, "高于"},
+		{`{"IdleConnTimeout": "soon"}`, "IdleConnTimeout"},
+		{`{"MaxConnsPerHost": -1}`, "MaxConnsPerHost"},
+		{`{"Timeout": "5s"}`, "unknown field"},
+	}
+	for _, tt := range tests {
+		_, err := loadWebhookClientConfig(writeClientConfig(t, tt.content))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want %q", tt.content, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// 程序名称，用于生成补全脚本
+const programName = "mysql-slow-sql-webhook"
+
+// 支持的子命令，各子命令在所在文件的 init 中注册
+var subcommands = map[string]func(args []string) error{}
+
+func init() {
+	subcommands["completion"] = runCompletion
+}
+
+// 执行子命令，name 不是子命令时返回 false
+func runSubcommand(name string, args []string) bool {
+	run, ok := subcommands[name]
+	if !ok {
+		return false
+	}
+	if err := run(args); err != nil {
+		logf(levelError, "%v", err)
+		os.Exit(1)
+	}
+	return true
+}
+
+// 参数值的固定候选项
+var flagValueCompletions = map[string][]string{
+	"logLevel":              {"debug", "info", "warn", "error"},
+	"webhookFormat":         webhookFormatNames(),
+	"webhookMethod":         webhookMethods,
+	"fallbackWebhookFormat": webhookFormatNames(),
+	"inputFormat":           {"mysql", "mongodb", "pmm"},
+	"batchGroupBy":          batchGroupByKeys,
+	"mysqlVersion":          mysqlVersionNames,
+}
+
+// 参数值为文件路径的参数
+var fileFlags = map[string]bool{
+	"slowLogFile":         true,
+	"databaseThresholds":  true,
+	"pidFile":             true,
+	"webhookCACert":       true,
+	"stateFile":           true,
+	"sshKeyFile":          true,
+	"sshAgentSocket":      true,
+	"sshKnownHosts":       true,
+	"baselineDB":          true,
+	"historyDB":           true,
+	"deadLetterFile":      true,
+	"webhookTemplate":     true,
+	"webhookClientConfig": true,
+	"worstQueryFile":      true,
+	"instances":           true,
+	"auditLog":            true,
+	"pmmSocket":           true,
+	"ptDigestOutput":      true,
+	"migrationFlagFile":   true,
+	"userWebhooks":        true,
+	"databaseWebhooks":    true,
+}
+
+// 参数值为目录的参数
+var dirFlags = map[string]bool{
+	"alertTemplateDir": true,
+	"configDir":        true,
+}
+
+// 补全脚本需要的参数信息
+type completionFlag struct {
+	Name      string
+	Shorthand string
+	Usage     string
+	TakesArg  bool
+	Values    []string
+	File      bool
+	Dir       bool
+}
+
+// 收集已注册的参数
+func completionFlags() []completionFlag {
+	var flags []completionFlag
+	pflag.CommandLine.VisitAll(func(f *pflag.Flag) {
+		flags = append(flags, completionFlag{
+			Name:      f.Name,
+			Shorthand: f.Shorthand,
+			Usage:     strings.SplitN(f.Usage, "\n", 2)[0],
+			TakesArg:  f.Value.Type() != "bool",
+			Values:    flagValueCompletions[f.Name],
+			File:      fileFlags[f.Name],
+			Dir:       dirFlags[f.Name],
+		})
+	})
+	return flags
+}
+
+// 子命令名称列表
+func subcommandNames() []string {
+	names := make([]string, 0, len(subcommands))
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// completion 子命令：输出指定 shell 的补全脚本
+func runCompletion(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("用法: %s completion bash|zsh|fish|powershell", programName)
+	}
+	flags := completionFlags()
+	switch args[0] {
+	case "bash":
+		writeBashCompletion(os.Stdout, flags)
+	case "zsh":
+		writeZshCompletion(os.Stdout, flags)
+	case "fish":
+		writeFishCompletion(os.Stdout, flags)
+	case "powershell":
+		writePowerShellCompletion(os.Stdout, flags)
+	default:
+		return fmt.Errorf("不支持的 shell: %s，可选值: bash, zsh, fish, powershell", args[0])
+	}
+	return nil
+}
+
+func writeBashCompletion(w io.Writer, flags []completionFlag) {
+	fmt.Fprintf(w, "# %s bash 补全脚本\n", programName)
+	fmt.Fprintf(w, "# 安装: %s completion bash > /etc/bash_completion.d/%s\n", programName, programName)
+	fmt.Fprintf(w, "# 或在 ~/.bashrc 中加入: source <(%s completion bash)\n\n", programName)
+
+	var words, fileNames, dirNames []string
+	valueCases := map[string][]string{}
+	for _, f := range flags {
+		names := []string{"--" + f.Name}
+		if f.Shorthand != "" {
+			names = append(names, "-"+f.Shorthand)
+		}
+		words = append(words, names...)
+		switch {
+		case len(f.Values) > 0:
+			valueCases[strings.Join(names, "|")] = f.Values
+		case f.File:
+			fileNames = append(fileNames, names...)
+		case f.Dir:
+			dirNames = append(dirNames, names...)
+		}
+	}
+
+	fmt.Fprintln(w, "_mysql_slow_sql_webhook() {")
+	fmt.Fprintln(w, `    local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"`)
+	fmt.Fprintln(w, `    case "$prev" in`)
+	cases := make([]string, 0, len(valueCases))
+	for pattern := range valueCases {
+		cases = append(cases, pattern)
+	}
+	sort.Strings(cases)
+	for _, pattern := range cases {
+		fmt.Fprintf(w, "        %s) COMPREPLY=( $(compgen -W %q -- \"$cur\") ); return ;;\n", pattern, strings.Join(valueCases[pattern], " "))
+	}
+	if len(fileNames) > 0 {
+		fmt.Fprintf(w, "        %s) COMPREPLY=( $(compgen -f -- \"$cur\") ); return ;;\n", strings.Join(fileNames, "|"))
+	}
+	if len(dirNames) > 0 {
+		fmt.Fprintf(w, "        %s) COMPREPLY=( $(compgen -d -- \"$cur\") ); return ;;\n", strings.Join(dirNames, "|"))
+	}
+	fmt.Fprintln(w, "    esac")
+	fmt.Fprintln(w, `    if [[ $COMP_CWORD -eq 1 && "$cur" != -* ]]; then`)
+	fmt.Fprintf(w, "        COMPREPLY=( $(compgen -W %q -- \"$cur\") ); return\n", strings.Join(subcommandNames(), " "))
+	fmt.Fprintln(w, "    fi")
+	fmt.Fprintf(w, "    COMPREPLY=( $(compgen -W %q -- \"$cur\") )\n", strings.Join(words, " "))
+	fmt.Fprintln(w, "}")
+	fmt.Fprintf(w, "complete -F _mysql_slow_sql_webhook %s\n", programName)
+}
+
+// 转义 zsh _arguments 描述中的特殊字符
+func zshEscape(s string) string {
+	return strings.NewReplacer("'", `'\''`, "[", `\[`, "]", `\]`, ":", `\:`).Replace(s)
+}
+
+func writeZshCompletion(w io.Writer, flags []completionFlag) {
+	fmt.Fprintf(w, "#compdef %s\n", programName)
+	fmt.Fprintf(w, "# %s zsh 补全脚本\n", programName)
+	fmt.Fprintf(w, "# 安装: %s completion zsh > \"${fpath[1]}/_%s\"，然后重新打开终端\n\n", programName, programName)
+	fmt.Fprintln(w, "_arguments \\")
+	for _, f := range flags {
+		action := ""
+		switch {
+		case len(f.Values) > 0:
+			action = fmt.Sprintf(":%s:(%s)", f.Name, strings.Join(f.Values, " "))
+		case f.File:
+			action = fmt.Sprintf(":%s:_files", f.Name)
+		case f.Dir:
+			action = fmt.Sprintf(":%s:_files -/", f.Name)
+		case f.TakesArg:
+			action = fmt.Sprintf(":%s: ", f.Name)
+		}
+		spec := fmt.Sprintf("--%s[%s]%s", f.Name, zshEscape(f.Usage), action)
+		if f.Shorthand != "" {
+			fmt.Fprintf(w, "  '(-%s --%s)'{-%s,--%s}'[%s]%s' \\\n", f.Shorthand, f.Name, f.Shorthand, f.Name, zshEscape(f.Usage), action)
+			continue
+		}
+		fmt.Fprintf(w, "  '%s' \\\n", spec)
+	}
+	fmt.Fprintf(w, "  '1::子命令:(%s)'\n", strings.Join(subcommandNames(), " "))
+}
+
+// 转义 fish 单引号字符串
+func fishEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s)
+}
+
+func writeFishCompletion(w io.Writer, flags []completionFlag) {
+	fmt.Fprintf(w, "# %s fish 补全脚本\n", programName)
+	fmt.Fprintf(w, "# 安装: %s completion fish > ~/.config/fish/completions/%s.fish\n\n", programName, programName)
+	fmt.Fprintf(w, "complete -c %s -n '__fish_use_subcommand' -x -a '%s'\n", programName, strings.Join(subcommandNames(), " "))
+	for _, f := range flags {
+		line := fmt.Sprintf("complete -c %s -l %s", programName, f.Name)
+		if f.Shorthand != "" {
+			line += " -s " + f.Shorthand
+		}
+		line += fmt.Sprintf(" -d '%s'", fishEscape(f.Usage))
+		switch {
+		case len(f.Values) > 0:
+			line += fmt.Sprintf(" -x -a '%s'", strings.Join(f.Values, " "))
+		case f.File:
+			line += " -r -F"
+		case f.Dir:
+			line += " -x -a '(__fish_complete_directories)'"
+		case f.TakesArg:
+			line += " -x"
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+func writePowerShellCompletion(w io.Writer, flags []completionFlag) {
+	fmt.Fprintf(w, "# %s PowerShell 补全脚本\n", programName)
+	fmt.Fprintf(w, "# 安装: %s completion powershell | Out-String | Invoke-Expression\n", programName)
+	fmt.Fprintf(w, "# 或将输出追加到 $PROFILE 中\n\n")
+	fmt.Fprintf(w, "Register-ArgumentCompleter -Native -CommandName '%s' -ScriptBlock {\n", programName)
+	fmt.Fprintln(w, "    param($wordToComplete, $commandAst, $cursorPosition)")
+	fmt.Fprintln(w, "    $values = @{")
+	for _, f := range flags {
+		if len(f.Values) > 0 {
+			fmt.Fprintf(w, "        '--%s' = @(%s)\n", f.Name, "'"+strings.Join(f.Values, "', '")+"'")
+		}
+	}
+	fmt.Fprintln(w, "    }")
+	var files, dirs []string
+	var names []string
+	for _, f := range flags {
+		names = append(names, "'--"+f.Name+"'")
+		if f.Shorthand != "" {
+			names = append(names, "'-"+f.Shorthand+"'")
+		}
+		if f.File {
+			files = append(files, "'--"+f.Name+"'")
+			if f.Shorthand != "" {
+				files = append(files, "'-"+f.Shorthand+"'")
+			}
+		}
+		if f.Dir {
+			dirs = append(dirs, "'--"+f.Name+"'")
+		}
+	}
+	fmt.Fprintf(w, "    $files = @(%s)\n", strings.Join(files, ", "))
+	fmt.Fprintf(w, "    $dirs = @(%s)\n", strings.Join(dirs, ", "))
+	fmt.Fprintf(w, "    $flags = @(%s)\n", strings.Join(names, ", "))
+	fmt.Fprintf(w, "    $subcommands = @(%s)\n", "'"+strings.Join(subcommandNames(), "', '")+"'")
+	fmt.Fprint(w, `    $elements = $commandAst.CommandElements | ForEach-Object { $_.ToString() }
+    $prev = if ($wordToComplete) { $elements[-2] } else { $elements[-1] }
+    if ($values.ContainsKey($prev)) {
+        $candidates = $values[$prev]
+    } elseif ($files -contains $prev) {
+        $candidates = Get-ChildItem -Path "$wordToComplete*" -Name
+    } elseif ($dirs -contains $prev) {
+        $candidates = Get-ChildItem -Path "$wordToComplete*" -Directory -Name
+    } elseif ($elements.Count -le 2 -and -not $wordToComplete.StartsWith('-')) {
+        $candidates = $subcommands
+    } else {
+        $candidates = $flags
+    }
+    $candidates | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+    }
+}
+`)
+}
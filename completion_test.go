@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestCompletionScripts(t *testing.T) {
+	prevFlags := pflag.CommandLine
+	t.Cleanup(func() { pflag.CommandLine = prevFlags })
+	pflag.CommandLine = pflag.NewFlagSet("test", pflag.ContinueOnError)
+	pflag.StringP("slowLogFile", "f", "", "MySQL慢查询日志文件路径")
+	pflag.String("webhookFormat", "wechat", "消息格式")
+	pflag.String("ptDigestOutput", "", "pt-query-digest 报告文件")
+	pflag.String("configDir", "", "配置目录")
+	pflag.Bool("readHistory", false, "从头读取日志")
+	flags := completionFlags()
+
+	formats := strings.Join(webhookFormatNames(), " ")
+	for _, tt := range []struct {
+		shell string
+		write func(w *strings.Builder)
+		want  []string
+	}{
+		{"bash", func(w *strings.Builder) { writeBashCompletion(w, flags) }, []string{
+			`--webhookFormat) COMPREPLY=( $(compgen -W "` + formats + `"`,
+			`--ptDigestOutput|--slowLogFile|-f) COMPREPLY=( $(compgen -f`,
+			`--configDir) COMPREPLY=( $(compgen -d`,
+			"complete -F _mysql_slow_sql_webhook " + programName,
+		}},
+		{"zsh", func(w *strings.Builder) { writeZshCompletion(w, flags) }, []string{
+			"--webhookFormat[消息格式]:webhookFormat:(" + formats + ")",
+			"'(-f --slowLogFile)'{-f,--slowLogFile}'[MySQL慢查询日志文件路径]:slowLogFile:_files'",
+			"--configDir[配置目录]:configDir:_files -/",
+			"'--readHistory[从头读取日志]'",
+		}},
+		{"fish", func(w *strings.Builder) { writeFishCompletion(w, flags) }, []string{
+			"-l webhookFormat -d '消息格式' -x -a '" + formats + "'",
+			"-l slowLogFile -s f -d 'MySQL慢查询日志文件路径' -r -F",
+			"-l configDir -d '配置目录' -x -a '(__fish_complete_directories)'",
+		}},
+		{"powershell", func(w *strings.Builder) { writePowerShellCompletion(w, flags) }, []string{
+			"'--webhookFormat' = @('" + strings.Join(webhookFormatNames(), "', '") + "')",
+			"$files = @('--ptDigestOutput', '--slowLogFile', '-f')",
+			"$dirs = @('--configDir')",
+		}},
+	} {
+		t.Run(tt.shell, func(t *testing.T) {
+			var w strings.Builder
+			tt.write(&w)
+			for _, want := range tt.want {
+				if !strings.Contains(w.String(), want) {
+					t.Errorf("补全脚本中缺少 %q:\n%s", want, w.String())
+				}
+			}
+		})
+	}
+
+	if err := runCompletion([]string{"tcsh"}); err == nil {
+		t.Error("不支持的 shell 应返回错误")
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// 运行中可以重新加载的配置项，其它配置项修改后需要重启才能生效
+var reloadableConfigKeys = map[string]bool{
+	"databaseThresholds": true,
+	"logLevel":           true,
+}
+
+// 配置目录更新后等待的时间，Kubernetes 更新 ConfigMap 时会连续产生多个文件事件
+const configReloadDelay = 500 * time.Millisecond
+
+// 命令行中显式指定的参数，配置文件中的同名配置不覆盖这些参数
+var commandLineFlags = make(map[string]bool)
+
+// 上一次从配置目录加载的配置，用于判断哪些配置项发生了变化
+var loadedConfig map[string]string
+
+// 串行化 SIGHUP 与配置目录触发的重新加载
+var reloadMu sync.Mutex
+
+// 记录命令行中显式指定的参数，需在 pflag.Parse 之后、加载配置目录之前调用
+func recordCommandLineFlags() {
+	pflag.Visit(func(f *pflag.Flag) {
+		commandLineFlags[f.Name] = true
+	})
+}
+
+// 读取目录中的所有 *.yaml 与 *.toml 文件，按文件名顺序合并，后面的文件覆盖前面的同名配置
+func loadConfigDir(dir string) (map[string]string, error) {
+	var paths []string
+	for _, pattern := range []string{"*.yaml", "*.toml"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+	sort.Strings(paths)
+
+	merged := make(map[string]string)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("无法读取配置文件 %s: %w", path, err)
+		}
+		var values map[string]any
+		if filepath.Ext(path) == ".toml" {
+			err = toml.Unmarshal(data, &values)
+		} else {
+			err = yaml.Unmarshal(data, &values)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("配置文件 %s 格式错误: %w", path, err)
+		}
+		for name, value := range values {
+			if name == configVersionKey {
+				// YAML 中的整数解析为 int，TOML 中的整数解析为 int64
+				if v, ok := value.(int64); ok {
+					value = int(v)
+				}
+				if version, ok := value.(int); !ok || version < 1 || version > configFormatVersion {
+					return nil, fmt.Errorf("配置文件 %s 的 %s 为 %v，当前版本只支持 1 ~ %d", path, configVersionKey, value, configFormatVersion)
+				}
+				continue
+			}
+			if pflag.Lookup(name) == nil || name == "configDir" {
+				return nil, fmt.Errorf("配置文件 %s 中的配置项 %q 无效", path, name)
+			}
+			s, err := configValueString(value)
+			if err != nil {
+				return nil, fmt.Errorf("配置文件 %s 中的配置项 %q 无效: %w", path, name, err)
+			}
+			merged[name] = s
+		}
+	}
+	return merged, nil
+}
+
+// 把 YAML / TOML 中的值转换为命令行参数的字符串形式：列表以逗号连接，对象及对象的列表转换为JSON（例如 databaseThresholds、instances）
+func configValueString(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []any:
+		for _, item := range v {
+			if _, ok := item.(map[string]any); ok {
+				data, err := json.Marshal(v)
+				return string(data), err
+			}
+		}
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := configValueString(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	case map[string]any:
+		data, err := json.Marshal(v)
+		return string(data), err
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// 把配置应用到对应的命令行参数，命令行中显式指定的参数优先
+func applyConfigValue(name, value string) error {
+	if commandLineFlags[name] {
+		return nil
+	}
+	f := pflag.Lookup(name)
+	if slice, ok := f.Value.(pflag.SliceValue); ok {
+		var items []string
+		if value != "" {
+			items = strings.Split(value, ",")
+		}
+		return slice.Replace(items)
+	}
+	return f.Value.Set(value)
+}
+
+// 启动时加载配置目录
+func applyConfigDir(dir string) error {
+	values, err := loadConfigDir(dir)
+	if err != nil {
+		return err
+	}
+	for name, value := range values {
+		if err := applyConfigValue(name, value); err != nil {
+			return fmt.Errorf("配置项 %s 无效: %w", name, err)
+		}
+	}
+	loadedConfig = values
+	return nil
+}
+
+// 重新加载可热更新的配置：配置目录中的日志级别与数据库阈值，以及数据库阈值文件
+func reloadConfig() {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	if configDir != "" {
+		if err := reloadConfigDir(configDir); err != nil {
+			logf(levelError, "重新加载配置目录失败，继续使用原配置: %v", err)
+			return
+		}
+	}
+	if err := reloadDatabaseThresholds(); err != nil {
+		logf(levelError, "重新加载数据库阈值配置失败，继续使用原配置: %v", err)
+		return
+	}
+	logf(levelInfo, "已重新加载数据库阈值配置")
+}
+
+// 重新读取配置目录，只应用可热更新的配置项
+func reloadConfigDir(dir string) error {
+	values, err := loadConfigDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var restartRequired []string
+	for name, value := range values {
+		if value == loadedConfig[name] || commandLineFlags[name] {
+			continue
+		}
+		if !reloadableConfigKeys[name] {
+			restartRequired = append(restartRequired, name)
+			continue
+		}
+		if name == "logLevel" {
+			level, err := parseLogLevel(value)
+			if err != nil {
+				return err
+			}
+			setLogLevel(level)
+		}
+		if err := applyConfigValue(name, value); err != nil {
+			return fmt.Errorf("配置项 %s 无效: %w", name, err)
+		}
+		logf(levelInfo, "配置项 %s 已更新", name)
+	}
+	if len(restartRequired) > 0 {
+		sort.Strings(restartRequired)
+		logf(levelWarn, "以下配置项已修改，需要重启后生效: %s", strings.Join(restartRequired, ", "))
+	}
+	loadedConfig = values
+	return nil
+}
+
+// 监听配置目录，文件变化后重新加载配置
+// Kubernetes 通过替换 ..data 符号链接更新 ConfigMap，因此监听整个目录而不是单个文件
+func watchConfigDir(dir string) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logf(levelError, "无法监听配置目录 %s: %v", dir, err)
+		return
+	}
+	defer watcher.Close()
+	if err := watcher.Add(dir); err != nil {
+		logf(levelError, "无法监听配置目录 %s: %v", dir, err)
+		return
+	}
+
+	var timer *time.Timer
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			logf(levelDebug, "配置目录事件: %s", event)
+			if timer == nil {
+				timer = time.AfterFunc(configReloadDelay, func() {
+					logf(levelInfo, "ConfigMap 已更新，重新加载配置")
+					reloadConfig()
+				})
+			} else {
+				timer.Reset(configReloadDelay)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			logf(levelWarn, "监听配置目录出错: %v", err)
+		}
+	}
+}
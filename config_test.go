@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestReloadConfigDir(t *testing.T) {
+	oldFlags, oldCommandLine, oldLoaded := pflag.CommandLine, commandLineFlags, loadedConfig
+	oldThreshold, oldSpec, oldTable, oldLogLevel := slowQueryThreshold, databaseThresholds, databaseThresholdTable.Load(), logLevel(currentLogLevel.Load())
+	oldLogLevelName, oldConfigDir := logLevelName, configDir
+	t.Cleanup(func() {
+		pflag.CommandLine, commandLineFlags, loadedConfig = oldFlags, oldCommandLine, oldLoaded
+		slowQueryThreshold, databaseThresholds, logLevelName, configDir = oldThreshold, oldSpec, oldLogLevelName, oldConfigDir
+		databaseThresholdTable.Store(oldTable)
+		setLogLevel(oldLogLevel)
+	})
+	pflag.CommandLine = pflag.NewFlagSet("test", pflag.ContinueOnError)
+	pflag.Float64Var(&slowQueryThreshold, "slowQueryThreshold", 0, "慢查询阈值")
+	pflag.StringVar(&databaseThresholds, "databaseThresholds", "", "按数据库覆盖的阈值配置")
+	pflag.StringVar(&logLevelName, "logLevel", "info", "日志级别")
+	commandLineFlags = make(map[string]bool)
+
+	configDir = t.TempDir()
+	path := filepath.Join(configDir, "config.yaml")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("slowQueryThreshold: 1\nlogLevel: info\ndatabaseThresholds:\n  analytics:\n    queryTime: 30\n")
+	if err := applyConfigDir(configDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloadDatabaseThresholds(); err != nil {
+		t.Fatal(err)
+	}
+	setLogLevel(levelInfo)
+	if slowQueryThreshold != 1 || thresholdFor("analytics").QueryTime != 30 {
+		t.Fatalf("启动时的配置: slowQueryThreshold = %v, analytics = %v", slowQueryThreshold, thresholdFor("analytics").QueryTime)
+	}
+
+	write("slowQueryThreshold: 5\nlogLevel: debug\ndatabaseThresholds:\n  analytics:\n    queryTime: 60\n")
+	reloadConfig()
+	if got := thresholdFor("analytics").QueryTime; got != 60 {
+		t.Errorf("重新加载后 analytics 的阈值 = %v, want 60", got)
+	}
+	if !logEnabled(levelDebug) {
+		t.Errorf("重新加载后日志级别应为 debug")
+	}
+	if slowQueryThreshold != 1 {
+		t.Errorf("slowQueryThreshold 需要重启后生效，重新加载后 = %v", slowQueryThreshold)
+	}
+
+	// 配置文件格式错误时保留原配置
+	write("databaseThresholds: [\n")
+	reloadConfig()
+	if got := thresholdFor("analytics").QueryTime; got != 60 {
+		t.Errorf("格式错误后 analytics 的阈值 = %v, want 60", got)
+	}
+}
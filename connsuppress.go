@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 连接数过高时的告警暂停状态
+var connSuppression = struct {
+	sync.Mutex
+	active bool
+	since  time.Time
+}{}
+
+// 通过 SHOW STATUS 查询当前连接数
+func queryThreadsConnected(db *sql.DB) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var name string
+	var count int
+	if err := db.QueryRowContext(ctx, "SHOW STATUS LIKE 'Threads_connected'").Scan(&name, &count); err != nil {
+		return 0, fmt.Errorf("无法查询 Threads_connected: %w", err)
+	}
+	return count, nil
+}
+
+// 告警是否因连接数过高而暂停
+func alertsSuppressedByConnCount() bool {
+	connSuppression.Lock()
+	defer connSuppression.Unlock()
+	return connSuppression.active
+}
+
+// 根据最新的连接数更新暂停状态，返回是否刚进入暂停状态
+func updateConnSuppression(count int, now time.Time) bool {
+	connSuppression.Lock()
+	defer connSuppression.Unlock()
+	switch {
+	case count > mysqlConnCountSuppressThreshold && !connSuppression.active:
+		connSuppression.active, connSuppression.since = true, now
+		return true
+	case count <= mysqlConnCountSuppressThreshold && connSuppression.active:
+		logf(levelInfo, "MySQL连接数已降至 %d，恢复发送慢查询告警（已暂停 %s）", count, now.Sub(connSuppression.since).Round(time.Second))
+		connSuppression.active = false
+	}
+	return false
+}
+
+// 生成暂停告警的通知
+func buildConnSuppressionNotification(count int) *notification {
+	return &notification{
+		Title: "⏸️ 连接数过高，暂停慢查询告警 (alert suppression due to high connection count)",
+		Fields: []notificationField{
+			{Label: "当前连接数", Value: fmt.Sprintf("%d", count), Highlight: true},
+			{Label: "暂停阈值", Value: fmt.Sprintf("%d", mysqlConnCountSuppressThreshold)},
+			{Label: "说明", Value: "数据库负载过高时所有查询都会变慢，连接数回落到阈值以下后自动恢复告警"},
+			{Label: "告警时间", Value: formatDisplayTime(time.Now())},
+		},
+		Labels: alertLabels,
+	}
+}
+
+// 发送暂停告警的通知，测试中可以替换为模拟实现
+var connSuppressionNotifier = func(targets []webhookTarget, count int) (int, error) {
+	return deliverNotification(targets, buildConnSuppressionNotification(count))
+}
+
+// 检查一次连接数，进入暂停状态时发送一次通知
+func checkConnCount(db *sql.DB) {
+	count, err := queryThreadsConnected(db)
+	if err != nil {
+		logf(levelWarn, "%v", err)
+		return
+	}
+	logf(levelDebug, "MySQL连接数: %d", count)
+	if updateConnSuppression(count, time.Now()) {
+		logf(levelWarn, "MySQL连接数 %d 超过 --mysqlConnCountSuppressThreshold=%d，暂停发送慢查询告警", count, mysqlConnCountSuppressThreshold)
+		connSuppressionNotifier(webhookDestinations, count)
+	}
+}
+
+// 连接 --mysqlDSN 并在后台定期检查连接数
+func startConnCountMonitor() error {
+	if mysqlDSN == "" {
+		return errors.New("使用 --mysqlConnCountSuppressThreshold 时必须指定 --mysqlDSN")
+	}
+	db, err := sql.Open("mysql", mysqlDSN)
+	if err != nil {
+		return fmt.Errorf("--mysqlDSN 无效: %w", err)
+	}
+	registerShutdownHook(func() { db.Close() })
+	go monitorConnCount(db, connCountCheckInterval)
+	return nil
+}
+
+// 按 --mysqlConnCountCheckInterval 定期检查连接数
+func monitorConnCount(db *sql.DB, interval time.Duration) {
+	checkConnCount(db)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		checkConnCount(db)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConnCountSuppression(t *testing.T) {
+	var alerted int
+	prevNotifier, prevThreshold := alertNotifier, mysqlConnCountSuppressThreshold
+	t.Cleanup(func() {
+		alertNotifier, mysqlConnCountSuppressThreshold = prevNotifier, prevThreshold
+		connSuppression.active = false
+	})
+	alertNotifier = func(targets []webhookTarget, entry *SlowQueryEntry) (int, error) {
+		alerted++
+		return 1, nil
+	}
+	mysqlConnCountSuppressThreshold = 500
+
+	query := fixtureLines(`
+# User@Host: app[app] @ localhost []  Id:    42
+# Query_time: 2.000000  Lock_time: 0.000000 Rows_sent: 1  Rows_examined: 1
+SELECT * FROM orders WHERE id = 1;`)
+
+	now := time.Now()
+	if updateConnSuppression(100, now) {
+		t.Fatal("连接数未超过阈值时不应暂停")
+	}
+	if !updateConnSuppression(800, now) {
+		t.Fatal("连接数超过阈值时应进入暂停状态")
+	}
+	if updateConnSuppression(900, now.Add(time.Minute)) {
+		t.Error("已暂停时不应重复发送暂停通知")
+	}
+	processSlowQuery(query, nil)
+	if alerted != 0 {
+		t.Fatalf("暂停期间不应发送慢查询告警")
+	}
+
+	updateConnSuppression(400, now.Add(2*time.Minute))
+	processSlowQuery(query, nil)
+	if alerted != 1 {
+		t.Errorf("连接数回落后应恢复告警，实际 %d 条", alerted)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// CPU时间占查询时间的比例，接近 1 说明是CPU密集型查询，远小于 1 说明主要在等待IO或锁
+func cpuTimeRatio(entry *SlowQueryEntry) float64 {
+	if entry.QueryTime <= 0 {
+		return 0
+	}
+	return entry.CPUTime / entry.QueryTime
+}
+
+// CPU时间字段，CPU密集型查询时突出显示
+func cpuTimeField(entry *SlowQueryEntry) notificationField {
+	ratio := cpuTimeRatio(entry)
+	return notificationField{
+		Label:     "CPU时间",
+		Value:     fmt.Sprintf("%.2f 秒（占查询时间 %.0f%%）", entry.CPUTime, ratio*100),
+		Highlight: cpuTimeRatioAlert > 0 && ratio > cpuTimeRatioAlert,
+	}
+}
+
+// 生成CPU密集型查询告警，与慢查询告警区分
+func buildCPUTimeNotification(entry *SlowQueryEntry) *notification {
+	n := buildSlowQueryNotification(entry)
+	n.Title = "🔥 CPU密集型查询 (CPU-bound query)"
+	return n
+}
+
+// 发送CPU密集型查询告警，测试中可以替换为模拟实现
+var cpuTimeNotifier = func(targets []webhookTarget, entry *SlowQueryEntry) (int, error) {
+	return deliverNotification(targets, buildCPUTimeNotification(entry))
+}
+
+// cpu_time 与 query_time 之比超过 --cpuTimeRatioAlert 且查询时间超过 --cpuTimeMinQueryTime 时告警，
+// 与阈值告警分别计算冷却时间
+func checkCPUTime(entry *SlowQueryEntry) {
+	if entry.CPUTime <= 0 || entry.QueryTime <= cpuTimeMinQueryTime || cpuTimeRatio(entry) <= cpuTimeRatioAlert {
+		return
+	}
+	if shouldSuppressByCooldown(computeQueryHash("cputime:"+entry.Fingerprint), time.Now()) {
+		logf(levelDebug, "指纹 %x 的CPU密集型告警处于冷却期内，不发送通知", entry.Hash)
+		return
+	}
+	logf(levelInfo, "检测到CPU密集型查询: 指纹 %x，CPU时间 %.2f 秒，查询时间 %.2f 秒", entry.Hash, entry.CPUTime, entry.QueryTime)
+	cpuTimeNotifier(routeTargets(entry), entry)
+}
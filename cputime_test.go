@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProcessSlowQueryCPUTime(t *testing.T) {
+	var alerts []*SlowQueryEntry
+	prevNotifier, prevAlert := cpuTimeNotifier, alertNotifier
+	prevRatio, prevMin, prevThreshold := cpuTimeRatioAlert, cpuTimeMinQueryTime, slowQueryThreshold
+	t.Cleanup(func() {
+		cpuTimeNotifier, alertNotifier = prevNotifier, prevAlert
+		cpuTimeRatioAlert, cpuTimeMinQueryTime, slowQueryThreshold = prevRatio, prevMin, prevThreshold
+	})
+	cpuTimeNotifier = func(targets []webhookTarget, entry *SlowQueryEntry) (int, error) {
+		alerts = append(alerts, entry)
+		return 1, nil
+	}
+	alertNotifier = func(targets []webhookTarget, entry *SlowQueryEntry) (int, error) { return 1, nil }
+	cpuTimeRatioAlert, cpuTimeMinQueryTime, slowQueryThreshold = 0.9, 1, 10
+
+	entry := func(queryTime, cpuTime string) []string {
+		return fixtureLines(`
+# Time: 2024-03-10T08:15:42.123456Z
+# User@Host: app[app] @ localhost []  Id:    42
+# Query_time: ` + queryTime + `  Lock_time: 0.000000 Rows_sent: 1  Rows_examined: 5000000
+# cpu_time: ` + cpuTime + `  elapsed: ` + queryTime + `  innodb_queue_wait: 0.000100
+SELECT SHA2(payload, 256) FROM events WHERE id > ` + queryTime + `;`)
+	}
+
+	parsed, err := ParseLogLines(entry("3.000000", "2.850000"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.CPUTime != 2.85 || parsed.Elapsed != 3 || parsed.InnoDBQueueWait != 0.0001 {
+		t.Errorf("CPUTime = %v, Elapsed = %v, InnoDBQueueWait = %v", parsed.CPUTime, parsed.Elapsed, parsed.InnoDBQueueWait)
+	}
+
+	processSlowQuery(entry("0.500000", "0.490000"), nil) // 查询时间未超过 --cpuTimeMinQueryTime
+	processSlowQuery(entry("3.000000", "0.300000"), nil) // 主要在等待IO
+	if len(alerts) != 0 {
+		t.Fatalf("不应告警: %+v", alerts)
+	}
+	processSlowQuery(entry("3.000000", "2.850000"), nil)
+	if len(alerts) != 1 {
+		t.Fatalf("CPU密集型查询应告警，alerts = %d", len(alerts))
+	}
+
+	f := cpuTimeField(alerts[0])
+	if !f.Highlight || !strings.Contains(f.Value, "95%") {
+		t.Errorf("CPU时间字段 = %+v", f)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+func init() {
+	subcommands["replay-dead-letter"] = runReplayDeadLetter
+}
+
+// 一次发送尝试
+type deadLetterAttempt struct {
+	Time  time.Time `json:"time"`
+	URLs  []string  `json:"urls"`
+	Error string    `json:"error"`
+}
+
+func newDeadLetterAttempt(started time.Time, targets []webhookTarget, err error) deadLetterAttempt {
+	return deadLetterAttempt{Time: started, URLs: webhookTargetURLs(targets), Error: err.Error()}
+}
+
+// 死信文件中的一条记录，重新发送时按当前的消息格式重新渲染 Notification
+type deadLetter struct {
+	Timestamp    time.Time           `json:"timestamp"`
+	Fingerprint  string              `json:"fingerprint,omitempty"`
+	Format       string              `json:"format"`
+	Notification *notification       `json:"notification"`
+	Attempts     []deadLetterAttempt `json:"attempts"`
+}
+
+// 串行化本进程内的写入
+var deadLetterMu sync.Mutex
+
+// 以追加方式写入一条记录，整行一次写入，多个进程同时追加也不会互相穿插
+func appendDeadLetter(path string, n *notification, attempts []deadLetterAttempt) error {
+	return writeDeadLetter(path, deadLetter{
+		Timestamp:    time.Now(),
+		Fingerprint:  n.Fingerprint,
+		Format:       activeWebhookFormat.Name,
+		Notification: n,
+		Attempts:     attempts,
+	})
+}
+
+func writeDeadLetter(path string, letter deadLetter) error {
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+	deadLetterMu.Lock()
+	defer deadLetterMu.Unlock()
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// 读取死信文件中的所有记录
+func readDeadLetters(path string) ([]deadLetter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var letters []deadLetter
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxRemoteLineBytes)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var letter deadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &letter); err != nil || letter.Notification == nil {
+			return nil, fmt.Errorf("死信文件 %s 第 %d 行格式错误: %v", path, line, err)
+		}
+		letters = append(letters, letter)
+	}
+	return letters, scanner.Err()
+}
+
+// 重新发送死信文件中的通知：先把文件重命名，再逐条发送，仍然失败的记录追加回死信文件
+// 重命名后运行中的进程写入的新记录不会丢失；上次重新发送中断时继续处理留下的文件
+func replayDeadLetters(path string) (int, int, error) {
+	replaying := path + ".replaying"
+	if _, err := os.Stat(replaying); errors.Is(err, os.ErrNotExist) {
+		if err := os.Rename(path, replaying); err != nil {
+			return 0, 0, err
+		}
+	} else {
+		logf(levelWarn, "发现上次未完成的重新发送 %s，继续处理", replaying)
+	}
+
+	letters, err := readDeadLetters(replaying)
+	if err != nil {
+		return 0, 0, err
+	}
+	var delivered, failed int
+	for _, letter := range letters {
+		_, attempts, _ := deliverWithFallback(webhookDestinations, letter.Notification)
+		if len(attempts) == 0 {
+			delivered++
+			continue
+		}
+		failed++
+		letter.Attempts = append(letter.Attempts, attempts...)
+		if err := writeDeadLetter(path, letter); err != nil {
+			return delivered, failed, fmt.Errorf("无法写回死信文件，%s 中的记录需要手动处理: %w", replaying, err)
+		}
+	}
+	return delivered, failed, os.Remove(replaying)
+}
+
+// replay-dead-letter 子命令：按当前的Webhook配置重新发送 --deadLetterFile 中的通知
+func runReplayDeadLetter(args []string) error {
+	if err := pflag.CommandLine.Parse(args); err != nil {
+		return err
+	}
+	if deadLetterFile == "" {
+		return fmt.Errorf("用法: %s replay-dead-letter --deadLetterFile=<文件> --webhookURL=<URL>", programName)
+	}
+	if err := configureWebhooks(); err != nil {
+		return err
+	}
+	if len(webhookDestinations) == 0 {
+		return errors.New("Webhook URL 必须设置！")
+	}
+
+	delivered, failed, err := replayDeadLetters(deadLetterFile)
+	if err != nil {
+		return err
+	}
+	logf(levelInfo, "重新发送完成: %d 条成功，%d 条仍然失败并已写回 %s", delivered, failed, deadLetterFile)
+	return nil
+}
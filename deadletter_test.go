@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeadLetterReplay(t *testing.T) {
+	down := true
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		received = append(received, r.URL.Path)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "dead-letters.jsonl")
+	prevFile, prevTargets := deadLetterFile, webhookDestinations
+	t.Cleanup(func() { deadLetterFile, webhookDestinations = prevFile, prevTargets })
+	deadLetterFile = path
+	webhookDestinations = []webhookTarget{{URL: server.URL + "/hook", Timeout: 5 * time.Second}}
+
+	entry := &SlowQueryEntry{QueryTime: 2, SQL: "SELECT 1;", Fingerprint: "select ?"}
+	if _, err := sendWebhookNotification(webhookDestinations, entry); err == nil {
+		t.Fatal("期望发送失败")
+	}
+	letters, err := readDeadLetters(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 1 || letters[0].Fingerprint != "select ?" || len(letters[0].Attempts) != 1 ||
+		!strings.Contains(letters[0].Attempts[0].Error, "503") {
+		t.Fatalf("死信记录 = %+v", letters)
+	}
+
+	// 仍然失败的记录写回死信文件，并追加本次尝试
+	if delivered, failed, err := replayDeadLetters(path); err != nil || delivered != 0 || failed != 1 {
+		t.Fatalf("replayDeadLetters = %d, %d, %v", delivered, failed, err)
+	}
+	if letters, _ = readDeadLetters(path); len(letters) != 1 || len(letters[0].Attempts) != 2 {
+		t.Fatalf("写回的死信记录 = %+v", letters)
+	}
+
+	down = false
+	if delivered, failed, err := replayDeadLetters(path); err != nil || delivered != 1 || failed != 0 {
+		t.Fatalf("replayDeadLetters = %d, %d, %v", delivered, failed, err)
+	}
+	if len(received) != 1 {
+		t.Errorf("收到 %d 条通知，want 1", len(received))
+	}
+	if _, err := os.Stat(path + ".replaying"); !os.IsNotExist(err) {
+		t.Errorf("重新发送完成后应删除临时文件: %v", err)
+	}
+}
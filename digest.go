@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 汇总中显示的 SQL 最大字符数
+const digestSQLRunes = 100
+
+// 单个数据库在一个汇总周期内的慢查询统计
+type digestDatabase struct {
+	queryTimes     []float64
+	totalTime      float64
+	fingerprints   map[uint64]bool
+	slowest        *SlowQueryEntry
+	rowsExamined   int64                       // 扫描的总行数
+	bytesSent      int64                       // 发送的总字节数
+	tmpDiskTables  int                         // 写入磁盘的临时表总数
+	filesortOnDisk int                         // 排序写入磁盘的次数
+	types          map[string]*digestQueryType // 按SQL类型统计
+}
+
+// 一种SQL类型在一个汇总周期内的慢查询统计
+type digestQueryType struct {
+	queries   int
+	totalTime float64
+}
+
+// 当前汇总周期内的慢查询，按数据库统计
+var digest = struct {
+	sync.Mutex
+	started    time.Time
+	byDatabase map[string]*digestDatabase
+}{started: time.Now(), byDatabase: make(map[string]*digestDatabase)}
+
+// 把达到告警阈值的慢查询计入汇总
+func recordDigest(entry *SlowQueryEntry) {
+	digest.Lock()
+	defer digest.Unlock()
+	db, ok := digest.byDatabase[entry.Database]
+	if !ok {
+		db = &digestDatabase{fingerprints: make(map[uint64]bool), types: make(map[string]*digestQueryType)}
+		digest.byDatabase[entry.Database] = db
+	}
+	db.queryTimes = append(db.queryTimes, entry.QueryTime)
+	db.totalTime += entry.QueryTime
+	db.fingerprints[entry.Hash] = true
+	db.rowsExamined += int64(entry.RowsExamined)
+	db.bytesSent += entry.BytesSent
+	db.tmpDiskTables += entry.TmpDiskTables
+	if entry.FilesortOnDisk {
+		db.filesortOnDisk++
+	}
+	if db.slowest == nil || entry.QueryTime > db.slowest.QueryTime {
+		db.slowest = entry
+	}
+	typ := queryType(entry)
+	t, ok := db.types[typ]
+	if !ok {
+		t = &digestQueryType{}
+		db.types[typ] = t
+	}
+	t.queries++
+	t.totalTime += entry.QueryTime
+}
+
+// 取出当前周期的统计并开始新的周期
+func takeDigest(now time.Time) (map[string]*digestDatabase, time.Time) {
+	digest.Lock()
+	defer digest.Unlock()
+	byDatabase, started := digest.byDatabase, digest.started
+	digest.byDatabase, digest.started = make(map[string]*digestDatabase), now
+	return byDatabase, started
+}
+
+// 按 nearest-rank 方法计算百分位数，values 需已排序
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p/100*float64(len(values)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return values[i]
+}
+
+// 汇总表格中的 SQL：合并为一行并截断
+func digestSQL(sql string) string {
+	sql = strings.Join(strings.Fields(sql), " ")
+	if runes := []rune(sql); len(runes) > digestSQLRunes {
+		sql = string(runes[:digestSQLRunes]) + "…"
+	}
+	return sql
+}
+
+// 生成汇总通知：按总耗时从高到低列出各数据库的慢查询统计，以及各数据库最慢的查询
+func buildDigestNotification(byDatabase map[string]*digestDatabase, from, to time.Time) *notification {
+	names := make([]string, 0, len(byDatabase))
+	var queries int
+	var totalTime float64
+	for name, db := range byDatabase {
+		names = append(names, name)
+		queries += len(db.queryTimes)
+		totalTime += db.totalTime
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := byDatabase[names[i]], byDatabase[names[j]]
+		if a.totalTime != b.totalTime {
+			return a.totalTime > b.totalTime
+		}
+		return names[i] < names[j]
+	})
+
+	breakdown := notificationTable{
+		Title:   "各数据库慢查询统计",
+		Columns: []string{"Database", "Queries", "Total Time", "Mean Time", "P95", "Unique Fingerprints"},
+	}
+	slowest := notificationTable{
+		Title:   "各数据库最慢的查询",
+		Columns: []string{"Database", "Query Time", "SQL"},
+	}
+	resources := notificationTable{
+		Title:   "资源使用汇总 (Resource Usage Summary)",
+		Columns: []string{"Database", "Rows Examined", "Bytes Sent", "Tmp Disk Tables", "Filesort On Disk"},
+	}
+	var total digestDatabase
+	types := make(map[string]*digestQueryType)
+	for _, name := range names {
+		db := byDatabase[name]
+		sort.Float64s(db.queryTimes)
+		if name == "" {
+			name = "-" // 日志中没有记录数据库名
+		}
+		breakdown.Rows = append(breakdown.Rows, []string{
+			name,
+			fmt.Sprintf("%d", len(db.queryTimes)),
+			fmt.Sprintf("%.2fs", db.totalTime),
+			fmt.Sprintf("%.2fs", db.totalTime/float64(len(db.queryTimes))),
+			fmt.Sprintf("%.2fs", percentile(db.queryTimes, 95)),
+			fmt.Sprintf("%d", len(db.fingerprints)),
+		})
+		slowest.Rows = append(slowest.Rows, []string{name, fmt.Sprintf("%.2fs", db.slowest.QueryTime), digestSQL(db.slowest.SQL)})
+		resources.Rows = append(resources.Rows, resourceUsageRow(name, db))
+		total.rowsExamined += db.rowsExamined
+		total.bytesSent += db.bytesSent
+		total.tmpDiskTables += db.tmpDiskTables
+		total.filesortOnDisk += db.filesortOnDisk
+		for typ, t := range db.types {
+			if types[typ] == nil {
+				types[typ] = &digestQueryType{}
+			}
+			types[typ].queries += t.queries
+			types[typ].totalTime += t.totalTime
+		}
+	}
+	resources.Rows = append(resources.Rows, resourceUsageRow("Total", &total))
+
+	filterFields, filterNotes := slowLogFilterDigest()
+	return &notification{
+		Title: "慢查询汇总",
+		Fields: append([]notificationField{
+			{Label: "统计时间", Value: formatDisplayTime(from) + " ~ " + formatDisplayTime(to)},
+			{Label: "慢查询数", Value: fmt.Sprintf("%d", queries), Highlight: true},
+			{Label: "总耗时", Value: fmt.Sprintf("%.2f 秒", totalTime), Highlight: true},
+		}, filterFields...),
+		Tables: []notificationTable{breakdown, slowest, resources, queryTypeTable(types)},
+		Notes:  filterNotes,
+		Labels: alertLabels,
+	}
+}
+
+// 按SQL类型统计的慢查询，用于区分读写：写入多说明可能是锁竞争，读取多说明可能缺少索引
+func queryTypeTable(types map[string]*digestQueryType) notificationTable {
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if a, b := types[names[i]], types[names[j]]; a.totalTime != b.totalTime {
+			return a.totalTime > b.totalTime
+		}
+		return names[i] < names[j]
+	})
+	table := notificationTable{
+		Title:   "各类型慢查询统计 (Query Types)",
+		Columns: []string{"Type", "Queries", "Total Time", "Mean Time"},
+	}
+	for _, name := range names {
+		t := types[name]
+		table.Rows = append(table.Rows, []string{
+			sqlTypeBadges[name],
+			fmt.Sprintf("%d", t.queries),
+			fmt.Sprintf("%.2fs", t.totalTime),
+			fmt.Sprintf("%.2fs", t.totalTime/float64(t.queries)),
+		})
+	}
+	return table
+}
+
+// 资源使用汇总中的一行
+func resourceUsageRow(name string, db *digestDatabase) []string {
+	return []string{
+		name,
+		fmt.Sprintf("%d", db.rowsExamined),
+		formatBytes(db.bytesSent),
+		fmt.Sprintf("%d", db.tmpDiskTables),
+		fmt.Sprintf("%d", db.filesortOnDisk),
+	}
+}
+
+// 字节数的显示格式，例如 1.5 MiB
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, exp := float64(n)/unit, 0
+	for value >= unit && exp < 4 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGTP"[exp])
+}
+
+// 按 --digestInterval 定期发送汇总，周期内没有慢查询时不发送
+func runDigest(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		if ptDigestOutput != "" {
+			if err := writePTDigestFile(ptDigestOutput, takePTDigest(), now); err != nil {
+				logf(levelWarn, "%v", err)
+			}
+		}
+		byDatabase, started := takeDigest(now)
+		if len(byDatabase) == 0 {
+			logf(levelDebug, "汇总周期内没有慢查询，不发送汇总")
+			continue
+		}
+		logf(levelInfo, "发送慢查询汇总: %d 个数据库", len(byDatabase))
+		n := buildDigestNotification(byDatabase, started, now)
+		if top := takeLockContentions(); len(top) > 0 {
+			n.Tables = append(n.Tables, lockContentionTable(top))
+		}
+		if top := takeTrackedQueries(); len(top) > 0 {
+			n.Tables = append(n.Tables, trackedQueriesTable(top))
+		}
+		if topFrequent > 0 {
+			n.Tables = append(n.Tables, frequentQueriesTable(topFrequentQueries(topFrequent)))
+			if resetFrequentAfterDigest {
+				resetFrequentQueries()
+			}
+		}
+		deliverNotification(webhookDestinations, n)
+	}
+}
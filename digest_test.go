@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildDigestNotification(t *testing.T) {
+	t.Cleanup(func() { takeDigest(time.Now()) })
+	takeDigest(time.Now())
+	for _, e := range []SlowQueryEntry{
+		{Database: "shop", QueryTime: 1, Hash: 1, SQL: "SELECT * FROM orders"},
+		{Database: "shop", QueryTime: 3, Hash: 2, SQL: "SELECT *\nFROM users"},
+		{Database: "report", QueryTime: 10, Hash: 3, SQL: "SELECT a | b FROM t"},
+	} {
+		recordDigest(&e)
+	}
+
+	byDatabase, started := takeDigest(time.Now())
+	n := buildDigestNotification(byDatabase, started, time.Now())
+	breakdown, slowest := n.Tables[0], n.Tables[1]
+	if len(breakdown.Rows) != 2 || breakdown.Rows[0][0] != "report" {
+		t.Fatalf("应按总耗时从高到低排序: %v", breakdown.Rows)
+	}
+	if got := strings.Join(breakdown.Rows[1], ","); got != "shop,2,4.00s,2.00s,3.00s,2" {
+		t.Errorf("shop 统计 = %s", got)
+	}
+	if slowest.Rows[1][2] != "SELECT * FROM users" {
+		t.Errorf("shop 最慢的查询 = %q", slowest.Rows[1][2])
+	}
+
+	text := renderWeChatMarkdown(n)
+	if !strings.Contains(text, "| Database | Queries | Total Time | Mean Time | P95 | Unique Fingerprints |") ||
+		!strings.Contains(text, `SELECT a \| b FROM t`) {
+		t.Errorf("企业微信汇总内容:\n%s", text)
+	}
+	if byDatabase, _ := takeDigest(time.Now()); len(byDatabase) != 0 {
+		t.Error("取出统计后应开始新的周期")
+	}
+}
+
+func TestDigestResourceUsage(t *testing.T) {
+	t.Cleanup(func() { takeDigest(time.Now()) })
+	takeDigest(time.Now())
+	entry, err := ParseLogLines(fixtureLines(`
+# User@Host: app[app] @ localhost []  Id:    42
+# Schema: shop  Last_errno: 0  Killed: 0
+# Query_time: 2.000000  Lock_time: 0.000100 Rows_sent: 10  Rows_examined: 500000  Rows_affected: 0
+# Bytes_sent: 1572864  Tmp_tables: 2  Tmp_disk_tables: 1  Tmp_table_sizes: 0
+# Filesort: Yes  Filesort_on_disk: Yes  Merge_passes: 3
+SELECT * FROM events ORDER BY payload;`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.BytesSent != 1572864 {
+		t.Fatalf("BytesSent = %d", entry.BytesSent)
+	}
+	recordDigest(entry)
+	recordDigest(&SlowQueryEntry{Database: "report", QueryTime: 1, RowsExamined: 100, BytesSent: 512})
+
+	byDatabase, started := takeDigest(time.Now())
+	n := buildDigestNotification(byDatabase, started, time.Now())
+	resources := n.Tables[2]
+	var rows []string
+	for _, row := range resources.Rows {
+		rows = append(rows, strings.Join(row, ","))
+	}
+	want := []string{"shop,500000,1.5 MiB,1,1", "report,100,512 B,0,0", "Total,500100,1.5 MiB,1,1"}
+	if strings.Join(rows, "\n") != strings.Join(want, "\n") {
+		t.Errorf("资源使用汇总 = %v, want %v", rows, want)
+	}
+}
+
+func TestDigestQueryTypes(t *testing.T) {
+	t.Cleanup(func() { takeDigest(time.Now()) })
+	takeDigest(time.Now())
+	recordDigest(&SlowQueryEntry{Database: "shop", QueryTime: 2, SQL: "SELECT * FROM orders;"})
+	recordDigest(&SlowQueryEntry{Database: "shop", QueryTime: 5, SQL: "UPDATE orders SET status = 1;"})
+	recordDigest(&SlowQueryEntry{Database: "report", QueryTime: 4, SQL: "SELECT COUNT(*) FROM events;"})
+
+	byDatabase, started := takeDigest(time.Now())
+	n := buildDigestNotification(byDatabase, started, time.Now())
+	var rows []string
+	for _, row := range n.Tables[3].Rows {
+		rows = append(rows, strings.Join(row, ","))
+	}
+	want := []string{"🔍 SELECT,2,6.00s,3.00s", "✏️ UPDATE,1,5.00s,5.00s"}
+	if strings.Join(rows, "\n") != strings.Join(want, "\n") {
+		t.Errorf("各类型慢查询统计 = %v, want %v", rows, want)
+	}
+}
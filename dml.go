@@ -0,0 +1,19 @@
+package main
+
+import "regexp"
+
+// 识别DML语句，允许前面有注释
+var dmlPattern = regexp.MustCompile(`(?is)^\s*(?:/\*.*?\*/\s*)*(INSERT|UPDATE|DELETE|REPLACE)\b`)
+
+// 大批量修改的告警标题
+const dmlWarningTitle = "⚠️ DML Warning: 大批量修改"
+
+// 判断SQL是否是DML语句
+func isDML(sql string) bool {
+	return dmlPattern.MatchString(sql)
+}
+
+// DML语句影响的行数达到 --rowsAffectedThreshold 时，告警中突出显示影响行数与锁定时间
+func isDMLWarning(entry *SlowQueryEntry) bool {
+	return rowsAffectedThreshold > 0 && entry.RowsAffected >= rowsAffectedThreshold && isDML(entry.SQL)
+}
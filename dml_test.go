@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestRowsAffectedThreshold(t *testing.T) {
+	prevRows, prevThreshold := rowsAffectedThreshold, slowQueryThreshold
+	t.Cleanup(func() { rowsAffectedThreshold, slowQueryThreshold = prevRows, prevThreshold })
+	rowsAffectedThreshold, slowQueryThreshold = 100000, 10
+
+	entry, err := ParseLogLines(fixtureLines(`
+# User@Host: app[app] @ localhost []  Id:    42
+# Query_time: 2.500000  Lock_time: 1.200000  Rows_sent: 0  Rows_examined: 1000000  Rows_affected: 1000000
+DELETE FROM events WHERE created_at < '2023-01-01';`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.RowsAffected != 1000000 {
+		t.Fatalf("RowsAffected = %d", entry.RowsAffected)
+	}
+	if !entry.Validate(thresholdFor(entry.Database)) {
+		t.Errorf("影响行数达到 --rowsAffectedThreshold 时应告警")
+	}
+
+	n := buildSlowQueryNotification(entry)
+	if n.Title != dmlWarningTitle {
+		t.Errorf("Title = %q", n.Title)
+	}
+	highlighted := make(map[string]bool)
+	for _, f := range n.Fields {
+		highlighted[f.Label] = f.Highlight
+	}
+	if !highlighted["影响的行数"] || !highlighted["锁定时间"] {
+		t.Errorf("DML Warning 应突出显示影响的行数与锁定时间: %+v", n.Fields)
+	}
+
+	// SELECT 不按影响行数告警
+	entry.SQL = "/* app: report */ SELECT * FROM events"
+	if entry.Validate(thresholdFor(entry.Database)) || isDMLWarning(entry) {
+		t.Errorf("非DML语句不应按影响行数告警")
+	}
+	if _, ok := slowQueryField("rowsAffected", entry); ok {
+		t.Errorf("非DML语句不应显示影响的行数")
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Docker API 的 socket 路径
+var dockerSocket = "/var/run/docker.sock"
+
+// 匹配 64 位十六进制的容器ID
+var containerIDPattern = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// 查找当前进程所在容器的ID：cgroup v1 中的 /docker/<id>，cgroup v2 下从 mountinfo 中的 /containers/<id>/ 获取
+func currentContainerID() (string, error) {
+	if data, err := os.ReadFile("/proc/self/cgroup"); err == nil {
+		if id := containerIDPattern.FindString(string(data)); id != "" {
+			return id, nil
+		}
+	}
+	if data, err := os.ReadFile("/proc/self/mountinfo"); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if i := strings.Index(line, "/containers/"); i >= 0 {
+				if id := containerIDPattern.FindString(line[i:]); id != "" {
+					return id, nil
+				}
+			}
+		}
+	}
+	return "", errors.New("无法确定当前容器ID，请确认程序运行在 Docker 容器中")
+}
+
+// 通过 Docker API 读取容器的标签
+func fetchContainerLabels(containerID string) (map[string]string, error) {
+	client := resty.New().SetTransport(&http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", dockerSocket)
+		},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := client.R().SetContext(ctx).Get("http://docker/containers/" + containerID + "/json")
+	if err != nil {
+		return nil, fmt.Errorf("无法访问 Docker API %s: %w", dockerSocket, err)
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("Docker API 返回 %s", resp.Status())
+	}
+
+	var info struct {
+		Config struct {
+			Labels map[string]string
+		}
+	}
+	if err := json.Unmarshal(resp.Body(), &info); err != nil {
+		return nil, fmt.Errorf("无法解析 Docker API 返回的容器信息: %w", err)
+	}
+	return info.Config.Labels, nil
+}
+
+// 读取当前容器中以 prefix 开头的标签，去掉前缀后按名称排序返回
+func dockerAutoLabels(prefix string) ([]notificationField, error) {
+	containerID, err := currentContainerID()
+	if err != nil {
+		return nil, err
+	}
+	containerLabels, err := fetchContainerLabels(containerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var labels []notificationField
+	for key, value := range containerLabels {
+		if name, ok := strings.CutPrefix(key, prefix); ok && name != "" {
+			labels = append(labels, notificationField{Label: name, Value: value})
+		}
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Label < labels[j].Label })
+	return labels, nil
+}
+
+// 合并标签，overrides 中的同名标签覆盖 base 中的值
+func mergeLabels(base, overrides []notificationField) []notificationField {
+	merged := make([]notificationField, 0, len(base)+len(overrides))
+	seen := make(map[string]bool, len(overrides))
+	for _, l := range overrides {
+		seen[l.Label] = true
+	}
+	for _, l := range base {
+		if !seen[l.Label] {
+			merged = append(merged, l)
+		}
+	}
+	return append(merged, overrides...)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFetchContainerLabels(t *testing.T) {
+	const containerID = "4f66ad9a0b2e1f1e6f7d0d1d1b7a2c0e9e8f8a7b6c5d4e3f2a1b0c9d8e7f6a5b"
+
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("不支持 unix socket: %v", err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/containers/"+containerID+"/json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"Id":"` + containerID + `","Config":{"Labels":{"mysql-monitor.service":"payments-db","mysql-monitor.team":"payments","com.docker.compose.project":"db"}}}`))
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	prev := dockerSocket
+	dockerSocket = socket
+	t.Cleanup(func() { dockerSocket = prev })
+
+	labels, err := fetchContainerLabels(containerID)
+	if err != nil {
+		t.Fatalf("fetchContainerLabels: %v", err)
+	}
+	if labels["mysql-monitor.service"] != "payments-db" || len(labels) != 3 {
+		t.Fatalf("容器标签解析错误: %v", labels)
+	}
+
+	merged := mergeLabels(
+		[]notificationField{{Label: "service", Value: "payments-db"}, {Label: "team", Value: "payments"}},
+		[]notificationField{{Label: "env", Value: "production"}, {Label: "team", Value: "dba"}},
+	)
+	want := []notificationField{{Label: "service", Value: "payments-db"}, {Label: "env", Value: "production"}, {Label: "team", Value: "dba"}}
+	if !reflect.DeepEqual(merged, want) {
+		t.Errorf("mergeLabels = %v, want %v", merged, want)
+	}
+}
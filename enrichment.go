@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// 请求 --enrichmentURL 获取告警的附加信息，例如负责团队、值班人
+func fetchEnrichment(entry *SlowQueryEntry) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), enrichmentTimeout)
+	defer cancel()
+
+	resp, err := resty.New().R().
+		SetContext(ctx).
+		SetQueryParams(map[string]string{
+			"database": entry.Database,
+			"user":     entry.User,
+			"host":     entry.Host,
+		}).
+		Get(enrichmentURL)
+	if err != nil {
+		return nil, err
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("HTTP %s", resp.Status())
+	}
+
+	var values map[string]any
+	if err := json.Unmarshal(resp.Body(), &values); err != nil {
+		return nil, fmt.Errorf("返回内容不是JSON对象: %w", err)
+	}
+	fields := make(map[string]string, len(values))
+	for key, value := range values {
+		if s, ok := value.(string); ok {
+			fields[key] = s
+			continue
+		}
+		raw, _ := json.Marshal(value)
+		fields[key] = string(raw)
+	}
+	return fields, nil
+}
+
+// 为告警补充附加信息，失败时不影响告警发送
+func enrichEntry(entry *SlowQueryEntry) {
+	if enrichmentURL == "" {
+		return
+	}
+	fields, err := fetchEnrichment(entry)
+	if err != nil {
+		logf(levelDebug, "获取告警附加信息失败 [%s]，跳过: %v", enrichmentURL, err)
+		return
+	}
+	entry.ExtraFields = fields
+}
+
+// 附加信息按字段名排序后转为通知字段，保证输出顺序稳定
+func extraNotificationFields(extra map[string]string) []notificationField {
+	keys := make([]string, 0, len(extra))
+	for key := range extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fields := make([]notificationField, 0, len(keys))
+	for _, key := range keys {
+		fields = append(fields, notificationField{Label: key, Value: extra[key]})
+	}
+	return fields
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEnrichEntry(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("database") {
+		case "shop":
+			if r.URL.Query().Get("user") != "app" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"team":"payments","oncall":["alice","bob"]}`)
+		case "broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "html":
+			fmt.Fprint(w, "<html></html>")
+		case "slow":
+			select {
+			case <-time.After(2 * time.Second):
+			case <-r.Context().Done():
+			}
+		}
+	}))
+	defer server.Close()
+
+	var alerted []*SlowQueryEntry
+	prevNotifier, prevURL, prevTimeout := alertNotifier, enrichmentURL, enrichmentTimeout
+	t.Cleanup(func() { alertNotifier, enrichmentURL, enrichmentTimeout = prevNotifier, prevURL, prevTimeout })
+	alertNotifier = func(targets []webhookTarget, entry *SlowQueryEntry) (int, error) {
+		alerted = append(alerted, entry)
+		return 1, nil
+	}
+	enrichmentURL, enrichmentTimeout = server.URL, 100*time.Millisecond
+
+	for _, tt := range []struct {
+		database string
+		want     map[string]string
+	}{
+		{"shop", map[string]string{"team": "payments", "oncall": `["alice","bob"]`}},
+		{"broken", nil},
+		{"html", nil},
+		{"slow", nil},
+	} {
+		t.Run(tt.database, func(t *testing.T) {
+			alerted = nil
+			processSlowQuery(fixtureLines(fmt.Sprintf(`
+# User@Host: app[app] @ localhost []  Id:    49
+# Query_time: 1.500000  Lock_time: 0.000100 Rows_sent: 1  Rows_examined: 100
+use %s;
+SELECT * FROM enrichment_%s;`, tt.database, tt.database)), nil)
+
+			// 获取附加信息失败时仍然发送告警，只是不带附加信息
+			if len(alerted) != 1 {
+				t.Fatalf("期望 1 条告警，实际 %d 条", len(alerted))
+			}
+			extra := alerted[0].ExtraFields
+			if len(extra) != len(tt.want) {
+				t.Fatalf("ExtraFields = %v, want %v", extra, tt.want)
+			}
+			for key, value := range tt.want {
+				if extra[key] != value {
+					t.Errorf("ExtraFields[%s] = %q, want %q", key, extra[key], value)
+				}
+			}
+		})
+	}
+
+	fields := extraNotificationFields(map[string]string{"team": "payments", "oncall": "alice"})
+	if len(fields) != 2 || fields[0].Label != "oncall" || fields[1].Label != "team" {
+		t.Errorf("附加信息应按字段名排序: %+v", fields)
+	}
+}
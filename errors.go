@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 读取慢查询日志文件的错误
+type TailError struct {
+	Path string
+	Op   string // open：无法打开文件，read：读取过程中出错
+	Err  error
+}
+
+func (e *TailError) Error() string {
+	if e.Op == "read" {
+		return fmt.Sprintf("读取慢查询日志文件 %s 出错: %v", e.Path, e.Err)
+	}
+	return fmt.Sprintf("无法跟踪慢查询日志文件 %s: %v", e.Path, e.Err)
+}
+
+func (e *TailError) Unwrap() error { return e.Err }
+
+// 解析日志条目的错误
+type ParseError struct {
+	Line string // 条目的第一行，便于定位
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("无法解析日志条目 %q: %v", e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// 日志条目的第一个非空行
+func firstLine(lines []string) string {
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// 推送到单个Webhook地址的错误
+type DeliveryError struct {
+	URL string
+	Err error
+}
+
+func (e *DeliveryError) Error() string {
+	return e.URL + ": " + e.Err.Error()
+}
+
+func (e *DeliveryError) Unwrap() error { return e.Err }
+
+// 运行中的错误处理配置
+type errorConfig struct {
+	errorHandler func(error) // 处理运行中出现的错误，默认按错误类型输出日志，测试中可以替换以捕获错误
+}
+
+var runtimeErrors = errorConfig{errorHandler: logError}
+
+// 交给 errorHandler 处理运行中出现的错误
+func reportError(err error) {
+	runtimeErrors.errorHandler(err)
+}
+
+// 默认的错误处理：按错误类型选择日志级别
+func logError(err error) {
+	var tailErr *TailError
+	var parseErr *ParseError
+	var versionErr *mysqlVersionError
+	var deliveryErr *DeliveryError
+	switch {
+	case errors.As(err, &tailErr):
+		if tailErr.Op == "read" {
+			logf(levelWarn, "%v", tailErr)
+		} else {
+			logf(levelError, "%v", tailErr)
+		}
+	case errors.As(err, &versionErr):
+		// 指定的版本与实际日志不符时每条日志都会失败，不能只在 debug 日志中输出
+		logf(levelWarn, "跳过日志条目: %v", err)
+	case errors.As(err, &parseErr):
+		logf(levelDebug, "跳过日志条目: %v", parseErr.Err)
+	case errors.As(err, &deliveryErr):
+		logf(levelWarn, "发送Webhook通知失败 [%s]: %v", deliveryErr.URL, deliveryErr.Err)
+	default:
+		logf(levelError, "%v", err)
+	}
+}
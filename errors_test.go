@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 替换 errorHandler，返回捕获到的错误
+func captureErrors(t *testing.T) func() []error {
+	var mu sync.Mutex
+	var captured []error
+	prev := runtimeErrors
+	t.Cleanup(func() { runtimeErrors = prev })
+	runtimeErrors.errorHandler = func(err error) {
+		mu.Lock()
+		captured = append(captured, err)
+		mu.Unlock()
+	}
+	return func() []error {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]error(nil), captured...)
+	}
+}
+
+func TestProcessSlowQueryReportsParseError(t *testing.T) {
+	errs := captureErrors(t)
+	processSlowQuery(fixtureLines(`
+# User@Host: app[app] @ localhost []
+SELECT 1;`), nil)
+
+	got := errs()
+	var parseErr *ParseError
+	if len(got) != 1 || !errors.As(got[0], &parseErr) {
+		t.Fatalf("期望一个 ParseError，实际为 %v", got)
+	}
+	if !errors.Is(parseErr, errMissingQueryTime) || parseErr.Line != "# User@Host: app[app] @ localhost []" {
+		t.Errorf("ParseError = %+v", parseErr)
+	}
+}
+
+func TestDeliverPayloadReportsDeliveryError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	errs := captureErrors(t)
+	sent, err := deliverPayload(&notification{Title: "慢查询警告"}, []webhookTarget{{URL: server.URL, Timeout: 5 * time.Second}}, "{}")
+	if sent != 0 || err == nil {
+		t.Fatalf("sent = %d, err = %v", sent, err)
+	}
+
+	var deliveryErr *DeliveryError
+	if got := errs(); len(got) != 1 || !errors.As(got[0], &deliveryErr) {
+		t.Fatalf("期望一个 DeliveryError，实际为 %v", got)
+	}
+	var statusErr *webhookStatusError
+	if deliveryErr.URL != server.URL || !errors.As(deliveryErr, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("DeliveryError = %v", deliveryErr)
+	}
+	if !errors.As(err, &deliveryErr) {
+		t.Errorf("返回的错误中应包含 DeliveryError: %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// 单个指纹的升级告警状态
+type escalationState struct {
+	alerts   int       // 已发送的告警次数，与冷却记录分开统计
+	lastSeen time.Time // 最近一次达到告警阈值的时间
+}
+
+// 按指纹哈希索引的升级告警状态
+var escalations = struct {
+	sync.Mutex
+	byHash map[uint64]*escalationState
+}{byHash: make(map[uint64]*escalationState)}
+
+// 通过 --escalationWebhookURL 配置的升级告警地址，nil 表示不升级
+var escalationTarget *webhookTarget
+
+// 记录指纹达到告警阈值，超过 --escalationResetAfter 未出现时重新计数
+func noteEscalationSeen(hash uint64, now time.Time) {
+	escalations.Lock()
+	defer escalations.Unlock()
+	s, ok := escalations.byHash[hash]
+	if !ok {
+		s = &escalationState{}
+		escalations.byHash[hash] = s
+	}
+	if now.Sub(s.lastSeen) > escalationResetAfter {
+		s.alerts = 0
+	}
+	s.lastSeen = now
+}
+
+// 记录一次告警，已发送 --escalationAfter 次以上时返回 true
+func escalationDue(hash uint64) bool {
+	escalations.Lock()
+	defer escalations.Unlock()
+	s, ok := escalations.byHash[hash]
+	if !ok {
+		s = &escalationState{lastSeen: time.Now()}
+		escalations.byHash[hash] = s
+	}
+	s.alerts++
+	return s.alerts > escalationAfter
+}
+
+// 同时把告警发送到升级告警地址
+func escalateNotification(n *notification) {
+	escalated := *n
+	escalated.Title = "[ESCALATED] " + n.Title
+	logf(levelInfo, "指纹重复告警已达 %d 次，发送升级告警 [%s]", escalationAfter, escalationTarget.URL)
+	deliverNotification([]webhookTarget{*escalationTarget}, &escalated)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSendWebhookNotificationEscalation(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer primary.Close()
+
+	var mu sync.Mutex
+	var escalated []string
+	oncall := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		escalated = append(escalated, string(body))
+		mu.Unlock()
+	}))
+	defer oncall.Close()
+
+	prevTarget, prevAfter, prevReset := escalationTarget, escalationAfter, escalationResetAfter
+	t.Cleanup(func() { escalationTarget, escalationAfter, escalationResetAfter = prevTarget, prevAfter, prevReset })
+	escalationTarget = &webhookTarget{URL: oncall.URL, Format: webhookFormats["generic"], Timeout: 5 * time.Second}
+	escalationAfter, escalationResetAfter = 2, time.Hour
+
+	entry := &SlowQueryEntry{QueryTime: 2, SQL: "SELECT * FROM escalation_test;", Hash: computeQueryHash("select * from escalation_test")}
+	targets := []webhookTarget{{URL: primary.URL, Timeout: 5 * time.Second}}
+	for i := 1; i <= 3; i++ {
+		noteEscalationSeen(entry.Hash, time.Now())
+		if _, err := sendWebhookNotification(targets, entry); err != nil {
+			t.Fatal(err)
+		}
+		mu.Lock()
+		got := len(escalated)
+		mu.Unlock()
+		if want := max(i-escalationAfter, 0); got != want {
+			t.Fatalf("第 %d 次告警后升级告警 %d 条, want %d", i, got, want)
+		}
+	}
+	if !strings.Contains(escalated[0], `"title":"[ESCALATED] 慢查询警告"`) {
+		t.Errorf("升级告警请求体: %s", escalated[0])
+	}
+
+	// 超过 --escalationResetAfter 未出现后重新计数
+	noteEscalationSeen(entry.Hash, time.Now().Add(2*time.Hour))
+	if escalationDue(entry.Hash) {
+		t.Errorf("重新计数后的第一次告警不应升级")
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EXPLAIN ANALYZE 会实际执行查询，超时时间比 EXPLAIN 长
+const explainAnalyzeTimeout = 30 * time.Second
+
+// 告警中最多列出的 EXPLAIN ANALYZE 节点数
+const analyzeMaxNodes = 10
+
+// 实际行数超过估算行数的该倍数、且不少于 analyzeMinRows 时，认为统计信息可能已过期
+const analyzeRowsDiscrepancy = 10
+const analyzeMinRows = 100
+
+// EXPLAIN ANALYZE 会实际执行查询，只对只读的查询执行
+var analyzablePattern = regexp.MustCompile(`(?i)^\s*(select|with)\b`)
+
+// 会加锁、写入文件或变量、等待的子句与函数，即使是 SELECT 也不能在生产库上执行
+var unsafeAnalyzePattern = regexp.MustCompile(`(?i)\bfor\s+(update|share)\b|\block\s+in\s+share\s+mode\b|\binto\b|\b(get_lock|release_lock|release_all_locks|is_used_lock|sleep|benchmark)\s*\(`)
+
+// SQL 中的括号与单词，用于跳过 WITH 中的公用表表达式
+var sqlTokenPattern = regexp.MustCompile(`[()]|\w+`)
+
+// 是否可以执行 EXPLAIN ANALYZE：只允许单条不加锁的 SELECT，WITH 的主语句也必须是 SELECT
+func isAnalyzable(query string) bool {
+	query = strings.TrimRight(strings.TrimSpace(stringLiteralPattern.ReplaceAllString(query, "?")), "; \t\n")
+	if !analyzablePattern.MatchString(query) || unsafeAnalyzePattern.MatchString(query) || strings.Contains(query, ";") {
+		return false
+	}
+	return mainStatement(query) == "select"
+}
+
+// 主语句的第一个关键字，括号中的公用表表达式与子查询不计入
+func mainStatement(query string) string {
+	depth := 0
+	for _, token := range sqlTokenPattern.FindAllString(query, -1) {
+		switch token = strings.ToLower(token); token {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		case "select", "insert", "update", "delete", "replace", "table", "values":
+			if depth == 0 {
+				return token
+			}
+		}
+	}
+	return ""
+}
+
+// EXPLAIN ANALYZE FORMAT=TREE 输出中的一个节点，例如
+// -> Table scan on orders  (cost=1005.25 rows=9963) (actual time=0.049..4.321 rows=10000 loops=1)
+var analyzeNodePattern = regexp.MustCompile(`^(\s*)-> (.+?)\s+(?:\(cost=([\d.]+(?:e[+-]?\d+)?) rows=([\d.]+(?:e[+-]?\d+)?)\)\s*)?(?:\(actual time=[\d.]+\.\.([\d.]+) rows=([\d.]+(?:e[+-]?\d+)?) loops=(\d+)\)|\(never executed\))`)
+
+// EXPLAIN ANALYZE 中的一个执行计划节点，行数都是每次循环的平均值
+type analyzeNode struct {
+	Depth         int
+	Operation     string  // 例如 Table scan on orders
+	Cost          float64 // 估算成本
+	EstimatedRows float64 // 估算行数
+	HasEstimate   bool    // 是否有 cost 与估算行数，Limit 等节点没有
+	ActualRows    float64 // 实际行数
+	Loops         int64   // 循环次数，0 表示未执行
+	ActualTime    float64 // 返回最后一行的耗时，单位毫秒
+}
+
+// 实际行数是估算行数的倍数，缺少估算或行数太少时为 0
+// 实际少于估算通常是 LIMIT 提前结束了读取，不能说明统计信息有问题，因此只看低估
+func (n analyzeNode) discrepancy() float64 {
+	if !n.HasEstimate || n.Loops == 0 || n.ActualRows < analyzeMinRows {
+		return 0
+	}
+	return n.ActualRows / max(n.EstimatedRows, 1)
+}
+
+// 一条查询的 EXPLAIN ANALYZE 结果
+type analyzeResult []analyzeNode
+
+// 解析 EXPLAIN ANALYZE FORMAT=TREE 的输出
+func parseExplainAnalyze(output string) analyzeResult {
+	var result analyzeResult
+	for _, line := range strings.Split(output, "\n") {
+		matches := analyzeNodePattern.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		node := analyzeNode{Depth: len(matches[1]) / 4, Operation: matches[2]}
+		if matches[3] != "" {
+			node.Cost, _ = strconv.ParseFloat(matches[3], 64)
+			node.EstimatedRows, _ = strconv.ParseFloat(matches[4], 64)
+			node.HasEstimate = true
+		}
+		if matches[7] != "" {
+			node.ActualTime, _ = strconv.ParseFloat(matches[5], 64)
+			node.ActualRows, _ = strconv.ParseFloat(matches[6], 64)
+			node.Loops, _ = strconv.ParseInt(matches[7], 10, 64)
+		}
+		result = append(result, node)
+	}
+	return result
+}
+
+// 实际行数比估算行数多得最多的节点，没有明显偏差时返回 false
+func (r analyzeResult) worstEstimate() (analyzeNode, bool) {
+	var worst analyzeNode
+	for _, node := range r {
+		if node.discrepancy() > worst.discrepancy() {
+			worst = node
+		}
+	}
+	return worst, worst.discrepancy() >= analyzeRowsDiscrepancy
+}
+
+// 在数据库 database 中执行 EXPLAIN ANALYZE，返回树形输出，测试中可以替换为模拟实现
+var runExplainAnalyze = func(database, query string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), explainAnalyzeTimeout)
+	defer cancel()
+	conn, err := explainConn(ctx, explainDB, database)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	var output string
+	err = conn.QueryRowContext(ctx, "EXPLAIN ANALYZE FORMAT=TREE "+strings.TrimRight(strings.TrimSpace(query), ";")).Scan(&output)
+	return output, err
+}
+
+// 每个指纹最近一次 EXPLAIN ANALYZE 的结果，在 --explainCacheTTL 内复用（包括执行失败）
+var explainAnalyzeCache = struct {
+	sync.Mutex
+	byHash map[uint64]explainAnalyzeCacheEntry
+}{byHash: make(map[uint64]explainAnalyzeCacheEntry)}
+
+type explainAnalyzeCacheEntry struct {
+	result    analyzeResult
+	err       error
+	checkedAt time.Time
+}
+
+// 取指纹的 EXPLAIN ANALYZE 结果，缓存过期时重新执行
+func explainAnalyzeFor(entry *SlowQueryEntry, now time.Time) (analyzeResult, error) {
+	explainAnalyzeCache.Lock()
+	defer explainAnalyzeCache.Unlock()
+	if cached, ok := explainAnalyzeCache.byHash[entry.Hash]; ok && now.Sub(cached.checkedAt) < explainCacheTTL {
+		return cached.result, cached.err
+	}
+	for hash, cached := range explainAnalyzeCache.byHash {
+		if now.Sub(cached.checkedAt) >= explainCacheTTL {
+			delete(explainAnalyzeCache.byHash, hash)
+		}
+	}
+	output, err := runExplainAnalyze(entry.Database, entry.SQL)
+	result := parseExplainAnalyze(output)
+	if err == nil && len(result) == 0 {
+		err = errors.New("EXPLAIN ANALYZE 的输出中没有执行计划")
+	}
+	explainAnalyzeCache.byHash[entry.Hash] = explainAnalyzeCacheEntry{result: result, err: err, checkedAt: now}
+	return result, err
+}
+
+// 告警前对只读查询执行 EXPLAIN ANALYZE，把实际的行数与耗时附加到告警中；失败时不影响告警发送
+func checkExplainAnalyze(entry *SlowQueryEntry) {
+	if !explainAnalyze || !isAnalyzable(entry.SQL) {
+		return
+	}
+	result, err := explainAnalyzeFor(entry, time.Now())
+	if err != nil {
+		logf(levelDebug, "无法执行 EXPLAIN ANALYZE，跳过: %v", err)
+		return
+	}
+	entry.PlanAnalyze = result
+}
+
+// 检查 --explainAnalyze：需要 MySQL 8.0.18+ 与 --mysqlDSN，未启用 --baselineDB 时单独连接
+func configureExplainAnalyze() error {
+	if !explainAnalyze {
+		return nil
+	}
+	if activeMySQLVersion == nil || !activeMySQLVersion.explainAnalyze {
+		return errors.New("--explainAnalyze 需要 --mysqlVersion 为 8.0 或 percona-8.0（EXPLAIN ANALYZE 从 MySQL 8.0.18 起支持）")
+	}
+	if mysqlDSN == "" {
+		return errors.New("使用 --explainAnalyze 时必须指定 --mysqlDSN")
+	}
+	if explainDB != nil {
+		return nil
+	}
+	db, err := sql.Open("mysql", mysqlDSN)
+	if err != nil {
+		return fmt.Errorf("--mysqlDSN 无效: %w", err)
+	}
+	registerShutdownHook(func() { db.Close() })
+	explainDB = db
+	return nil
+}
+
+// 告警中的 EXPLAIN ANALYZE 节点，按执行计划的层级缩进
+func explainAnalyzeTables(entry *SlowQueryEntry) []notificationTable {
+	if len(entry.PlanAnalyze) == 0 {
+		return nil
+	}
+	table := notificationTable{
+		Title:   "EXPLAIN ANALYZE",
+		Columns: []string{"Operation", "Estimated Rows", "Actual Rows", "Loops", "Actual Time", "Cost"},
+	}
+	for i, node := range entry.PlanAnalyze {
+		if i == analyzeMaxNodes {
+			break
+		}
+		estimated, cost := "-", "-"
+		if node.HasEstimate {
+			estimated, cost = fmt.Sprintf("%.0f", node.EstimatedRows), fmt.Sprintf("%.2f", node.Cost)
+		}
+		actual, loops, elapsed := "未执行", "0", "-"
+		if node.Loops > 0 {
+			actual, loops, elapsed = strconv.FormatFloat(node.ActualRows, 'f', -1, 64), fmt.Sprintf("%d", node.Loops), fmt.Sprintf("%.3f ms", node.ActualTime)
+		}
+		table.Rows = append(table.Rows, []string{strings.Repeat("  ", node.Depth) + node.Operation, estimated, actual, loops, elapsed, cost})
+	}
+	return []notificationTable{table}
+}
+
+// 实际行数远多于估算行数时提示更新统计信息
+func explainAnalyzeNotes(entry *SlowQueryEntry) []string {
+	node, ok := entry.PlanAnalyze.worstEstimate()
+	if !ok {
+		return nil
+	}
+	return []string{fmt.Sprintf("⚠️ `%s` 估算 %.0f 行、实际 %s 行，相差超过 %d 倍，统计信息可能已过期，可以执行 ANALYZE TABLE 更新",
+		node.Operation, node.EstimatedRows, strconv.FormatFloat(node.ActualRows, 'f', -1, 64), analyzeRowsDiscrepancy)}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleExplainAnalyze = `-> Limit: 100 row(s)  (actual time=12.510..12.530 rows=100 loops=1)
+    -> Sort: orders.created_at DESC, limit input to 100 row(s) per chunk  (cost=1005.25 rows=9963) (actual time=12.508..12.520 rows=100 loops=1)
+        -> Filter: (orders.status = 'pending')  (cost=1005.25 rows=996) (actual time=0.052..11.432 rows=48210 loops=1)
+            -> Table scan on orders  (cost=1005.25 rows=9963) (actual time=0.049..8.321 rows=100000 loops=1)
+        -> Index lookup on refunds using idx_order (order_id=orders.id)  (cost=0.25 rows=1) (never executed)
+`
+
+func TestParseExplainAnalyze(t *testing.T) {
+	result := parseExplainAnalyze(sampleExplainAnalyze)
+	if len(result) != 5 {
+		t.Fatalf("应解析出 5 个节点，实际 %d: %+v", len(result), result)
+	}
+	limit, filter, scan, lookup := result[0], result[2], result[3], result[4]
+	if limit.HasEstimate || limit.ActualRows != 100 || limit.Loops != 1 || limit.Operation != "Limit: 100 row(s)" {
+		t.Errorf("Limit 节点 = %+v", limit)
+	}
+	if filter.Depth != 2 || filter.Operation != "Filter: (orders.status = 'pending')" || filter.EstimatedRows != 996 || filter.ActualRows != 48210 || filter.ActualTime != 11.432 {
+		t.Errorf("Filter 节点 = %+v", filter)
+	}
+	if scan.Cost != 1005.25 || scan.EstimatedRows != 9963 || scan.ActualRows != 100000 {
+		t.Errorf("Table scan 节点 = %+v", scan)
+	}
+	if lookup.Loops != 0 || !lookup.HasEstimate {
+		t.Errorf("未执行的节点 = %+v", lookup)
+	}
+	worst, ok := result.worstEstimate()
+	if !ok || worst.Operation != filter.Operation {
+		t.Errorf("估算偏差最大的应是 Filter 节点，实际 %+v, %v", worst, ok)
+	}
+}
+
+func TestCheckExplainAnalyze(t *testing.T) {
+	var runs int
+	prevRun, prevEnabled, prevTTL := runExplainAnalyze, explainAnalyze, explainCacheTTL
+	t.Cleanup(func() {
+		runExplainAnalyze, explainAnalyze, explainCacheTTL = prevRun, prevEnabled, prevTTL
+		explainAnalyzeCache.byHash = make(map[uint64]explainAnalyzeCacheEntry)
+	})
+	runExplainAnalyze = func(database, query string) (string, error) {
+		runs++
+		return sampleExplainAnalyze, nil
+	}
+	explainAnalyze, explainCacheTTL = true, time.Hour
+
+	entry := &SlowQueryEntry{Hash: 42, Database: "shop", SQL: "SELECT * FROM orders WHERE status = 'pending' ORDER BY created_at DESC LIMIT 100"}
+	checkExplainAnalyze(entry)
+	again := *entry
+	again.PlanAnalyze = nil
+	checkExplainAnalyze(&again)
+	if runs != 1 || len(again.PlanAnalyze) != 5 {
+		t.Errorf("缓存时间内同一指纹只应执行一次，实际 %d 次", runs)
+	}
+	for i, sql := range []string{
+		"DELETE FROM orders",
+		"WITH old AS (SELECT id FROM orders WHERE created_at < '2020-01-01') DELETE FROM orders WHERE id IN (SELECT id FROM old)",
+		"WITH RECURSIVE ids (n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM ids WHERE n < 10) UPDATE orders SET status = 'x' WHERE id IN (SELECT n FROM ids)",
+		"SELECT * FROM orders WHERE id = 1 FOR UPDATE",
+		"SELECT * FROM orders WHERE id = 1 FOR SHARE",
+		"SELECT * FROM orders WHERE id = 1 LOCK IN SHARE MODE",
+		"SELECT * FROM orders INTO OUTFILE '/tmp/orders.csv'",
+		"SELECT COUNT(*) INTO @total FROM orders",
+		"SELECT GET_LOCK('orders', 10)",
+		"SELECT SLEEP(5)",
+		"SELECT 1; DELETE FROM orders",
+	} {
+		checkExplainAnalyze(&SlowQueryEntry{Hash: uint64(100 + i), SQL: sql})
+		if runs != 1 {
+			t.Fatalf("不应对会修改数据、加锁或等待的语句执行 EXPLAIN ANALYZE: %s", sql)
+		}
+	}
+	for _, sql := range []string{
+		"WITH recent AS (SELECT * FROM orders WHERE created_at > '2024-01-01') SELECT COUNT(*) FROM recent",
+		"SELECT note FROM orders WHERE note = 'insert into orders for update';",
+	} {
+		if !isAnalyzable(sql) {
+			t.Errorf("只读查询应执行 EXPLAIN ANALYZE: %s", sql)
+		}
+	}
+
+	batch := &alertBatch{value: "shop", first: time.Now(), entries: []*SlowQueryEntry{{Hash: 44, SQL: "SELECT 1", Database: "shop"}, entry}}
+	for name, n := range map[string]*notification{
+		"单条告警": buildSlowQueryNotification(entry),
+		"合并告警": buildBatchNotification(batch, entry),
+	} {
+		text := renderWeChatMarkdown(n)
+		for _, want := range []string{"EXPLAIN ANALYZE", "Table scan on orders", "100000", "未执行", "统计信息可能已过期"} {
+			if !strings.Contains(text, want) {
+				t.Errorf("%s中缺少 %q:\n%s", name, want, text)
+			}
+		}
+	}
+}
+
+func TestConfigureExplainAnalyzeRequiresMySQL8(t *testing.T) {
+	prevEnabled, prevVersion := explainAnalyze, activeMySQLVersion
+	t.Cleanup(func() { explainAnalyze, activeMySQLVersion = prevEnabled, prevVersion })
+	explainAnalyze = true
+	for _, version := range []string{"", "5.7", "mariadb-10.x"} {
+		activeMySQLVersion = mysqlVersionProfiles[version]
+		if err := configureExplainAnalyze(); err == nil || !strings.Contains(err.Error(), "8.0") {
+			t.Errorf("--mysqlVersion=%q 时应返回错误: %v", version, err)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import "time"
+
+// 排序方式字段，写入磁盘时突出显示
+func filesortField(entry *SlowQueryEntry) notificationField {
+	if entry.FilesortOnDisk {
+		return notificationField{Label: "Filesort", Value: "⚠️ Yes（写入磁盘）", Highlight: true}
+	}
+	return notificationField{Label: "Filesort", Value: "Yes（内存中）"}
+}
+
+// 生成 filesort 提示，与慢查询告警区分
+func buildFilesortNotification(entry *SlowQueryEntry) *notification {
+	n := buildSlowQueryNotification(entry)
+	n.Title = "💾 Filesort 提示 (info)"
+	if entry.FilesortOnDisk {
+		n.Title = "💾 Filesort 写入磁盘 (info)"
+	}
+	return n
+}
+
+// 发送 filesort 提示，测试中可以替换为模拟实现
+var filesortNotifier = func(targets []webhookTarget, entry *SlowQueryEntry) (int, error) {
+	return deliverNotification(targets, buildFilesortNotification(entry))
+}
+
+// 检查并发送 filesort 提示，与阈值告警分别计算冷却时间
+func checkFilesort(entry *SlowQueryEntry) {
+	if shouldSuppressByCooldown(computeQueryHash("filesort:"+entry.Fingerprint), time.Now()) {
+		logf(levelDebug, "指纹 %x 的 filesort 提示处于冷却期内，不发送通知", entry.Hash)
+		return
+	}
+	logf(levelInfo, "检测到 filesort: 指纹 %x，写入磁盘: %v", entry.Hash, entry.FilesortOnDisk)
+	filesortNotifier(routeTargets(entry), entry)
+}
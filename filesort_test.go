@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestProcessSlowQueryFilesort(t *testing.T) {
+	var filesorts []*SlowQueryEntry
+	prevNotifier, prevThreshold, prevFlag := filesortNotifier, slowQueryThreshold, alertOnFilesort
+	t.Cleanup(func() { filesortNotifier, slowQueryThreshold, alertOnFilesort = prevNotifier, prevThreshold, prevFlag })
+	filesortNotifier = func(targets []webhookTarget, entry *SlowQueryEntry) (int, error) {
+		filesorts = append(filesorts, entry)
+		return 1, nil
+	}
+	slowQueryThreshold = 5
+
+	lines := fixtureLines(`
+# User@Host: app[app] @ localhost []  Id:    42
+# Query_time: 0.300000  Lock_time: 0.000000 Rows_sent: 100  Rows_examined: 200000
+# Filesort: Yes  Filesort_on_disk: Yes  Merge_passes: 4
+SELECT * FROM events ORDER BY created_at DESC LIMIT 100;`)
+	processSlowQuery(lines, nil)
+	if len(filesorts) != 0 {
+		t.Fatalf("未设置 --alertOnFilesort 时不应发送提示")
+	}
+
+	alertOnFilesort = true
+	processSlowQuery(lines, nil)
+	if len(filesorts) != 1 || !filesorts[0].Filesort || !filesorts[0].FilesortOnDisk {
+		t.Fatalf("filesorts = %+v", filesorts)
+	}
+	n := buildFilesortNotification(filesorts[0])
+	if n.Title != "💾 Filesort 写入磁盘 (info)" {
+		t.Errorf("Title = %q", n.Title)
+	}
+	var found bool
+	for _, f := range n.Fields {
+		found = found || f.Label == "Filesort" && f.Highlight
+	}
+	if !found {
+		t.Errorf("通知中缺少写入磁盘的 Filesort 字段: %+v", n.Fields)
+	}
+}
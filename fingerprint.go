@@ -0,0 +1,61 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// 正则表达式，用于把SQL归一化为指纹
+var (
+	stringLiteralPattern = regexp.MustCompile(`'(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*"`)
+	numberLiteralPattern = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	inListPattern        = regexp.MustCompile(`(?i)\bin\s*\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	whitespacePattern    = regexp.MustCompile(`\s+`)
+)
+
+// 将SQL归一化为指纹：替换字面量、折叠 IN 列表、合并空白并转为小写
+func normalizeQuery(sql string) string {
+	fingerprint := stringLiteralPattern.ReplaceAllString(sql, "?")
+	fingerprint = numberLiteralPattern.ReplaceAllString(fingerprint, "?")
+	fingerprint = inListPattern.ReplaceAllString(fingerprint, "in (?+)")
+	fingerprint = whitespacePattern.ReplaceAllString(fingerprint, " ")
+	// 去掉末尾所有的分号与空白，例如 "SELECT 1;;"
+	fingerprint = strings.TrimRight(fingerprint, "; \t\n")
+	return strings.ToLower(strings.TrimSpace(fingerprint))
+}
+
+// 计算归一化SQL的哈希值，作为去重的键
+func computeQueryHash(normalizedSQL string) uint64 {
+	return xxhash.Sum64String(normalizedSQL)
+}
+
+// 告警冷却缓存：指纹哈希 -> 最近一次告警时间
+var alertCooldownCache = struct {
+	sync.Mutex
+	lastAlert map[uint64]time.Time
+}{lastAlert: make(map[uint64]time.Time)}
+
+// 判断该指纹是否处于冷却期内，不在冷却期时记录本次告警时间
+func shouldSuppressByCooldown(hash uint64, now time.Time) bool {
+	if alertCooldown <= 0 {
+		return false
+	}
+
+	alertCooldownCache.Lock()
+	defer alertCooldownCache.Unlock()
+
+	if last, ok := alertCooldownCache.lastAlert[hash]; ok && now.Sub(last) < alertCooldown {
+		return true
+	}
+	for h, last := range alertCooldownCache.lastAlert {
+		if now.Sub(last) >= alertCooldown {
+			delete(alertCooldownCache.lastAlert, h)
+		}
+	}
+	alertCooldownCache.lastAlert[hash] = now
+	return false
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 生成一批互不相同的指纹，用于碰撞检测和基准测试
+func sampleFingerprints(n int) []string {
+	fingerprints := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		sql := fmt.Sprintf("SELECT id, name, col_%d FROM table_%d WHERE user_id = %d AND status = 'active' ORDER BY created_at DESC LIMIT 10;", i, i%97, i)
+		fingerprints = append(fingerprints, normalizeQuery(sql))
+	}
+	return fingerprints
+}
+
+func TestNormalizeQuery(t *testing.T) {
+	cases := []struct {
+		sql  string
+		want string
+	}{
+		{"SELECT * FROM users WHERE id = 42;", "select * from users where id = ?"},
+		{"select *   from users\twhere name = 'bob' and age > 3.5", "select * from users where name = ? and age > ?"},
+		{"SELECT * FROM t WHERE id IN (1, 2, 3);", "select * from t where id in (?+)"},
+		{`UPDATE t SET v = "it\"s" WHERE id = 7;`, "update t set v = ? where id = ?"},
+	}
+	for _, c := range cases {
+		if got := normalizeQuery(c.sql); got != c.want {
+			t.Errorf("normalizeQuery(%q) = %q, want %q", c.sql, got, c.want)
+		}
+	}
+
+	if normalizeQuery("SELECT * FROM users WHERE id = 1;") != normalizeQuery("select * from users where id = 999") {
+		t.Error("只有字面量不同的SQL应该得到相同的指纹")
+	}
+}
+
+func TestComputeQueryHashNoCollision(t *testing.T) {
+	fingerprints := sampleFingerprints(50000)
+
+	byHash := make(map[uint64]string, len(fingerprints))
+	bySHA := make(map[[sha256.Size]byte]string, len(fingerprints))
+	for _, fp := range fingerprints {
+		sum := sha256.Sum256([]byte(fp))
+		if prev, ok := bySHA[sum]; ok {
+			if prev != fp {
+				t.Fatalf("SHA-256 碰撞: %q 与 %q", prev, fp)
+			}
+			continue
+		}
+		bySHA[sum] = fp
+
+		hash := computeQueryHash(fp)
+		if prev, ok := byHash[hash]; ok && prev != fp {
+			t.Fatalf("指纹哈希碰撞: %q 与 %q 得到相同的哈希 %d", prev, fp, hash)
+		}
+		byHash[hash] = fp
+	}
+
+	if len(byHash) != len(bySHA) {
+		t.Fatalf("不同指纹数量不一致: xxhash %d, sha256 %d", len(byHash), len(bySHA))
+	}
+}
+
+func TestComputeQueryHashStable(t *testing.T) {
+	fp := normalizeQuery("SELECT * FROM orders WHERE id = 1;")
+	if computeQueryHash(fp) != computeQueryHash(strings.Clone(fp)) {
+		t.Fatal("相同指纹的哈希值必须一致")
+	}
+}
+
+func TestShouldSuppressByCooldownPrunes(t *testing.T) {
+	prevCooldown := alertCooldown
+	t.Cleanup(func() {
+		alertCooldown = prevCooldown
+		alertCooldownCache.lastAlert = make(map[uint64]time.Time)
+	})
+	alertCooldown = 10 * time.Minute
+	alertCooldownCache.lastAlert = make(map[uint64]time.Time)
+
+	now := time.Now()
+	if shouldSuppressByCooldown(1, now.Add(-time.Hour)) || shouldSuppressByCooldown(2, now.Add(-time.Minute)) {
+		t.Fatal("首次出现的指纹不应被抑制")
+	}
+	if !shouldSuppressByCooldown(2, now) {
+		t.Error("冷却期内的指纹应被抑制")
+	}
+	shouldSuppressByCooldown(3, now)
+	// 已过冷却期的指纹在写入时清理，缓存不会随指纹数无限增长
+	if _, ok := alertCooldownCache.lastAlert[1]; ok || len(alertCooldownCache.lastAlert) != 2 {
+		t.Errorf("lastAlert = %v", alertCooldownCache.lastAlert)
+	}
+}
+
+func BenchmarkCooldownStringKey(b *testing.B) {
+	fingerprints := sampleFingerprints(1000)
+	cache := make(map[string]int64, len(fingerprints))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fp := fingerprints[i%len(fingerprints)]
+		cache[fp]++
+	}
+}
+
+func BenchmarkCooldownHashKey(b *testing.B) {
+	fingerprints := sampleFingerprints(1000)
+	cache := make(map[uint64]int64, len(fingerprints))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// 与 shouldSuppressByCooldown 的调用路径一致，每次都计算哈希
+		cache[computeQueryHash(fingerprints[i%len(fingerprints)])]++
+	}
+}
+
+func BenchmarkComputeQueryHash(b *testing.B) {
+	fp := sampleFingerprints(1)[0]
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		computeQueryHash(fp)
+	}
+}
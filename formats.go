@@ -0,0 +1,573 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Webhook 消息格式
+type webhookFormat struct {
+	Name            string
+	MaxPayloadBytes int                          // 平台允许的最大请求体字节数
+	Render          func(n *notification) string // 渲染为该平台的消息文本
+	Payload         func(title, text string) any // 根据标题和消息文本构建请求体
+
+	ValidateResponse func(body []byte) error // 检查 2xx 响应的返回内容，nil 时按JSON中的 errcode 与 ok 判断
+}
+
+// 支持的消息格式
+var webhookFormats = map[string]*webhookFormat{
+	"wechat": {
+		Name:            "wechat",
+		MaxPayloadBytes: 4096,
+		Render:          renderWeChatMarkdown,
+		Payload: func(title, text string) any {
+			return map[string]any{"msgtype": "markdown", "markdown": map[string]any{"content": text}}
+		},
+		ValidateResponse: validateJSONResponse,
+	},
+	"slack": {
+		Name:            "slack",
+		MaxPayloadBytes: 3000,
+		Render:          renderSlackMarkdown,
+		Payload: func(title, text string) any {
+			return map[string]any{
+				"text":   title,
+				"blocks": []any{map[string]any{"type": "section", "text": map[string]any{"type": "mrkdwn", "text": text}}},
+			}
+		},
+		ValidateResponse: validateSlackResponse,
+	},
+	"teams": {
+		Name:            "teams",
+		MaxPayloadBytes: 28 * 1024,
+		Render:          renderTeamsMarkdown,
+		Payload: func(title, text string) any {
+			return map[string]any{
+				"@type":      "MessageCard",
+				"@context":   "https://schema.org/extensions",
+				"summary":    title,
+				"themeColor": "FFA500",
+				"text":       text,
+			}
+		},
+		ValidateResponse: validateTeamsResponse,
+	},
+	"feishu": {
+		Name:            "feishu",
+		MaxPayloadBytes: 20 * 1024,
+		Render:          renderFeishuMarkdown,
+		Payload: func(title, text string) any {
+			return map[string]any{
+				"msg_type": "interactive",
+				"card": map[string]any{
+					"header":   map[string]any{"template": "orange", "title": map[string]any{"tag": "plain_text", "content": title}},
+					"elements": []any{map[string]any{"tag": "markdown", "content": text}},
+				},
+			}
+		},
+		ValidateResponse: validateFeishuResponse,
+	},
+	"generic": {
+		Name:            "generic",
+		MaxPayloadBytes: 64 * 1024,
+		Render:          renderPlainText,
+		Payload: func(title, text string) any {
+			return map[string]any{"title": title, "text": text}
+		},
+	},
+}
+
+// 支持的消息格式名称
+func webhookFormatNames() []string {
+	names := make([]string, 0, len(webhookFormats))
+	for name := range webhookFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 根据名称查找消息格式
+func lookupWebhookFormat(name string) (*webhookFormat, error) {
+	format, ok := webhookFormats[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return nil, fmt.Errorf("未知的消息格式 %q，可选值: %s", name, strings.Join(webhookFormatNames(), ", "))
+	}
+	return format, nil
+}
+
+// 原始日志上下文的标题
+const contextTitle = "原始日志上下文 (raw context)"
+
+// 执行计划变化的标题
+const planChangeTitle = "⚠️ 执行计划已变化 (Plan changed!)"
+
+// 渲染为 markdown 表格
+func markdownTable(t notificationTable) string {
+	var b strings.Builder
+	row := func(cells []string) {
+		b.WriteString("|")
+		for _, cell := range cells {
+			b.WriteString(" " + strings.ReplaceAll(cell, "|", `\|`) + " |")
+		}
+		b.WriteString("\n")
+	}
+	row(t.Columns)
+	separator := make([]string, len(t.Columns))
+	for i := range separator {
+		separator[i] = "---"
+	}
+	row(separator)
+	for _, r := range t.Rows {
+		row(r)
+	}
+	return b.String()
+}
+
+// 字符串的显示宽度，中日韩字符按两个字符宽度计算
+func displayWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		if unicode.In(r, unicode.Han, unicode.Hangul, unicode.Hiragana, unicode.Katakana) {
+			width += 2
+		} else {
+			width++
+		}
+	}
+	return width
+}
+
+// 渲染为等宽字体下对齐的文本表格，用于不支持 markdown 表格的平台
+func alignedTable(t notificationTable) string {
+	widths := make([]int, len(t.Columns))
+	for _, r := range append([][]string{t.Columns}, t.Rows...) {
+		for i, cell := range r {
+			if i < len(widths) && displayWidth(cell) > widths[i] {
+				widths[i] = displayWidth(cell)
+			}
+		}
+	}
+	var b strings.Builder
+	for _, r := range append([][]string{t.Columns}, t.Rows...) {
+		for i, cell := range r {
+			if i == len(r)-1 {
+				b.WriteString(cell)
+				break
+			}
+			b.WriteString(cell + strings.Repeat(" ", widths[i]-displayWidth(cell)+2))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// 企业微信 markdown
+func renderWeChatMarkdown(n *notification) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<font color="warning">**%s**</font>`+"\n", n.Title)
+	for _, f := range n.Fields {
+		color := "comment"
+		if f.Highlight {
+			color = "warning"
+		}
+		fmt.Fprintf(&b, `> **%s:** <font color="%s">%s</font>`+"\n", f.Label, color, f.Value)
+	}
+	for _, t := range n.Tables {
+		fmt.Fprintf(&b, "\n**%s**\n%s", t.Title, markdownTable(t))
+	}
+	if n.SQL != "" {
+		fmt.Fprintf(&b, `> **%s:** <font color="comment">%s</font>`+"\n", n.sqlLabel(), n.SQL)
+	}
+	if len(n.Plan) > 0 {
+		fmt.Fprintf(&b, "> **%s**\n", planChangeTitle)
+		for _, f := range n.Plan {
+			fmt.Fprintf(&b, `> **%s:** <font color="warning">%s</font>`+"\n", f.Label, f.Value)
+		}
+	}
+	if len(n.Context) > 0 {
+		fmt.Fprintf(&b, "> **%s:**\n", contextTitle)
+		for _, line := range n.Context {
+			fmt.Fprintf(&b, "`%s`\n", line)
+		}
+	}
+	if len(n.Labels) > 0 {
+		fmt.Fprintf(&b, `> **🏷️ Tags:** <font color="comment">%s</font>`+"\n", formatLabels(n.Labels))
+	}
+	for _, note := range n.Notes {
+		fmt.Fprintf(&b, `<font color="comment">%s</font>`+"\n", note)
+	}
+	return b.String()
+}
+
+// Slack mrkdwn
+func renderSlackMarkdown(n *notification) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, ":warning: *%s*\n", n.Title)
+	for _, f := range n.Fields {
+		fmt.Fprintf(&b, "*%s:* %s\n", f.Label, f.Value)
+	}
+	for _, t := range n.Tables {
+		fmt.Fprintf(&b, "*%s:*\n```%s```\n", t.Title, alignedTable(t))
+	}
+	if n.SQL != "" {
+		fmt.Fprintf(&b, "*%s:*\n```%s```\n", n.sqlLabel(), n.SQL)
+	}
+	if len(n.Plan) > 0 {
+		fmt.Fprintf(&b, "*%s*\n", planChangeTitle)
+		for _, f := range n.Plan {
+			fmt.Fprintf(&b, "*%s:* %s\n", f.Label, f.Value)
+		}
+	}
+	if len(n.Context) > 0 {
+		fmt.Fprintf(&b, "*%s:*\n```%s```\n", contextTitle, strings.Join(n.Context, "\n"))
+	}
+	if len(n.Labels) > 0 {
+		fmt.Fprintf(&b, "*🏷️ Tags:* %s\n", formatLabels(n.Labels))
+	}
+	for _, note := range n.Notes {
+		fmt.Fprintf(&b, "_%s_\n", note)
+	}
+	return b.String()
+}
+
+// Teams MessageCard markdown，换行需要空行分隔
+func renderTeamsMarkdown(n *notification) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "**%s**\n\n", n.Title)
+	for _, f := range n.Fields {
+		fmt.Fprintf(&b, "**%s:** %s\n\n", f.Label, f.Value)
+	}
+	for _, t := range n.Tables {
+		fmt.Fprintf(&b, "**%s:**\n\n%s\n", t.Title, markdownTable(t))
+	}
+	if n.SQL != "" {
+		fmt.Fprintf(&b, "**%s:**\n\n```\n%s\n```\n", n.sqlLabel(), n.SQL)
+	}
+	if len(n.Plan) > 0 {
+		fmt.Fprintf(&b, "\n**%s**\n\n", planChangeTitle)
+		for _, f := range n.Plan {
+			fmt.Fprintf(&b, "**%s:** %s\n\n", f.Label, f.Value)
+		}
+	}
+	if len(n.Context) > 0 {
+		fmt.Fprintf(&b, "\n**%s:**\n\n```\n%s\n```\n", contextTitle, strings.Join(n.Context, "\n"))
+	}
+	if len(n.Labels) > 0 {
+		fmt.Fprintf(&b, "\n**🏷️ Tags:** %s\n", formatLabels(n.Labels))
+	}
+	for _, note := range n.Notes {
+		fmt.Fprintf(&b, "\n_%s_\n", note)
+	}
+	return b.String()
+}
+
+// 飞书卡片 markdown
+func renderFeishuMarkdown(n *notification) string {
+	var b strings.Builder
+	for _, f := range n.Fields {
+		fmt.Fprintf(&b, "**%s:** %s\n", f.Label, f.Value)
+	}
+	for _, t := range n.Tables {
+		fmt.Fprintf(&b, "**%s:**\n%s", t.Title, markdownTable(t))
+	}
+	if n.SQL != "" {
+		fmt.Fprintf(&b, "**%s:**\n```sql\n%s\n```\n", n.sqlLabel(), n.SQL)
+	}
+	if len(n.Plan) > 0 {
+		fmt.Fprintf(&b, "**%s**\n", planChangeTitle)
+		for _, f := range n.Plan {
+			fmt.Fprintf(&b, "**%s:** %s\n", f.Label, f.Value)
+		}
+	}
+	if len(n.Context) > 0 {
+		fmt.Fprintf(&b, "**%s:**\n```\n%s\n```\n", contextTitle, strings.Join(n.Context, "\n"))
+	}
+	if len(n.Labels) > 0 {
+		fmt.Fprintf(&b, "**🏷️ Tags:** %s\n", formatLabels(n.Labels))
+	}
+	for _, note := range n.Notes {
+		fmt.Fprintf(&b, "<font color='grey'>%s</font>\n", note)
+	}
+	return b.String()
+}
+
+// 纯文本
+func renderPlainText(n *notification) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", n.Title)
+	for _, f := range n.Fields {
+		fmt.Fprintf(&b, "%s: %s\n", f.Label, f.Value)
+	}
+	for _, t := range n.Tables {
+		fmt.Fprintf(&b, "%s:\n%s", t.Title, alignedTable(t))
+	}
+	if n.SQL != "" {
+		fmt.Fprintf(&b, "%s: %s\n", n.sqlLabel(), n.SQL)
+	}
+	if len(n.Plan) > 0 {
+		fmt.Fprintf(&b, "%s\n", planChangeTitle)
+		for _, f := range n.Plan {
+			fmt.Fprintf(&b, "%s: %s\n", f.Label, f.Value)
+		}
+	}
+	if len(n.Context) > 0 {
+		fmt.Fprintf(&b, "%s:\n", contextTitle)
+		for _, line := range n.Context {
+			fmt.Fprintf(&b, "    %s\n", line)
+		}
+	}
+	if len(n.Labels) > 0 {
+		fmt.Fprintf(&b, "🏷️ Tags: %s\n", formatLabels(n.Labels))
+	}
+	for _, note := range n.Notes {
+		fmt.Fprintf(&b, "%s\n", note)
+	}
+	return b.String()
+}
+
+// 渲染并序列化一条通知
+func renderPayload(format *webhookFormat, n *notification) (string, error) {
+	return marshalPayload(format.Payload(n.Title, renderText(format, n)))
+}
+
+// 返回生效的请求体大小上限，--maxPayloadBytes 未设置时使用格式的默认值
+func payloadLimit(format *webhookFormat) int {
+	if maxPayloadBytes > 0 {
+		return maxPayloadBytes
+	}
+	return format.MaxPayloadBytes
+}
+
+// 将通知渲染为一个或多个请求体：超过大小限制时，第一条只包含元数据，
+// 放不下的表格与 SQL 拆分到后续消息中
+func buildPayloads(format *webhookFormat, n *notification) ([]string, error) {
+	limit := payloadLimit(format)
+	payload, err := renderPayload(format, n)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) <= limit {
+		return []string{payload}, nil
+	}
+
+	metadata := &notification{
+		Title:   n.Title,
+		Fields:  slices.Clone(n.Fields),
+		Labels:  n.Labels,
+		Context: n.Context,
+		Plan:    n.Plan,
+		Tables:  n.Tables,
+		Notes:   slices.Clone(n.Notes),
+
+		SQLLabel:    n.SQLLabel,
+		Severity:    n.Severity,
+		Fingerprint: n.Fingerprint,
+	}
+	if n.SQL != "" {
+		metadata.Fields = append(metadata.Fields, notificationField{Label: n.sqlLabel(), Value: "内容过长，见后续消息"})
+	}
+	first, err := renderPayload(format, metadata)
+	if err != nil {
+		return nil, err
+	}
+	var tables []string
+	if len(first) > limit && len(n.Tables) > 0 {
+		var dropped []string
+		tables, dropped, err = splitTablePayloads(format, n, limit)
+		if err != nil {
+			return nil, err
+		}
+		metadata.Tables = nil
+		for _, title := range dropped {
+			metadata.Notes = append(metadata.Notes, fmt.Sprintf("* 表格「%s」中的单行内容超过消息大小上限，已省略", title))
+		}
+		if first, err = renderPayload(format, metadata); err != nil {
+			return nil, err
+		}
+	}
+	payloads := append([]string{first}, tables...)
+
+	// 去掉表格后仍然超过上限时，把日志上下文与脚注也拆分到单独的消息中
+	if len(first) > limit && len(metadata.Context) > 0 {
+		parts, err := splitLinePayloads(format, limit, metadata.Context, func(title string, lines []string) *notification {
+			return &notification{Title: n.Title + " - 日志上下文" + title, Context: lines, Severity: n.Severity}
+		})
+		if err != nil {
+			return nil, err
+		}
+		metadata.Context = nil
+		if first, err = renderPayload(format, metadata); err != nil {
+			return nil, err
+		}
+		payloads = append(append([]string{first}, tables...), parts...)
+	}
+	if len(first) > limit && len(metadata.Notes) > 0 {
+		parts, err := splitLinePayloads(format, limit, metadata.Notes, func(title string, lines []string) *notification {
+			return &notification{Title: n.Title + " - 说明" + title, Notes: lines, Severity: n.Severity}
+		})
+		if err != nil {
+			return nil, err
+		}
+		metadata.Notes = nil
+		if first, err = renderPayload(format, metadata); err != nil {
+			return nil, err
+		}
+		payloads = append(append([]string{first}, payloads[1:]...), parts...)
+	}
+	if len(first) > limit {
+		logf(levelWarn, "告警的字段共 %d 字节，超过消息大小上限 %d 字节，平台可能拒绝该消息", len(first), limit)
+	}
+
+	if n.SQL != "" {
+		parts, err := splitSQLPayloads(format, n, limit)
+		if err != nil {
+			return nil, err
+		}
+		payloads = append(payloads, parts...)
+	}
+	logf(levelDebug, "通知内容 %d 字节超过上限 %d 字节，已拆分为 %d 条消息", len(payload), limit, len(payloads))
+	return payloads, nil
+}
+
+// 把表格拆分为多条不超过大小限制的消息，行数过多时按行拆分；单行也放不下的表格省略，返回其标题
+func splitTablePayloads(format *webhookFormat, n *notification, limit int) ([]string, []string, error) {
+	var payloads, dropped []string
+	for _, table := range n.Tables {
+		rowsPerPart := max(len(table.Rows), 1)
+		for {
+			chunks := slices.Collect(slices.Chunk(table.Rows, rowsPerPart))
+			if len(chunks) == 0 {
+				chunks = [][][]string{nil}
+			}
+			parts := make([]string, 0, len(chunks))
+			fits := true
+			for i, rows := range chunks {
+				title := fmt.Sprintf("%s - %s", n.Title, table.Title)
+				if len(chunks) > 1 {
+					title += fmt.Sprintf(" (%d/%d)", i+1, len(chunks))
+				}
+				part := &notification{Title: title, Tables: []notificationTable{{Title: table.Title, Columns: table.Columns, Rows: rows}}, Severity: n.Severity}
+				payload, err := renderPayload(format, part)
+				if err != nil {
+					return nil, nil, err
+				}
+				if len(payload) > limit {
+					fits = false
+					break
+				}
+				parts = append(parts, payload)
+			}
+			if fits {
+				payloads = append(payloads, parts...)
+				break
+			}
+			if rowsPerPart <= 1 {
+				dropped = append(dropped, table.Title)
+				break
+			}
+			rowsPerPart /= 2
+		}
+	}
+	return payloads, dropped, nil
+}
+
+// 把日志上下文、脚注等多行内容拆分为多条不超过大小限制的消息，build 生成标题后缀为 title 的消息
+// 单行也放不下时截断过长的行
+func splitLinePayloads(format *webhookFormat, limit int, lines []string, build func(title string, lines []string) *notification) ([]string, error) {
+	render := func(lines []string, part, total int) (string, error) {
+		var title string
+		if total > 1 {
+			title = fmt.Sprintf(" (%d/%d)", part, total)
+		}
+		return renderPayload(format, build(title, lines))
+	}
+	for linesPerPart := len(lines); ; linesPerPart /= 2 {
+		chunks := slices.Collect(slices.Chunk(lines, linesPerPart))
+		payloads := make([]string, 0, len(chunks))
+		for i, chunk := range chunks {
+			payload, err := render(chunk, i+1, len(chunks))
+			if err != nil {
+				return nil, err
+			}
+			if len(payload) > limit {
+				break
+			}
+			payloads = append(payloads, payload)
+		}
+		if len(payloads) == len(chunks) {
+			return payloads, nil
+		}
+		if linesPerPart <= 1 {
+			break
+		}
+	}
+
+	truncated := make([]string, len(lines))
+	for i, line := range lines {
+		truncated[i] = line
+		for runes := []rune(line); ; runes = runes[:len(runes)/2] {
+			payload, err := render([]string{truncated[i]}, len(lines), len(lines))
+			if err != nil {
+				return nil, err
+			}
+			if len(payload) <= limit {
+				break
+			}
+			if len(runes) <= 1 {
+				return nil, fmt.Errorf("请求体大小上限 %d 字节过小，无法容纳消息", limit)
+			}
+			truncated[i] = string(runes[:len(runes)/2]) + "…"
+		}
+	}
+	return splitLinePayloads(format, limit, truncated, build)
+}
+
+// 把 SQL 拆分为多条不超过大小限制的消息
+func splitSQLPayloads(format *webhookFormat, n *notification, limit int) ([]string, error) {
+	sql := n.SQL
+	chunkRunes := utf8.RuneCountInString(sql)
+	for {
+		chunks := splitRunes(sql, chunkRunes)
+		payloads := make([]string, 0, len(chunks))
+		fits := true
+		for i, chunk := range chunks {
+			part := &notification{Title: fmt.Sprintf("%s - SQL (%d/%d)", n.Title, i+1, len(chunks)), SQL: chunk, SQLLabel: n.SQLLabel, Severity: n.Severity}
+			payload, err := renderPayload(format, part)
+			if err != nil {
+				return nil, err
+			}
+			if len(payload) > limit {
+				fits = false
+				break
+			}
+			payloads = append(payloads, payload)
+		}
+		if fits {
+			return payloads, nil
+		}
+		if chunkRunes <= 1 {
+			return nil, fmt.Errorf("请求体大小上限 %d 字节过小，无法容纳消息", limit)
+		}
+		chunkRunes /= 2
+	}
+}
+
+// 按字符数拆分字符串，不截断多字节字符
+func splitRunes(s string, size int) []string {
+	runes := []rune(s)
+	var chunks []string
+	for len(runes) > 0 {
+		n := size
+		if n > len(runes) {
+			n = len(runes)
+		}
+		chunks = append(chunks, string(runes[:n]))
+		runes = runes[n:]
+	}
+	return chunks
+}
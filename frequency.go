@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 统计执行次数的窗口长度，以秒为单位分桶
+const frequencyWindowSeconds = 60
+
+// 一分钟滑动窗口内的执行次数与查询时间总和
+type frequencyWindow struct {
+	seconds   [frequencyWindowSeconds]int64 // 每个桶对应的 Unix 秒
+	counts    [frequencyWindowSeconds]int
+	totals    [frequencyWindowSeconds]float64
+	lastAlert time.Time // 上一次发送高频告警的时间，一个窗口内只告警一次
+}
+
+// 记录一次执行，返回最近一分钟内的执行次数与查询时间总和
+func (w *frequencyWindow) add(t time.Time, queryTime float64) (int, float64) {
+	sec := t.Unix()
+	i := sec % frequencyWindowSeconds
+	if w.seconds[i] != sec {
+		w.seconds[i], w.counts[i], w.totals[i] = sec, 0, 0
+	}
+	w.counts[i]++
+	w.totals[i] += queryTime
+
+	var count int
+	var total float64
+	for j := range w.seconds {
+		if sec-w.seconds[j] < frequencyWindowSeconds {
+			count += w.counts[j]
+			total += w.totals[j]
+		}
+	}
+	return count, total
+}
+
+// 按指纹统计的执行次数
+var frequencyWindows = struct {
+	sync.Mutex
+	byHash map[uint64]*frequencyWindow
+}{byHash: make(map[uint64]*frequencyWindow)}
+
+// 一次高频告警的统计信息
+type frequencyFinding struct {
+	RPM       int     // 最近一分钟的执行次数
+	TotalTime float64 // 最近一分钟的查询时间总和，单位：秒
+}
+
+// 记录一次执行，超过 --fingerprintRPM 时返回统计信息，同一指纹一分钟内只返回一次
+func detectHighFrequency(entry *SlowQueryEntry) (frequencyFinding, bool) {
+	at := queryStartTime(entry)
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	frequencyWindows.Lock()
+	defer frequencyWindows.Unlock()
+	w, ok := frequencyWindows.byHash[entry.Hash]
+	if !ok {
+		w = &frequencyWindow{}
+		frequencyWindows.byHash[entry.Hash] = w
+	}
+	count, total := w.add(at, entry.QueryTime)
+	if count <= fingerprintRPM || at.Sub(w.lastAlert) < frequencyWindowSeconds*time.Second {
+		return frequencyFinding{}, false
+	}
+	w.lastAlert = at
+	return frequencyFinding{RPM: count, TotalTime: total}, true
+}
+
+// 生成高频慢查询告警
+func buildFrequencyNotification(entry *SlowQueryEntry, finding frequencyFinding) *notification {
+	return &notification{
+		Title: "高频慢查询",
+		Fields: []notificationField{
+			{Label: "SQL指纹", Value: entry.Fingerprint},
+			{Label: "每分钟执行次数", Value: fmt.Sprintf("%d 次", finding.RPM), Highlight: true},
+			{Label: "告警阈值", Value: fmt.Sprintf("%d 次/分钟", fingerprintRPM)},
+			{Label: "平均查询时间", Value: fmt.Sprintf("%.3f 秒", finding.TotalTime/float64(finding.RPM))},
+			{Label: "最近一分钟总耗时", Value: fmt.Sprintf("%.2f 秒", finding.TotalTime), Highlight: true},
+			{Label: "数据库", Value: entry.Database},
+			{Label: "用户", Value: entry.User},
+			{Label: "告警时间", Value: formatDisplayTime(time.Now())},
+		},
+		SQL:    entry.SQL,
+		Labels: entryLabels(entry),
+	}
+}
+
+// 发送高频慢查询告警，测试中可以替换为模拟实现
+var frequencyNotifier = func(targets []webhookTarget, entry *SlowQueryEntry, finding frequencyFinding) (int, error) {
+	return deliverNotification(targets, buildFrequencyNotification(entry, finding))
+}
+
+// 检查并发送高频慢查询告警
+func checkHighFrequency(entry *SlowQueryEntry) {
+	finding, ok := detectHighFrequency(entry)
+	if !ok {
+		return
+	}
+	logf(levelInfo, "检测到高频慢查询: 指纹 %x 最近一分钟执行 %d 次", entry.Hash, finding.RPM)
+	frequencyNotifier(routeTargets(entry), entry, finding)
+}
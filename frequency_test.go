@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFrequencyWindow(t *testing.T) {
+	var w frequencyWindow
+	start := time.Unix(1700000000, 0)
+	for i := 0; i < 30; i++ {
+		w.add(start.Add(time.Duration(i)*time.Second), 0.5)
+	}
+	count, total := w.add(start.Add(30*time.Second), 0.5)
+	if count != 31 || total != 15.5 {
+		t.Errorf("add = %d, %v, want 31, 15.5", count, total)
+	}
+
+	// 一分钟之前的执行不再计入
+	count, total = w.add(start.Add(75*time.Second), 1)
+	if count != 16 || total != 8.5 {
+		t.Errorf("add = %d, %v, want 16, 8.5", count, total)
+	}
+}
+
+func TestDetectHighFrequency(t *testing.T) {
+	prev := fingerprintRPM
+	t.Cleanup(func() {
+		fingerprintRPM = prev
+		frequencyWindows.byHash = make(map[uint64]*frequencyWindow)
+	})
+	fingerprintRPM = 10
+
+	start := time.Unix(1700000000, 0)
+	var rpms []int
+	for i := 0; i < 100; i++ {
+		entry := &SlowQueryEntry{Hash: 42, QueryTime: 0.2, Timestamp: start.Add(time.Duration(i) * time.Second)}
+		if finding, ok := detectHighFrequency(entry); ok {
+			rpms = append(rpms, finding.RPM)
+		}
+	}
+	// 第 11 次执行时告警，之后一分钟内不重复告警，第 71 次执行时再次告警
+	if len(rpms) != 2 || rpms[0] != 11 || rpms[1] != 60 {
+		t.Errorf("告警时的 RPM = %v, want [11 60]", rpms)
+	}
+}
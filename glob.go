@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 判断 --slowLogFile 是否为通配符，例如 /var/log/mysql/mysql-slow.log*
+func isGlobPattern(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// 通配符模式下的文件读取状态
+var globFiles = struct {
+	sync.Mutex
+	tailing   map[string]uint64 // 正在读取的路径 -> 该路径当前文件的 inode
+	processed map[uint64]bool   // 已轮转改名、内容已读取过的文件 inode
+}{tailing: make(map[string]uint64), processed: make(map[uint64]bool)}
+
+// 定期按通配符查找日志文件，为新发现的文件启动读取协程
+func watchGlobSlowLogs(pattern string, interval time.Duration) {
+	scanGlobSlowLogs(pattern, true)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		scanGlobSlowLogs(pattern, false)
+	}
+}
+
+// 查找一次匹配的日志文件，initial 为 true 时表示启动时的第一次查找
+func scanGlobSlowLogs(pattern string, initial bool) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		logf(levelError, "慢查询日志通配符 %s 无效: %v", pattern, err)
+		return
+	}
+
+	globFiles.Lock()
+	defer globFiles.Unlock()
+
+	// 正在读取的路径对应的文件发生变化，说明原文件已被轮转改名，其内容已经读取过
+	active := make(map[uint64]bool, len(globFiles.tailing))
+	for path, ino := range globFiles.tailing {
+		if info, err := os.Stat(path); err == nil {
+			if current, ok := fileInode(info); ok && current != ino {
+				globFiles.processed[ino] = true
+				globFiles.tailing[path] = current
+				ino = current
+			}
+		}
+		active[ino] = true
+	}
+
+	existing := make(map[uint64]bool, len(matches))
+	for _, path := range matches {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		ino, hasInode := fileInode(info)
+		if hasInode {
+			existing[ino] = true
+		}
+		if _, ok := globFiles.tailing[path]; ok {
+			continue
+		}
+		if hasInode && (globFiles.processed[ino] || active[ino]) {
+			continue
+		}
+
+		globFiles.tailing[path] = ino
+		active[ino] = true
+		logf(levelInfo, "开始读取慢查询日志文件: %s", path)
+		go watchSlowLog(tailJob{path: path, firstRun: initial, fromStart: !initial})
+	}
+
+	// 文件被删除后 inode 可能被新文件复用，不再保留已不存在的记录
+	for ino := range globFiles.processed {
+		if !existing[ino] {
+			delete(globFiles.processed, ino)
+		}
+	}
+}
+
+// 通配符模式下文件被删除后停止读取，返回是否应继续读取该文件
+func keepTailingGlobFile(path string) bool {
+	if _, err := os.Stat(path); err == nil {
+		return true
+	}
+	globFiles.Lock()
+	delete(globFiles.tailing, path)
+	globFiles.Unlock()
+	logf(levelInfo, "慢查询日志文件 %s 已不存在，停止读取", path)
+	return false
+}
+
+// 返回已读取过的轮转文件 inode，用于保存到状态文件
+func processedGlobInodes() []uint64 {
+	globFiles.Lock()
+	defer globFiles.Unlock()
+	inodes := make([]uint64, 0, len(globFiles.processed))
+	for ino := range globFiles.processed {
+		inodes = append(inodes, ino)
+	}
+	return inodes
+}
+
+// 从状态文件恢复已读取过的轮转文件 inode
+func restoreGlobInodes(inodes []uint64) {
+	globFiles.Lock()
+	defer globFiles.Unlock()
+	for _, ino := range inodes {
+		globFiles.processed[ino] = true
+	}
+}
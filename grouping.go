@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 一个分组窗口内同一指纹的告警
+type alertGroup struct {
+	first, last time.Time
+	count       int
+	minTime     float64
+	maxTime     float64
+	totalTime   float64
+	clients     map[string]bool // 执行过该查询的 user@host
+	slowest     *SlowQueryEntry
+}
+
+// 正在等待窗口结束的分组，按指纹哈希索引
+var alertGroups = struct {
+	sync.Mutex
+	byHash map[uint64]*alertGroup
+}{byHash: make(map[uint64]*alertGroup)}
+
+// 把告警加入分组，指纹的第一条告警开始一个 --groupingWindow 窗口，窗口结束时发送
+func addToAlertGroup(entry *SlowQueryEntry) {
+	now := time.Now()
+	alertGroups.Lock()
+	defer alertGroups.Unlock()
+
+	g, ok := alertGroups.byHash[entry.Hash]
+	if !ok {
+		g = &alertGroup{first: now, minTime: entry.QueryTime, clients: make(map[string]bool)}
+		alertGroups.byHash[entry.Hash] = g
+		hash := entry.Hash
+		time.AfterFunc(groupingWindow, func() { flushAlertGroup(hash) })
+	}
+	g.last = now
+	g.count++
+	g.totalTime += entry.QueryTime
+	if entry.QueryTime < g.minTime {
+		g.minTime = entry.QueryTime
+	}
+	if entry.QueryTime > g.maxTime {
+		g.maxTime = entry.QueryTime
+	}
+	g.clients[entry.User+"@"+entry.Host] = true
+	if g.slowest == nil || entry.QueryTime > g.slowest.QueryTime {
+		g.slowest = entry
+	}
+}
+
+// 窗口结束，发送分组告警；窗口内只有一条时发送普通告警
+func flushAlertGroup(hash uint64) {
+	alertGroups.Lock()
+	g := alertGroups.byHash[hash]
+	delete(alertGroups.byHash, hash)
+	alertGroups.Unlock()
+	if g == nil {
+		return
+	}
+
+	if shouldSuppressByCooldown(hash, time.Now()) {
+		logf(levelDebug, "指纹 %x 处于冷却期内，不发送分组告警", hash)
+		return
+	}
+	prepareAlert(g.slowest)
+	if g.count == 1 {
+		alertNotifier(routeTargets(g.slowest), g.slowest)
+		return
+	}
+	logf(levelInfo, "发送分组告警: 指纹 %x 在窗口内出现 %d 次", hash, g.count)
+	sendEntryNotification(routeTargets(g.slowest), g.slowest, buildGroupNotification(g))
+}
+
+// 退出前发送所有尚未结束窗口的分组
+func flushAllAlertGroups() {
+	alertGroups.Lock()
+	hashes := make([]uint64, 0, len(alertGroups.byHash))
+	for hash := range alertGroups.byHash {
+		hashes = append(hashes, hash)
+	}
+	alertGroups.Unlock()
+	for _, hash := range hashes {
+		flushAlertGroup(hash)
+	}
+}
+
+// 生成分组告警：在单条告警的基础上加上窗口内的统计，SQL 与各字段取窗口内最慢的一次
+func buildGroupNotification(g *alertGroup) *notification {
+	clients := make([]string, 0, len(g.clients))
+	for client := range g.clients {
+		clients = append(clients, client)
+	}
+	sort.Strings(clients)
+
+	n := buildSlowQueryNotification(g.slowest)
+	n.Title += fmt.Sprintf("（%d 次）", g.count)
+	n.Fields = append([]notificationField{
+		{Label: "SQL指纹", Value: g.slowest.Fingerprint},
+		{Label: "出现次数", Value: fmt.Sprintf("%d 次", g.count), Highlight: true},
+		{Label: "最短查询时间", Value: fmt.Sprintf("%.2f 秒", g.minTime)},
+		{Label: "最长查询时间", Value: fmt.Sprintf("%.2f 秒", g.maxTime), Highlight: true},
+		{Label: "平均查询时间", Value: fmt.Sprintf("%.2f 秒", g.totalTime/float64(g.count))},
+		{Label: "用户@主机", Value: strings.Join(clients, ", ")},
+		{Label: "时间范围", Value: formatDisplayTime(g.first) + " ~ " + formatDisplayTime(g.last)},
+	}, n.Fields...)
+	n.Notes = append(n.Notes, "* 查询时间、主机等字段与 SQL 取窗口内最慢的一次")
+	return n
+}
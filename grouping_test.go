@@ -0,0 +1,97 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"mysql-slow-sql-webhook/testutil"
+)
+
+func TestAlertGrouping(t *testing.T) {
+	server := testutil.NewMockWebhookServer(t)
+
+	prevWindow, prevTargets := groupingWindow, webhookDestinations
+	t.Cleanup(func() { groupingWindow, webhookDestinations = prevWindow, prevTargets })
+	groupingWindow = 100 * time.Millisecond
+	webhookDestinations = []webhookTarget{{URL: server.WeChatURL(), Timeout: 5 * time.Second}}
+
+	for i, client := range []struct{ user, host string }{{"app", "10.0.0.1"}, {"app", "10.0.0.2"}, {"report", "10.0.0.1"}} {
+		addToAlertGroup(&SlowQueryEntry{
+			Hash: 99, Fingerprint: "select * from orders where id = ?", SQL: "SELECT * FROM orders WHERE id = 1;",
+			QueryTime: float64(i + 1), User: client.user, Host: client.host,
+		})
+	}
+
+	if !server.WaitForN(1, 5*time.Second) {
+		t.Fatal("窗口结束后应发送分组告警")
+	}
+	time.Sleep(50 * time.Millisecond)
+	received := server.Received()
+	if len(received) != 1 {
+		t.Fatalf("期望收到 1 条分组告警，实际收到 %d 条", len(received))
+	}
+	body := string(received[0].Raw)
+	for _, want := range []string{"慢查询警告（3 次）", "1.00 秒", "3.00 秒", "2.00 秒", "app@10.0.0.1, app@10.0.0.2, report@10.0.0.1"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("分组告警中缺少 %q: %s", want, body)
+		}
+	}
+}
+
+func TestGroupNotificationReplicationLag(t *testing.T) {
+	g := &alertGroup{
+		slowest: &SlowQueryEntry{Hash: 7, SQL: "SELECT 1", QueryTime: 12, ReplicationLag: 45 * time.Second},
+		count:   2, minTime: 11, maxTime: 12, totalTime: 23,
+		clients: map[string]bool{"app@10.0.0.1": true},
+		first:   time.Now(), last: time.Now(),
+	}
+	n := buildGroupNotification(g)
+	if !slices.Contains(n.Notes, replicationLagNote) {
+		t.Errorf("分组告警中缺少复制延迟提示: %v", n.Notes)
+	}
+	if !slices.ContainsFunc(n.Fields, func(f notificationField) bool { return f.Label == "复制延迟" && f.Value == "45s" }) {
+		t.Errorf("分组告警中缺少复制延迟字段: %+v", n.Fields)
+	}
+}
+
+func TestGroupNotificationUsesSingleAlertPath(t *testing.T) {
+	server := testutil.NewMockWebhookServer(t)
+	oncall := testutil.NewMockWebhookServer(t)
+	prevWindow, prevTargets, prevCritical, prevAck, prevLimit, prevMinRows := groupingWindow, webhookDestinations, criticalThreshold, ackCallbackURL, alertOnMissingLimit, missingLimitMinRows
+	prevTarget, prevAfter := escalationTarget, escalationAfter
+	t.Cleanup(func() {
+		groupingWindow, webhookDestinations, criticalThreshold, ackCallbackURL, alertOnMissingLimit, missingLimitMinRows = prevWindow, prevTargets, prevCritical, prevAck, prevLimit, prevMinRows
+		escalationTarget, escalationAfter = prevTarget, prevAfter
+	})
+	groupingWindow = time.Hour
+	webhookDestinations = []webhookTarget{{URL: server.WeChatURL(), Timeout: 5 * time.Second}}
+	criticalThreshold, ackCallbackURL, alertOnMissingLimit, missingLimitMinRows = 10, "https://alerts.example.com", true, 100
+	escalationTarget, escalationAfter = &webhookTarget{URL: oncall.WeChatURL(), Timeout: 5 * time.Second}, 0
+
+	for _, queryTime := range []float64{3, 12} {
+		addToAlertGroup(&SlowQueryEntry{
+			Hash: 98, Fingerprint: "select * from orders", SQL: "SELECT * FROM orders;", QueryTime: queryTime, RowsSent: 5000,
+			Migration: &migrationInfo{Elapsed: time.Minute},
+		})
+	}
+	alertGroups.Lock()
+	n := buildGroupNotification(alertGroups.byHash[98])
+	alertGroups.Unlock()
+	if n.Severity != "critical" || !strings.HasPrefix(n.Title, "[MIGRATION ALERT] ") || !slices.Contains(n.Notes, missingLimitNote) {
+		t.Errorf("分组告警应包含单条告警的级别、标题前缀与提示: %+v", n)
+	}
+	if !slices.ContainsFunc(n.Fields, func(f notificationField) bool { return f.Label == "确认告警" }) {
+		t.Errorf("分组告警中缺少确认链接: %+v", n.Fields)
+	}
+
+	// 分组告警与单条告警一样按告警次数升级
+	flushAlertGroup(98)
+	if !server.WaitForN(1, 5*time.Second) || !oncall.WaitForN(1, 5*time.Second) {
+		t.Fatal("分组告警应发送到推送地址并升级")
+	}
+	if body := string(oncall.Received()[0].Raw); !strings.Contains(body, "[ESCALATED] [MIGRATION ALERT] 慢查询警告（2 次）") {
+		t.Errorf("升级告警: %s", body)
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+func init() {
+	httpMux.HandleFunc("GET /api/v1/delivery-stats", handleDeliveryStats)
+}
+
+// 清理过期历史记录的间隔
+const historyPurgeInterval = time.Hour
+
+// 保存在 SQLite 中的告警历史：触发告警的慢查询与每次Webhook发送的结果
+type historyStore struct {
+	db *sql.DB
+}
+
+// 启用 --historyDB 时的历史记录，nil 表示不记录
+var history *historyStore
+
+// 打开历史数据库，不存在时创建
+func openHistory(path string) (*historyStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite 不支持并发写入，并发发送的结果依次写入
+	db.SetMaxOpenConns(1)
+	for _, stmt := range []string{
+		`PRAGMA foreign_keys = ON`,
+		`CREATE TABLE IF NOT EXISTS queries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			hash TEXT NOT NULL,
+			fingerprint TEXT NOT NULL,
+			database TEXT NOT NULL,
+			user TEXT NOT NULL,
+			host TEXT NOT NULL,
+			query_time REAL NOT NULL,
+			rows_examined INTEGER NOT NULL,
+			sql TEXT NOT NULL,
+			seen_at TIMESTAMP NOT NULL,
+			alerted INTEGER NOT NULL DEFAULT 1,
+			instance_id TEXT NOT NULL DEFAULT '',
+			labels TEXT NOT NULL DEFAULT '{}'
+		)`,
+		`CREATE INDEX IF NOT EXISTS queries_seen_at ON queries (seen_at)`,
+		`CREATE TABLE IF NOT EXISTS notifications (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			query_id INTEGER NOT NULL REFERENCES queries (id) ON DELETE CASCADE,
+			webhook_url_hash TEXT NOT NULL,
+			sent_at TIMESTAMP NOT NULL,
+			status TEXT NOT NULL,
+			http_status_code INTEGER NOT NULL,
+			latency_ms INTEGER NOT NULL,
+			retry_count INTEGER NOT NULL,
+			error_msg TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS notifications_sent_at ON notifications (sent_at)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("无法初始化历史数据库 %s: %w", path, err)
+		}
+	}
+	// 旧版本创建的数据库没有后来增加的列
+	for _, column := range [][2]string{
+		{"alerted", "INTEGER NOT NULL DEFAULT 1"},
+		{"instance_id", "TEXT NOT NULL DEFAULT ''"}, // --instances 中的实例名称
+		{"labels", "TEXT NOT NULL DEFAULT '{}'"},    // 告警标签，JSON对象
+	} {
+		if err := addHistoryColumn(db, "queries", column[0], column[1]); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("无法升级历史数据库 %s: %w", path, err)
+		}
+	}
+	return &historyStore{db: db}, nil
+}
+
+// 表中没有该列时添加
+func addHistoryColumn(db *sql.DB, table, column, definition string) error {
+	var exists bool
+	if err := db.QueryRow(`SELECT COUNT(*) > 0 FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	_, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+func (h *historyStore) Close() error {
+	return h.db.Close()
+}
+
+// 记录触发告警的慢查询，返回记录ID
+func (h *historyStore) recordQuery(entry *SlowQueryEntry, seenAt time.Time) (int64, error) {
+	return h.insertQuery(entry, seenAt, true)
+}
+
+// 记录 --trackMinQueryTime 跟踪的、未达到告警阈值的查询
+func (h *historyStore) recordTrackedQuery(entry *SlowQueryEntry, seenAt time.Time) error {
+	_, err := h.insertQuery(entry, seenAt, false)
+	return err
+}
+
+func (h *historyStore) insertQuery(entry *SlowQueryEntry, seenAt time.Time, alerted bool) (int64, error) {
+	labels := make(map[string]string)
+	for _, l := range entryLabels(entry) {
+		labels[l.Label] = l.Value
+	}
+	labelsJSON, err := json.Marshal(labels)
+	if err != nil {
+		return 0, err
+	}
+	result, err := h.db.Exec(`INSERT INTO queries (hash, fingerprint, database, user, host, query_time, rows_examined, sql, seen_at, alerted, instance_id, labels)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		fmt.Sprintf("%016x", entry.Hash), entry.Fingerprint, entry.Database, entry.User, entry.Host,
+		entry.QueryTime, entry.RowsExamined, entry.SQL, seenAt.UTC(), alerted, instanceName(entry.Instance), string(labelsJSON))
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// 一次Webhook请求的结果
+type deliveryRecord struct {
+	QueryID    int64
+	URL        string
+	SentAt     time.Time
+	StatusCode int // HTTP 状态码，未收到响应时为 0
+	Latency    time.Duration
+	RetryCount int
+	Err        error
+}
+
+// 计算Webhook地址的哈希，地址中通常带有密钥，因此不直接保存
+func webhookURLHash(url string) string {
+	return fmt.Sprintf("%016x", xxhash.Sum64String(url))
+}
+
+// 记录一次Webhook请求的结果
+func (h *historyStore) recordDelivery(r deliveryRecord) error {
+	status, message := "success", ""
+	if r.Err != nil {
+		status, message = "failed", r.Err.Error()
+	}
+	_, err := h.db.Exec(`INSERT INTO notifications (query_id, webhook_url_hash, sent_at, status, http_status_code, latency_ms, retry_count, error_msg)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.QueryID, webhookURLHash(r.URL), r.SentAt.UTC(), status, r.StatusCode, r.Latency.Milliseconds(), r.RetryCount, message)
+	return err
+}
+
+// 删除 before 之前的查询记录及其发送记录
+func (h *historyStore) purge(before time.Time) (int64, error) {
+	if _, err := h.db.Exec(`DELETE FROM notifications WHERE sent_at < ?`, before.UTC()); err != nil {
+		return 0, err
+	}
+	result, err := h.db.Exec(`DELETE FROM queries WHERE seen_at < ?`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// 定期清理超过 --historyRetention 的历史记录
+func purgeHistoryPeriodically(h *historyStore, retention time.Duration) {
+	for {
+		if n, err := h.purge(time.Now().Add(-retention)); err != nil {
+			logf(levelWarn, "清理历史记录失败: %v", err)
+		} else if n > 0 {
+			logf(levelDebug, "已清理 %d 条过期的历史记录", n)
+		}
+		time.Sleep(historyPurgeInterval)
+	}
+}
+
+// 单个Webhook地址的发送统计
+type deliveryStats struct {
+	WebhookURLHash string  `json:"webhook_url_hash"`
+	Total          int     `json:"total"`
+	Success        int     `json:"success"`
+	Failed         int     `json:"failed"`
+	AvgLatencyMs   float64 `json:"avg_latency_ms"`
+	LastFailure    string  `json:"last_failure,omitempty"`
+}
+
+// 统计 since 之后各Webhook地址的发送结果，失败次数多的排在前面
+func (h *historyStore) deliveryStats(since time.Time) ([]deliveryStats, error) {
+	rows, err := h.db.Query(`SELECT webhook_url_hash, COUNT(*),
+			SUM(CASE WHEN status = 'success' THEN 1 ELSE 0 END),
+			AVG(latency_ms),
+			COALESCE(MAX(CASE WHEN status = 'failed' THEN sent_at END), '')
+		FROM notifications WHERE sent_at >= ?
+		GROUP BY webhook_url_hash
+		ORDER BY COUNT(*) - SUM(CASE WHEN status = 'success' THEN 1 ELSE 0 END) DESC, webhook_url_hash`, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []deliveryStats{}
+	for rows.Next() {
+		var s deliveryStats
+		if err := rows.Scan(&s.WebhookURLHash, &s.Total, &s.Success, &s.AvgLatencyMs, &s.LastFailure); err != nil {
+			return nil, err
+		}
+		s.Failed = s.Total - s.Success
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+// 为告警记录历史，返回记录ID，未启用 --historyDB 或记录失败时返回 0
+func recordAlertHistory(entry *SlowQueryEntry) int64 {
+	if history == nil {
+		return 0
+	}
+	id, err := history.recordQuery(entry, time.Now())
+	if err != nil {
+		logf(levelWarn, "记录告警历史失败: %v", err)
+		return 0
+	}
+	return id
+}
+
+// 记录一次Webhook请求的结果，未关联历史记录时忽略
+func recordDeliveryHistory(queryID int64, target webhookTarget, sentAt time.Time, err error) {
+	if history == nil || queryID == 0 {
+		return
+	}
+	record := deliveryRecord{QueryID: queryID, URL: target.URL, SentAt: sentAt, Latency: time.Since(sentAt), Err: err}
+	var statusErr *webhookStatusError
+	if err == nil {
+		record.StatusCode = http.StatusOK
+	} else if errors.As(err, &statusErr) {
+		record.StatusCode = statusErr.StatusCode
+	}
+	if err := history.recordDelivery(record); err != nil {
+		logf(levelWarn, "记录Webhook发送结果失败: %v", err)
+	}
+}
+
+// GET /api/v1/delivery-stats?since=168h：统计各Webhook地址的发送结果，since 默认为 --historyRetention
+func handleDeliveryStats(w http.ResponseWriter, r *http.Request) {
+	if history == nil {
+		http.Error(w, "未启用 --historyDB", http.StatusNotFound)
+		return
+	}
+	window := historyRetention
+	if s := r.URL.Query().Get("since"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			http.Error(w, "since 参数无效: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		window = d
+	}
+	stats, err := history.deliveryStats(time.Now().Add(-window))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"since": window.String(), "webhooks": stats})
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDeliveryHistory(t *testing.T) {
+	h, err := openHistory(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	prev := history
+	t.Cleanup(func() { history = prev })
+	history = h
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer failing.Close()
+	working := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer working.Close()
+
+	entry := &SlowQueryEntry{QueryTime: 2, Database: "shop", SQL: "SELECT 1;", Fingerprint: "select ?", Hash: 1}
+	targets := []webhookTarget{{URL: failing.URL, Timeout: 5 * time.Second}, {URL: working.URL, Timeout: 5 * time.Second}}
+	if _, err := sendWebhookNotification(targets, entry); err == nil {
+		t.Fatal("期望返回失败地址的错误")
+	}
+
+	stats, err := h.deliveryStats(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("stats = %+v, want 2 个地址", stats)
+	}
+	if stats[0].WebhookURLHash != webhookURLHash(failing.URL) || stats[0].Failed != 1 || stats[0].LastFailure == "" {
+		t.Errorf("失败次数多的地址应排在前面: %+v", stats[0])
+	}
+	if stats[1].Success != 1 || stats[1].Failed != 0 {
+		t.Errorf("stats[1] = %+v", stats[1])
+	}
+
+	var code int
+	if err := h.db.QueryRow(`SELECT http_status_code FROM notifications WHERE status = 'failed'`).Scan(&code); err != nil || code != http.StatusTooManyRequests {
+		t.Errorf("http_status_code = %d, %v", code, err)
+	}
+
+	if _, err := h.purge(time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if stats, _ := h.deliveryStats(time.Time{}); len(stats) != 0 {
+		t.Errorf("清理后仍有发送记录: %+v", stats)
+	}
+}
+
+func TestHistoryRecordsLabels(t *testing.T) {
+	h, err := openHistory(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	prev := alertLabels
+	t.Cleanup(func() { alertLabels = prev })
+	alertLabels = []notificationField{{Label: "env", Value: "production"}, {Label: "region", Value: "ap-southeast-1"}}
+
+	id, err := h.recordQuery(&SlowQueryEntry{QueryTime: 2, Database: "shop", SQL: "SELECT 1;", Fingerprint: "select ?", Hash: 1}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var labels string
+	if err := h.db.QueryRow(`SELECT labels FROM queries WHERE id = ?`, id).Scan(&labels); err != nil {
+		t.Fatal(err)
+	}
+	if labels != `{"env":"production","region":"ap-southeast-1"}` {
+		t.Errorf("labels = %s", labels)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// 内置 HTTP 服务的路由，各功能在所在文件的 init 中注册
+var httpMux = http.NewServeMux()
+
+func init() {
+	httpMux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+}
+
+// 启动内置 HTTP 服务，退出时关闭
+func serveHTTP(addr string) {
+	server := &http.Server{Addr: addr, Handler: httpMux, ReadHeaderTimeout: 10 * time.Second}
+	registerShutdownHook(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	})
+	logf(levelInfo, "HTTP 服务已启动: %s", addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logf(levelError, "HTTP 服务异常退出: %v", err)
+	}
+}
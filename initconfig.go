@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	subcommands["init-config"] = runInitConfig
+}
+
+// 配置文件格式的版本，格式发生不兼容的变化时递增
+const configFormatVersion = 1
+
+// 配置文件中记录格式版本的配置项
+const configVersionKey = "configVersion"
+
+// 不写入示例配置的参数：configDir 不能在配置目录中设置，test 与 startupTest 只用于单次检查
+var sampleConfigSkipped = map[string]bool{
+	"configDir":   true,
+	"test":        true,
+	"startupTest": true,
+}
+
+// 复杂配置项的示例，写在默认值之后的注释中
+var sampleConfigExamples = map[string]string{
+	"databaseThresholds": `databaseThresholds:
+  analytics: {queryTime: 30, rowsExamined: 5000000}
+  payments: {queryTime: 0.1}`,
+	"userWebhooks": `userWebhooks:
+  etl_user: https://etl-webhook.example.com
+  app_*: https://app-webhook.example.com`,
+	"databaseWebhooks": `databaseWebhooks:
+  analytics: https://bi-webhook.example.com`,
+	"labels":      `labels: [env=production, region=ap-southeast-1]`,
+	"webhookURLs": `webhookURLs: ["https://hooks.slack.com/services/xxx|slack", "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=xxx|wechat"]`,
+}
+
+// TOML 格式的复杂配置项示例，使用内联表，写在文件任意位置都有效
+var sampleConfigTOMLExamples = map[string]string{
+	"databaseThresholds": `databaseThresholds = {analytics = {queryTime = 30, rowsExamined = 5000000}, payments = {queryTime = 0.1}}`,
+	"userWebhooks":       `userWebhooks = {etl_user = "https://etl-webhook.example.com", "app_*" = "https://app-webhook.example.com"}`,
+	"databaseWebhooks":   `databaseWebhooks = {analytics = "https://bi-webhook.example.com"}`,
+	"labels":             `labels = ["env=production", "region=ap-southeast-1"]`,
+	"webhookURLs":        `webhookURLs = ["https://hooks.slack.com/services/xxx|slack", "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=xxx|wechat"]`,
+}
+
+// 示例配置的一种文件格式
+type sampleConfigFormat struct {
+	separator string                              // 键与值之间的分隔符
+	keyValue  func(f *pflag.Flag) (string, error) // 一个配置项及其默认值，例如 logLevel: info
+	examples  map[string]string
+}
+
+var sampleConfigFormats = map[string]sampleConfigFormat{
+	".yaml": {separator: ": ", keyValue: sampleConfigYAML, examples: sampleConfigExamples},
+	".toml": {separator: " = ", keyValue: sampleConfigTOML, examples: sampleConfigTOMLExamples},
+}
+
+// 参数默认值的 YAML 表示
+func sampleConfigValue(f *pflag.Flag) (string, error) {
+	var value any = f.DefValue
+	switch f.Value.Type() {
+	case "bool", "int", "int64", "uint64", "float64":
+		return f.DefValue, nil
+	}
+	if slice, ok := f.Value.(pflag.SliceValue); ok {
+		node := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+		for _, item := range slice.GetSlice() {
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: item})
+		}
+		value = node
+	}
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// YAML 格式的配置项
+func sampleConfigYAML(f *pflag.Flag) (string, error) {
+	value, err := sampleConfigValue(f)
+	return f.Name + ": " + value, err
+}
+
+// TOML 格式的配置项，数值与布尔值保持原类型，其它参数写为字符串
+func sampleConfigTOML(f *pflag.Flag) (string, error) {
+	var value any = f.DefValue
+	var err error
+	switch f.Value.Type() {
+	case "bool":
+		value, err = strconv.ParseBool(f.DefValue)
+	case "int", "int64":
+		value, err = strconv.ParseInt(f.DefValue, 10, 64)
+	case "uint64":
+		value, err = strconv.ParseUint(f.DefValue, 10, 64)
+	case "float64":
+		value, err = strconv.ParseFloat(f.DefValue, 64)
+	}
+	if err != nil {
+		return "", err
+	}
+	if slice, ok := f.Value.(pflag.SliceValue); ok {
+		value = slice.GetSlice()
+	}
+	var buf strings.Builder
+	if err := toml.NewEncoder(&buf).Encode(map[string]any{f.Name: value}); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// 输出带注释的 YAML 示例配置，包括所有参数及其默认值
+func writeSampleConfig(w io.Writer, flags *pflag.FlagSet) error {
+	return writeSampleConfigAs(w, flags, ".yaml")
+}
+
+// 按扩展名 ext 对应的格式输出带注释的示例配置
+func writeSampleConfigAs(w io.Writer, flags *pflag.FlagSet, ext string) error {
+	format := sampleConfigFormats[ext]
+	fmt.Fprintf(w, "# %s 示例配置，放在 --configDir 指定的目录中使用，命令行参数优先于配置文件\n", programName)
+	fmt.Fprintf(w, "# 配置文件格式版本，格式变化时用于检测旧的配置文件\n%s%s%d\n", configVersionKey, format.separator, configFormatVersion)
+	var err error
+	flags.VisitAll(func(f *pflag.Flag) {
+		if err != nil || sampleConfigSkipped[f.Name] || f.Hidden {
+			return
+		}
+		var line string
+		if line, err = format.keyValue(f); err != nil {
+			err = fmt.Errorf("无法生成配置项 %s: %w", f.Name, err)
+			return
+		}
+		fmt.Fprintf(w, "\n# %s\n%s\n", strings.Join(strings.Fields(f.Usage), " "), line)
+		if example, ok := format.examples[f.Name]; ok {
+			fmt.Fprintf(w, "# 示例:\n#   %s\n", strings.ReplaceAll(example, "\n", "\n#   "))
+		}
+	})
+	return err
+}
+
+// init-config 子命令：输出示例配置到 --output 或标准输出，格式由 --output 的扩展名决定
+func runInitConfig(args []string) error {
+	var output string
+	flags := pflag.NewFlagSet("init-config", pflag.ContinueOnError)
+	flags.StringVar(&output, "output", "", "示例配置的输出文件（.yaml 或 .toml），未指定时以 YAML 格式输出到标准输出")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if output == "" {
+		return writeSampleConfig(os.Stdout, pflag.CommandLine)
+	}
+	ext := filepath.Ext(output)
+	if _, ok := sampleConfigFormats[ext]; !ok {
+		return fmt.Errorf("不支持的配置文件格式 %q，可选 .yaml 或 .toml", ext)
+	}
+	var buf strings.Builder
+	if err := writeSampleConfigAs(&buf, pflag.CommandLine, ext); err != nil {
+		return err
+	}
+	return writeFileAtomic(output, []byte(buf.String()))
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+func TestWriteSampleConfig(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.String("webhookURL", "", "Webhook URL")
+	flags.Float64("slowQueryThreshold", 0.5, "慢查询阈值，单位：秒")
+	flags.Duration("alertCooldown", 10*time.Minute, "同一SQL指纹的告警冷却时间")
+	flags.StringSlice("labels", []string{"env=dev"}, "告警标签")
+	flags.String("databaseThresholds", "", "按数据库覆盖的阈值配置")
+	flags.String("configDir", "", "配置目录")
+
+	var buf strings.Builder
+	if err := writeSampleConfig(&buf, flags); err != nil {
+		t.Fatal(err)
+	}
+	sample := buf.String()
+	for _, want := range []string{"# 慢查询阈值，单位：秒\nslowQueryThreshold: 0.5\n", "#   databaseThresholds:\n#     analytics:"} {
+		if !strings.Contains(sample, want) {
+			t.Errorf("示例配置中缺少 %q:\n%s", want, sample)
+		}
+	}
+	if strings.Contains(sample, "\nconfigDir:") {
+		t.Errorf("示例配置不应包含 configDir")
+	}
+
+	var values map[string]any
+	if err := yaml.Unmarshal([]byte(sample), &values); err != nil {
+		t.Fatalf("示例配置不是有效的 YAML: %v", err)
+	}
+	for name, want := range map[string]string{
+		configVersionKey: "1", "webhookURL": "", "slowQueryThreshold": "0.5", "alertCooldown": "10m0s", "labels": "env=dev",
+	} {
+		if got, err := configValueString(values[name]); err != nil || got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestLoadConfigDirVersion(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte("configVersion: 1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfigDir(dir); err != nil {
+		t.Fatalf("当前版本的配置应能加载: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("configVersion: 2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfigDir(dir); err == nil || !strings.Contains(err.Error(), "configVersion") {
+		t.Errorf("更高版本的配置应返回错误: %v", err)
+	}
+}
+
+func TestWriteSampleConfigTOML(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.String("webhookURL", "", "Webhook URL")
+	flags.Float64("slowQueryThreshold", 0.5, "慢查询阈值，单位：秒")
+	flags.Int("alertContextLines", 3, "附带的日志行数")
+	flags.Bool("verbose", true, "输出详细日志")
+	flags.Duration("alertCooldown", 10*time.Minute, "同一SQL指纹的告警冷却时间")
+	flags.StringSlice("labels", []string{"env=dev", "region=cn"}, "告警标签")
+	flags.String("databaseThresholds", "", "按数据库覆盖的阈值配置")
+
+	var buf strings.Builder
+	if err := writeSampleConfigAs(&buf, flags, ".toml"); err != nil {
+		t.Fatal(err)
+	}
+	sample := buf.String()
+	for _, want := range []string{"configVersion = 1\n", "# 慢查询阈值，单位：秒\nslowQueryThreshold = 0.5\n", "#   databaseThresholds = {analytics = "} {
+		if !strings.Contains(sample, want) {
+			t.Errorf("示例配置中缺少 %q:\n%s", want, sample)
+		}
+	}
+
+	var values map[string]any
+	if err := toml.Unmarshal([]byte(sample), &values); err != nil {
+		t.Fatalf("示例配置不是有效的 TOML: %v", err)
+	}
+	for name, want := range map[string]string{
+		configVersionKey: "1", "webhookURL": "", "slowQueryThreshold": "0.5", "alertContextLines": "3",
+		"verbose": "true", "alertCooldown": "10m0s", "labels": "env=dev,region=cn",
+	} {
+		if got, err := configValueString(values[name]); err != nil || got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	// 注释中的内联表示例去掉注释符号后也应是有效的 TOML
+	for name, example := range sampleConfigTOMLExamples {
+		if err := toml.Unmarshal([]byte(example), &values); err != nil {
+			t.Errorf("%s 的示例不是有效的 TOML: %v", name, err)
+		}
+	}
+}
+
+func TestLoadConfigDirTOML(t *testing.T) {
+	oldFlags := pflag.CommandLine
+	t.Cleanup(func() { pflag.CommandLine = oldFlags })
+	pflag.CommandLine = pflag.NewFlagSet("test", pflag.ContinueOnError)
+	pflag.Float64("slowQueryThreshold", 0, "慢查询阈值")
+	pflag.StringSlice("labels", nil, "告警标签")
+	pflag.String("databaseThresholds", "", "按数据库覆盖的阈值配置")
+
+	dir := t.TempDir()
+	data := "configVersion = 1\nslowQueryThreshold = 2.5\nlabels = [\"env=prod\", \"region=cn\"]\n" + sampleConfigTOMLExamples["databaseThresholds"] + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "config.toml"), []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	values, err := loadConfigDir(dir)
+	if err != nil {
+		t.Fatalf("TOML 配置应能加载: %v", err)
+	}
+	for name, want := range map[string]string{
+		"slowQueryThreshold": "2.5",
+		"labels":             "env=prod,region=cn",
+		"databaseThresholds": `{"analytics":{"queryTime":30,"rowsExamined":5000000},"payments":{"queryTime":0.1}}`,
+	} {
+		if values[name] != want {
+			t.Errorf("%s = %q, want %q", name, values[name], want)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "config.toml"), []byte("configVersion = 2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfigDir(dir); err == nil || !strings.Contains(err.Error(), "configVersion") {
+		t.Errorf("更高版本的配置应返回错误: %v", err)
+	}
+}
+
+func TestRunInitConfigFormat(t *testing.T) {
+	oldFlags := pflag.CommandLine
+	t.Cleanup(func() { pflag.CommandLine = oldFlags })
+	pflag.CommandLine = pflag.NewFlagSet("test", pflag.ContinueOnError)
+	pflag.Float64("slowQueryThreshold", 0.5, "慢查询阈值")
+	pflag.Duration("alertCooldown", 10*time.Minute, "同一SQL指纹的告警冷却时间")
+	pflag.StringSlice("labels", []string{"env=dev"}, "告警标签")
+	pflag.String("databaseThresholds", "", "按数据库覆盖的阈值配置")
+
+	dir := t.TempDir()
+	if err := runInitConfig([]string{"--output", filepath.Join(dir, "config.json")}); err == nil || !strings.Contains(err.Error(), ".toml") {
+		t.Errorf("不支持的格式应返回错误: %v", err)
+	}
+	path := filepath.Join(dir, "config.toml")
+	if err := runInitConfig([]string{"--output", path}); err != nil {
+		t.Fatal(err)
+	}
+	values, err := loadConfigDir(dir)
+	if err != nil {
+		t.Fatalf("生成的 TOML 示例配置应能加载: %v", err)
+	}
+	if values["slowQueryThreshold"] != "0.5" || values["alertCooldown"] != "10m0s" || values["labels"] != "env=dev" {
+		t.Errorf("示例配置中的默认值 = %v", values)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package main
+
+import "os"
+
+// Windows 下无法从 os.FileInfo 取得文件编号，轮转后的文件只能按路径识别
+func fileInode(info os.FileInfo) (uint64, bool) {
+	return 0, false
+}
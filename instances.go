@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// --instances 中单个MySQL实例的配置，未设置的字段沿用全局配置
+type instanceConfig struct {
+	Name               string   `json:"name"`               // 实例名称，用于日志、告警标签与指标，默认为日志文件名
+	SlowLogFile        string   `json:"slowLogFile"`        // 该实例的慢查询日志文件，仅支持本地单个文件
+	WebhookURL         string   `json:"webhookURL"`         // 该实例的告警地址，格式同 --webhookURL，未设置时按全局路由发送
+	SlowQueryThreshold *float64 `json:"slowQueryThreshold"` // 该实例的慢查询阈值，单位：秒
+	Labels             []string `json:"labels"`             // 附加到该实例告警的标签，格式为 key=value
+	AppHostname        string   `json:"appHostname"`        // 日志中没有 # Hostname: 时显示的应用主机，未设置时使用 --appHostname
+
+	targets      []webhookTarget
+	labels       []notificationField
+	offset       atomic.Int64 // 已处理完的位置，保存到状态文件
+	lastLineRead atomic.Int64 // 最近一次读取到日志行的时间（UnixNano），启动时为启动时间
+	resumeOffset int64        // 启动时从状态文件恢复的读取位置，-1 表示不恢复
+}
+
+// 解析 --instances 参数：以 [ 开头时视为JSON字符串，否则视为JSON文件路径
+func loadInstances(spec string) ([]*instanceConfig, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+
+	data := []byte(spec)
+	if !strings.HasPrefix(spec, "[") {
+		content, err := os.ReadFile(spec)
+		if err != nil {
+			return nil, fmt.Errorf("无法读取实例配置文件 %s: %w", spec, err)
+		}
+		data = content
+	}
+
+	var instances []*instanceConfig
+	if err := json.Unmarshal(data, &instances); err != nil {
+		return nil, fmt.Errorf("实例配置格式错误: %w", err)
+	}
+	names := make(map[string]bool, len(instances))
+	for i, inst := range instances {
+		if err := inst.init(); err != nil {
+			return nil, fmt.Errorf("第 %d 个实例配置无效: %w", i+1, err)
+		}
+		if names[inst.Name] {
+			return nil, fmt.Errorf("实例名称 %q 重复", inst.Name)
+		}
+		names[inst.Name] = true
+	}
+	return instances, nil
+}
+
+// 检查实例配置并解析告警地址与标签
+func (inst *instanceConfig) init() error {
+	if inst.SlowLogFile == "" {
+		return fmt.Errorf("必须设置 slowLogFile")
+	}
+	if isGlobPattern(inst.SlowLogFile) {
+		return fmt.Errorf("slowLogFile %q 不支持通配符", inst.SlowLogFile)
+	}
+	if inst.Name == "" {
+		inst.Name = filepath.Base(inst.SlowLogFile)
+	}
+	for _, spec := range strings.Split(inst.WebhookURL, ",") {
+		if spec = strings.TrimSpace(spec); spec == "" {
+			continue
+		}
+		target, err := parseWebhookTarget(spec)
+		if err != nil {
+			return err
+		}
+		inst.targets = append(inst.targets, target)
+	}
+	labels, err := parseLabels(inst.Labels)
+	if err != nil {
+		return err
+	}
+	inst.labels = mergeLabels(labels, []notificationField{{Label: "instance", Value: inst.Name}})
+	inst.resumeOffset = -1
+	inst.lastLineRead.Store(time.Now().UnixNano())
+	return nil
+}
+
+// 当前监控的实例，未设置 --instances 时为空
+var monitoredInstances []*instanceConfig
+
+// 告警附带的标签：全局标签加上实例的标签与日志行前缀中的标签，同名时使用后者
+func entryLabels(entry *SlowQueryEntry) []notificationField {
+	labels := alertLabels
+	if entry.Instance != nil {
+		labels = mergeLabels(labels, entry.Instance.labels)
+	}
+	if len(entry.SourceLabels) > 0 {
+		labels = mergeLabels(labels, entry.SourceLabels)
+	}
+	return labels
+}
+
+// 日志中没有 # Hostname: 时使用的应用主机，实例的配置优先于 --appHostname
+func defaultAppHostname(inst *instanceConfig) string {
+	if inst != nil && inst.AppHostname != "" {
+		return inst.AppHostname
+	}
+	return appHostname
+}
+
+// 指标中的实例名称，未设置 --instances 时为空字符串
+func instanceName(inst *instanceConfig) string {
+	if inst == nil {
+		return ""
+	}
+	return inst.Name
+}
+
+// 所有实例的告警地址，用于启动时校验
+func instanceWebhookURLs() []string {
+	var urls []string
+	for _, inst := range monitoredInstances {
+		urls = append(urls, webhookTargetURLs(inst.targets)...)
+	}
+	return urls
+}
+
+// 所有实例单独配置的推送目标，用于 --startupTest
+func instanceTargets() []webhookTarget {
+	var targets []webhookTarget
+	for _, inst := range monitoredInstances {
+		targets = append(targets, inst.targets...)
+	}
+	return targets
+}
+
+// 所有实例的日志文件，用于启动时校验
+func instanceLogFiles() []string {
+	files := make([]string, 0, len(monitoredInstances))
+	for _, inst := range monitoredInstances {
+		files = append(files, inst.SlowLogFile)
+	}
+	return files
+}
+
+// 为每个实例启动一个读取协程，各自在退出后重新启动
+func watchInstances() {
+	var wg sync.WaitGroup
+	for _, inst := range monitoredInstances {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			watchSlowLog(tailJob{path: inst.SlowLogFile, firstRun: true, instance: inst})
+		}()
+	}
+	wg.Wait()
+}
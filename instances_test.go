@@ -0,0 +1,151 @@
+package main
+
+import (
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadInstances(t *testing.T) {
+	instances, err := loadInstances(`[
+		{"name": "primary", "slowLogFile": "/var/log/mysql/primary-slow.log", "webhookURL": "https://primary|slack", "labels": ["role=primary"]},
+		{"slowLogFile": "/var/log/mysql/replica-slow.log", "slowQueryThreshold": 5}
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(instances) != 2 {
+		t.Fatalf("len(instances) = %d", len(instances))
+	}
+	primary, replica := instances[0], instances[1]
+	if len(primary.targets) != 1 || primary.targets[0].Format != webhookFormats["slack"] {
+		t.Errorf("primary targets = %+v", primary.targets)
+	}
+	if formatLabels(primary.labels) != "role=primary, instance=primary" {
+		t.Errorf("primary labels = %s", formatLabels(primary.labels))
+	}
+	if replica.Name != "replica-slow.log" || *replica.SlowQueryThreshold != 5 || replica.resumeOffset != -1 {
+		t.Errorf("replica = %+v", replica)
+	}
+
+	for _, spec := range []string{
+		`[{"name": "a"}]`,
+		`[{"slowLogFile": "/var/log/mysql/*.log"}]`,
+		`[{"name": "a", "slowLogFile": "/a.log"}, {"name": "a", "slowLogFile": "/b.log"}]`,
+		`[{"slowLogFile": "/a.log", "labels": ["bad"]}]`,
+	} {
+		if _, err := loadInstances(spec); err == nil {
+			t.Errorf("loadInstances(%s) 应返回错误", spec)
+		}
+	}
+}
+
+func TestProcessInstanceSlowQuery(t *testing.T) {
+	var alerted []*SlowQueryEntry
+	var targets [][]webhookTarget
+	prevNotifier, prevThreshold, prevDestinations, prevLabels := alertNotifier, slowQueryThreshold, webhookDestinations, alertLabels
+	t.Cleanup(func() {
+		alertNotifier, slowQueryThreshold, webhookDestinations, alertLabels = prevNotifier, prevThreshold, prevDestinations, prevLabels
+	})
+	alertNotifier = func(to []webhookTarget, entry *SlowQueryEntry) (int, error) {
+		alerted, targets = append(alerted, entry), append(targets, to)
+		return 1, nil
+	}
+	slowQueryThreshold = 1
+	webhookDestinations = []webhookTarget{{URL: "https://default"}}
+	alertLabels = []notificationField{{Label: "env", Value: "production"}}
+
+	instances, err := loadInstances(`[
+		{"name": "primary", "slowLogFile": "/primary.log", "webhookURL": "https://primary"},
+		{"name": "replica", "slowLogFile": "/replica.log", "slowQueryThreshold": 5}
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	primary, replica := instances[0], instances[1]
+
+	query := fixtureLines(`
+# User@Host: app[app] @ localhost []  Id:    42
+# Query_time: 2.000000  Lock_time: 0.000000 Rows_sent: 1  Rows_examined: 1
+SELECT * FROM orders WHERE id = 1;`)
+	processInstanceSlowQuery(replica, nil, query, nil)
+	if len(alerted) != 0 {
+		t.Fatalf("应使用实例的 slowQueryThreshold")
+	}
+	processInstanceSlowQuery(primary, nil, query, nil)
+	if len(alerted) != 1 {
+		t.Fatalf("实际告警 %d 条", len(alerted))
+	}
+	if targets[0][0].URL != "https://primary" {
+		t.Errorf("应发送到实例的 webhookURL: %+v", targets[0])
+	}
+	if got := formatLabels(entryLabels(alerted[0])); got != "env=production, instance=primary" {
+		t.Errorf("labels = %s", got)
+	}
+
+	processSlowQuery(query, nil)
+	if len(alerted) != 2 || targets[1][0].URL != "https://default" {
+		t.Fatalf("未设置 --instances 时应使用全局配置")
+	}
+	if alerted[0].Hash == alerted[1].Hash {
+		t.Errorf("不同实例的同一指纹应分别冷却")
+	}
+}
+
+func TestInstanceMetricsAndHistory(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"primary.log", "replica.log"} {
+		if err := os.WriteFile(filepath.Join(dir, name), make([]byte, 100), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	instances, err := loadInstances(`[
+		{"name": "primary", "slowLogFile": "` + filepath.Join(dir, "primary.log") + `"},
+		{"slowLogFile": "` + filepath.Join(dir, "replica.log") + `"}
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := openHistory(filepath.Join(dir, "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	prevInstances := monitoredInstances
+	t.Cleanup(func() { monitoredInstances = prevInstances })
+	monitoredInstances = instances
+	instances[1].lastLineRead.Store(time.Now().Add(-time.Hour).UnixNano())
+
+	server := httptest.NewServer(httpMux)
+	defer server.Close()
+	resp, err := server.Client().Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	for _, want := range []string{
+		`slow_log_last_line_age_seconds{mysql_instance="primary"} `,
+		`slow_log_last_line_age_seconds{mysql_instance="replica.log"} 3600.`,
+		`slow_log_file_size_bytes{mysql_instance="replica.log"} 100`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("/metrics 中缺少 %q", want)
+		}
+	}
+
+	if _, err := h.recordQuery(&SlowQueryEntry{Instance: instances[0], Fingerprint: "select ?"}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	var instanceID string
+	if err := h.db.QueryRow(`SELECT instance_id FROM queries`).Scan(&instanceID); err != nil {
+		t.Fatal(err)
+	}
+	if instanceID != "primary" {
+		t.Errorf("instance_id = %q", instanceID)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"mysql-slow-sql-webhook/testutil"
+)
+
+func TestTailSlowLogSendsExpectedNotifications(t *testing.T) {
+	server := testutil.NewMockWebhookServer(t)
+
+	data, err := os.ReadFile("testdata/slow.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prevFile, prevHistory, prevThreshold, prevTargets, prevOpen := slowLogFile, readHistory, slowQueryThreshold, webhookDestinations, openLogLineReader
+	t.Cleanup(func() {
+		slowLogFile, readHistory, slowQueryThreshold, webhookDestinations, openLogLineReader = prevFile, prevHistory, prevThreshold, prevTargets, prevOpen
+	})
+	openLogLineReader = func(path string, offset int64, whence int) (LogLineReader, error) {
+		return testutil.NewMockLogLineReader(strings.Split(string(data), "\n")), nil
+	}
+	slowLogFile = "testdata/slow.log"
+	readHistory = true
+	slowQueryThreshold = 0.5
+	webhookDestinations = []webhookTarget{{URL: server.WeChatURL(), Timeout: 5 * time.Second}}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go tailSlowLog(tailJob{path: slowLogFile, firstRun: true}, &wg, make(chan bool, 1))
+
+	if !server.WaitForN(2, 5*time.Second) {
+		t.Fatalf("期望收到 2 条通知，实际收到 %d 条", len(server.Received()))
+	}
+	// 等待一段时间，确认没有多余的通知
+	time.Sleep(300 * time.Millisecond)
+	// 模拟的读取器发送完所有行后关闭通道，tailSlowLog 随之返回；在恢复全局变量之前等待它退出
+	wg.Wait()
+
+	received := server.Received()
+	if len(received) != 2 {
+		t.Fatalf("期望收到 2 条通知，实际收到 %d 条", len(received))
+	}
+
+	wantSQL := []string{
+		"SELECT * FROM orders WHERE status = 'pending';",
+		"UPDATE orders",
+	}
+	for i, payload := range received {
+		if payload.Platform != testutil.PlatformWeChat || payload.Body["msgtype"] != "markdown" {
+			t.Fatalf("第 %d 条通知格式不正确: %s", i+1, payload.Raw)
+		}
+		markdown, _ := payload.Body["markdown"].(map[string]any)
+		content, _ := markdown["content"].(string)
+		if !strings.Contains(content, wantSQL[i]) {
+			t.Errorf("第 %d 条通知缺少SQL %q，内容: %s", i+1, wantSQL[i], content)
+		}
+	}
+}
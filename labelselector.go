@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// 设置了 --labelSelector 而没有设置 --labelPrefixPattern 时使用的前缀格式
+// 匹配 Promtail、Fluentd 等日志采集器添加的前缀，例如 pod=mysql-0 namespace=db |
+const defaultLabelPrefixPattern = `^((?:[\w./-]+=[^\s|]+[\s,]*)+)\|\s?`
+
+// 前缀中的一个 key=value 标签，值可以用双引号括起来
+var labelPairPattern = regexp.MustCompile(`([\w./-]+)=("[^"]*"|[^\s,|]+)`)
+
+// 由 --labelPrefixPattern 与 --labelSelector 在启动时设置
+var labelPrefixRegexp *regexp.Regexp
+var labelSelectorLabels []notificationField
+
+// 编译 --labelPrefixPattern 并解析 --labelSelector
+func configureLabelSelector() error {
+	labelPrefixRegexp, labelSelectorLabels = nil, nil
+	spec := labelPrefixPattern
+	if spec == "" && labelSelector != "" {
+		spec = defaultLabelPrefixPattern
+	}
+	if spec != "" {
+		pattern, err := regexp.Compile(spec)
+		if err != nil {
+			return fmt.Errorf("--labelPrefixPattern 不是有效的正则表达式: %w", err)
+		}
+		labelPrefixRegexp = pattern
+	}
+	if labelSelector == "" {
+		return nil
+	}
+	selector, err := parseLabels(strings.Split(labelSelector, ","))
+	if err != nil {
+		return fmt.Errorf("--labelSelector 无效: %w", err)
+	}
+	labelSelectorLabels = selector
+	return nil
+}
+
+// 去掉日志行的标签前缀，返回剩余内容与前缀中的标签
+// 正则表达式中有名为 labels 的分组时从该分组解析标签，其次是第一个分组，没有分组时使用整个前缀
+func stripLabelPrefix(line string) (string, []notificationField) {
+	if labelPrefixRegexp == nil {
+		return line, nil
+	}
+	loc := labelPrefixRegexp.FindStringSubmatchIndex(line)
+	if loc == nil {
+		return line, nil
+	}
+	group := 0
+	if i := labelPrefixRegexp.SubexpIndex("labels"); i > 0 {
+		group = i
+	} else if labelPrefixRegexp.NumSubexp() > 0 {
+		group = 1
+	}
+	var labels []notificationField
+	if start, end := loc[2*group], loc[2*group+1]; start >= 0 {
+		for _, m := range labelPairPattern.FindAllStringSubmatch(line[start:end], -1) {
+			labels = append(labels, notificationField{Label: m[1], Value: strings.Trim(m[2], `"`)})
+		}
+	}
+	return line[loc[1]:], labels
+}
+
+// 标签是否满足 --labelSelector 中的所有条件，未设置时都满足
+func matchesLabelSelector(labels []notificationField) bool {
+	for _, want := range labelSelectorLabels {
+		matched := false
+		for _, l := range labels {
+			if l.Label == want.Label && l.Value == want.Value {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
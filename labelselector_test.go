@@ -0,0 +1,52 @@
+package main
+
+import "testing"
+
+func TestLabelSelector(t *testing.T) {
+	var alerted []*SlowQueryEntry
+	prevNotifier, prevSelector, prevPattern := alertNotifier, labelSelector, labelPrefixPattern
+	t.Cleanup(func() {
+		alertNotifier, labelSelector, labelPrefixPattern = prevNotifier, prevSelector, prevPattern
+		labelPrefixRegexp, labelSelectorLabels = nil, nil
+	})
+	alertNotifier = func(targets []webhookTarget, entry *SlowQueryEntry) (int, error) {
+		alerted = append(alerted, entry)
+		return 1, nil
+	}
+	labelSelector, labelPrefixPattern = "pod=mysql-0", ""
+	if err := configureLabelSelector(); err != nil {
+		t.Fatal(err)
+	}
+
+	assembler := newEntryAssembler(false)
+	for _, line := range []string{
+		"pod=mysql-0 namespace=db | # Time: 2024-01-15T14:00:00.000000Z",
+		"pod=mysql-1 namespace=db | # Time: 2024-01-15T14:00:00.000000Z",
+		"pod=mysql-0 namespace=db | # User@Host: app[app] @ localhost []  Id:    42",
+		"pod=mysql-1 namespace=db | # User@Host: other[other] @ localhost []  Id:    43",
+		"pod=mysql-0 namespace=db | # Query_time: 2.000000  Lock_time: 0.000000 Rows_sent: 1  Rows_examined: 1",
+		"pod=mysql-1 namespace=db | # Query_time: 3.000000  Lock_time: 0.000000 Rows_sent: 1  Rows_examined: 1",
+		"pod=mysql-1 namespace=db | SELECT * FROM users WHERE id = 2;",
+		"pod=mysql-0 namespace=db | SELECT * FROM orders WHERE id = 1;",
+	} {
+		assembler.feed(line)
+	}
+	if len(alerted) != 1 {
+		t.Fatalf("实际告警 %d 条", len(alerted))
+	}
+	if alerted[0].User != "app" || alerted[0].SQL != "SELECT * FROM orders WHERE id = 1;" {
+		t.Errorf("entry = %+v", alerted[0])
+	}
+	if got := formatLabels(entryLabels(alerted[0])); got != "pod=mysql-0, namespace=db" {
+		t.Errorf("labels = %s", got)
+	}
+
+	labelPrefixPattern = `^\[(?P<labels>[^\]]*)\] `
+	if err := configureLabelSelector(); err != nil {
+		t.Fatal(err)
+	}
+	line, labels := stripLabelPrefix(`[app="mysql", pod=mysql-0] # Time: 2024-01-15T14:00:00.000000Z`)
+	if line != "# Time: 2024-01-15T14:00:00.000000Z" || formatLabels(labels) != "app=mysql, pod=mysql-0" {
+		t.Errorf("stripLabelPrefix = %q, %s", line, formatLabels(labels))
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import "regexp"
+
+// 没有 LIMIT 且返回大量行的查询附加到告警的说明
+const missingLimitNote = "⚠️ Large unbounded query (no LIMIT clause)"
+
+// --alertOnMissingLimit 检查的语句：SELECT，允许前面有注释
+var missingLimitPattern = regexp.MustCompile(`(?is)^\s*(?:/\*.*?\*/\s*)*\(?\s*SELECT\b`)
+var limitClausePattern = regexp.MustCompile(`(?i)\bLIMIT\b`)
+
+// 选择列表以聚合函数开头、且没有 GROUP BY 的查询只返回一行，不需要分页
+var aggregateSelectPattern = regexp.MustCompile(`(?is)^\s*(?:/\*.*?\*/\s*)*\(?\s*SELECT\s+(?:(?:ALL|DISTINCT|HIGH_PRIORITY|STRAIGHT_JOIN|SQL_\w+)\s+)*(?:COUNT|SUM|AVG|MIN|MAX|GROUP_CONCAT|JSON_ARRAYAGG|JSON_OBJECTAGG|BIT_AND|BIT_OR|BIT_XOR|STDDEV|STD|VARIANCE)\s*\(`)
+var groupByPattern = regexp.MustCompile(`(?i)\bGROUP\s+BY\b`)
+
+// 是否是没有 LIMIT 的 SELECT；先去掉字符串字面量，避免 'limit' 这样的值影响判断
+func isUnboundedSelect(sql string) bool {
+	if !missingLimitPattern.MatchString(sql) {
+		return false
+	}
+	sql = stringLiteralPattern.ReplaceAllString(sql, "?")
+	if limitClausePattern.MatchString(sql) {
+		return false
+	}
+	return !aggregateSelectPattern.MatchString(sql) || groupByPattern.MatchString(sql)
+}
+
+// 开启 --alertOnMissingLimit 时，没有 LIMIT 且发送的行数超过 --missingLimitMinRows 的查询通常是忘了分页
+func missingLimitNotes(entry *SlowQueryEntry) []string {
+	if !alertOnMissingLimit || isMongoDBEntry(entry) || entry.RowsSent <= missingLimitMinRows || !isUnboundedSelect(entry.SQL) {
+		return nil
+	}
+	return []string{missingLimitNote}
+}
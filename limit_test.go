@@ -0,0 +1,53 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestIsUnboundedSelect(t *testing.T) {
+	for _, tt := range []struct {
+		sql       string
+		unbounded bool
+	}{
+		{"SELECT * FROM orders WHERE status = 'pending'", true},
+		{"select id, name from users", true},
+		{"/* app:web */ SELECT * FROM orders", true},
+		{"(SELECT id FROM a) UNION (SELECT id FROM b)", true},
+		{"SELECT DISTINCT user_id FROM orders", true},
+		{"SELECT * FROM orders WHERE note = ' limit '", true},
+		{"SELECT status, COUNT(*) FROM orders GROUP BY status", true},
+		{"SELECT * FROM orders LIMIT 20", false},
+		{"select * from orders limit 20 offset 40", false},
+		{"SELECT * FROM orders\nLIMIT 10, 20", false},
+		{"SELECT COUNT(*) FROM orders", false},
+		{"select count(1) from orders where status = 'pending'", false},
+		{"SELECT SQL_NO_CACHE MAX(id) FROM orders", false},
+		{"SELECT COUNT(*) AS n, status FROM orders GROUP BY status LIMIT 100", false},
+		{"UPDATE orders SET status = 'paid'", false},
+		{"INSERT INTO archive SELECT * FROM orders", false},
+	} {
+		if got := isUnboundedSelect(tt.sql); got != tt.unbounded {
+			t.Errorf("isUnboundedSelect(%q) = %v, want %v", tt.sql, got, tt.unbounded)
+		}
+	}
+}
+
+func TestMissingLimitNote(t *testing.T) {
+	prevEnabled, prevMin := alertOnMissingLimit, missingLimitMinRows
+	t.Cleanup(func() { alertOnMissingLimit, missingLimitMinRows = prevEnabled, prevMin })
+	alertOnMissingLimit, missingLimitMinRows = true, 1000
+
+	entry := &SlowQueryEntry{SQL: "SELECT * FROM orders", RowsSent: 50000}
+	if n := buildSlowQueryNotification(entry); !slices.Contains(n.Notes, missingLimitNote) {
+		t.Errorf("没有 LIMIT 的大结果集查询应附加说明: %v", n.Notes)
+	}
+	entry.RowsSent = 1000
+	if notes := missingLimitNotes(entry); notes != nil {
+		t.Errorf("Rows_sent 未超过阈值时不应附加说明: %v", notes)
+	}
+	entry.RowsSent, alertOnMissingLimit = 50000, false
+	if notes := missingLimitNotes(entry); notes != nil {
+		t.Errorf("未开启 --alertOnMissingLimit 时不应附加说明: %v", notes)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// 汇总中列出的锁等待最多的查询个数
+const digestTopLockWaits = 5
+
+// 锁等待的标题
+const lockWaitTitle = "🔒 Lock Wait Detected"
+
+// 单个指纹在一个汇总周期内的锁等待统计
+type lockContention struct {
+	fingerprint string
+	count       int     // 出现锁等待的次数
+	waits       float64 // InnoDB_rec_lock_waits 之和
+	queueWait   float64 // InnoDB_queue_wait 之和，单位：秒
+}
+
+// 当前汇总周期内出现锁等待的查询，按指纹哈希索引
+var lockContentions = struct {
+	sync.Mutex
+	byHash map[uint64]*lockContention
+}{byHash: make(map[uint64]*lockContention)}
+
+// 把出现锁等待的慢查询计入汇总
+func recordLockContention(entry *SlowQueryEntry) {
+	if entry.InnoDBRecLockWaits <= 0 {
+		return
+	}
+	lockContentions.Lock()
+	defer lockContentions.Unlock()
+	c, ok := lockContentions.byHash[entry.Hash]
+	if !ok {
+		c = &lockContention{fingerprint: entry.Fingerprint}
+		lockContentions.byHash[entry.Hash] = c
+	}
+	c.count++
+	c.waits += entry.InnoDBRecLockWaits
+	c.queueWait += entry.InnoDBQueueWait
+}
+
+// 取出当前周期锁等待最多的查询并重新统计
+func takeLockContentions() []*lockContention {
+	lockContentions.Lock()
+	byHash := lockContentions.byHash
+	lockContentions.byHash = make(map[uint64]*lockContention)
+	lockContentions.Unlock()
+
+	top := make([]*lockContention, 0, len(byHash))
+	for _, c := range byHash {
+		top = append(top, c)
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].waits != top[j].waits {
+			return top[i].waits > top[j].waits
+		}
+		return top[i].fingerprint < top[j].fingerprint
+	})
+	if len(top) > digestTopLockWaits {
+		top = top[:digestTopLockWaits]
+	}
+	return top
+}
+
+// 汇总中锁等待最多的查询表格
+func lockContentionTable(top []*lockContention) notificationTable {
+	t := notificationTable{
+		Title:   fmt.Sprintf("锁等待最多的 %d 个查询", digestTopLockWaits),
+		Columns: []string{"Lock Waits", "Queries", "Queue Wait", "Fingerprint"},
+	}
+	for _, c := range top {
+		t.Rows = append(t.Rows, []string{fmt.Sprintf("%g", c.waits), fmt.Sprintf("%d", c.count), fmt.Sprintf("%.2fs", c.queueWait), digestSQL(c.fingerprint)})
+	}
+	return t
+}
+
+// 告警中的锁等待信息，没有锁等待时返回 nil
+func lockWaitTables(entry *SlowQueryEntry) []notificationTable {
+	if entry.InnoDBRecLockWaits <= 0 {
+		return nil
+	}
+	return []notificationTable{{
+		Title:   lockWaitTitle,
+		Columns: []string{"InnoDB_rec_lock_waits", "InnoDB_queue_wait", "Lock_time"},
+		Rows: [][]string{{
+			fmt.Sprintf("%g", entry.InnoDBRecLockWaits),
+			fmt.Sprintf("%.6fs", entry.InnoDBQueueWait),
+			fmt.Sprintf("%.6fs", entry.LockTime),
+		}},
+	}}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseLogLinesLockWaits(t *testing.T) {
+	lines := fixtureLines(`
+# User@Host: app[app] @ localhost []  Id:    49
+# Query_time: 0.100000  Lock_time: 0.080000 Rows_sent: 0  Rows_examined: 1
+# InnoDB_rec_lock_waits: 3  InnoDB_queue_wait: 0.050000s
+UPDATE accounts SET balance = balance - 1 WHERE id = 7;`)
+	entry, err := ParseLogLines(lines)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if entry.InnoDBRecLockWaits != 3 || entry.InnoDBQueueWait != 0.05 {
+		t.Errorf("InnoDBRecLockWaits = %v, InnoDBQueueWait = %v", entry.InnoDBRecLockWaits, entry.InnoDBQueueWait)
+	}
+	if entry.Validate(thresholdConfig{QueryTime: 1}) {
+		t.Errorf("未设置锁等待阈值时不应告警")
+	}
+	if !entry.Validate(thresholdConfig{QueryTime: 1, LockWaits: 1}) {
+		t.Errorf("锁等待达到阈值时应告警")
+	}
+
+	tables := buildSlowQueryNotification(entry).Tables
+	if len(tables) != 1 || tables[0].Title != lockWaitTitle || tables[0].Rows[0][0] != "3" {
+		t.Errorf("锁等待表格 = %+v", tables)
+	}
+}
+
+func TestTakeLockContentions(t *testing.T) {
+	takeLockContentions()
+	for i := 1; i <= 7; i++ {
+		fingerprint := fmt.Sprintf("update t%d set v = ?", i)
+		entry := &SlowQueryEntry{Fingerprint: fingerprint, Hash: computeQueryHash(fingerprint), InnoDBRecLockWaits: float64(i)}
+		recordLockContention(entry)
+		recordLockContention(entry)
+	}
+	recordLockContention(&SlowQueryEntry{Fingerprint: "select 1", Hash: computeQueryHash("select 1")})
+
+	top := takeLockContentions()
+	if len(top) != digestTopLockWaits {
+		t.Fatalf("期望 %d 个查询，实际 %d 个", digestTopLockWaits, len(top))
+	}
+	if top[0].fingerprint != "update t7 set v = ?" || top[0].waits != 14 || top[0].count != 2 {
+		t.Errorf("锁等待最多的查询 = %+v", top[0])
+	}
+	if len(takeLockContentions()) != 0 {
+		t.Errorf("取出后应重新统计")
+	}
+}
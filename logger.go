@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// 日志级别
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+var logLevelNames = map[logLevel]string{
+	levelDebug: "DEBUG",
+	levelInfo:  "INFO",
+	levelWarn:  "WARN",
+	levelError: "ERROR",
+}
+
+// 当前生效的日志级别，低于该级别的日志不输出，可在运行中重新加载
+var currentLogLevel atomic.Int32
+
+func init() {
+	setLogLevel(levelInfo)
+}
+
+// 设置当前生效的日志级别
+func setLogLevel(level logLevel) {
+	currentLogLevel.Store(int32(level))
+}
+
+// 工具自身的日志输出
+var logger = log.New(os.Stdout, "", log.LstdFlags)
+
+// 设置日志是否带时间前缀，由 --noTimestamp 控制
+func setLogTimestamp(enabled bool) {
+	if enabled {
+		logger.SetFlags(log.LstdFlags)
+	} else {
+		logger.SetFlags(0)
+	}
+}
+
+// 解析 --logLevel 参数
+func parseLogLevel(value string) (logLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "debug":
+		return levelDebug, nil
+	case "info":
+		return levelInfo, nil
+	case "warn", "warning":
+		return levelWarn, nil
+	case "error":
+		return levelError, nil
+	}
+	return levelInfo, fmt.Errorf("未知的日志级别 %q，可选值: debug, info, warn, error", value)
+}
+
+// 判断指定级别的日志是否会输出，用于避免在关闭 debug 时做多余的格式化
+func logEnabled(level logLevel) bool {
+	return level >= logLevel(currentLogLevel.Load())
+}
+
+// 按指定级别输出日志
+func logf(level logLevel, format string, args ...any) {
+	if !logEnabled(level) {
+		return
+	}
+	logger.Printf("[%s] %s", logLevelNames[level], fmt.Sprintf(format, args...))
+}
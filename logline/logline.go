@@ -0,0 +1,14 @@
+// Package logline 定义读取日志行的接口，便于在测试中替换真实的文件跟踪。
+package logline
+
+// 读取到的一行日志
+type Line struct {
+	Text string
+	Err  error // 读取出错时设置，Text 为空
+}
+
+// 日志行读取器，Lines 返回的通道在读取结束或 Stop 后关闭
+type Reader interface {
+	Lines() <-chan Line
+	Stop()
+}
@@ -1,174 +1,634 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"github.com/go-resty/resty/v2"
-	"github.com/hpcloud/tail"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
 	"github.com/spf13/pflag"
-	"regexp"
-	"strconv"
-	"sync"
 )
 
 // 配置命令行参数
 var webhookURL string
 var slowLogFile string
-var slowQueryThreshold float64 // 慢查询阈值，单位：秒
-var isTest bool                // 是否发送测试WebHook请求
-var readHistory bool           // 是否读取历史日志数据，默认为 false
-
-// 正则表达式，用于提取慢查询日志中的信息
-var queryStartPattern = regexp.MustCompile(`^# Time: \d{4}-\d{2}-\d{2}.*$`)
-var queryTimePattern = regexp.MustCompile(`# Query_time:\s*(\d+\.\d+|\d+)\s*Lock_time:\s*(\d+\.\d+|\d+)\s*Rows_sent:\s*(\d+)\s*Rows_examined:\s*(\d+)`)
-var userHostPattern = regexp.MustCompile(`# User@Host:\s*(\S+)\s*\[\S+\]\s*@\s*(\S+)`)
-var databasePattern = regexp.MustCompile(`# Schema:\s*(\S+)`) // 匹配数据库名
-var sqlQueryEndPattern = regexp.MustCompile(`(?i)^(SELECT|UPDATE|DELETE|INSERT)\s+.*;$`)
-
-// 发送Webhook通知
-func sendWebhookNotification(content string) {
-	payload := fmt.Sprintf(`{
-		"msgtype": "markdown",
-		"markdown": {
-			"content": "%s"
-		}
-	}`, content)
-
-	client := resty.New()
-	_, err := client.R().
-		SetHeader("Content-Type", "application/json").
-		SetBody(payload).
-		Post(webhookURL)
+var slowQueryThreshold float64      // 慢查询阈值，单位：秒
+var isTest bool                     // 是否发送测试WebHook请求
+var readHistory bool                // 是否读取历史日志数据，默认为 false
+var alertCooldown time.Duration     // 同一SQL指纹的告警冷却时间，0 表示不启用
+var startFrom string                // 从指定时间点开始处理历史日志
+var startFromTime time.Time         // 解析后的 startFrom
+var databaseThresholds string       // 按数据库覆盖的阈值配置（JSON字符串或文件路径）
+var logLevelName string             // 日志级别：debug、info、warn、error
+var pidFile string                  // PID 文件路径
+var noFork bool                     // 始终在前台运行，仅用于在启动脚本中说明
+var webhookFormatName string        // Webhook 消息格式
+var maxPayloadBytes int             // 单条消息请求体的最大字节数，0 表示使用消息格式的默认值
+var enrichmentURL string            // 获取告警附加信息的HTTP地址
+var enrichmentTimeout time.Duration // 获取告警附加信息的超时时间
+var displayTZ string                // 通知中显示时间使用的时区
+var stateFile string                // 状态文件路径，保存读取位置与告警冷却缓存
 
-	if err != nil {
-		fmt.Printf("发送Webhook通知失败: %v\n", err)
-	} else {
-		fmt.Println("Webhook通知已发送")
-	}
+// Webhook 发送相关配置
+var webhookURLs []string                   // 额外的Webhook地址，与 webhookURL 一起并发推送
+var webhookConcurrency int                 // Webhook并发发送数，0 表示与地址数量相同
+var webhookTimeout time.Duration           // 单次通知的发送超时时间
+var webhookTLSSkipVerify bool              // 是否跳过Webhook证书校验（不安全）
+var webhookCACert string                   // Webhook服务端证书的CA文件路径
+var webhookMethod string                   // Webhook请求使用的HTTP方法
+var webhookKeepAliveInterval time.Duration // Webhook连接的TCP keep-alive 探测间隔
+var webhookHTTP2 bool                      // 是否对Webhook请求启用 HTTP/2
+var webhookFallbackURL string              // 所有地址都发送失败时使用的备用地址
+var fallbackWebhookFormatName string       // 备用地址的消息格式，为空时与 --webhookFormat 相同
+var fallbackWebhookHeaders []string        // 备用地址额外的请求头
+var labelSpecs []string                    // 附加到每条告警的环境标签
+var alertContextLines int                  // 告警中附带的前置原始日志行数
+var anomalyDetection bool                  // 是否按历史查询时间检测异常慢查询
+var anomalySigmas float64                  // 超过均值多少倍标准差视为异常
+var anomalyMinTime time.Duration           // 异常告警的最小查询时间
+var groupingWindow time.Duration           // 同一指纹的告警合并发送的时间窗口，0 表示不合并
+var digestInterval time.Duration           // 发送慢查询汇总的间隔，0 表示不发送
+var deadLetterFile string                  // 无法送达的通知写入的 JSON Lines 文件
+var historyDB string                       // 告警历史 SQLite 文件路径
+var historyRetention time.Duration         // 告警历史的保留时长
+var httpAddr string                        // 内置 HTTP 服务的监听地址，为空表示不启动
+var ackCallbackURL string                  // 告警确认链接的地址前缀
+var ackSuppressWindow time.Duration        // 告警确认后不再通知该指纹的时长
+var fingerprintRPM int                     // 同一SQL指纹每分钟执行次数超过该值时告警，0 表示不启用
+var mysqlDSN string                        // 执行 EXPLAIN 与查询连接数使用的 MySQL 连接串
+var baselineDB string                      // 执行计划基线 SQLite 文件路径
+var planChangeThreshold float64            // 估算行数增长超过多少倍视为执行计划变化
+var configDir string                       // 配置目录，读取其中的 *.yaml 与 *.toml 文件
+var dockerAutoTag bool                     // 是否读取所在 Docker 容器的标签作为告警标签
+var dockerLabelPrefix string               // 作为告警标签的容器标签前缀
+var maxLinesPerEntry int                   // 单条日志条目的最大行数，0 表示不限制
+var maxBytesPerEntry int                   // 单条日志条目的最大字节数，0 表示不限制
+var defaultDatabase string                 // 日志中没有记录数据库时使用的数据库名
+var userWebhooks string                    // 按用户发送到不同Webhook地址的配置（JSON字符串或文件路径）
+var databaseWebhooks string                // 按数据库发送到不同Webhook地址的配置（JSON字符串或文件路径）
+var escalationWebhookURL string            // 升级告警的Webhook地址
+var escalationAfter int                    // 同一指纹发送多少次告警后升级，0 表示不升级
+var escalationResetAfter time.Duration     // 指纹多久未出现后重新计数
+var sessionAlertCount int                  // 同一连接在窗口内的慢查询达到该条数时告警，0 表示不启用
+var sessionAlertWindow time.Duration       // 统计同一连接慢查询的窗口
+var migrationMode bool                     // 是否处于数据库迁移期间
+var migrationFlagFile string               // 存在期间视为迁移进行中的标记文件
+var migrationThreshold float64             // 迁移期间的慢查询阈值，单位：秒，0 表示沿用 --slowQueryThreshold
+var worstQueryFile string                  // 保存各指纹最慢记录的JSON文件
+var notificationFields []string            // 告警中显示的字段及顺序
+var sqlFieldMaxLength int                  // 告警中SQL的最大字符数，0 表示不限制
+var lockWaitThreshold float64              // 行锁等待阈值，0 表示不按锁等待告警
+var mergePassesThreshold int               // 排序合并次数阈值，0 表示不按合并次数告警
+var tmpDiskTablesThreshold int             // 磁盘临时表数量阈值，0 表示不按磁盘临时表告警
+var topFrequent int                        // 统计出现次数最多的慢查询指纹个数，0 表示不统计
+var resetFrequentAfterDigest bool          // 每次发送汇总后是否清空指纹出现次数
+var autoTuneThreshold bool                 // 是否根据历史慢查询自动调整慢查询阈值
+var autoTuneWindowHours int                // 自动调整阈值时统计的最近小时数
+var autoTunePercentile float64             // 自动调整阈值使用的百分位数
+var autoTuneMinThreshold float64           // 自动调整后的最小阈值，单位：秒
+var autoTuneMaxThreshold float64           // 自动调整后的最大阈值，单位：秒，0 表示不限制
+var autoTuneInterval time.Duration         // 重新调整阈值的间隔
+var sqlKeywords []string                   // 识别SQL语句的关键字，替换默认列表
+var sqlKeywordsExtra []string              // 在默认列表之外追加的SQL关键字
+var explainAnalyze bool                    // 告警前执行 EXPLAIN ANALYZE，附加实际的行数与耗时
+var explainCacheTTL time.Duration          // 同一指纹的 EXPLAIN ANALYZE 结果的缓存时间
+var assumePrefiltered bool                 // 慢查询日志已由 MySQL 的 log_slow_filter 筛选，不再按阈值过滤
+var slowLogFilter string                   // MySQL 开启的 log_slow_filter，例如 full_scan,full_join
+var webhookAcceptHeader string             // Webhook请求的 Accept 请求头，用于按内容协商选择格式的平台
+var webhookFormatParam string              // 在Webhook地址后追加的消息格式参数名
+var alertOnMissingLimit bool               // 没有 LIMIT 且返回大量行的 SELECT 在告警中附加说明
+var missingLimitMinRows int                // --alertOnMissingLimit 的发送行数阈值
+var mysqlVersion string                    // 慢查询日志的 MySQL 版本，只按该版本的格式解析，为空时自动识别
+var startupTest bool                       // 启动时检查日志解析、日志读取与Webhook送达后退出
+var batchGroupBy string                    // 在 --batchInterval 内按该字段合并告警：fingerprint、database、user 或 none
+var batchInterval time.Duration            // 按 --batchGroupBy 合并告警的时间窗口
+var pmmSocket string                       // 读取 Percona PMM 慢查询事件的 Unix socket，替代 --slowLogFile
+var heartbeatTable string                  // pt-heartbeat 的心跳表，设置后告警前检查复制延迟
+var heartbeatSchema string                 // 心跳表所在的数据库
+var heartbeatUTC bool                      // pt-heartbeat 以 --utc 运行，心跳时间为UTC时间
+var maxReplicationLag time.Duration        // 复制延迟超过该值时在告警中提示
+var lagCacheTTL time.Duration              // 复制延迟查询结果的缓存时间
+var selfAlertWebhookURL string             // 发送自身健康告警的地址，与 --webhookURL 分开
+var selfAlertCheckInterval time.Duration   // 检查自身健康状况的间隔
+var selfAlertCooldown time.Duration        // 自身健康告警的冷却时间
+var alertTemplateDir string                // 消息模板目录，按 <消息格式>.tmpl 或 <告警级别>.tmpl 覆盖自带模板
+var awsAurora bool                         // 按 Aurora MySQL 的日志格式解析主机并在告警中附带 Aurora 版本
+var appHostname string                     // 日志中没有 # Hostname: 时告警中显示的应用主机
+var trackMinQueryTime float64              // 不低于该值的查询都记录到历史数据库、指标与汇总，但不发送告警，单位：秒
+var auditLogFile string                    // 记录每条通知发送结果的 JSON Lines 文件
+var labelSelector string                   // 只处理日志行前缀中的标签满足该条件的日志，例如 pod=mysql-0
+var labelPrefixPattern string              // 日志行标签前缀的正则表达式，匹配的前缀会被去掉并作为告警标签
+var inputFormat string                     // 慢查询日志格式：mysql、mongodb 或 pmm
+var mysqlConnCountSuppressThreshold int    // MySQL连接数超过该值时暂停慢查询告警，0 表示不启用
+var connCountCheckInterval time.Duration   // 检查MySQL连接数的间隔
+var readFrom string                        // --readHistory 时从该时间开始处理
+var readUntil string                       // 处理到该时间为止
+var readUntilTime time.Time                // 解析后的 readUntil
+var ptDigestOutput string                  // 每个汇总周期写入的 pt-query-digest 格式报告文件
+var otelMetricsEndpoint string             // OTLP gRPC 指标推送地址
+var otelMetricsInterval time.Duration      // OTLP 指标推送间隔
+var alertOnNewFingerprint bool             // 指纹第一次出现时发送通知
+var maxEntryAge time.Duration              // 日志条目距现在超过该时间时不处理，0 表示不限制
+var instances string                       // 同时监控的多个MySQL实例（JSON字符串或文件路径）
+var rowsAffectedThreshold int              // 单条DML语句影响的行数阈值，0 表示不按影响行数告警
+var cpuTimeRatioAlert float64              // cpu_time 与 query_time 之比超过该值时告警，0 表示不启用
+var cpuTimeMinQueryTime float64            // CPU密集型告警的最小查询时间，单位：秒
+var criticalThreshold float64              // 慢查询计为 critical 级别的查询时间，单位：秒，0 表示不区分
+var metricsMinQueryTime time.Duration      // 未达到告警阈值的查询计入 tier="none" 的最小查询时间
+var webhookClientConfig string             // Webhook HTTP客户端配置文件（JSON），设置连接数与TLS版本、加密套件
+var minOccurrencesBeforeAlert int          // 同一指纹在窗口内出现达到该次数后才发送第一次告警
+var occurrenceWindow time.Duration         // 统计 --minOccurrencesBeforeAlert 出现次数的窗口
+var noTimestamp bool                       // 工具自身的日志不输出时间前缀
+var alertOnFilesort bool                   // 是否对 # Filesort: Yes 的查询发送提示，与查询时间无关
+var alertOnAdminCommands bool              // 是否对 # administrator command: Quit 等管理命令告警
+var alertOnQCHit bool                      // 是否对命中查询缓存的慢查询告警
+var webhookTemplate string                 // 消息模板文件，使用 Go text/template 语法
+var globCheckInterval time.Duration        // 通配符模式下查找新日志文件的间隔
+
+// 通过SSH读取远程主机上的慢查询日志
+var sshHost string        // 远程主机地址，为空表示读取本地文件
+var sshUser string        // SSH用户名
+var sshKeyFile string     // SSH私钥文件路径
+var sshAgentSocket string // SSH agent 的 socket 路径
+var sshKnownHosts string  // known_hosts 文件路径，用于校验远程主机公钥
+
+// 监听 SIGHUP 信号，重新加载可热更新的配置
+func handleReloadSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	reloadOnSignals(signals)
 }
 
-// 解析慢查询日志并判断是否是慢查询
-func processSlowQuery(logLines []string) {
-	// 变量声明
-	var queryTime float64
-	var lockTime float64
-	var rowsSent int
-	var rowsExamined int
-	var database string
-	var user string
-	var host string
-	var sqlQuery string
-
-	for _, line := range logLines {
-		if matches := queryTimePattern.FindStringSubmatch(line); matches != nil {
-			queryTime, _ = strconv.ParseFloat(matches[1], 64)
-			lockTime, _ = strconv.ParseFloat(matches[2], 64)
-			rowsSent, _ = strconv.Atoi(matches[3])
-			rowsExamined, _ = strconv.Atoi(matches[4])
-		}
-		if matches := userHostPattern.FindStringSubmatch(line); matches != nil {
-			user = matches[1]
-			host = matches[2]
-		}
-		if matches := databasePattern.FindStringSubmatch(line); matches != nil {
-			database = matches[1]
-		}
-		if matches := sqlQueryEndPattern.FindStringSubmatch(line); matches != nil {
-			sqlQuery = line
-		}
-	}
-
-	if queryTime >= slowQueryThreshold {
-		notificationContent := fmt.Sprintf(
-			`<font color=\"warning\">**慢查询警告**</font>\n`+
-				`> **查询时间:** <font color=\"warning\">%.2f 秒</font>\n`+
-				`> **锁定时间:** <font color=\"comment\">%.2f 秒</font>\n`+
-				`> **数据库:** <font color=\"comment\">%s</font>\n`+
-				`> **主机:** <font color=\"comment\">%s</font>\n`+
-				`> **用户:** <font color=\"comment\">%s</font>\n`+
-				`> **发送的行数:** <font color=\"comment\">%d</font>\n`+
-				`> **扫描的行数:** <font color=\"comment\">%d</font>\n`+
-				`> **SQL 查询:** <font color=\"comment\">%s</font>\n`,
-			queryTime, lockTime, database, host, user, rowsSent, rowsExamined, sqlQuery)
-
-		// 发送 Webhook 通知
-		sendWebhookNotification(notificationContent)
+// 每收到一个信号重新加载一次配置，直到 signals 关闭
+func reloadOnSignals(signals <-chan os.Signal) {
+	for range signals {
+		reloadConfig()
 	}
 }
 
-// 实时读取MySQL慢查询日志
-func tailSlowLog(wg *sync.WaitGroup, restart chan bool) {
-	defer wg.Done()
+// 注册命令行参数
+func registerFlags() {
+	pflag.StringVarP(&webhookURL, "webhookURL", "u", "", "Webhook URL 用于发送通知，多个用逗号分隔，支持 url|format 格式单独指定消息格式，例如 https://hooks.slack.com/...|slack,https://qyapi.weixin.qq.com/...|wechat")
+	pflag.StringVarP(&slowLogFile, "slowLogFile", "f", "/var/log/mysql/mysql-slow.log", "MySQL慢查询日志文件路径，支持通配符，例如 /var/log/mysql/mysql-slow.log*")
+	pflag.StringVar(&userWebhooks, "userWebhooks", "", `按用户把告警发送到不同的Webhook地址，JSON字符串或文件路径，例如 {"etl_user":"https://etl-webhook","app_*":"https://app-webhook"}，用户名支持通配符，优先于 --databaseWebhooks`)
+	pflag.StringVar(&databaseWebhooks, "databaseWebhooks", "", `按数据库把告警发送到不同的Webhook地址，JSON字符串或文件路径，例如 {"analytics":"https://bi-webhook"}，未匹配的告警发送到 --webhookURL`)
+	pflag.StringVar(&escalationWebhookURL, "escalationWebhookURL", "", "升级告警的Webhook地址（例如值班群或 PagerDuty），同一指纹告警次数超过 --escalationAfter 后，之后的告警同时发送到该地址，也可以用 url|格式 指定消息格式")
+	pflag.IntVar(&escalationAfter, "escalationAfter", 3, "同一指纹发送多少次告警后升级，0 表示不升级")
+	pflag.DurationVar(&escalationResetAfter, "escalationResetAfter", time.Hour, "指纹超过该时间未出现时重新统计告警次数")
+	pflag.IntVar(&sessionAlertCount, "sessionAlertCount", 0, "同一连接（Thread_id / Id）在 --sessionAlertWindow 内产生的慢查询达到该条数时发送会话模式告警，用于发现循环执行查询的请求，0 表示不启用")
+	pflag.DurationVar(&sessionAlertWindow, "sessionAlertWindow", time.Minute, "统计同一连接慢查询的窗口")
+	pflag.BoolVar(&migrationMode, "migrationMode", false, "迁移模式：告警标题加上 [MIGRATION ALERT]，使用 --migrationThreshold，并显示迁移已进行的时间与日志中的DDL语句")
+	pflag.StringVar(&migrationFlagFile, "migrationFlagFile", "", "迁移标记文件，文件存在期间启用迁移模式，迁移开始时间为文件的修改时间，适合在迁移脚本中 touch / rm")
+	pflag.Float64Var(&migrationThreshold, "migrationThreshold", 0, "迁移期间的慢查询阈值，单位：秒，低于当前阈值时生效，0 表示沿用 --slowQueryThreshold")
+	pflag.StringVar(&worstQueryFile, "worstQueryFile", "", "保存各SQL指纹最慢查询时间的JSON文件，重启后继续使用，慢查询刷新了该指纹的最慢记录时发送通知")
+	pflag.StringSliceVar(&notificationFields, "notificationFields", nil, "告警中显示的字段及顺序（逗号分隔），未列出的字段不显示，可选值: "+strings.Join(slowQueryFieldNames, ",")+"，SQL 始终显示在字段之后")
+	pflag.IntVar(&sqlFieldMaxLength, "sqlFieldMaxLength", 0, "告警中SQL的最大字符数，超过时截断，0 表示不限制")
+	pflag.Float64Var(&lockWaitThreshold, "lockWaitThreshold", 0, "Percona 日志中 InnoDB_rec_lock_waits 达到该值时告警，与查询时间无关，用于发现锁竞争，0 表示不按锁等待告警")
+	pflag.IntVar(&mergePassesThreshold, "mergePassesThreshold", 0, "Percona 日志中 Merge_passes 达到该值时告警，与查询时间无关，合并次数越多说明写入磁盘的排序越大，0 表示不按合并次数告警")
+	pflag.IntVar(&tmpDiskTablesThreshold, "tmpDiskTablesThreshold", 0, "Percona 日志中 Tmp_disk_tables 达到该值时告警（排序或 GROUP BY 写入磁盘临时表），0 表示不按磁盘临时表告警")
+	pflag.IntVar(&topFrequent, "topFrequent", 10, "统计启动以来出现次数最多的慢查询指纹个数，通过 /api/v1/frequent-queries 查看并加入汇总，0 表示不统计")
+	pflag.BoolVar(&resetFrequentAfterDigest, "resetFrequentAfterDigest", false, "每次发送汇总后清空慢查询指纹的出现次数")
+	pflag.BoolVar(&autoTuneThreshold, "autoTuneThreshold", false, "启动时根据慢查询日志中最近的查询时间分布自动设置 --slowQueryThreshold，并定期重新调整，仅支持本地单个日志文件")
+	pflag.IntVar(&autoTuneWindowHours, "autoTuneWindowHours", 24, "自动调整阈值时统计最近多少小时的慢查询")
+	pflag.Float64Var(&autoTunePercentile, "autoTunePercentile", 95, "自动调整阈值使用的查询时间百分位数")
+	pflag.Float64Var(&autoTuneMinThreshold, "autoTuneMinThreshold", 0.1, "自动调整后的最小阈值，单位：秒")
+	pflag.Float64Var(&autoTuneMaxThreshold, "autoTuneMaxThreshold", 60, "自动调整后的最大阈值，单位：秒，0 表示不限制")
+	pflag.DurationVar(&autoTuneInterval, "autoTuneInterval", 6*time.Hour, "重新调整阈值的间隔")
+	pflag.StringSliceVar(&sqlKeywords, "sqlKeywords", nil, "识别SQL语句的关键字（逗号分隔），替换默认列表 "+strings.Join(defaultSQLKeywords, ","))
+	pflag.StringSliceVar(&sqlKeywordsExtra, "sqlKeywordsExtra", nil, "在默认SQL关键字之外追加的关键字（逗号分隔），例如 LOAD,TRUNCATE")
+	pflag.BoolVar(&explainAnalyze, "explainAnalyze", false, "告警前对 SELECT 执行 EXPLAIN ANALYZE FORMAT=TREE，在告警中列出各节点的估算与实际行数、循环次数、耗时与成本，估算与实际相差较大时提示统计信息可能过期；需要 MySQL 8.0.18+（--mysqlVersion 为 8.0 或 percona-8.0）与 --mysqlDSN，注意 EXPLAIN ANALYZE 会实际执行查询，带 FOR UPDATE / INTO 等子句或主语句不是 SELECT 的查询不执行")
+	pflag.DurationVar(&explainCacheTTL, "explainCacheTTL", time.Hour, "同一指纹的 EXPLAIN ANALYZE 结果的缓存时间，避免重复执行耗时的查询")
+	pflag.BoolVar(&assumePrefiltered, "assumePrefiltered", false, "慢查询日志已由 MySQL 的 log_slow_filter 等设置筛选，不再按 --slowQueryThreshold 等阈值过滤，对每条解析出的日志条目都告警；管理命令、查询缓存命中与过期条目仍然跳过")
+	pflag.StringVar(&slowLogFilter, "slowLogFilter", "", "MySQL 开启的 log_slow_filter，多个用逗号分隔，例如 full_scan,full_join；设置后慢查询汇总中会注明日志只包含匹配这些条件的查询")
+	pflag.StringVar(&webhookAcceptHeader, "webhookAcceptHeader", "", "Webhook请求的 Accept 请求头，用于 Zapier、n8n 等按内容协商选择消息格式的平台，为空时不发送；各地址的 Headers 配置优先")
+	pflag.StringVar(&webhookFormatParam, "webhookFormatParam", "", "在Webhook地址后追加消息格式参数，例如设置为 format 时请求 https://example.com/hook?format=slack，值为该地址使用的消息格式，用于在自动化平台中按格式分流")
+	pflag.BoolVar(&alertOnMissingLimit, "alertOnMissingLimit", false, "SELECT 语句没有 LIMIT 且 Rows_sent 超过 --missingLimitMinRows 时，在告警中附加 \"⚠️ Large unbounded query (no LIMIT clause)\" 说明，用于发现忘了分页的查询；没有 GROUP BY 的 COUNT(*) 等聚合查询除外")
+	pflag.IntVar(&missingLimitMinRows, "missingLimitMinRows", 1000, "--alertOnMissingLimit 的发送行数阈值")
+	pflag.StringVar(&mysqlVersion, "mysqlVersion", "", "慢查询日志的 MySQL 版本: 5.5、5.6、5.7、8.0、percona-5.7、percona-8.0 或 mariadb-10.x，只按该版本的时间格式与字段解析，日志条目不符合该版本的格式时输出警告并说明原因；为空时自动识别各版本的格式")
+	pflag.BoolVar(&startupTest, "startupTest", false, "检查完整流程后退出：按当前配置解析内置日志样例、读取慢查询日志、向配置的地址发送测试通知并确认送达；退出码 0 成功、1 配置无效、2 日志无法读取、3 Webhook 无法送达、4 Webhook 鉴权失败，可用作 Kubernetes init 容器")
+	pflag.StringVar(&batchGroupBy, "batchGroupBy", "", "按字段合并告警: fingerprint、database、user 或 none（全部合并），同一分组在 --batchInterval 内的慢查询合并为一条列出各查询的通知，避免单个数据库的大量告警淹没其他数据库的告警，不能与 --groupingWindow 同时使用")
+	pflag.DurationVar(&batchInterval, "batchInterval", 30*time.Second, "按 --batchGroupBy 合并告警的时间窗口")
+	pflag.StringVar(&pmmSocket, "pmmSocket", "", "从 Percona PMM 的 Unix socket 读取每行一条 JSON 的慢查询事件（ts、schema、username、client_host、query_time、rows_examined、example 等字段），替代读取 --slowLogFile，适用于无法访问日志文件的容器或托管MySQL")
+	pflag.StringVar(&heartbeatTable, "heartbeatTable", "", "pt-heartbeat 的心跳表，设置后在发送告警前通过 --mysqlDSN 查询从库的复制延迟，超过 --maxReplicationLag 时在告警中显示复制延迟与提示")
+	pflag.StringVar(&heartbeatSchema, "heartbeatSchema", "percona", "心跳表所在的数据库，与 pt-heartbeat 的 --database 相同")
+	pflag.BoolVar(&heartbeatUTC, "heartbeatUTC", false, "pt-heartbeat 以 --utc 运行时设置，使用 UTC_TIMESTAMP() 计算复制延迟")
+	pflag.DurationVar(&maxReplicationLag, "maxReplicationLag", 30*time.Second, "复制延迟超过该值时在告警中提示慢查询可能与复制延迟有关")
+	pflag.DurationVar(&lagCacheTTL, "lagCacheTTL", 10*time.Second, "复制延迟查询结果的缓存时间，避免每条告警都查询数据库")
+	pflag.StringVar(&selfAlertWebhookURL, "selfAlertWebhookURL", "", "自身运行异常（最近5分钟Webhook发送失败率超过50%、日志监控协程重新启动超过3次）时发送纯文本告警的地址，格式同 --webhookURL，应与 --webhookURL 不同，避免告警地址故障时无法通知")
+	pflag.DurationVar(&selfAlertCheckInterval, "selfAlertCheckInterval", time.Minute, "检查自身健康状况的间隔")
+	pflag.DurationVar(&selfAlertCooldown, "selfAlertCooldown", 30*time.Minute, "自身健康告警的冷却时间，异常持续时每隔该时间重复发送")
+	pflag.StringVar(&alertTemplateDir, "alertTemplateDir", "", "消息模板目录：<消息格式>.tmpl（例如 wechat.tmpl、slack.tmpl）替换该格式自带的模板，critical.tmpl、warn.tmpl 用于对应级别（见 --criticalThreshold）的告警，没有对应文件时使用自带模板，目录修改后自动重新加载")
+	pflag.BoolVar(&awsAurora, "awsAurora", false, "读取 Amazon Aurora MySQL 的慢查询日志：# User@Host: 中的地址作为主机、VPC内的主机名作为客户端主机名，告警中附带 # aurora_version: 记录的版本（没有记录的条目使用最近一次的版本）")
+	pflag.StringVar(&appHostname, "appHostname", "", "日志中没有 ProxySQL、MaxScale 添加的 # Hostname: 时，告警中显示的应用主机（Application Host，与MySQL服务器主机不同）；--instances 中可以用 appHostname 为每个实例单独设置")
+	pflag.Float64Var(&trackMinQueryTime, "trackMinQueryTime", 0, "查询时间不低于该值（秒）的查询都记录到 --historyDB、slow_query_total{tier=\"none\"} 与汇总中，但只有达到 --slowQueryThreshold 的才发送告警，0 表示不启用")
+	pflag.StringVar(&auditLogFile, "auditLog", "", "把每条通知的发送结果（时间、标题、地址、是否成功）追加到该 JSON Lines 文件，logrotate 轮转后发送 SIGUSR1 或 SIGUSR2 重新打开文件")
+	pflag.StringVar(&labelSelector, "labelSelector", "", "只处理日志行前缀中的标签满足该条件的日志（key=value，多个条件以逗号分隔且需同时满足），用于读取日志采集器合并的多个 Pod 的日志，例如 pod=mysql-0")
+	pflag.StringVar(&labelPrefixPattern, "labelPrefixPattern", "", "日志行标签前缀的正则表达式，匹配的前缀在解析前去掉，其中的 key=value 作为告警标签；有名为 labels 的分组时只从该分组解析，未设置时只在使用 --labelSelector 时按 key=value ... | 格式处理前缀")
+	pflag.StringVar(&inputFormat, "inputFormat", "mysql", "慢查询日志格式: mysql、mongodb（每行一条 JSON 的结构化日志，或旧版本的文本日志）或 pmm（Percona PMM 导出的每行一条 JSON 的慢查询事件）")
+	pflag.IntVar(&mysqlConnCountSuppressThreshold, "mysqlConnCountSuppressThreshold", 0, "通过 --mysqlDSN 定期查询 Threads_connected，超过该值时数据库负载过高、所有查询都会变慢，暂停发送慢查询告警并发送一次通知，回落后自动恢复，0 表示不启用")
+	pflag.DurationVar(&connCountCheckInterval, "mysqlConnCountCheckInterval", 30*time.Second, "检查MySQL连接数的间隔")
+	pflag.StringVar(&readFrom, "readFrom", "", "配合 --readHistory 使用，跳过该时间之前的日志条目（不发送告警），从 # Time: 不早于该时间的条目开始处理，格式同 --startFrom")
+	pflag.StringVar(&readUntil, "readUntil", "", "处理到该时间为止，# Time: 不早于该时间的日志条目及之后的日志不再处理，与 --readFrom 一起用于重放某个时间段的慢查询，格式同 --startFrom")
+	pflag.StringVar(&ptDigestOutput, "ptDigestOutput", "", "按 pt-query-digest 的文本格式输出慢查询报告的文件，需要 --digestInterval，每个汇总周期覆盖写入一次；batch 子命令中输出整个日志的报告")
+	pflag.StringVar(&otelMetricsEndpoint, "otelMetricsEndpoint", "", "通过 OTLP gRPC 推送与 /metrics 相同的指标，例如 https://otlp-gateway.example.com:4317，http:// 表示不使用TLS，认证信息通过 OTEL_EXPORTER_OTLP_HEADERS 环境变量设置，可以与 --httpAddr 同时使用")
+	pflag.DurationVar(&otelMetricsInterval, "otelMetricsInterval", time.Minute, "OTLP 指标推送间隔")
+	pflag.BoolVar(&alertOnNewFingerprint, "alertOnNewFingerprint", false, "SQL指纹第一次出现时发送新慢查询模式通知，即使未达到 --slowQueryThreshold，配合 --stateFile 在重启后保留已出现过的指纹，适合在开发、测试环境发现新引入的查询")
+	pflag.DurationVar(&maxEntryAge, "maxEntryAge", 0, "按 # Time: 计算，距现在超过该时间的日志条目不处理也不告警，避免 --readHistory 或长时间中断后发送过期的告警，0 表示不限制")
+	pflag.StringVar(&instances, "instances", "", `同时监控多个MySQL实例，JSON数组字符串或文件路径，每个实例可单独设置 slowLogFile、webhookURL、slowQueryThreshold 与 labels，例如 [{"name":"primary","slowLogFile":"/var/log/mysql/primary-slow.log"},{"name":"replica","slowLogFile":"/var/log/mysql/replica-slow.log","slowQueryThreshold":5}]，设置后忽略 --slowLogFile`)
+	pflag.IntVar(&rowsAffectedThreshold, "rowsAffectedThreshold", 0, "单条 UPDATE/DELETE/INSERT/REPLACE 语句的 Rows_affected 达到该值时发送 DML Warning 告警，与查询时间无关，用于发现造成锁竞争的大批量修改，0 表示不按影响行数告警")
+	pflag.Float64Var(&cpuTimeRatioAlert, "cpuTimeRatioAlert", 0, "MySQL 8.0.14+ 开启 log_slow_extra 时，cpu_time 与 query_time 之比超过该值（例如 0.9）时发送CPU密集型查询告警，与告警阈值无关，0 表示不启用")
+	pflag.Float64Var(&cpuTimeMinQueryTime, "cpuTimeMinQueryTime", 1, "CPU密集型查询告警的最小查询时间，单位：秒")
+	pflag.Float64Var(&criticalThreshold, "criticalThreshold", 0, "查询时间达到该值（秒）的告警在 slow_query_total 中计为 tier=\"critical\"，其余告警计为 tier=\"warn\"，0 表示不区分")
+	pflag.DurationVar(&metricsMinQueryTime, "metricsMinQueryTime", 0, "未达到告警阈值的慢查询中，查询时间不低于该值的计入 slow_query_total{tier=\"none\"}，例如 100ms")
+	pflag.StringVar(&webhookClientConfig, "webhookClientConfig", "", "Webhook HTTP客户端配置文件（JSON），可设置 MaxIdleConns、MaxConnsPerHost、IdleConnTimeout、TLSMinVersion、TLSMaxVersion、TLSCipherSuites，请求超时始终由 --webhookTimeout 决定")
+	pflag.IntVar(&minOccurrencesBeforeAlert, "minOccurrencesBeforeAlert", 1, "同一SQL指纹在 --occurrenceWindow 内出现达到该次数后才发送第一次告警，避免只出现一次的偶发慢查询（例如一次性的迁移语句）产生噪音，之后的告警不再等待")
+	pflag.DurationVar(&occurrenceWindow, "occurrenceWindow", 5*time.Minute, "统计 --minOccurrencesBeforeAlert 出现次数的窗口")
+	pflag.BoolVar(&noTimestamp, "noTimestamp", false, "工具自身的日志不输出时间前缀，适用于 systemd、Docker 等已经为每行日志添加时间的环境，不影响通知内容")
+	pflag.BoolVar(&alertOnFilesort, "alertOnFilesort", false, "Percona 日志中 # Filesort: Yes 的查询未达到告警阈值时也发送提示（💾），并标明是否写入磁盘（Filesort_on_disk）")
+	pflag.BoolVar(&alertOnAdminCommands, "alertOnAdminCommands", false, "对只有 # administrator command: Quit/Connect/Sleep 的日志条目也发送告警，这类条目的查询时间通常是连接空闲的时间")
+	pflag.BoolVar(&alertOnQCHit, "alertOnQCHit", false, "对日志中 # QC_Hit: Yes 的慢查询也发送告警，这类查询由查询缓存返回，慢通常是因为缓存锁竞争")
+	pflag.StringVar(&webhookTemplate, "webhookTemplate", "", "消息模板文件（Go text/template 语法），替换消息格式自带的消息文本，文件修改后自动重新加载")
+	pflag.StringVar(&defaultDatabase, "defaultDatabase", "", "日志中既没有 # Schema: 也没有 use db; 时使用的数据库名，告警中会注明数据库为推断值")
+	pflag.IntVar(&maxLinesPerEntry, "maxLinesPerEntry", 1000, "单条日志条目的最大行数，超过时截断后处理，避免异常的超大条目耗尽内存，0 表示不限制")
+	pflag.IntVar(&maxBytesPerEntry, "maxBytesPerEntry", 4*1024*1024, "单条日志条目的最大字节数，超过时截断后处理，0 表示不限制")
+	pflag.DurationVar(&globCheckInterval, "globCheckInterval", time.Minute, "--slowLogFile 为通配符时查找新日志文件的间隔")
+	pflag.Float64VarP(&slowQueryThreshold, "slowQueryThreshold", "s", 0.5, "慢查询阈值，单位：秒，支持整数或小数")
+	pflag.BoolVarP(&isTest, "test", "t", false, "发送一个测试WebHook请求")
+	pflag.BoolVarP(&readHistory, "readHistory", "r", false, "是否读取历史日志数据")
+	pflag.DurationVar(&alertCooldown, "alertCooldown", 0, "同一SQL指纹的告警冷却时间，例如 10m，0 表示不启用")
+	pflag.StringSliceVar(&webhookURLs, "webhookURLs", nil, "额外的Webhook URL，多个用逗号分隔，与 --webhookURL 一起并发推送，支持 url|format|timeout 格式单独指定消息格式与超时")
+	pflag.IntVar(&webhookConcurrency, "webhookConcurrency", 0, "Webhook并发发送数，默认与地址数量相同，最大 10")
+	pflag.DurationVar(&webhookTimeout, "webhookTimeout", 10*time.Second, "发送Webhook通知的默认超时时间")
+	pflag.BoolVar(&webhookTLSSkipVerify, "webhookTLSSkipVerify", false, "跳过Webhook服务端证书校验（不安全，仅用于测试环境）")
+	pflag.StringVar(&webhookCACert, "webhookCACert", "", "Webhook服务端证书的CA文件路径（PEM格式），用于自签名证书")
+	pflag.DurationVar(&webhookKeepAliveInterval, "webhookKeepAliveInterval", 30*time.Second, "Webhook连接的TCP keep-alive 探测间隔")
+	pflag.StringVar(&webhookFallbackURL, "webhookFallbackURL", "", "备用Webhook URL，通知未能发送到任何地址时改为发送到该地址")
+	pflag.StringVar(&fallbackWebhookFormatName, "fallbackWebhookFormat", "", "备用地址的消息格式，默认与 --webhookFormat 相同")
+	pflag.StringArrayVar(&fallbackWebhookHeaders, "fallbackWebhookHeader", nil, "备用地址额外的请求头，格式为 \"Name: Value\"，可重复指定")
+	pflag.StringSliceVar(&labelSpecs, "labels", nil, "附加到每条告警的环境标签，格式为 key=value，多个用逗号分隔，例如 env=production,region=ap-southeast-1，同时作为 Prometheus 指标的固定标签并记录到 --historyDB")
+	pflag.IntVar(&alertContextLines, "alertContextLines", 0, "在告警中附带该条日志之前的 N 行原始日志，便于排查锁等待、批量操作等上下文")
+	pflag.StringVar(&sshHost, "sshHost", "", "通过SSH读取远程主机上的慢查询日志，格式为 host 或 host:port，--slowLogFile 为远程路径")
+	pflag.StringVar(&sshUser, "sshUser", "", "SSH用户名，默认为当前用户")
+	pflag.StringVar(&sshKeyFile, "sshKeyFile", "", "SSH私钥文件路径")
+	pflag.StringVar(&sshAgentSocket, "sshAgentSocket", "", "SSH agent 的 socket 路径（例如 $SSH_AUTH_SOCK），用于认证并转发到远程主机")
+	pflag.StringVar(&sshKnownHosts, "sshKnownHosts", defaultKnownHostsFile(), "known_hosts 文件路径，用于校验远程主机公钥")
+	pflag.BoolVar(&dockerAutoTag, "dockerAutoTag", false, "运行在 Docker 容器中时，读取容器标签作为告警标签（需要挂载 "+dockerSocket+"）")
+	pflag.StringVar(&dockerLabelPrefix, "dockerLabelPrefix", "mysql-monitor.", "--dockerAutoTag 读取的容器标签前缀，去掉前缀后作为标签名")
+	pflag.BoolVar(&anomalyDetection, "anomalyDetection", false, "按数据库、用户统计历史查询时间，明显偏离历史水平时单独发送异常告警（即使未达到慢查询阈值）")
+	pflag.Float64Var(&anomalySigmas, "anomalySigmas", 3.0, "查询时间超过历史均值多少倍标准差时视为异常")
+	pflag.DurationVar(&anomalyMinTime, "anomalyMinTime", 100*time.Millisecond, "异常告警的最小查询时间，低于该值时不视为异常")
+	pflag.DurationVar(&groupingWindow, "groupingWindow", 0, "同一SQL指纹的告警在该时间窗口内合并为一条通知，在窗口结束时发送出现次数、查询时间范围与执行的用户和主机，0 表示不合并")
+	pflag.DurationVar(&digestInterval, "digestInterval", 0, "定期发送慢查询汇总的间隔，按数据库统计查询数、总耗时、平均耗时、P95 与指纹数，例如 1h、24h，0 表示不发送")
+	pflag.StringVar(&deadLetterFile, "deadLetterFile", "", "所有地址（包括备用地址）都发送失败的通知写入该 JSON Lines 文件，可通过 replay-dead-letter 子命令重新发送")
+	pflag.StringVar(&historyDB, "historyDB", "", "告警历史 SQLite 文件路径，记录触发告警的慢查询与每次Webhook发送的结果，可通过 /api/v1/delivery-stats 查看发送统计")
+	pflag.DurationVar(&historyRetention, "historyRetention", 30*24*time.Hour, "告警历史的保留时长，过期记录每小时清理一次")
+	pflag.StringVar(&httpAddr, "httpAddr", "", "内置 HTTP 服务的监听地址，提供 /healthz、/metrics 与告警确认接口，例如 :8080")
+	pflag.StringVar(&ackCallbackURL, "ackCallbackURL", "", "告警确认链接的地址前缀，通常为 --httpAddr 对外的访问地址，例如 https://monitor.example.com:8080，设置后每条告警附带一次性的确认链接")
+	pflag.DurationVar(&ackSuppressWindow, "ackSuppressWindow", 4*time.Hour, "告警确认后不再通知该SQL指纹的时长")
+	pflag.IntVar(&fingerprintRPM, "fingerprintRPM", 0, "同一SQL指纹每分钟出现次数超过该值时发送高频慢查询告警，用于发现 N+1 查询，0 表示不启用")
+	pflag.StringVar(&mysqlDSN, "mysqlDSN", "", "执行 EXPLAIN 与查询连接数使用的 MySQL 连接串，例如 monitor:password@tcp(127.0.0.1:3306)/")
+	pflag.StringVar(&baselineDB, "baselineDB", "", "执行计划基线 SQLite 文件路径，指定后告警前重新执行 EXPLAIN 并与基线比较（需同时指定 --mysqlDSN）")
+	pflag.Float64Var(&planChangeThreshold, "planChangeThreshold", 10, "估算扫描行数超过基线多少倍时视为执行计划变化")
+	pflag.StringVar(&configDir, "configDir", "", "配置目录（例如 Kubernetes ConfigMap 挂载目录），按文件名顺序合并其中的 *.yaml 与 *.toml 文件，配置项与命令行参数同名，目录变化时自动重新加载")
+	pflag.BoolVar(&webhookHTTP2, "webhookHTTP2", false, "Webhook请求启用 HTTP/2，同一主机的并发请求复用一个连接；HTTP/2 需要 HTTPS，服务端不支持时自动使用 HTTP/1.1")
+	pflag.StringVar(&webhookMethod, "webhookMethod", "POST", "Webhook请求使用的HTTP方法："+strings.Join(webhookMethods, "、"))
+	pflag.StringVar(&startFrom, "startFrom", "", "从指定时间开始处理历史日志，例如 2024-01-01T08:00:00+08:00 或 \"2024-01-01 08:00:00\"")
+	pflag.StringVar(&databaseThresholds, "databaseThresholds", "", `按数据库覆盖阈值，JSON字符串或文件路径，例如 {"analytics":{"queryTime":30,"rowsExamined":5000000}}，文件方式支持 SIGHUP 热加载`)
+	pflag.StringVar(&logLevelName, "logLevel", "info", "日志级别：debug、info、warn、error")
+	pflag.StringVar(&pidFile, "pidFile", "", "PID文件路径，启动时写入、退出时删除，用于 init.d / systemd PIDFile=")
+	pflag.StringVar(&webhookFormatName, "webhookFormat", "wechat", "Webhook消息格式："+strings.Join(webhookFormatNames(), "、"))
+	pflag.IntVar(&maxPayloadBytes, "maxPayloadBytes", 0, "单条消息请求体的最大字节数，超过时拆分为多条发送，默认按消息格式取值（企业微信 4096、Slack 3000、Teams 28KB、飞书 20KB）")
+	pflag.StringVar(&enrichmentURL, "enrichmentURL", "", "发送告警前请求该地址获取附加信息（GET ?database=&user=&host=，返回JSON对象），结果作为额外字段加入通知")
+	pflag.DurationVar(&enrichmentTimeout, "enrichmentTimeout", 500*time.Millisecond, "获取告警附加信息的超时时间，超时后不带附加信息直接发送")
+	pflag.StringVar(&displayTZ, "displayTZ", "Local", "通知中显示时间使用的时区，例如 Asia/Shanghai、UTC，建议显式设置")
+	pflag.StringVar(&stateFile, "stateFile", "", "状态文件路径，退出时保存读取位置与告警冷却记录，重启后据此继续处理并避免重复告警")
+	pflag.BoolVar(&noFork, "noFork", false, "在前台运行（本工具始终在前台运行，此参数仅用于在启动脚本中明确说明）")
+}
+
+// 需要同时输出参数说明的配置错误
+type usageError struct{ error }
 
-	t, err := tail.TailFile(slowLogFile, tail.Config{
-		Follow:    true, // 实时跟踪文件变化
-		ReOpen:    true, // 支持文件轮转
-		MustExist: true, // 文件必须存在
-		Poll:      true, // 使用轮询模式
-	})
+// 解析与检查配置，返回的错误说明配置无效
+func configure() error {
+	if configDir != "" {
+		if err := applyConfigDir(configDir); err != nil {
+			return err
+		}
+	}
+
+	level, err := parseLogLevel(logLevelName)
 	if err != nil {
-		fmt.Printf("无法跟踪慢查询日志文件: %v\n", err)
-		restart <- true
-		return
+		return err
 	}
+	setLogLevel(level)
+	setLogTimestamp(!noTimestamp)
 
-	var logLines []string
-	for line := range t.Lines {
-		// 读取每一行日志
-		if line.Text == "" {
-			continue
+	if startFrom != "" {
+		t, err := parseTimestamp(startFrom)
+		if err != nil {
+			return fmt.Errorf("--startFrom 参数无效: %w", err)
 		}
-
-		if queryStartPattern.MatchString(line.Text) {
-			if len(logLines) > 0 {
-				processSlowQuery(logLines) // 处理当前完整日志条目
-			}
-			logLines = []string{line.Text} // 初始化新的日志条目
-		} else {
-			logLines = append(logLines, line.Text)
+		startFromTime = t
+	}
+	if readFrom != "" {
+		if !readHistory || startFrom != "" {
+			return errors.New("--readFrom 需要同时设置 --readHistory，且不能与 --startFrom 同时使用")
 		}
+		t, err := parseTimestamp(readFrom)
+		if err != nil {
+			return fmt.Errorf("--readFrom 参数无效: %w", err)
+		}
+		startFromTime = t
+	}
+	if readUntil != "" {
+		t, err := parseTimestamp(readUntil)
+		if err != nil {
+			return fmt.Errorf("--readUntil 参数无效: %w", err)
+		}
+		if !startFromTime.IsZero() && !t.After(startFromTime) {
+			return errors.New("--readUntil 必须晚于 --readFrom / --startFrom")
+		}
+		readUntilTime = t
+	}
+
+	if err := configureSQLKeywords(); err != nil {
+		return err
+	}
+	if err := configureInputFormat(); err != nil {
+		return err
+	}
+	if err := configureSlowLogFilter(); err != nil {
+		return err
+	}
+	if err := configureMySQLVersion(); err != nil {
+		return err
+	}
+	if err := configureLabelSelector(); err != nil {
+		return err
+	}
+	if err := validateNotificationFields(notificationFields); err != nil {
+		return err
+	}
+	if err := configureBatchGroupBy(); err != nil {
+		return err
+	}
+
+	if err := reloadDatabaseThresholds(); err != nil {
+		return err
+	}
+
+	location, err := time.LoadLocation(displayTZ)
+	if err != nil {
+		return fmt.Errorf("--displayTZ 参数无效: %w", err)
+	}
+	displayLocation = location
 
-		if sqlQueryEndPattern.MatchString(line.Text) {
-			processSlowQuery(logLines) // 处理完整的日志条目
-			logLines = nil             // 清空已处理的日志
+	if alertLabels, err = parseLabels(labelSpecs); err != nil {
+		return err
+	}
+	if dockerAutoTag {
+		containerLabels, err := dockerAutoLabels(dockerLabelPrefix)
+		if err != nil {
+			return fmt.Errorf("读取容器标签失败: %w", err)
 		}
+		alertLabels = mergeLabels(containerLabels, alertLabels)
+	}
+	configureMetricLabels(alertLabels)
+
+	if ptDigestOutput != "" && digestInterval <= 0 {
+		return errors.New("--ptDigestOutput 需要同时设置 --digestInterval")
+	}
+	if monitoredInstances, err = loadInstances(instances); err != nil {
+		return err
+	}
+	if len(monitoredInstances) > 0 && (sshHost != "" || autoTuneThreshold) {
+		return errors.New("--instances 不能与 --sshHost、--autoTuneThreshold 同时使用")
+	}
+	if pmmSocket != "" && (len(monitoredInstances) > 0 || sshHost != "" || autoTuneThreshold) {
+		return errors.New("--pmmSocket 不能与 --instances、--sshHost、--autoTuneThreshold 同时使用")
+	}
 
-		// 处理日志的最后剩余部分（文件结束时未处理的部分）
-		if len(logLines) > 0 {
-			processSlowQuery(logLines)
+	cfg, err := currentConfig()
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		return usageError{err}
+	}
+
+	if err := configureWebhooks(); err != nil {
+		return err
+	}
+	if webhookTLSSkipVerify {
+		logf(levelWarn, "已启用 --webhookTLSSkipVerify，Webhook请求将不校验服务端证书，存在中间人攻击风险！")
+	}
+
+	if webhookHTTP2 {
+		for _, target := range webhookDestinations {
+			if strings.HasPrefix(strings.ToLower(target.URL), "http://") {
+				logf(levelWarn, "HTTP/2 需要 HTTPS，%s 将使用 HTTP/1.1", target.URL)
+			}
 		}
 	}
+	return nil
 }
 
 func main() {
-	pflag.StringVarP(&webhookURL, "webhookURL", "u", "", "Webhook URL 用于发送通知")
-	pflag.StringVarP(&slowLogFile, "slowLogFile", "f", "/var/log/mysql/mysql-slow.log", "MySQL慢查询日志文件路径")
-	pflag.Float64VarP(&slowQueryThreshold, "slowQueryThreshold", "s", 0.5, "慢查询阈值，单位：秒")
-	pflag.BoolVarP(&isTest, "test", "t", false, "发送一个测试WebHook请求")
-	pflag.BoolVarP(&readHistory, "readHistory", "r", false, "是否读取历史日志数据")
+	registerFlags()
+	if len(os.Args) > 1 && runSubcommand(os.Args[1], os.Args[2:]) {
+		return
+	}
 	pflag.Parse()
 
-	if webhookURL == "" {
-		fmt.Println("Webhook URL 必须设置！")
-		pflag.Usage()
+	recordCommandLineFlags()
+	if err := configure(); err != nil {
+		logf(levelError, "%v", err)
+		if errors.As(err, new(usageError)) {
+			pflag.Usage()
+		}
+		os.Exit(startupExitConfig)
+	}
+	if startupTest {
+		exitWithStartupTest()
+	}
+
+	logf(levelInfo, "Webhook URL: %s", strings.Join(webhookTargetURLs(webhookDestinations), ", "))
+	logf(levelInfo, "消息格式: %s", activeWebhookFormat.Name)
+	if fallbackDestination != nil {
+		logf(levelInfo, "备用Webhook URL: %s（消息格式: %s）", fallbackDestination.URL, fallbackWebhookFormat.Name)
+	}
+	if escalationTarget != nil {
+		logf(levelInfo, "升级告警Webhook URL: %s（同一指纹告警 %d 次后升级）", escalationTarget.URL, escalationAfter)
+	}
+	if pmmSocket != "" {
+		logf(levelInfo, "PMM socket: %s", pmmSocket)
+	} else if len(monitoredInstances) == 0 {
+		logf(levelInfo, "慢查询日志文件: %s", slowLogFile)
+	}
+	for _, inst := range monitoredInstances {
+		logf(levelInfo, "实例 %s: 慢查询日志文件 %s", inst.Name, inst.SlowLogFile)
+	}
+	if sshHost != "" {
+		logf(levelInfo, "通过SSH读取远程主机: %s", sshAddress())
+	}
+	if autoTuneThreshold {
+		if sshHost != "" || isGlobPattern(slowLogFile) {
+			logf(levelError, "--autoTuneThreshold 仅支持本地单个慢查询日志文件")
+			return
+		}
+		if err := autoTuneThresholdOnce(time.Now()); err != nil {
+			logf(levelError, "%v", err)
+			return
+		}
+	}
+	logf(levelInfo, "慢查询阈值: %.2f 秒", slowQueryThreshold)
+	logf(levelInfo, "读取历史日志数据: %v", readHistory)
+	logf(levelInfo, "告警冷却时间: %s", alertCooldown)
+	logf(levelInfo, "显示时区: %s", displayLocation)
+	if len(alertLabels) > 0 {
+		logf(levelInfo, "告警标签: %s", formatLabels(alertLabels))
+	}
+	if !startFromTime.IsZero() {
+		logf(levelInfo, "从指定时间开始处理: %s", startFromTime.Format(time.RFC3339))
+	}
+	if !readUntilTime.IsZero() {
+		logf(levelInfo, "处理到指定时间为止: %s", readUntilTime.Format(time.RFC3339))
+	}
+
+	if historyDB != "" {
+		h, err := openHistory(historyDB)
+		if err != nil {
+			logf(levelError, "%v", err)
+			return
+		}
+		history = h
+		registerShutdownHook(func() { history.Close() })
+		go purgeHistoryPeriodically(history, historyRetention)
+		logf(levelInfo, "告警历史: %s（保留 %s）", historyDB, historyRetention)
+	}
+
+	if baselineDB != "" {
+		db, baselines, err := openPlanCheck()
+		if err != nil {
+			logf(levelError, "%v", err)
+			return
+		}
+		explainDB, planBaselines = db, baselines
+		registerShutdownHook(func() { planBaselines.Close() })
+		logf(levelInfo, "执行计划基线: %s", baselineDB)
+	}
+	if err := configureExplainAnalyze(); err != nil {
+		logf(levelError, "%v", err)
 		return
 	}
 
-	fmt.Printf("Webhook URL: %s\n", webhookURL)
-	fmt.Printf("慢查询日志文件: %s\n", slowLogFile)
-	fmt.Printf("慢查询阈值: %.2f 秒\n", slowQueryThreshold)
-	fmt.Printf("读取历史日志数据: %v\n", readHistory)
+	if stateFile != "" {
+		if err := restoreState(stateFile); err != nil {
+			logf(levelError, "%v", err)
+			return
+		}
+		registerShutdownHook(func() { flushState(stateFile) })
+	}
+	if worstQueryFile != "" {
+		if err := loadWorstQueries(worstQueryFile); err != nil {
+			logf(levelError, "%v", err)
+			return
+		}
+	}
+
+	if pidFile != "" {
+		if err := writePIDFile(pidFile); err != nil {
+			logf(levelError, "%v", err)
+			os.Exit(1)
+		}
+		registerShutdownHook(func() { removePIDFile(pidFile) })
+	}
+
+	if auditLogFile != "" {
+		if err := openAuditLog(auditLogFile); err != nil {
+			logf(levelError, "无法打开审计日志 %s: %v", auditLogFile, err)
+			return
+		}
+		registerShutdownHook(closeAuditLog)
+		go handleAuditLogSignals()
+	}
 
-	var wg sync.WaitGroup
-	restart := make(chan bool)
+	if mysqlConnCountSuppressThreshold > 0 {
+		if err := startConnCountMonitor(); err != nil {
+			logf(levelError, "%v", err)
+			return
+		}
+		logf(levelInfo, "MySQL连接数超过 %d 时暂停慢查询告警（每 %s 检查一次）", mysqlConnCountSuppressThreshold, connCountCheckInterval)
+	}
+
+	if heartbeatTable != "" {
+		if err := openHeartbeatDB(); err != nil {
+			logf(levelError, "%v", err)
+			return
+		}
+		logf(levelInfo, "告警前检查复制延迟（心跳表 %s.%s），超过 %s 时在告警中提示", heartbeatSchema, heartbeatTable, maxReplicationLag)
+	}
 
-	for {
-		wg.Add(1)
-		go tailSlowLog(&wg, restart)
-		select {
-		case <-restart:
-			fmt.Println("日志监控协程退出，正在重新启动...")
+	if otelMetricsEndpoint != "" {
+		shutdown, err := startOTelMetrics(otelMetricsEndpoint, otelMetricsInterval)
+		if err != nil {
+			logf(levelError, "%v", err)
+			return
 		}
+		registerShutdownHook(shutdown)
+		logf(levelInfo, "OTLP 指标推送: %s（间隔 %s）", otelMetricsEndpoint, otelMetricsInterval)
 	}
 
-	wg.Wait()
+	go handleShutdownSignals()
+	go handleReloadSignals()
+	if httpAddr != "" {
+		go serveHTTP(httpAddr)
+	}
+	if digestInterval > 0 {
+		go runDigest(digestInterval)
+	}
+	if autoTuneThreshold && autoTuneInterval > 0 {
+		go runAutoTune(autoTuneInterval)
+	}
+	if groupingWindow > 0 {
+		registerShutdownHook(flushAllAlertGroups)
+	}
+	if batchGroupBy != "" {
+		registerShutdownHook(flushAllAlertBatches)
+	}
+	if configDir != "" {
+		go watchConfigDir(configDir)
+	}
+	if webhookTemplate != "" {
+		go watchWebhookTemplate(webhookTemplate)
+	}
+	if alertTemplateDir != "" {
+		go watchAlertTemplateDir(alertTemplateDir)
+	}
+	if selfAlertTarget != nil {
+		go runSelfHealthCheck(selfAlertCheckInterval)
+	}
+
+	if pmmSocket != "" {
+		watchPMMSocket(pmmSocket)
+		return
+	}
+	if len(monitoredInstances) > 0 {
+		watchInstances()
+		return
+	}
+	if sshHost != "" {
+		if err := watchRemoteSlowLog(); err != nil {
+			logf(levelError, "%v", err)
+		}
+		return
+	}
+	if isGlobPattern(slowLogFile) {
+		watchGlobSlowLogs(slowLogFile, globCheckInterval)
+		return
+	}
+	watchSlowLog(tailJob{path: slowLogFile, firstRun: true})
 }
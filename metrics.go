@@ -0,0 +1,162 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func init() {
+	lastLineRead.Store(time.Now().UnixNano())
+	registerMetrics(nil)
+	httpMux.Handle("GET /metrics", promhttp.HandlerFor(metricsGatherer, promhttp.HandlerOpts{}))
+}
+
+// 最近一次读取到日志行的时间（UnixNano），启动时为启动时间
+var lastLineRead atomic.Int64
+
+// 记录读取到一行日志，inst 为 --instances 中读取的实例
+func markLineRead(inst *instanceConfig) {
+	now := time.Now().UnixNano()
+	lastLineRead.Store(now)
+	if inst != nil {
+		inst.lastLineRead.Store(now)
+	}
+}
+
+// 指标中 --labels 不能使用的标签名，这些标签由指标本身使用
+var metricVariableLabels = []string{"tier", "type", "database", "user", "mysql_instance"}
+
+// Prometheus 标签名中不允许的字符
+var invalidMetricLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+var (
+	slowLogOffsetDesc      *prometheus.Desc
+	slowLogSizeDesc        *prometheus.Desc
+	slowLogLastLineAgeDesc *prometheus.Desc
+)
+
+// 按告警级别统计的慢查询条数
+var slowQueryTotal *prometheus.CounterVec
+
+// 正在执行的Webhook请求数，持续处于 --webhookConcurrency 说明推送地址太慢或告警太多
+var webhookCallsActive prometheus.Gauge
+
+// 所有指标所在的 Registry，设置 --labels 后换成带固定标签的新 Registry
+// 同名指标在一个 Registry 中不能改变标签，因此不使用 prometheus.DefaultRegisterer
+var metricsRegistry *prometheus.Registry
+
+// 读取当前的 metricsRegistry，/metrics 与 OTLP 推送共用
+var metricsGatherer = prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+	return metricsRegistry.Gather()
+})
+
+// 创建并注册所有指标，constLabels 作为每个指标的固定标签
+func registerMetrics(constLabels prometheus.Labels) {
+	registry := prometheus.NewRegistry()
+	prometheus.WrapRegistererWith(constLabels, registry).MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	slowLogOffsetDesc = prometheus.NewDesc("slow_log_file_offset_bytes",
+		"慢查询日志中已处理完的位置，与 slow_log_file_size_bytes 之差为尚未读取的字节数", []string{"mysql_instance"}, constLabels)
+	slowLogSizeDesc = prometheus.NewDesc("slow_log_file_size_bytes",
+		"慢查询日志文件的当前大小", []string{"mysql_instance"}, constLabels)
+	slowLogLastLineAgeDesc = prometheus.NewDesc("slow_log_last_line_age_seconds",
+		"距最近一次读取到日志行的秒数，持续增长说明读取已停滞或日志没有写入", []string{"mysql_instance"}, constLabels)
+	slowQueryTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "slow_query_total",
+		Help:        "按告警级别统计的慢查询条数：critical 达到 --criticalThreshold，warn 达到告警阈值，none 未达到告警阈值但不低于 --metricsMinQueryTime 与 --trackMinQueryTime；type 为SQL类型",
+		ConstLabels: constLabels,
+	}, metricVariableLabels)
+	webhookCallsActive = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:        "slow_query_goroutines_active",
+		Help:        "正在执行Webhook请求的 goroutine 数，持续处于 --webhookConcurrency 的上限说明推送地址太慢或告警太多",
+		ConstLabels: constLabels,
+	})
+	registry.MustRegister(slowLogCollector{}, slowQueryTotal, webhookCallsActive)
+	metricsRegistry = registry
+}
+
+// 把 --labels 作为所有指标的固定标签，标签名中的非法字符替换为下划线，与指标自带标签重名的跳过
+func configureMetricLabels(labels []notificationField) {
+	if len(labels) == 0 {
+		return
+	}
+	constLabels := make(prometheus.Labels, len(labels))
+	for _, l := range labels {
+		name := invalidMetricLabelChars.ReplaceAllString(l.Label, "_")
+		if name[0] >= '0' && name[0] <= '9' {
+			name = "_" + name
+		}
+		if slices.Contains(metricVariableLabels, name) || strings.HasPrefix(name, "__") {
+			logf(levelWarn, "标签 %s 与指标自带的标签重名，不添加到 Prometheus 指标", l.Label)
+			continue
+		}
+		constLabels[name] = l.Value
+	}
+	registerMetrics(constLabels)
+}
+
+// 返回慢查询的告警级别，未达到告警阈值且低于 --metricsMinQueryTime 时返回空字符串
+func slowQueryTier(entry *SlowQueryEntry, alerting bool) string {
+	switch {
+	case alerting && criticalThreshold > 0 && entry.QueryTime >= criticalThreshold:
+		return "critical"
+	case alerting:
+		return "warn"
+	case entry.QueryTime >= metricsMinQueryTime.Seconds() && entry.QueryTime >= trackMinQueryTime:
+		return "none"
+	}
+	return ""
+}
+
+// 把慢查询计入 slow_query_total
+func recordSlowQueryTier(entry *SlowQueryEntry, alerting bool) {
+	if tier := slowQueryTier(entry, alerting); tier != "" {
+		slowQueryTotal.WithLabelValues(tier, queryType(entry), entry.Database, entry.User, instanceName(entry.Instance)).Inc()
+	}
+}
+
+// 在抓取时读取日志的处理进度；读取位置与文件大小只在读取单个本地文件时提供
+type slowLogCollector struct{}
+
+func (slowLogCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- slowLogOffsetDesc
+	ch <- slowLogSizeDesc
+	ch <- slowLogLastLineAgeDesc
+}
+
+func (slowLogCollector) Collect(ch chan<- prometheus.Metric) {
+	if len(monitoredInstances) > 0 {
+		for _, inst := range monitoredInstances {
+			collectLastLineAge(ch, inst.Name, inst.lastLineRead.Load())
+			collectSlowLogOffset(ch, inst.Name, inst.SlowLogFile, inst.offset.Load())
+		}
+		return
+	}
+	collectLastLineAge(ch, "", lastLineRead.Load())
+	if sshHost != "" || pmmSocket != "" || isGlobPattern(slowLogFile) {
+		return
+	}
+	collectSlowLogOffset(ch, "", slowLogFile, processedOffset.Load())
+}
+
+// 输出距最近一次读取到日志行的秒数
+func collectLastLineAge(ch chan<- prometheus.Metric, instance string, lastRead int64) {
+	age := time.Since(time.Unix(0, lastRead)).Seconds()
+	ch <- prometheus.MustNewConstMetric(slowLogLastLineAgeDesc, prometheus.GaugeValue, age, instance)
+}
+
+// 输出一个日志文件的读取位置与文件大小，mysql_instance 为 --instances 中的实例名称
+func collectSlowLogOffset(ch chan<- prometheus.Metric, instance, path string, offset int64) {
+	ch <- prometheus.MustNewConstMetric(slowLogOffsetDesc, prometheus.GaugeValue, float64(offset), instance)
+	if info, err := os.Stat(path); err == nil {
+		ch <- prometheus.MustNewConstMetric(slowLogSizeDesc, prometheus.GaugeValue, float64(info.Size()), instance)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSlowLogMetrics(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "slow.log")
+	if err := os.WriteFile(path, make([]byte, 1000), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	prevFile, prevOffset := slowLogFile, processedOffset.Load()
+	t.Cleanup(func() {
+		slowLogFile = prevFile
+		processedOffset.Store(prevOffset)
+	})
+	slowLogFile = path
+	processedOffset.Store(400)
+
+	server := httptest.NewServer(httpMux)
+	defer server.Close()
+	resp, err := server.Client().Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	for _, want := range []string{`slow_log_file_offset_bytes{mysql_instance=""} 400` + "\n", `slow_log_file_size_bytes{mysql_instance=""} 1000` + "\n", `slow_log_last_line_age_seconds{mysql_instance=""} `} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("/metrics 中缺少 %q", want)
+		}
+	}
+}
+
+func TestSlowQueryTier(t *testing.T) {
+	prevCritical, prevMin := criticalThreshold, metricsMinQueryTime
+	t.Cleanup(func() { criticalThreshold, metricsMinQueryTime = prevCritical, prevMin })
+	criticalThreshold, metricsMinQueryTime = 10, 100*time.Millisecond
+
+	tests := []struct {
+		queryTime float64
+		alerting  bool
+		want      string
+	}{
+		{12, true, "critical"},
+		{2, true, "warn"},
+		{0.5, false, "none"},
+		{0.05, false, ""},
+	}
+	for _, tt := range tests {
+		if got := slowQueryTier(&SlowQueryEntry{QueryTime: tt.queryTime}, tt.alerting); got != tt.want {
+			t.Errorf("slowQueryTier(%v, %v) = %q, want %q", tt.queryTime, tt.alerting, got, tt.want)
+		}
+	}
+
+	entry := &SlowQueryEntry{QueryTime: 12, Database: "shop", User: "app", SQL: "UPDATE orders SET status = 1;"}
+	counter := slowQueryTotal.WithLabelValues("critical", "UPDATE", "shop", "app", "")
+	before := promtestutil.ToFloat64(counter)
+	recordSlowQueryTier(entry, true)
+	if got := promtestutil.ToFloat64(counter); got != before+1 {
+		t.Errorf("slow_query_total{tier=\"critical\"} = %v, want %v", got, before+1)
+	}
+}
+
+func TestWebhookCallsActiveMetric(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+
+	targets := []webhookTarget{{URL: server.URL, Timeout: 5 * time.Second}, {URL: server.URL + "/b", Timeout: 5 * time.Second}}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		deliverPayload(&notification{Title: "慢查询警告"}, targets, "{}")
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for promtestutil.ToFloat64(webhookCallsActive) != 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := promtestutil.ToFloat64(webhookCallsActive); got != 2 {
+		t.Errorf("发送中 slow_query_goroutines_active = %v，应为 2", got)
+	}
+	close(release)
+	<-done
+	if got := promtestutil.ToFloat64(webhookCallsActive); got != 0 {
+		t.Errorf("发送完成后 slow_query_goroutines_active = %v，应为 0", got)
+	}
+}
+
+func TestMetricsConstLabels(t *testing.T) {
+	t.Cleanup(func() { registerMetrics(nil) })
+	configureMetricLabels([]notificationField{{Label: "env", Value: "production"}, {Label: "app-name", Value: "billing"}, {Label: "type", Value: "ignored"}})
+	recordSlowQueryTier(&SlowQueryEntry{QueryTime: 12, Database: "shop", User: "app", SQL: "SELECT 1"}, true)
+
+	families, err := metricsGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checked := 0
+	for _, family := range families {
+		switch family.GetName() {
+		case "slow_query_total", "slow_query_goroutines_active", "slow_log_last_line_age_seconds":
+		default:
+			continue
+		}
+		checked++
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, pair := range metric.GetLabel() {
+				labels[pair.GetName()] = pair.GetValue()
+			}
+			if labels["env"] != "production" || labels["app_name"] != "billing" || labels["type"] == "ignored" {
+				t.Errorf("%s 的标签 = %v", family.GetName(), labels)
+			}
+		}
+	}
+	if checked != 3 {
+		t.Errorf("只检查了 %d 个指标", checked)
+	}
+}
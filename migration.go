@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// 识别DDL语句
+var ddlPattern = regexp.MustCompile(`(?is)^\s*(ALTER|CREATE|DROP|RENAME|TRUNCATE)\s+`)
+
+// 迁移期间告警附带的信息
+type migrationInfo struct {
+	DDL     string        // 迁移期间最近一次出现在日志中的DDL语句
+	Elapsed time.Duration // 从迁移开始到该查询开始执行的时间
+}
+
+// 进程启动时间，--migrationMode 时作为迁移开始时间
+var processStartedAt = time.Now()
+
+// 迁移期间最近一次出现的DDL语句
+var migrationDDL struct {
+	sync.Mutex
+	sql string
+}
+
+// 返回迁移是否进行中以及迁移开始时间：--migrationMode 始终进行中，
+// --migrationFlagFile 在文件存在期间进行中，开始时间为文件的修改时间
+func migrationStatus() (bool, time.Time) {
+	if migrationFlagFile != "" {
+		if info, err := os.Stat(migrationFlagFile); err == nil {
+			return true, info.ModTime()
+		}
+	}
+	return migrationMode, processStartedAt
+}
+
+// 迁移进行中时返回告警需要附带的信息，并记录日志中的DDL语句
+func checkMigration(entry *SlowQueryEntry) *migrationInfo {
+	active, started := migrationStatus()
+	if !active {
+		return nil
+	}
+	migrationDDL.Lock()
+	if ddlPattern.MatchString(entry.SQL) {
+		migrationDDL.sql = entry.SQL
+	}
+	info := &migrationInfo{DDL: migrationDDL.sql}
+	migrationDDL.Unlock()
+
+	at := entry.Timestamp
+	if at.IsZero() {
+		at = queryStartTime(entry)
+	}
+	if at.IsZero() {
+		at = time.Now()
+	}
+	if elapsed := at.Sub(started); elapsed > 0 {
+		info.Elapsed = elapsed
+	}
+	return info
+}
+
+// 迁移期间的告警阈值：--migrationThreshold 低于当前阈值时使用 --migrationThreshold
+func migrationThresholdFor(threshold thresholdConfig) thresholdConfig {
+	if migrationThreshold > 0 && migrationThreshold < threshold.QueryTime {
+		threshold.QueryTime = migrationThreshold
+	}
+	return threshold
+}
+
+// 迁移期间告警中的字段
+func migrationFields(info *migrationInfo) []notificationField {
+	fields := []notificationField{{Label: "迁移已进行", Value: info.Elapsed.Round(time.Second).String(), Highlight: true}}
+	if info.DDL != "" {
+		fields = append(fields, notificationField{Label: "迁移DDL", Value: digestSQL(info.DDL)})
+	}
+	return fields
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProcessSlowQueryMigrationFlagFile(t *testing.T) {
+	var alerted []*SlowQueryEntry
+	prevNotifier, prevThreshold, prevFlagFile, prevMigrationThreshold := alertNotifier, slowQueryThreshold, migrationFlagFile, migrationThreshold
+	t.Cleanup(func() {
+		alertNotifier, slowQueryThreshold, migrationFlagFile, migrationThreshold = prevNotifier, prevThreshold, prevFlagFile, prevMigrationThreshold
+		migrationDDL.sql = ""
+	})
+	alertNotifier = func(targets []webhookTarget, entry *SlowQueryEntry) (int, error) {
+		alerted = append(alerted, entry)
+		return 1, nil
+	}
+	slowQueryThreshold, migrationThreshold = 5, 0.5
+	migrationFlagFile = filepath.Join(t.TempDir(), "migration")
+
+	query := fixtureLines(`
+# User@Host: app[app] @ localhost []  Id:    49
+# Query_time: 1.000000  Lock_time: 0.900000 Rows_sent: 1  Rows_examined: 1
+SET timestamp=1710058800;
+SELECT * FROM orders WHERE id = 1;`)
+	processSlowQuery(query, nil)
+	if len(alerted) != 0 {
+		t.Fatalf("没有标记文件时应使用 --slowQueryThreshold")
+	}
+
+	if err := os.WriteFile(migrationFlagFile, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	started := time.Unix(1710058800, 0).Add(-10 * time.Minute)
+	if err := os.Chtimes(migrationFlagFile, started, started); err != nil {
+		t.Fatal(err)
+	}
+	processSlowQuery(fixtureLines(`
+# User@Host: admin[admin] @ localhost []  Id:    50
+# Query_time: 0.100000  Lock_time: 0.000000 Rows_sent: 0  Rows_examined: 0
+SET timestamp=1710058000;
+ALTER TABLE orders ADD INDEX idx_status (status);`), nil)
+	processSlowQuery(query, nil)
+	if len(alerted) != 1 {
+		t.Fatalf("迁移期间应使用 --migrationThreshold，实际告警 %d 条", len(alerted))
+	}
+
+	n := buildSlowQueryNotification(alerted[0])
+	if !strings.HasPrefix(n.Title, "[MIGRATION ALERT] ") {
+		t.Errorf("Title = %q", n.Title)
+	}
+	fields := make(map[string]string)
+	for _, f := range n.Fields {
+		fields[f.Label] = f.Value
+	}
+	if fields["迁移已进行"] != "10m0s" {
+		t.Errorf("迁移已进行 = %q", fields["迁移已进行"])
+	}
+	if !strings.HasPrefix(fields["迁移DDL"], "ALTER TABLE orders") {
+		t.Errorf("迁移DDL = %q", fields["迁移DDL"])
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MongoDB 的慢查询日志：4.4 起每行一条 JSON，更早的版本为一行文本
+//
+//	{"t":{"$date":"2024-01-01T10:00:00.000+08:00"},"s":"I","c":"COMMAND","ctx":"conn42","msg":"Slow query","attr":{"ns":"shop.orders","command":{"find":"orders","filter":{"status":"pending"}},"planSummary":"COLLSCAN","docsExamined":50000,"nreturned":10,"durationMillis":1234}}
+//	2019-03-01T10:00:00.123+0800 I COMMAND  [conn42] command shop.orders command: find { find: "orders", filter: { status: "pending" } } planSummary: COLLSCAN keysExamined:0 docsExamined:50000 nreturned:10 reslen:1234 locks:{} protocol:op_msg 1234ms
+type MongoDBLogParser struct{}
+
+// 每行都是一条完整的日志条目
+func (MongoDBLogParser) IsEntryStart(line string, current []string) bool { return true }
+
+func (MongoDBLogParser) IsEntryComplete(line string, lines []string) bool { return true }
+
+func (MongoDBLogParser) EntryTime(line string) (time.Time, bool) {
+	if strings.HasPrefix(line, "{") {
+		var record struct {
+			T struct {
+				Date time.Time `json:"$date"`
+			} `json:"t"`
+		}
+		if json.Unmarshal([]byte(line), &record) != nil || record.T.Date.IsZero() {
+			return time.Time{}, false
+		}
+		return record.T.Date, true
+	}
+	t, err := time.Parse(mongoTextTimeLayout, strings.SplitN(line, " ", 2)[0])
+	return t, err == nil
+}
+
+func (MongoDBLogParser) Parse(lines []string) (*SlowQueryEntry, error) {
+	line := strings.TrimSpace(strings.Join(lines, " "))
+	var entry *SlowQueryEntry
+	var err error
+	if strings.HasPrefix(line, "{") {
+		entry, err = parseMongoDBJSONLine(line)
+	} else {
+		entry, err = parseMongoDBTextLine(line)
+	}
+	if err != nil {
+		return nil, &ParseError{Line: line, Err: err}
+	}
+	entry.Database, entry.Collection, _ = strings.Cut(entry.Database, ".")
+	entry.Hash = computeQueryHash(entry.Fingerprint)
+	return entry, nil
+}
+
+// 是否是 --inputFormat=mongodb 解析的日志条目
+func isMongoDBEntry(entry *SlowQueryEntry) bool {
+	return entry.Operation != ""
+}
+
+// 不是慢查询的日志行，例如连接、复制等普通日志
+var errNotMongoDBSlowQuery = errors.New("不是MongoDB慢查询日志")
+
+// MongoDB 结构化日志中慢查询相关的字段
+type mongoDBSlowQuery struct {
+	T struct {
+		Date time.Time `json:"$date"`
+	} `json:"t"`
+	Ctx  string `json:"ctx"`
+	Msg  string `json:"msg"`
+	Attr struct {
+		Type           string          `json:"type"`
+		NS             string          `json:"ns"`
+		Command        json.RawMessage `json:"command"`
+		PlanSummary    string          `json:"planSummary"`
+		KeysExamined   int             `json:"keysExamined"`
+		DocsExamined   int             `json:"docsExamined"`
+		NReturned      int             `json:"nreturned"`
+		NModified      int             `json:"nModified"`
+		NDeleted       int             `json:"ndeleted"`
+		Reslen         int64           `json:"reslen"`
+		Remote         string          `json:"remote"`
+		DurationMillis float64         `json:"durationMillis"`
+	} `json:"attr"`
+}
+
+func parseMongoDBJSONLine(line string) (*SlowQueryEntry, error) {
+	var record mongoDBSlowQuery
+	if err := json.Unmarshal([]byte(line), &record); err != nil {
+		return nil, err
+	}
+	if record.Msg != "Slow query" {
+		return nil, errNotMongoDBSlowQuery
+	}
+	attr := record.Attr
+	entry := &SlowQueryEntry{
+		Time:         record.T.Date,
+		QueryTime:    attr.DurationMillis / 1000,
+		RowsSent:     attr.NReturned,
+		RowsExamined: attr.DocsExamined,
+		RowsAffected: attr.NModified + attr.NDeleted,
+		BytesSent:    attr.Reslen,
+		Database:     attr.NS,
+		Host:         mongoDBRemoteHost(attr.Remote),
+		ConnectionID: mongoDBConnectionID(record.Ctx),
+		PlanSummary:  attr.PlanSummary,
+		Operation:    attr.Type,
+	}
+
+	var command map[string]json.RawMessage
+	if len(attr.Command) > 0 {
+		if err := json.Unmarshal(attr.Command, &command); err != nil {
+			return nil, err
+		}
+		// 命令的第一个键是操作类型，例如 {"find": "orders", ...}；写操作的 type 本身就是操作类型
+		if op := firstJSONKey(attr.Command); op != "" && (attr.Type == "" || attr.Type == "command") {
+			entry.Operation = op
+		}
+	}
+	filter := mongoDBJSONFilter(command)
+	if filter == nil {
+		filter = attr.Command
+	}
+	entry.SQL = compactJSON(filter)
+	entry.Fingerprint = fmt.Sprintf("%s %s %s", entry.Operation, attr.NS, mongoDBFilterShape(filter))
+	return entry, nil
+}
+
+// JSON 对象的第一个键
+func firstJSONKey(raw json.RawMessage) string {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return ""
+	}
+	key, _ := dec.Token()
+	s, _ := key.(string)
+	return s
+}
+
+// 命令中相当于 WHERE 子句的查询条件：find 的 filter，update / delete 的 q，aggregate 的 pipeline
+func mongoDBJSONFilter(command map[string]json.RawMessage) json.RawMessage {
+	for _, key := range []string{"filter", "query", "q", "pipeline"} {
+		if raw, ok := command[key]; ok {
+			return raw
+		}
+	}
+	for _, key := range []string{"updates", "deletes"} {
+		var statements []map[string]json.RawMessage
+		if json.Unmarshal(command[key], &statements) == nil && len(statements) > 0 {
+			return statements[0]["q"]
+		}
+	}
+	return nil
+}
+
+// 把查询条件中的值替换为 ?，只保留字段与操作符，作为指纹
+func mongoDBFilterShape(raw json.RawMessage) string {
+	var value any
+	if json.Unmarshal(raw, &value) != nil {
+		return normalizeQuery(string(raw))
+	}
+	shape, _ := json.Marshal(mongoDBValueShape(value))
+	return string(shape)
+}
+
+func mongoDBValueShape(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		shape := make(map[string]any, len(v))
+		for key, item := range v {
+			shape[key] = mongoDBValueShape(item)
+		}
+		return shape
+	case []any:
+		// $in 等数组只保留第一个元素的结构，元素个数不同的查询属于同一指纹
+		if len(v) == 0 {
+			return v
+		}
+		return []any{mongoDBValueShape(v[0])}
+	}
+	return "?"
+}
+
+func compactJSON(raw json.RawMessage) string {
+	var b bytes.Buffer
+	if json.Compact(&b, raw) != nil {
+		return string(raw)
+	}
+	return b.String()
+}
+
+// "10.0.0.5:51234" 中的地址
+func mongoDBRemoteHost(remote string) string {
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		return host
+	}
+	return remote
+}
+
+// "conn42" 中的连接ID
+func mongoDBConnectionID(ctx string) uint64 {
+	id, _ := strconv.ParseUint(strings.TrimPrefix(ctx, "conn"), 10, 64)
+	return id
+}
+
+const mongoTextTimeLayout = "2006-01-02T15:04:05.000-0700"
+
+var mongoTextLinePattern = regexp.MustCompile(`^(\S+)\s+[A-Z]\s+\w+\s+\[([^\]]+)\]\s+(\w+)\s+(\S+)\s+(.*)\s(\d+)ms$`)
+var mongoTextCommandPattern = regexp.MustCompile(`^command:\s*(\w+)\s`)
+var mongoTextPlanSummaryPattern = regexp.MustCompile(`\bplanSummary:\s*(\w+(?: \{[^}]*\})?)`)
+var mongoTextDocsExaminedPattern = regexp.MustCompile(`\b(?:docsExamined|nscannedObjects):(\d+)`) // 3.0 及更早的版本为 nscannedObjects
+var mongoTextNReturnedPattern = regexp.MustCompile(`\bnreturned:(\d+)`)
+var mongoTextModifiedPattern = regexp.MustCompile(`\b(?:nModified|ndeleted):(\d+)`)
+var mongoTextFilterPattern = regexp.MustCompile(`\b(?:filter|query|q): \{`)
+
+func parseMongoDBTextLine(line string) (*SlowQueryEntry, error) {
+	matches := mongoTextLinePattern.FindStringSubmatch(line)
+	if matches == nil {
+		return nil, errNotMongoDBSlowQuery
+	}
+	entry := &SlowQueryEntry{
+		Database:     matches[4],
+		Operation:    matches[3],
+		ConnectionID: mongoDBConnectionID(matches[2]),
+	}
+	entry.Time, _ = time.Parse(mongoTextTimeLayout, matches[1])
+	millis, _ := strconv.ParseFloat(matches[6], 64)
+	entry.QueryTime = millis / 1000
+
+	body := matches[5]
+	if m := mongoTextCommandPattern.FindStringSubmatch(body); m != nil {
+		entry.Operation = m[1]
+	}
+	if m := mongoTextPlanSummaryPattern.FindStringSubmatch(body); m != nil {
+		entry.PlanSummary = m[1]
+	}
+	if m := mongoTextDocsExaminedPattern.FindStringSubmatch(body); m != nil {
+		entry.RowsExamined, _ = strconv.Atoi(m[1])
+	}
+	if m := mongoTextNReturnedPattern.FindStringSubmatch(body); m != nil {
+		entry.RowsSent, _ = strconv.Atoi(m[1])
+	}
+	if m := mongoTextModifiedPattern.FindStringSubmatch(body); m != nil {
+		entry.RowsAffected, _ = strconv.Atoi(m[1])
+	}
+
+	entry.SQL = mongoTextFilter(body)
+	if entry.SQL == "" {
+		// 没有查询条件时显示 planSummary 之前的命令内容
+		entry.SQL, _, _ = strings.Cut(strings.TrimPrefix(body, "command: "), " planSummary:")
+	}
+	entry.Fingerprint = fmt.Sprintf("%s %s %s", entry.Operation, entry.Database, normalizeQuery(entry.SQL))
+	return entry, nil
+}
+
+// 文本日志中 filter: { ... } 的内容，按括号配对截取
+func mongoTextFilter(body string) string {
+	loc := mongoTextFilterPattern.FindStringIndex(body)
+	if loc == nil {
+		return ""
+	}
+	start, depth := loc[1]-1, 0
+	for i := start; i < len(body); i++ {
+		switch body[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				return body[start : i+1]
+			}
+		}
+	}
+	return body[start:]
+}
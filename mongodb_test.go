@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMongoDBLogParser(t *testing.T) {
+	parser := MongoDBLogParser{}
+	tests := []struct {
+		name string
+		line string
+		want SlowQueryEntry
+	}{
+		{
+			name: "json",
+			line: `{"t":{"$date":"2024-01-01T10:00:00.000+08:00"},"s":"I","c":"COMMAND","id":51803,"ctx":"conn42","msg":"Slow query","attr":{"type":"command","ns":"shop.orders","command":{"find":"orders","filter":{"status":"pending","amount":{"$gt":100}},"$db":"shop"},"planSummary":"COLLSCAN","keysExamined":0,"docsExamined":50000,"nreturned":10,"reslen":1234,"remote":"10.0.0.5:51234","durationMillis":1234}}`,
+			want: SlowQueryEntry{
+				QueryTime: 1.234, RowsSent: 10, RowsExamined: 50000, BytesSent: 1234, ConnectionID: 42,
+				Database: "shop", Collection: "orders", Operation: "find", PlanSummary: "COLLSCAN", Host: "10.0.0.5",
+				SQL:         `{"status":"pending","amount":{"$gt":100}}`,
+				Fingerprint: `find shop.orders {"amount":{"$gt":"?"},"status":"?"}`,
+			},
+		},
+		{
+			name: "json update",
+			line: `{"t":{"$date":"2024-01-01T10:00:00.000Z"},"s":"I","c":"WRITE","ctx":"conn7","msg":"Slow query","attr":{"type":"update","ns":"shop.orders","command":{"q":{"_id":{"$in":[1,2,3]}},"u":{"$set":{"status":"done"}}},"planSummary":"IXSCAN { _id: 1 }","docsExamined":3,"nModified":3,"durationMillis":800}}`,
+			want: SlowQueryEntry{
+				QueryTime: 0.8, RowsExamined: 3, RowsAffected: 3, ConnectionID: 7,
+				Database: "shop", Collection: "orders", Operation: "update", PlanSummary: "IXSCAN { _id: 1 }",
+				SQL:         `{"_id":{"$in":[1,2,3]}}`,
+				Fingerprint: `update shop.orders {"_id":{"$in":["?"]}}`,
+			},
+		},
+		{
+			name: "text",
+			line: `2019-03-01T10:00:00.123+0800 I COMMAND  [conn42] command shop.orders command: find { find: "orders", filter: { status: "pending", items: { $size: 3 } }, $db: "shop" } planSummary: COLLSCAN keysExamined:0 docsExamined:50000 cursorExhausted:1 numYields:390 nreturned:10 reslen:1234 locks:{ Global: { acquireCount: { r: 782 } } } protocol:op_msg 1234ms`,
+			want: SlowQueryEntry{
+				QueryTime: 1.234, RowsSent: 10, RowsExamined: 50000, ConnectionID: 42,
+				Database: "shop", Collection: "orders", Operation: "find", PlanSummary: "COLLSCAN",
+				SQL:         `{ status: "pending", items: { $size: 3 } }`,
+				Fingerprint: `find shop.orders { status: ?, items: { $size: ? } }`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parser.Parse([]string{tt.line})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Time.IsZero() {
+				t.Error("未解析日志时间")
+			}
+			got.Time, got.Hash = time.Time{}, 0
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("got  %+v\nwant %+v", *got, tt.want)
+			}
+		})
+	}
+
+	_, err := parser.Parse([]string{`{"t":{"$date":"2024-01-01T10:00:00.000Z"},"s":"I","c":"NETWORK","ctx":"listener","msg":"Connection accepted","attr":{}}`})
+	if !errors.Is(err, errNotMongoDBSlowQuery) {
+		t.Errorf("非慢查询日志应返回 errNotMongoDBSlowQuery，实际 %v", err)
+	}
+	if at, ok := parser.EntryTime(tests[2].line); !ok || at.UTC().Format(time.RFC3339) != "2019-03-01T02:00:00Z" {
+		t.Errorf("EntryTime = %v, %v", at, ok)
+	}
+}
+
+func TestBuildMongoDBNotification(t *testing.T) {
+	entry, err := MongoDBLogParser{}.Parse([]string{`{"t":{"$date":"2024-01-01T10:00:00.000Z"},"ctx":"conn42","msg":"Slow query","attr":{"type":"command","ns":"shop.orders","command":{"find":"orders","filter":{"status":"pending"}},"planSummary":"COLLSCAN","docsExamined":50000,"nreturned":10,"durationMillis":1234}}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := buildSlowQueryNotification(entry)
+	if n.Title != "MongoDB 慢查询警告" || n.SQL != `{"status":"pending"}` {
+		t.Errorf("title = %q, sql = %q", n.Title, n.SQL)
+	}
+	var labels []string
+	for _, f := range n.Fields {
+		labels = append(labels, f.Label)
+	}
+	if got := strings.Join(labels, ","); !strings.HasPrefix(got, "类型,查询时间,数据库,集合,操作类型,执行计划,主机,返回的文档数,扫描的文档数,") {
+		t.Errorf("fields = %s", got)
+	}
+	payload, err := renderPayload(webhookFormats["generic"], n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(payload, "查询条件 (Filter)") {
+		t.Errorf("payload 中缺少查询条件:\n%s", payload)
+	}
+}
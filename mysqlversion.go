@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// 一个 MySQL 版本的慢查询日志格式
+type mysqlVersionProfile struct {
+	timeLayouts []string // # Time: 行的时间格式
+	threadIDRow bool     // 连接ID记录在 # Thread_id: 行（MariaDB），否则在 # User@Host: 行的 Id:
+	extended    bool     // 记录 Percona / MariaDB 的扩展字段，例如 Tmp_tables、Filesort
+	slowExtra   bool     // 支持 log_slow_extra 记录的 Start、End、Bytes_sent 等字段（8.0.14+）
+
+	explainAnalyze bool // 支持 EXPLAIN ANALYZE（8.0.18+）
+}
+
+var isoTimeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999"} // 5.7 起：2024-03-10T08:15:42.123456Z
+var legacyTimeLayouts = []string{"060102 15:04:05"}                           // 5.5 / 5.6 / MariaDB：240310  8:15:42
+
+// --mysqlVersion 的可选值
+var mysqlVersionNames = []string{"5.5", "5.6", "5.7", "8.0", "percona-5.7", "percona-8.0", "mariadb-10.x"}
+
+var mysqlVersionProfiles = map[string]*mysqlVersionProfile{
+	"5.5":          {timeLayouts: legacyTimeLayouts},
+	"5.6":          {timeLayouts: legacyTimeLayouts},
+	"5.7":          {timeLayouts: isoTimeLayouts},
+	"8.0":          {timeLayouts: isoTimeLayouts, slowExtra: true, explainAnalyze: true},
+	"percona-5.7":  {timeLayouts: isoTimeLayouts, extended: true},
+	"percona-8.0":  {timeLayouts: isoTimeLayouts, extended: true, slowExtra: true, explainAnalyze: true},
+	"mariadb-10.x": {timeLayouts: legacyTimeLayouts, threadIDRow: true, extended: true},
+}
+
+// 由 --mysqlVersion 选择，为 nil 时自动兼容各版本的格式
+var activeMySQLVersion *mysqlVersionProfile
+
+var threadIDRowPattern = regexp.MustCompile(`^# Thread_id:\s*\d+`)
+
+// 只有 Percona / MariaDB 记录的字段
+var extendedFieldPatterns = []*regexp.Regexp{tmpTablesPattern, filesortPattern, mergePassesPattern, recLockWaitsPattern, hostnamePattern}
+
+// 只有 log_slow_extra 与 Percona 记录的字段
+var slowExtraFieldPatterns = []*regexp.Regexp{cpuTimePattern, elapsedPattern, bytesSentPattern}
+
+// 日志条目与 --mysqlVersion 指定的版本格式不符
+type mysqlVersionError struct {
+	Version string
+	Reason  string
+}
+
+func (e *mysqlVersionError) Error() string {
+	return fmt.Sprintf("日志格式与 --mysqlVersion %s 不符: %s；如果不确定版本，可以去掉 --mysqlVersion 自动识别各版本的格式", e.Version, e.Reason)
+}
+
+// 检查 --mysqlVersion
+func configureMySQLVersion() error {
+	if mysqlVersion == "" {
+		activeMySQLVersion = nil
+		return nil
+	}
+	mysqlVersion = strings.ToLower(strings.TrimSpace(mysqlVersion))
+	profile, ok := mysqlVersionProfiles[mysqlVersion]
+	if !ok {
+		return fmt.Errorf("--mysqlVersion %q 无效，可选值: %s；不设置时自动识别各版本的格式", mysqlVersion, strings.Join(mysqlVersionNames, ", "))
+	}
+	if logParser != logParsers["mysql"] {
+		return fmt.Errorf("--mysqlVersion 只能用于 mysql 格式的慢查询日志")
+	}
+	activeMySQLVersion = profile
+	return nil
+}
+
+// 按指定版本的时间格式解析 # Time: 行中的时间
+func (p *mysqlVersionProfile) parseTime(value string) (time.Time, bool) {
+	for _, layout := range p.timeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// 检查日志条目是否符合指定版本的格式，返回不符的原因
+func (p *mysqlVersionProfile) mismatch(lines []string) string {
+	hasThreadIDRow := false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if matches := queryStartTimePattern.FindStringSubmatch(trimmed); matches != nil {
+			value := whitespacePattern.ReplaceAllString(matches[1], " ")
+			if _, ok := p.parseTime(value); !ok {
+				return fmt.Sprintf("# Time: 行的时间 %q 不是该版本的格式（例如 %s）", value, p.timeExample())
+			}
+		}
+		if threadIDRowPattern.MatchString(trimmed) {
+			hasThreadIDRow = true
+		}
+		if !p.extended && slices.ContainsFunc(extendedFieldPatterns, func(re *regexp.Regexp) bool { return re.MatchString(trimmed) }) {
+			return fmt.Sprintf("%q 中包含 Percona / MariaDB 才会记录的字段", trimmed)
+		}
+		if !p.slowExtra && !p.extended && slices.ContainsFunc(slowExtraFieldPatterns, func(re *regexp.Regexp) bool { return re.MatchString(trimmed) }) {
+			return fmt.Sprintf("%q 中包含 MySQL 8.0 log_slow_extra 才会记录的字段", trimmed)
+		}
+	}
+	switch {
+	case p.threadIDRow && !hasThreadIDRow:
+		return "缺少 MariaDB 记录的 # Thread_id: 行"
+	case !p.threadIDRow && !p.extended && hasThreadIDRow:
+		return "包含 MariaDB 记录的 # Thread_id: 行"
+	}
+	return ""
+}
+
+// 错误提示中该版本的时间示例
+func (p *mysqlVersionProfile) timeExample() string {
+	if slices.Equal(p.timeLayouts, legacyTimeLayouts) {
+		return "240310  8:15:42"
+	}
+	return "2024-03-10T08:15:42.123456Z"
+}
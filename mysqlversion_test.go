@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMySQLVersionFormat(t *testing.T) {
+	prevVersion, prevParser := mysqlVersion, logParser
+	t.Cleanup(func() {
+		mysqlVersion, logParser = prevVersion, prevParser
+		configureMySQLVersion()
+	})
+	logParser = MySQLLogParser{}
+
+	mysql57 := []string{
+		"# Time: 2024-03-10T08:15:42.123456Z",
+		"# User@Host: app[app] @ localhost [127.0.0.1]  Id:    42",
+		"# Query_time: 2.500000  Lock_time: 0.000100 Rows_sent: 1  Rows_examined: 1000",
+		"SET timestamp=1710058542;",
+		"SELECT * FROM orders;",
+	}
+	mariadb := []string{
+		"# Time: 240310  8:15:42",
+		"# User@Host: app[app] @ localhost [127.0.0.1]",
+		"# Thread_id: 42  Schema: shop  QC_hit: No",
+		"# Query_time: 2.500000  Lock_time: 0.000100  Rows_sent: 1  Rows_examined: 1000",
+		"SET timestamp=1710058542;",
+		"SELECT * FROM orders;",
+	}
+	percona := []string{
+		"# Time: 2024-03-10T08:15:42.123456Z",
+		"# User@Host: app[app] @ localhost [127.0.0.1]  Id:    42",
+		"# Query_time: 2.500000  Lock_time: 0.000100 Rows_sent: 1  Rows_examined: 1000",
+		"# Tmp_tables: 1  Tmp_disk_tables: 1  Tmp_table_sizes: 16384",
+		"SELECT * FROM orders;",
+	}
+
+	for _, tt := range []struct {
+		version string
+		lines   []string
+		reason  string // 为空时应解析成功
+	}{
+		{"5.7", mysql57, ""},
+		{"8.0", mysql57, ""},
+		{"5.6", mysql57, "# Time: 行的时间"},
+		{"mariadb-10.x", mariadb, ""},
+		{"5.7", mariadb, "# Time: 行的时间"},
+		{"5.6", mariadb, "Thread_id"},
+		{"mariadb-10.x", append([]string{"# Time: 240310  8:15:42"}, mysql57[1:]...), "缺少 MariaDB"},
+		{"percona-5.7", percona, ""},
+		{"5.7", percona, "Percona"},
+	} {
+		mysqlVersion = tt.version
+		if err := configureMySQLVersion(); err != nil {
+			t.Fatal(err)
+		}
+		_, err := logParser.Parse(tt.lines)
+		var versionErr *mysqlVersionError
+		switch {
+		case tt.reason == "" && err != nil:
+			t.Errorf("%s: 应解析成功: %v", tt.version, err)
+		case tt.reason != "" && (!errors.As(err, &versionErr) || !strings.Contains(err.Error(), tt.reason)):
+			t.Errorf("%s: 应返回包含 %q 的版本不符错误，实际: %v", tt.version, tt.reason, err)
+		case tt.reason != "" && !strings.Contains(err.Error(), "去掉 --mysqlVersion"):
+			t.Errorf("%s: 错误中应提示去掉 --mysqlVersion 自动识别: %v", tt.version, err)
+		}
+	}
+
+	mysqlVersion = "9.0"
+	if err := configureMySQLVersion(); err == nil {
+		t.Error("无效的 --mysqlVersion 应返回错误")
+	}
+	mysqlVersion, logParser = "5.7", logParsers["mongodb"]
+	if err := configureMySQLVersion(); err == nil {
+		t.Error("--mysqlVersion 不能用于 mongodb 格式")
+	}
+}
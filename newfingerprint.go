@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// 已出现过的指纹哈希，--stateFile 中保存以便重启后继续识别新指纹
+var knownFingerprints = struct {
+	sync.Mutex
+	hashes map[uint64]bool
+}{hashes: make(map[uint64]bool)}
+
+// 记录指纹，第一次出现时返回 true
+func noteFingerprint(hash uint64) bool {
+	knownFingerprints.Lock()
+	defer knownFingerprints.Unlock()
+	if knownFingerprints.hashes[hash] {
+		return false
+	}
+	knownFingerprints.hashes[hash] = true
+	return true
+}
+
+// 返回已出现过的指纹哈希，用于保存到状态文件
+func knownFingerprintHashes() []uint64 {
+	knownFingerprints.Lock()
+	defer knownFingerprints.Unlock()
+	hashes := make([]uint64, 0, len(knownFingerprints.hashes))
+	for hash := range knownFingerprints.hashes {
+		hashes = append(hashes, hash)
+	}
+	return hashes
+}
+
+// 从状态文件恢复已出现过的指纹哈希
+func restoreKnownFingerprints(hashes []uint64) {
+	knownFingerprints.Lock()
+	defer knownFingerprints.Unlock()
+	for _, hash := range hashes {
+		knownFingerprints.hashes[hash] = true
+	}
+}
+
+// 生成新指纹通知，与慢查询告警区分
+func buildNewFingerprintNotification(entry *SlowQueryEntry) *notification {
+	n := buildSlowQueryNotification(entry)
+	n.Title = "🆕 发现新的慢查询模式 (New slow query pattern detected)"
+	n.Fields = append([]notificationField{{Label: "指纹哈希", Value: fmt.Sprintf("%016x", entry.Hash), Highlight: true}}, n.Fields...)
+	return n
+}
+
+// 发送新指纹通知，测试中可以替换为模拟实现
+var newFingerprintNotifier = func(targets []webhookTarget, entry *SlowQueryEntry) (int, error) {
+	return deliverNotification(targets, buildNewFingerprintNotification(entry))
+}
+
+// 指纹第一次出现时发送通知，不受 --slowQueryThreshold 与冷却时间限制
+func checkNewFingerprint(entry *SlowQueryEntry) {
+	if !noteFingerprint(entry.Hash) {
+		return
+	}
+	logf(levelInfo, "发现新的SQL指纹 %016x: %s", entry.Hash, digestSQL(entry.Fingerprint))
+	newFingerprintNotifier(routeTargets(entry), entry)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCheckNewFingerprint(t *testing.T) {
+	var notified []*SlowQueryEntry
+	prevNotifier, prevAlert, prevEnabled, prevThreshold := newFingerprintNotifier, alertNotifier, alertOnNewFingerprint, slowQueryThreshold
+	t.Cleanup(func() {
+		newFingerprintNotifier, alertNotifier, alertOnNewFingerprint, slowQueryThreshold = prevNotifier, prevAlert, prevEnabled, prevThreshold
+		knownFingerprints.hashes = make(map[uint64]bool)
+	})
+	newFingerprintNotifier = func(targets []webhookTarget, entry *SlowQueryEntry) (int, error) {
+		notified = append(notified, entry)
+		return 1, nil
+	}
+	alertNotifier = func(targets []webhookTarget, entry *SlowQueryEntry) (int, error) {
+		t.Errorf("未达到阈值的查询不应发送慢查询告警")
+		return 1, nil
+	}
+	alertOnNewFingerprint, slowQueryThreshold = true, 10
+	knownFingerprints.hashes = make(map[uint64]bool)
+
+	query := func(id int) []string {
+		return fixtureLines(fmt.Sprintf(`
+# User@Host: app[app] @ localhost []  Id:    42
+# Query_time: 0.010000  Lock_time: 0.000000 Rows_sent: 1  Rows_examined: 1
+SELECT * FROM orders WHERE id = %d;`, id))
+	}
+	processSlowQuery(query(1), nil)
+	processSlowQuery(query(2), nil)
+	if len(notified) != 1 {
+		t.Fatalf("同一指纹只在第一次出现时通知，实际 %d 条", len(notified))
+	}
+	n := buildNewFingerprintNotification(notified[0])
+	if n.Fields[0].Value != fmt.Sprintf("%016x", notified[0].Hash) {
+		t.Errorf("通知中应包含指纹哈希: %+v", n.Fields[0])
+	}
+
+	// 重启后从状态文件恢复已出现过的指纹
+	hashes := knownFingerprintHashes()
+	knownFingerprints.hashes = make(map[uint64]bool)
+	restoreKnownFingerprints(hashes)
+	processSlowQuery(query(3), nil)
+	if len(notified) != 1 {
+		t.Errorf("恢复后已出现过的指纹不应再次通知")
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// 通知中的一个字段
+type notificationField struct {
+	Label     string
+	Value     string
+	Highlight bool // 是否突出显示，例如查询时间
+}
+
+// 通知中的表格，例如汇总中按数据库统计的慢查询
+type notificationTable struct {
+	Title   string
+	Columns []string
+	Rows    [][]string
+}
+
+// 与平台无关的通知内容，由各消息格式渲染为具体的请求体
+type notification struct {
+	Title   string
+	Fields  []notificationField
+	SQL     string
+	Labels  []notificationField // 环境标签，显示在消息末尾
+	Context []string            // 告警前的原始日志行，以等宽字体显示
+	Plan    []notificationField // 执行计划变化，显示原计划与现计划
+	Tables  []notificationTable // 显示在字段之后的表格
+	Notes   []string            // 脚注，以小字显示在消息最后
+
+	SQLLabel    string // SQL 的标题，为空时显示 "SQL 查询"
+	Severity    string // 慢查询告警的级别 critical 或 warn，用于选择 --alertTemplateDir 中的模板
+	Fingerprint string // SQL指纹，不在消息中显示，用于死信文件等记录
+	HistoryID   int64  `json:"-"` // --historyDB 中对应的查询记录ID，0 表示未记录
+}
+
+// 消息中 SQL 的标题
+func (n *notification) sqlLabel() string {
+	if n.SQLLabel == "" {
+		return "SQL 查询"
+	}
+	return n.SQLLabel
+}
+
+// 通过 --labels 配置的环境标签，附加到每条告警
+var alertLabels []notificationField
+
+// 解析 key=value 格式的标签，保留配置顺序
+func parseLabels(specs []string) ([]notificationField, error) {
+	labels := make([]notificationField, 0, len(specs))
+	for _, spec := range specs {
+		key, value, ok := strings.Cut(spec, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("无法解析标签 %q，格式应为 key=value", spec)
+		}
+		labels = append(labels, notificationField{Label: key, Value: strings.TrimSpace(value)})
+	}
+	return labels, nil
+}
+
+// 把标签拼接为一行文本，例如 env=production, region=ap-southeast-1
+func formatLabels(labels []notificationField) string {
+	parts := make([]string, 0, len(labels))
+	for _, l := range labels {
+		parts = append(parts, l.Label+"="+l.Value)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// 通知中显示时间使用的时区，由 --displayTZ 指定
+var displayLocation = time.Local
+
+// 按 --displayTZ 格式化通知中显示的时间
+func formatDisplayTime(t time.Time) string {
+	return t.In(displayLocation).Format("2006-01-02 15:04:05 MST")
+}
+
+// 查询开始时间，优先使用 # Time: 行，其次使用 SET timestamp=
+func queryStartTime(entry *SlowQueryEntry) time.Time {
+	if !entry.Time.IsZero() {
+		return entry.Time
+	}
+	return entry.Timestamp
+}
+
+// 数据库名为推断值时的脚注
+func databaseNotes(entry *SlowQueryEntry) []string {
+	if !entry.DatabaseInferred {
+		return nil
+	}
+	return []string{fmt.Sprintf("* 日志中没有记录数据库，%s 来自 --defaultDatabase，可能不准确", entry.Database)}
+}
+
+// 慢查询告警中的字段名称，按默认顺序排列，可以通过 --notificationFields 选择与排序
+var slowQueryFieldNames = []string{
+	"queryType", "queryTime", "lockTime", "database", "collection", "operation", "planSummary", "host", "clientHostname", "appHostname", "user",
+	"rowsSent", "rowsExamined", "rowsAffected", "tmpTables", "filesort", "mergePasses", "cpuTime", "replicationLag", "auroraVersion", "startTime", "alertTime", "sql",
+}
+
+// 检查 --notificationFields 中的字段名称
+func validateNotificationFields(names []string) error {
+	for _, name := range names {
+		if !slices.Contains(slowQueryFieldNames, name) {
+			return fmt.Errorf("--notificationFields 中的字段 %q 无效，可选值: %s", name, strings.Join(slowQueryFieldNames, ", "))
+		}
+	}
+	return nil
+}
+
+// 生成慢查询告警中的一个字段，日志中没有记录该信息时返回 false
+func slowQueryField(name string, entry *SlowQueryEntry) (notificationField, bool) {
+	switch name {
+	case "queryType":
+		return notificationField{Label: "类型", Value: sqlTypeBadges[queryType(entry)]}, true
+	case "queryTime":
+		return notificationField{Label: "查询时间", Value: fmt.Sprintf("%.2f 秒", entry.QueryTime), Highlight: true}, true
+	case "lockTime":
+		return notificationField{Label: "锁定时间", Value: fmt.Sprintf("%.2f 秒", entry.LockTime), Highlight: isDMLWarning(entry)}, !isMongoDBEntry(entry)
+	case "database":
+		return notificationField{Label: "数据库", Value: entry.Database}, true
+	case "collection":
+		return notificationField{Label: "集合", Value: entry.Collection}, entry.Collection != ""
+	case "operation":
+		return notificationField{Label: "操作类型", Value: entry.Operation}, isMongoDBEntry(entry)
+	case "planSummary":
+		// COLLSCAN 表示全集合扫描，通常缺少索引
+		collscan := strings.HasPrefix(entry.PlanSummary, "COLLSCAN")
+		return notificationField{Label: "执行计划", Value: entry.PlanSummary, Highlight: collscan}, entry.PlanSummary != ""
+	case "host":
+		return notificationField{Label: "主机", Value: entry.Host}, true
+	case "clientHostname":
+		return notificationField{Label: "客户端主机名", Value: entry.ClientHostname}, entry.ClientHostname != ""
+	case "appHostname":
+		return notificationField{Label: "应用主机 (Application Host)", Value: entry.AppHostname}, entry.AppHostname != ""
+	case "user":
+		return notificationField{Label: "用户", Value: entry.User}, entry.User != "" || !isMongoDBEntry(entry)
+	case "rowsSent":
+		if isMongoDBEntry(entry) {
+			return notificationField{Label: "返回的文档数", Value: fmt.Sprintf("%d", entry.RowsSent)}, true
+		}
+		return notificationField{Label: "发送的行数", Value: fmt.Sprintf("%d", entry.RowsSent)}, true
+	case "rowsExamined":
+		if isMongoDBEntry(entry) {
+			return notificationField{Label: "扫描的文档数", Value: fmt.Sprintf("%d", entry.RowsExamined)}, true
+		}
+		return notificationField{Label: "扫描的行数", Value: fmt.Sprintf("%d", entry.RowsExamined)}, true
+	case "rowsAffected":
+		if isMongoDBEntry(entry) {
+			return notificationField{Label: "修改的文档数", Value: fmt.Sprintf("%d", entry.RowsAffected)}, entry.RowsAffected > 0
+		}
+		return notificationField{Label: "影响的行数", Value: fmt.Sprintf("%d", entry.RowsAffected), Highlight: isDMLWarning(entry)}, entry.RowsAffected > 0 && isDML(entry.SQL)
+	case "tmpTables":
+		if entry.TmpTables == 0 {
+			return notificationField{}, false
+		}
+		value := fmt.Sprintf("%d (%d on disk)", entry.TmpTables, entry.TmpDiskTables)
+		if entry.TmpDiskTables > 0 {
+			value = "⚠️ " + value
+		}
+		return notificationField{Label: "Tmp Tables", Value: value, Highlight: entry.TmpDiskTables > 0}, true
+	case "filesort":
+		return filesortField(entry), entry.Filesort
+	case "cpuTime":
+		return cpuTimeField(entry), entry.CPUTime > 0
+	case "mergePasses":
+		high := mergePassesThreshold > 0 && entry.MergePasses >= mergePassesThreshold
+		return notificationField{Label: "Merge Passes", Value: fmt.Sprintf("%d", entry.MergePasses), Highlight: high}, entry.MergePasses > 0
+	case "replicationLag":
+		return notificationField{Label: "复制延迟", Value: entry.ReplicationLag.Round(time.Millisecond).String(), Highlight: true}, entry.ReplicationLag > 0
+	case "auroraVersion":
+		return notificationField{Label: "Aurora 版本", Value: entry.AuroraVersion}, entry.AuroraVersion != ""
+	case "startTime":
+		start := queryStartTime(entry)
+		return notificationField{Label: "开始时间", Value: formatDisplayTime(start)}, !start.IsZero()
+	case "alertTime":
+		return notificationField{Label: "告警时间", Value: formatDisplayTime(time.Now())}, true
+	}
+	return notificationField{}, false
+}
+
+// 告警中显示的SQL，超过 --sqlFieldMaxLength 个字符时截断
+func notificationSQL(sql string) string {
+	if runes := []rune(sql); sqlFieldMaxLength > 0 && len(runes) > sqlFieldMaxLength {
+		return string(runes[:sqlFieldMaxLength]) + "…"
+	}
+	return sql
+}
+
+// 根据慢查询日志条目生成告警通知，字段及顺序由 --notificationFields 控制
+func buildSlowQueryNotification(entry *SlowQueryEntry) *notification {
+	n := &notification{
+		Title:       "慢查询警告",
+		Labels:      entryLabels(entry),
+		Context:     entry.ContextLines,
+		Fingerprint: entry.Fingerprint,
+		Notes:       slices.Concat(databaseNotes(entry), planHintNotes(entry.PlanHints), replicationLagNotes(entry), missingLimitNotes(entry), explainAnalyzeNotes(entry)),
+		Tables:      append(lockWaitTables(entry), explainAnalyzeTables(entry)...),
+		Plan:        planChangeFields(entry.PlanChange),
+		Severity:    slowQueryTier(entry, true),
+	}
+	if isDMLWarning(entry) {
+		n.Title = dmlWarningTitle
+	}
+	if isMongoDBEntry(entry) {
+		n.Title, n.SQLLabel = "MongoDB 慢查询警告", "查询条件 (Filter)"
+	}
+	if entry.QCHit {
+		n.Title += " [QC Hit]"
+	}
+	if entry.Migration != nil {
+		n.Title = "[MIGRATION ALERT] " + n.Title
+	}
+	names := slowQueryFieldNames
+	if len(notificationFields) > 0 {
+		names = notificationFields
+	}
+	for _, name := range names {
+		if name == "sql" {
+			n.SQL = notificationSQL(entry.SQL)
+			continue
+		}
+		if f, ok := slowQueryField(name, entry); ok {
+			n.Fields = append(n.Fields, f)
+		}
+	}
+	if entry.Migration != nil {
+		n.Fields = append(n.Fields, migrationFields(entry.Migration)...)
+	}
+	if entry.Occurrence != nil {
+		n.Fields = append(n.Fields, occurrenceFields(entry.Occurrence)...)
+	}
+	n.Fields = append(n.Fields, extraNotificationFields(entry.QueryAnnotations)...)
+	n.Fields = append(n.Fields, extraNotificationFields(entry.ExtraFields)...)
+	if ackCallbackURL != "" {
+		n.Fields = append(n.Fields, notificationField{Label: "确认告警", Value: newAckURL(entry)})
+	}
+	return n
+}
+
+// 执行计划变化显示的原计划与现计划，change 为 nil 时返回 nil
+func planChangeFields(change *planChange) []notificationField {
+	if change == nil {
+		return nil
+	}
+	return []notificationField{
+		{Label: "原执行计划", Value: change.Baseline.String()},
+		{Label: "现执行计划", Value: change.Current.String(), Highlight: true},
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestBuildSlowQueryNotificationFields(t *testing.T) {
+	prevFields, prevMaxLength := notificationFields, sqlFieldMaxLength
+	t.Cleanup(func() { notificationFields, sqlFieldMaxLength = prevFields, prevMaxLength })
+
+	entry := &SlowQueryEntry{QueryTime: 2, LockTime: 0.5, Database: "shop", User: "app", RowsExamined: 100, SQL: "SELECT * FROM orders WHERE id = 1;"}
+	notificationFields, sqlFieldMaxLength = []string{"database", "queryTime", "sql", "rowsExamined"}, 13
+	n := buildSlowQueryNotification(entry)
+
+	var labels []string
+	for _, f := range n.Fields {
+		labels = append(labels, f.Label)
+	}
+	want := []string{"数据库", "查询时间", "扫描的行数"}
+	if len(labels) != len(want) {
+		t.Fatalf("字段 = %q, want %q", labels, want)
+	}
+	for i := range want {
+		if labels[i] != want[i] {
+			t.Errorf("字段 = %q, want %q", labels, want)
+			break
+		}
+	}
+	if n.SQL != "SELECT * FROM…" {
+		t.Errorf("SQL = %q", n.SQL)
+	}
+
+	notificationFields = []string{"queryTime"}
+	if n := buildSlowQueryNotification(entry); n.SQL != "" {
+		t.Errorf("未列出 sql 时不应显示SQL，SQL = %q", n.SQL)
+	}
+
+	if err := validateNotificationFields([]string{"queryTime", "planCost"}); err == nil {
+		t.Errorf("期望未知字段报错")
+	}
+}
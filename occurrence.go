@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 指纹数超过该值时清理窗口内没有出现、也没有告警过的指纹
+const occurrenceSweepSize = 4096
+
+// 单个指纹在 --occurrenceWindow 内的出现时间
+type occurrenceState struct {
+	seen    []time.Time
+	alerted bool // 已经发送过首次告警，之后的告警不再等待出现次数
+}
+
+// 按指纹哈希统计的出现次数
+var occurrences = struct {
+	sync.Mutex
+	byHash map[uint64]*occurrenceState
+}{byHash: make(map[uint64]*occurrenceState)}
+
+// 首次告警前累计的出现次数
+type occurrenceInfo struct {
+	Count int           // 窗口内的出现次数
+	Span  time.Duration // 第一次出现到本次出现的时间
+}
+
+// 丢弃窗口之外的出现时间
+func (s *occurrenceState) prune(now time.Time) {
+	i := 0
+	for i < len(s.seen) && now.Sub(s.seen[i]) >= occurrenceWindow {
+		i++
+	}
+	s.seen = s.seen[i:]
+}
+
+// 记录指纹的一次出现，返回是否可以告警；首次达到 --minOccurrencesBeforeAlert 时同时返回出现次数
+func noteOccurrence(entry *SlowQueryEntry) (*occurrenceInfo, bool) {
+	at := queryStartTime(entry)
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	occurrences.Lock()
+	defer occurrences.Unlock()
+	if len(occurrences.byHash) > occurrenceSweepSize {
+		for hash, s := range occurrences.byHash {
+			if s.prune(at); !s.alerted && len(s.seen) == 0 {
+				delete(occurrences.byHash, hash)
+			}
+		}
+	}
+	s, ok := occurrences.byHash[entry.Hash]
+	if !ok {
+		s = &occurrenceState{}
+		occurrences.byHash[entry.Hash] = s
+	}
+	if s.alerted {
+		return nil, true
+	}
+	s.prune(at)
+	s.seen = append(s.seen, at)
+	if len(s.seen) < minOccurrencesBeforeAlert {
+		return nil, false
+	}
+	info := &occurrenceInfo{Count: len(s.seen), Span: at.Sub(s.seen[0])}
+	s.alerted, s.seen = true, nil
+	return info, true
+}
+
+// 首次告警中的出现次数字段
+func occurrenceFields(info *occurrenceInfo) []notificationField {
+	return []notificationField{{
+		Label: "首次告警",
+		Value: fmt.Sprintf("First alert after %d occurrences in %s", info.Count, info.Span.Round(time.Second)),
+	}}
+}
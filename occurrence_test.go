@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNoteOccurrence(t *testing.T) {
+	prevMin, prevWindow := minOccurrencesBeforeAlert, occurrenceWindow
+	t.Cleanup(func() {
+		minOccurrencesBeforeAlert, occurrenceWindow = prevMin, prevWindow
+		occurrences.byHash = make(map[uint64]*occurrenceState)
+	})
+	minOccurrencesBeforeAlert, occurrenceWindow = 3, 5*time.Minute
+
+	entry, err := ParseLogLines(fixtureLines(`
+# Time: 2024-03-10T08:00:00.000000Z
+# User@Host: app[app] @ localhost []  Id:    42
+# Query_time: 2.000000  Lock_time: 0.000000 Rows_sent: 1  Rows_examined: 1
+SELECT * FROM items WHERE id = 1;`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(offset time.Duration) *SlowQueryEntry {
+		e := *entry
+		e.Time = entry.Time.Add(offset)
+		return &e
+	}
+
+	// 只出现一次的查询在窗口过后重新计数
+	if _, ok := noteOccurrence(at(0)); ok {
+		t.Fatalf("第 1 次出现不应告警")
+	}
+	if _, ok := noteOccurrence(at(10 * time.Minute)); ok {
+		t.Fatalf("窗口过后的第 1 次出现不应告警")
+	}
+	if _, ok := noteOccurrence(at(11 * time.Minute)); ok {
+		t.Fatalf("第 2 次出现不应告警")
+	}
+	info, ok := noteOccurrence(at(13 * time.Minute))
+	if !ok || info == nil || info.Count != 3 || info.Span != 3*time.Minute {
+		t.Fatalf("第 3 次出现应告警, info = %+v, ok = %v", info, ok)
+	}
+	if got := occurrenceFields(info)[0].Value; got != "First alert after 3 occurrences in 3m0s" {
+		t.Errorf("首次告警 = %q", got)
+	}
+
+	// 首次告警之后不再等待出现次数
+	if info, ok := noteOccurrence(at(30 * time.Minute)); !ok || info != nil {
+		t.Errorf("首次告警之后应直接告警, info = %+v, ok = %v", info, ok)
+	}
+}
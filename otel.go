@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	otelprom "go.opentelemetry.io/contrib/bridges/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// 通过 OTLP gRPC 定期推送指标，内容与 /metrics 相同，返回退出时推送剩余指标并关闭的函数
+// endpoint 为 http:// 时不使用TLS，认证请求头等可以通过 OTEL_EXPORTER_OTLP_HEADERS 等环境变量设置
+func startOTelMetrics(endpoint string, interval time.Duration) (func(), error) {
+	exporter, err := otlpmetricgrpc.New(context.Background(), otlpmetricgrpc.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("无法创建 OTLP 指标导出器 %s: %w", endpoint, err)
+	}
+	// 从 Prometheus 默认注册表读取指标，两种方式导出的指标保持一致
+	reader := sdkmetric.NewPeriodicReader(exporter,
+		sdkmetric.WithInterval(interval),
+		sdkmetric.WithProducer(otelprom.NewMetricProducer(otelprom.WithGatherer(metricsGatherer))))
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(reader),
+		sdkmetric.WithResource(resource.NewSchemaless(attribute.String("service.name", programName))))
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			logf(levelWarn, "关闭 OTLP 指标导出器失败: %v", err)
+		}
+	}, nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// 写入 PID 文件；文件已存在且对应进程仍在运行时返回错误
+func writePIDFile(path string) error {
+	if content, err := os.ReadFile(path); err == nil {
+		if pid, err := strconv.Atoi(strings.TrimSpace(string(content))); err == nil && pid != os.Getpid() && processRunning(pid) {
+			return fmt.Errorf("already running (pid %d)", pid)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("无法读取PID文件 %s: %w", path, err)
+	}
+
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0o644); err != nil {
+		return fmt.Errorf("无法写入PID文件 %s: %w", path, err)
+	}
+	return nil
+}
+
+// 删除 PID 文件，只删除由当前进程写入的文件
+func removePIDFile(path string) {
+	content, err := os.ReadFile(path)
+	if err != nil || strings.TrimSpace(string(content)) != strconv.Itoa(os.Getpid()) {
+		return
+	}
+	if err := os.Remove(path); err != nil {
+		logf(levelWarn, "删除PID文件失败: %v", err)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+package main
+
+import "syscall"
+
+// 判断进程是否仍在运行
+func processRunning(pid int) bool {
+	const processQueryLimitedInformation = 0x1000
+	handle, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	defer syscall.CloseHandle(handle)
+
+	var exitCode uint32
+	const stillActive = 259
+	return syscall.GetExitCodeProcess(handle, &exitCode) == nil && exitCode == stillActive
+}
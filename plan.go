@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/spf13/pflag"
+	_ "modernc.org/sqlite"
+)
+
+func init() {
+	subcommands["baseline"] = runBaseline
+}
+
+// 单次 EXPLAIN 的超时时间
+const explainTimeout = 5 * time.Second
+
+// 可以执行 EXPLAIN 的语句
+var explainablePattern = regexp.MustCompile(`(?i)^\s*(select|insert|update|delete|replace|with)\b`)
+
+// EXPLAIN 结果中的一行
+type planRow struct {
+	Table string // 表名
+	Type  string // 访问类型，例如 ref、range、ALL
+	Key   string // 使用的索引，为空表示未使用索引
+	Rows  int64  // 估算扫描行数
+	Extra string // Extra 列，例如 Using filesort
+}
+
+// 一条查询的执行计划摘要
+type queryPlan []planRow
+
+// 估算扫描的总行数
+func (p queryPlan) estimatedRows() int64 {
+	var total int64
+	for _, row := range p {
+		total += row.Rows
+	}
+	return total
+}
+
+// 格式化为一行文本，例如 orders: ref(idx_user), 120 行; users: ALL, 50000 行
+func (p queryPlan) String() string {
+	parts := make([]string, 0, len(p))
+	for _, row := range p {
+		access := row.Type
+		if row.Key != "" {
+			access += "(" + row.Key + ")"
+		}
+		parts = append(parts, fmt.Sprintf("%s: %s, %d 行", row.Table, access, row.Rows))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// 执行计划与基线相比是否明显变差：原本使用索引的表变为全表扫描，或估算行数增长超过 threshold 倍
+func planRegressed(baseline, current queryPlan, threshold float64) bool {
+	baselineTypes := make(map[string]string, len(baseline))
+	for _, row := range baseline {
+		baselineTypes[row.Table] = row.Type
+	}
+	for _, row := range current {
+		if old, ok := baselineTypes[row.Table]; ok && old != "ALL" && row.Type == "ALL" {
+			return true
+		}
+	}
+	old := baseline.estimatedRows()
+	return old > 0 && float64(current.estimatedRows()) > float64(old)*threshold
+}
+
+// 取一个独立的连接并切换到数据库 database：USE 只对当前连接生效
+func explainConn(ctx context.Context, db *sql.DB, database string) (*sql.Conn, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if database != "" {
+		if _, err := conn.ExecContext(ctx, "USE "+quoteIdentifier(database)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// 在数据库 database 中对 query 执行 EXPLAIN
+func explainQuery(db *sql.DB, database, query string) (queryPlan, error) {
+	if !explainablePattern.MatchString(query) {
+		return nil, errors.New("不支持 EXPLAIN 的语句")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), explainTimeout)
+	defer cancel()
+	conn, err := explainConn(ctx, db, database)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(ctx, "EXPLAIN "+strings.TrimRight(strings.TrimSpace(query), ";"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var plan queryPlan
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dest := make([]any, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		var row planRow
+		for i, column := range columns {
+			switch strings.ToLower(column) {
+			case "table":
+				row.Table = values[i].String
+			case "type":
+				row.Type = values[i].String
+			case "key":
+				row.Key = values[i].String
+			case "rows":
+				row.Rows, _ = strconv.ParseInt(values[i].String, 10, 64)
+			case "extra":
+				row.Extra = values[i].String
+			}
+		}
+		plan = append(plan, row)
+	}
+	return plan, rows.Err()
+}
+
+// 保存在 SQLite 中的执行计划基线，按指纹哈希索引
+type planBaseline struct {
+	db *sql.DB
+}
+
+// 打开基线数据库，不存在时创建
+func openPlanBaseline(path string) (*planBaseline, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS plan_baseline (
+		hash TEXT PRIMARY KEY,
+		fingerprint TEXT NOT NULL,
+		plan TEXT NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	)`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("无法初始化执行计划基线数据库 %s: %w", path, err)
+	}
+	return &planBaseline{db: db}, nil
+}
+
+// 读取指纹的基线执行计划
+func (b *planBaseline) load(hash uint64) (queryPlan, bool, error) {
+	var data string
+	err := b.db.QueryRow(`SELECT plan FROM plan_baseline WHERE hash = ?`, fmt.Sprintf("%016x", hash)).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var plan queryPlan
+	if err := json.Unmarshal([]byte(data), &plan); err != nil {
+		return nil, false, err
+	}
+	return plan, true, nil
+}
+
+// 保存指纹的基线执行计划，已存在时覆盖
+func (b *planBaseline) save(hash uint64, fingerprint string, plan queryPlan) error {
+	data, err := json.Marshal(plan)
+	if err != nil {
+		return err
+	}
+	_, err = b.db.Exec(`INSERT INTO plan_baseline (hash, fingerprint, plan, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(hash) DO UPDATE SET fingerprint = excluded.fingerprint, plan = excluded.plan, updated_at = excluded.updated_at`,
+		fmt.Sprintf("%016x", hash), fingerprint, string(data), time.Now().UTC())
+	return err
+}
+
+func (b *planBaseline) Close() error {
+	return b.db.Close()
+}
+
+// 执行 EXPLAIN 使用的 MySQL 连接与基线数据库，未启用 --baselineDB 时为 nil；只启用 --explainAnalyze 时也会打开连接
+var explainDB *sql.DB
+var planBaselines *planBaseline
+
+// 打开 --mysqlDSN 与 --baselineDB
+func openPlanCheck() (*sql.DB, *planBaseline, error) {
+	if mysqlDSN == "" {
+		return nil, nil, errors.New("使用 --baselineDB 时必须指定 --mysqlDSN")
+	}
+	db, err := sql.Open("mysql", mysqlDSN)
+	if err != nil {
+		return nil, nil, fmt.Errorf("--mysqlDSN 无效: %w", err)
+	}
+	baselines, err := openPlanBaseline(baselineDB)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	return db, baselines, nil
+}
+
+// 与基线相比发生变化的执行计划
+type planChange struct {
+	Baseline queryPlan
+	Current  queryPlan
+}
+
+// 告警前重新执行 EXPLAIN 与基线比较，执行计划变差时把新旧计划附加到告警中
+// 尚无基线的指纹以本次的执行计划作为基线；失败时不影响告警发送
+func checkPlanChange(entry *SlowQueryEntry) {
+	if planBaselines == nil {
+		return
+	}
+	current, err := explainQuery(explainDB, entry.Database, entry.SQL)
+	if err != nil {
+		logf(levelDebug, "无法获取执行计划，跳过比较: %v", err)
+		return
+	}
+	entry.PlanHints = explainHints(current)
+	baseline, ok, err := planBaselines.load(entry.Hash)
+	if err != nil {
+		logf(levelWarn, "读取执行计划基线失败: %v", err)
+		return
+	}
+	if !ok {
+		if err := planBaselines.save(entry.Hash, entry.Fingerprint, current); err != nil {
+			logf(levelWarn, "保存执行计划基线失败: %v", err)
+		}
+		return
+	}
+	if planRegressed(baseline, current, planChangeThreshold) {
+		logf(levelInfo, "指纹 %x 的执行计划已变化: %s -> %s", entry.Hash, baseline, current)
+		entry.PlanChange = &planChange{Baseline: baseline, Current: current}
+	}
+}
+
+// 读取日志文件中的所有日志条目
+func readLogEntries(path string) ([][]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries [][]string
+	var current []string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxRemoteLineBytes)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		if logParser.IsEntryStart(line, current) {
+			if len(current) > 0 {
+				entries = append(entries, current)
+			}
+			current = []string{line}
+		} else {
+			current = append(current, line)
+		}
+		if logParser.IsEntryComplete(line, current) {
+			entries = append(entries, current)
+			current = nil
+		}
+	}
+	if len(current) > 0 {
+		entries = append(entries, current)
+	}
+	return entries, scanner.Err()
+}
+
+// baseline 子命令：对慢查询日志中出现过的每个指纹执行 EXPLAIN，保存为执行计划基线
+func runBaseline(args []string) error {
+	if err := pflag.CommandLine.Parse(args); err != nil {
+		return err
+	}
+	if err := configureSQLKeywords(); err != nil {
+		return err
+	}
+	if baselineDB == "" {
+		return fmt.Errorf("用法: %s baseline --baselineDB=<文件> --mysqlDSN=<DSN> --slowLogFile=<文件>", programName)
+	}
+	db, baselines, err := openPlanCheck()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	defer baselines.Close()
+
+	entries, err := readLogEntries(slowLogFile)
+	if err != nil {
+		return fmt.Errorf("无法读取慢查询日志 %s: %w", slowLogFile, err)
+	}
+	seen := make(map[uint64]bool)
+	var saved, failed int
+	for _, lines := range entries {
+		entry, err := ParseLogLines(lines)
+		if err != nil || seen[entry.Hash] {
+			continue
+		}
+		seen[entry.Hash] = true
+		plan, err := explainQuery(db, entry.Database, entry.SQL)
+		if err != nil {
+			logf(levelDebug, "指纹 %x 无法执行 EXPLAIN: %v", entry.Hash, err)
+			failed++
+			continue
+		}
+		if err := baselines.save(entry.Hash, entry.Fingerprint, plan); err != nil {
+			return err
+		}
+		saved++
+	}
+	logf(levelInfo, "已保存 %d 个指纹的执行计划基线，%d 个无法执行 EXPLAIN", saved, failed)
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPlanRegressed(t *testing.T) {
+	baseline := queryPlan{{Table: "orders", Type: "ref", Key: "idx_user", Rows: 120}}
+	tests := []struct {
+		name    string
+		current queryPlan
+		want    bool
+	}{
+		{"相同", queryPlan{{Table: "orders", Type: "ref", Key: "idx_user", Rows: 150}}, false},
+		{"变为全表扫描", queryPlan{{Table: "orders", Type: "ALL", Rows: 150}}, true},
+		{"估算行数增长超过阈值", queryPlan{{Table: "orders", Type: "range", Key: "idx_created", Rows: 1300}}, true},
+		{"估算行数增长未超过阈值", queryPlan{{Table: "orders", Type: "range", Key: "idx_created", Rows: 1100}}, false},
+	}
+	for _, tt := range tests {
+		if got := planRegressed(baseline, tt.current, 10); got != tt.want {
+			t.Errorf("%s: planRegressed = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPlanBaseline(t *testing.T) {
+	baselines, err := openPlanBaseline(filepath.Join(t.TempDir(), "baseline.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer baselines.Close()
+
+	if _, ok, err := baselines.load(1); err != nil || ok {
+		t.Fatalf("load 不存在的基线 = %v, %v", ok, err)
+	}
+	plan := queryPlan{{Table: "orders", Type: "ref", Key: "idx_user", Rows: 120}}
+	if err := baselines.save(1, "select * from orders where user_id = ?", plan); err != nil {
+		t.Fatal(err)
+	}
+	updated := queryPlan{{Table: "orders", Type: "const", Key: "PRIMARY", Rows: 1}}
+	if err := baselines.save(1, "select * from orders where user_id = ?", updated); err != nil {
+		t.Fatal(err)
+	}
+	got, ok, err := baselines.load(1)
+	if err != nil || !ok {
+		t.Fatalf("load = %v, %v", ok, err)
+	}
+	if got.String() != updated.String() {
+		t.Errorf("load = %s, want %s", got, updated)
+	}
+}
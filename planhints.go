@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 告警中最多显示的优化建议条数
+const maxPlanHints = 3
+
+// EXPLAIN 的 Extra 列中常见的内容与对应的建议，%s 为表名
+var planHints = map[string]string{
+	"Using filesort":                "表 `%s` 使用 filesort 排序 — 考虑为 ORDER BY 的列添加索引",
+	"Using temporary":               "表 `%s` 使用临时表 — 考虑添加覆盖 GROUP BY 列的索引",
+	"Using join buffer":             "表 `%s` 的关联使用了 join buffer — 考虑为关联条件的列添加索引",
+	"Range checked for each record": "表 `%s` 对每一行重新选择索引 — 考虑为关联条件的列添加合适的索引",
+}
+
+// 全表扫描的建议，%s 为表名
+const fullScanHint = "表 `%s` 全表扫描 — 该 WHERE 条件没有可用的索引"
+
+// 根据执行计划生成优化建议，按执行计划中表的顺序，最多 maxPlanHints 条
+func explainHints(plan queryPlan) []string {
+	patterns := make([]string, 0, len(planHints))
+	for pattern := range planHints {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+
+	var hints []string
+	seen := make(map[string]bool)
+	add := func(hint string) {
+		if !seen[hint] && len(hints) < maxPlanHints {
+			seen[hint] = true
+			hints = append(hints, hint)
+		}
+	}
+	for _, row := range plan {
+		if row.Type == "ALL" && row.Table != "" {
+			add(fmt.Sprintf(fullScanHint, row.Table))
+		}
+		for _, pattern := range patterns {
+			if strings.Contains(row.Extra, pattern) {
+				add(fmt.Sprintf(planHints[pattern], row.Table))
+			}
+		}
+	}
+	return hints
+}
+
+// 告警中的优化建议，注明为自动生成
+func planHintNotes(hints []string) []string {
+	if len(hints) == 0 {
+		return nil
+	}
+	notes := []string{"* 以下建议根据 EXPLAIN 自动生成，仅供参考，请结合实际情况判断:"}
+	for _, hint := range hints {
+		notes = append(notes, "* 💡 "+hint)
+	}
+	return notes
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExplainHints(t *testing.T) {
+	plan := queryPlan{
+		{Table: "orders", Type: "ALL", Rows: 50000, Extra: "Using where; Using temporary; Using filesort"},
+		{Table: "users", Type: "eq_ref", Key: "PRIMARY", Rows: 1},
+		{Table: "items", Type: "ALL", Rows: 800, Extra: "Using where; Using join buffer (hash join)"},
+	}
+	want := []string{
+		"表 `orders` 全表扫描 — 该 WHERE 条件没有可用的索引",
+		"表 `orders` 使用 filesort 排序 — 考虑为 ORDER BY 的列添加索引",
+		"表 `orders` 使用临时表 — 考虑添加覆盖 GROUP BY 列的索引",
+	}
+	hints := explainHints(plan)
+	if !reflect.DeepEqual(hints, want) {
+		t.Fatalf("explainHints = %q, want %q", hints, want)
+	}
+	if hints := explainHints(plan[1:2]); hints != nil {
+		t.Errorf("使用索引的执行计划不应有建议: %q", hints)
+	}
+
+	notes := buildSlowQueryNotification(&SlowQueryEntry{SQL: "SELECT 1", PlanHints: hints}).Notes
+	if len(notes) != 4 || !strings.Contains(notes[0], "自动生成") || notes[1] != "* 💡 "+want[0] {
+		t.Errorf("Notes = %q", notes)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Percona PMM 通过 Unix socket 推送的慢查询事件，每行一条 JSON，字段已由 pmm-agent 解析
+//
+//	{"ts":"2024-01-01T10:00:00Z","schema":"shop","username":"app","client_host":"10.0.0.5","thread_id":42,"query_time":2.5,"lock_time":0.001,"rows_sent":10,"rows_examined":50000,"rows_affected":0,"example":"SELECT * FROM orders WHERE status = 'pending'","fingerprint":"select * from orders where status = ?"}
+type PMMLogParser struct{}
+
+// PMM 慢查询事件中的字段，query 与 example 均为SQL原文
+type pmmEvent struct {
+	Timestamp    time.Time `json:"ts"`
+	Schema       string    `json:"schema"`
+	Username     string    `json:"username"`
+	ClientHost   string    `json:"client_host"`
+	ThreadID     uint64    `json:"thread_id"`
+	QueryTime    float64   `json:"query_time"`
+	LockTime     float64   `json:"lock_time"`
+	RowsSent     int       `json:"rows_sent"`
+	RowsExamined int       `json:"rows_examined"`
+	RowsAffected int       `json:"rows_affected"`
+	Query        string    `json:"query"`
+	Example      string    `json:"example"`
+	Fingerprint  string    `json:"fingerprint"`
+}
+
+// 每行都是一条完整的事件
+func (PMMLogParser) IsEntryStart(line string, current []string) bool { return true }
+
+func (PMMLogParser) IsEntryComplete(line string, lines []string) bool { return true }
+
+func (PMMLogParser) EntryTime(line string) (time.Time, bool) {
+	var event struct {
+		Timestamp time.Time `json:"ts"`
+	}
+	if json.Unmarshal([]byte(line), &event) != nil || event.Timestamp.IsZero() {
+		return time.Time{}, false
+	}
+	return event.Timestamp, true
+}
+
+func (PMMLogParser) Parse(lines []string) (*SlowQueryEntry, error) {
+	line := strings.TrimSpace(strings.Join(lines, " "))
+	var event pmmEvent
+	if err := json.Unmarshal([]byte(line), &event); err != nil {
+		return nil, &ParseError{Line: line, Err: err}
+	}
+	sql := event.Query
+	if sql == "" {
+		sql = event.Example
+	}
+	if sql == "" {
+		return nil, &ParseError{Line: line, Err: errors.New("事件中没有 query 或 example")}
+	}
+	entry := &SlowQueryEntry{
+		Time:         event.Timestamp,
+		QueryTime:    event.QueryTime,
+		LockTime:     event.LockTime,
+		RowsSent:     event.RowsSent,
+		RowsExamined: event.RowsExamined,
+		RowsAffected: event.RowsAffected,
+		Database:     event.Schema,
+		User:         event.Username,
+		Host:         event.ClientHost,
+		ConnectionID: event.ThreadID,
+		SQL:          sql,
+		Fingerprint:  event.Fingerprint,
+	}
+	if entry.Fingerprint == "" {
+		entry.Fingerprint = normalizeQuery(sql)
+	}
+	entry.Hash = computeQueryHash(entry.Fingerprint)
+	return entry, nil
+}
+
+// 连接 --pmmSocket 读取慢查询事件，直到连接断开
+func readPMMSocket(path string, firstRun bool) error {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return fmt.Errorf("无法连接 PMM socket %s: %w", path, err)
+	}
+	defer conn.Close()
+	logf(levelInfo, "已连接 PMM socket %s，开始读取慢查询事件", path)
+
+	assembler := newEntryAssembler(firstRun)
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), maxRemoteLineBytes)
+	for scanner.Scan() {
+		assembler.feed(scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取 PMM socket 失败: %w", err)
+	}
+	return errors.New("PMM socket 连接已关闭")
+}
+
+// 持续读取 --pmmSocket，连接断开后按与本地文件相同的退避策略重新连接
+func watchPMMSocket(path string) {
+	var backoff restartBackoff
+	for firstRun := true; ; firstRun = false {
+		started := time.Now()
+		err := readPMMSocket(path, firstRun)
+		delay := backoff.next(time.Since(started))
+		logf(levelWarn, "%v，%s 后重新连接...", err, delay)
+		time.Sleep(delay)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPMMLogParser(t *testing.T) {
+	entry, err := PMMLogParser{}.Parse([]string{`{"ts":"2024-01-01T10:00:00Z","schema":"shop","username":"app","client_host":"10.0.0.5","thread_id":42,"query_time":2.5,"lock_time":0.001,"rows_sent":10,"rows_examined":50000,"example":"SELECT * FROM orders WHERE status = 'pending'"}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Database != "shop" || entry.User != "app" || entry.Host != "10.0.0.5" || entry.ConnectionID != 42 ||
+		entry.QueryTime != 2.5 || entry.RowsExamined != 50000 || !entry.Time.Equal(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("entry = %+v", entry)
+	}
+	if entry.Fingerprint != normalizeQuery(entry.SQL) || entry.Hash != computeQueryHash(entry.Fingerprint) {
+		t.Errorf("没有 fingerprint 时应按SQL计算指纹: %q", entry.Fingerprint)
+	}
+
+	for _, line := range []string{`not json`, `{"ts":"2024-01-01T10:00:00Z","query_time":1}`} {
+		if _, err := (PMMLogParser{}).Parse([]string{line}); err == nil {
+			t.Errorf("Parse(%s) 应返回错误", line)
+		}
+	}
+}
+
+func TestReadPMMSocket(t *testing.T) {
+	var alerted []*SlowQueryEntry
+	prevNotifier, prevThreshold, prevParser := alertNotifier, slowQueryThreshold, logParser
+	t.Cleanup(func() { alertNotifier, slowQueryThreshold, logParser = prevNotifier, prevThreshold, prevParser })
+	alertNotifier = func(targets []webhookTarget, entry *SlowQueryEntry) (int, error) {
+		alerted = append(alerted, entry)
+		return 1, nil
+	}
+	slowQueryThreshold, logParser = 1, PMMLogParser{}
+
+	path := filepath.Join(t.TempDir(), "pmm.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte(`{"ts":"2024-01-01T10:00:00Z","schema":"shop","query_time":0.2,"query":"SELECT 1"}` + "\n" +
+			`{"ts":"2024-01-01T10:00:01Z","schema":"shop","query_time":3,"query":"SELECT * FROM orders","fingerprint":"select * from orders"}` + "\n"))
+	}()
+
+	if err := readPMMSocket(path, true); err == nil {
+		t.Fatal("连接关闭后应返回错误以便重新连接")
+	}
+	if len(alerted) != 1 || alerted[0].Fingerprint != "select * from orders" || alerted[0].QueryTime != 3 {
+		t.Fatalf("alerted = %+v", alerted)
+	}
+}
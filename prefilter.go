@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// log_slow_filter 的可选值，包含 Percona 与 MariaDB 支持的全部过滤条件
+var slowLogFilterNames = []string{
+	"admin", "filesort", "filesort_on_disk", "filesort_priority_queue", "full_join", "full_scan",
+	"not_using_index", "qc_miss", "query_cache", "query_cache_miss", "tmp_table", "tmp_table_on_disk",
+}
+
+// 由 --slowLogFilter 解析得到的过滤条件
+var slowLogFilters []string
+
+// 解析 --slowLogFilter，格式与 MySQL 的 log_slow_filter 相同，例如 full_scan,full_join
+func configureSlowLogFilter() error {
+	slowLogFilters = nil
+	for _, name := range strings.Split(slowLogFilter, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(slowLogFilterNames, name) {
+			return fmt.Errorf("--slowLogFilter 中的 %q 无效，可选值: %s", name, strings.Join(slowLogFilterNames, ", "))
+		}
+		slowLogFilters = append(slowLogFilters, name)
+	}
+	return nil
+}
+
+// 开启了 log_slow_filter 时，汇总中的查询只是匹配过滤条件的部分，在汇总中说明
+func slowLogFilterDigest() ([]notificationField, []string) {
+	if len(slowLogFilters) == 0 {
+		return nil, nil
+	}
+	field := notificationField{Label: "log_slow_filter", Value: strings.Join(slowLogFilters, ", ")}
+	note := "* 慢查询日志只记录匹配 log_slow_filter 的查询，统计中不包含其他超过 long_query_time 的查询"
+	return []notificationField{field}, []string{note}
+}
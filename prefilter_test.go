@@ -0,0 +1,64 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAssumePrefiltered(t *testing.T) {
+	var alerted int
+	prevNotifier, prevThreshold, prevPrefiltered := alertNotifier, slowQueryThreshold, assumePrefiltered
+	t.Cleanup(func() {
+		alertNotifier, slowQueryThreshold, assumePrefiltered = prevNotifier, prevThreshold, prevPrefiltered
+	})
+	alertNotifier = func(targets []webhookTarget, entry *SlowQueryEntry) (int, error) {
+		alerted++
+		return 1, nil
+	}
+	slowQueryThreshold = 10
+	lines := fixtureLines(`
+# Time: 2024-03-10T08:15:42.000000Z
+# User@Host: app[app] @ localhost [127.0.0.1]  Id:    49
+# Query_time: 0.200000  Lock_time: 0.000100 Rows_sent: 1  Rows_examined: 50000
+SELECT * FROM orders WHERE note LIKE '%refund%';`)
+
+	processSlowQuery(lines, nil)
+	if alerted != 0 {
+		t.Fatal("未开启 --assumePrefiltered 时应按阈值过滤")
+	}
+	assumePrefiltered = true
+	processSlowQuery(lines, nil)
+	if alerted != 1 {
+		t.Errorf("开启 --assumePrefiltered 时每条日志都应告警，实际 %d 条", alerted)
+	}
+}
+
+func TestSlowLogFilterDigest(t *testing.T) {
+	prev := slowLogFilter
+	t.Cleanup(func() {
+		slowLogFilter = prev
+		configureSlowLogFilter()
+		takeDigest(time.Now())
+	})
+	slowLogFilter = "full_scan, FULL_JOIN"
+	if err := configureSlowLogFilter(); err != nil {
+		t.Fatal(err)
+	}
+	takeDigest(time.Now())
+	recordDigest(&SlowQueryEntry{Database: "shop", QueryTime: 1, Hash: 1, SQL: "SELECT * FROM orders"})
+	byDatabase, started := takeDigest(time.Now())
+	n := buildDigestNotification(byDatabase, started, time.Now())
+	if !slices.Contains(n.Fields, notificationField{Label: "log_slow_filter", Value: "full_scan, full_join"}) {
+		t.Errorf("汇总中应列出 log_slow_filter: %+v", n.Fields)
+	}
+	if len(n.Notes) != 1 || !strings.Contains(n.Notes[0], "log_slow_filter") {
+		t.Errorf("汇总中应说明日志已被筛选: %v", n.Notes)
+	}
+
+	slowLogFilter = "full_scan,slow"
+	if err := configureSlowLogFilter(); err == nil {
+		t.Error("无效的 --slowLogFilter 应返回错误")
+	}
+}
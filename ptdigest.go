@@ -0,0 +1,341 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 报告中详细列出的指纹个数，与 pt-query-digest 的默认 --limit 相同
+const ptDigestTopN = 20
+
+// 识别SQL中第一个表名，用于 Profile 中的 Item 列
+var ptItemTablePattern = regexp.MustCompile("(?i)\\b(?:FROM|INTO|UPDATE|JOIN|TABLE)\\s+`?([\\w.$]+)`?")
+
+// 一组查询的各项指标
+type ptSamples struct {
+	queryTime    []float64
+	lockTime     []float64
+	rowsSent     []float64
+	rowsExamined []float64
+	querySize    []float64
+}
+
+func (s *ptSamples) add(entry *SlowQueryEntry) {
+	s.queryTime = append(s.queryTime, entry.QueryTime)
+	s.lockTime = append(s.lockTime, entry.LockTime)
+	s.rowsSent = append(s.rowsSent, float64(entry.RowsSent))
+	s.rowsExamined = append(s.rowsExamined, float64(entry.RowsExamined))
+	s.querySize = append(s.querySize, float64(len(entry.SQL)))
+}
+
+// 单个SQL指纹的统计
+type ptFingerprint struct {
+	hash        uint64
+	fingerprint string
+	example     *SlowQueryEntry // 查询时间最长的一次，作为示例查询
+	databases   map[string]int
+	users       map[string]int
+	first, last time.Time
+	samples     ptSamples
+}
+
+// 按 pt-query-digest 格式统计慢查询
+type ptDigest struct {
+	global       ptSamples
+	fingerprints map[uint64]*ptFingerprint
+	first, last  time.Time
+}
+
+func newPTDigest() *ptDigest {
+	return &ptDigest{fingerprints: make(map[uint64]*ptFingerprint)}
+}
+
+// 记录的时间范围，at 为零值时不更新
+func extendTimeRange(first, last *time.Time, at time.Time) {
+	if at.IsZero() {
+		return
+	}
+	if first.IsZero() || at.Before(*first) {
+		*first = at
+	}
+	if at.After(*last) {
+		*last = at
+	}
+}
+
+func (d *ptDigest) add(entry *SlowQueryEntry) {
+	d.global.add(entry)
+	fp, ok := d.fingerprints[entry.Hash]
+	if !ok {
+		fp = &ptFingerprint{hash: entry.Hash, fingerprint: entry.Fingerprint, databases: make(map[string]int), users: make(map[string]int)}
+		d.fingerprints[entry.Hash] = fp
+	}
+	fp.samples.add(entry)
+	fp.databases[entry.Database]++
+	fp.users[entry.User]++
+	if fp.example == nil || entry.QueryTime > fp.example.QueryTime {
+		fp.example = entry
+	}
+	at := queryStartTime(entry)
+	extendTimeRange(&d.first, &d.last, at)
+	extendTimeRange(&fp.first, &fp.last, at)
+}
+
+// 一项指标的统计值
+type ptStats struct {
+	total, min, max, avg, pct95, stddev, median float64
+}
+
+func computePTStats(values []float64) ptStats {
+	if len(values) == 0 {
+		return ptStats{}
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	var s ptStats
+	for _, v := range sorted {
+		s.total += v
+	}
+	s.min, s.max = sorted[0], sorted[len(sorted)-1]
+	s.avg = s.total / float64(len(sorted))
+	var variance float64
+	for _, v := range sorted {
+		variance += (v - s.avg) * (v - s.avg)
+	}
+	s.stddev = math.Sqrt(variance / float64(len(sorted)))
+	s.pct95 = percentile(sorted, 95)
+	s.median = percentile(sorted, 50)
+	return s
+}
+
+// 时间的显示格式：s、ms、us
+func ptTime(seconds float64) string {
+	switch {
+	case seconds == 0:
+		return "0"
+	case seconds >= 100:
+		return fmt.Sprintf("%.0fs", seconds)
+	case seconds >= 1:
+		return fmt.Sprintf("%.2fs", seconds)
+	case seconds >= 0.001:
+		return fmt.Sprintf("%.0fms", seconds*1000)
+	}
+	return fmt.Sprintf("%.0fus", seconds*1e6)
+}
+
+// 数量的显示格式：k、M、G
+func ptCount(n float64) string {
+	switch {
+	case n >= 1e9:
+		return fmt.Sprintf("%.2fG", n/1e9)
+	case n >= 1e6:
+		return fmt.Sprintf("%.2fM", n/1e6)
+	case n >= 1e3:
+		return fmt.Sprintf("%.2fk", n/1e3)
+	}
+	return fmt.Sprintf("%.0f", n)
+}
+
+// 属性表中的一行，pct 为该指纹占全部查询的百分比，小于 0 时不显示
+func writePTAttribute(w io.Writer, name string, pct float64, values []float64, format func(float64) string) {
+	s := computePTStats(values)
+	pctColumn := "    "
+	if pct >= 0 {
+		pctColumn = fmt.Sprintf("%3.0f ", pct)
+	}
+	fmt.Fprintf(w, "# %-12s %s%7s %7s %7s %7s %7s %7s %7s\n", name, pctColumn,
+		format(s.total), format(s.min), format(s.max), format(s.avg), format(s.pct95), format(s.stddev), format(s.median))
+}
+
+// 属性表中的指标
+var ptAttributes = []struct {
+	name   string
+	values func(s *ptSamples) []float64
+	format func(float64) string
+}{
+	{"Exec time", func(s *ptSamples) []float64 { return s.queryTime }, ptTime},
+	{"Lock time", func(s *ptSamples) []float64 { return s.lockTime }, ptTime},
+	{"Rows sent", func(s *ptSamples) []float64 { return s.rowsSent }, ptCount},
+	{"Rows examine", func(s *ptSamples) []float64 { return s.rowsExamined }, ptCount},
+	{"Query size", func(s *ptSamples) []float64 { return s.querySize }, ptCount},
+}
+
+// part 占 whole 的百分比
+func ptShare(part, whole float64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return 100 * part / whole
+}
+
+// 所有指标的属性表，global 不为 nil 时显示该指纹占全部查询的百分比
+func writePTAttributes(w io.Writer, s *ptSamples, global *ptSamples) {
+	if global == nil {
+		fmt.Fprintf(w, "# Attribute          total     min     max     avg     95%%  stddev  median\n")
+		fmt.Fprintf(w, "# ============     ======= ======= ======= ======= ======= ======= =======\n")
+	} else {
+		fmt.Fprintf(w, "# Attribute    pct   total     min     max     avg     95%%  stddev  median\n")
+		fmt.Fprintf(w, "# ============ === ======= ======= ======= ======= ======= ======= =======\n")
+		count := len(s.queryTime)
+		fmt.Fprintf(w, "# %-12s %3.0f %7d\n", "Count", ptShare(float64(count), float64(len(global.queryTime))), count)
+	}
+	for _, a := range ptAttributes {
+		pct := -1.0
+		if global != nil {
+			pct = ptShare(computePTStats(a.values(s)).total, computePTStats(a.values(global)).total)
+		}
+		writePTAttribute(w, a.name, pct, a.values(s), a.format)
+	}
+}
+
+// 出现次数最多的值排在前面，例如 # Databases    shop (30/75%), analytics (10/25%)
+func ptValueCounts(counts map[string]int) string {
+	values := make([]string, 0, len(counts))
+	var total int
+	for v, n := range counts {
+		values = append(values, v)
+		total += n
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if counts[values[i]] != counts[values[j]] {
+			return counts[values[i]] > counts[values[j]]
+		}
+		return values[i] < values[j]
+	})
+	if len(values) == 1 {
+		return displayName(values[0])
+	}
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		parts = append(parts, fmt.Sprintf("%s (%d/%.0f%%)", displayName(v), counts[v], 100*float64(counts[v])/float64(total)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// 日志中没有记录的数据库或用户显示为 -
+func displayName(name string) string {
+	if name == "" {
+		return "-"
+	}
+	return name
+}
+
+// Profile 中的 Item 列：语句类型与第一个表名，例如 SELECT orders
+func ptItem(fingerprint string) string {
+	fields := strings.Fields(fingerprint)
+	if len(fields) == 0 {
+		return ""
+	}
+	item := strings.ToUpper(fields[0])
+	if m := ptItemTablePattern.FindStringSubmatch(fingerprint); m != nil {
+		item += " " + m[1]
+	}
+	return item
+}
+
+// 每秒查询数与并发度，时间范围不足一秒时不计算
+func ptRates(count int, totalTime float64, first, last time.Time) (float64, float64) {
+	span := last.Sub(first).Seconds()
+	if span < 1 {
+		return 0, 0
+	}
+	return float64(count) / span, totalTime / span
+}
+
+// 按 pt-query-digest 的文本格式输出报告：全局统计、Profile 以及按总耗时排列的各指纹详情
+func (d *ptDigest) write(w io.Writer, now time.Time) {
+	fps := make([]*ptFingerprint, 0, len(d.fingerprints))
+	for _, fp := range d.fingerprints {
+		fps = append(fps, fp)
+	}
+	totals := make(map[uint64]float64, len(fps))
+	for _, fp := range fps {
+		totals[fp.hash] = computePTStats(fp.samples.queryTime).total
+	}
+	sort.Slice(fps, func(i, j int) bool {
+		if a, b := totals[fps[i].hash], totals[fps[j].hash]; a != b {
+			return a > b
+		}
+		return fps[i].hash < fps[j].hash
+	})
+	if len(fps) > ptDigestTopN {
+		fps = fps[:ptDigestTopN]
+	}
+
+	globalTime := computePTStats(d.global.queryTime).total
+	qps, concurrency := ptRates(len(d.global.queryTime), globalTime, d.first, d.last)
+	fmt.Fprintf(w, "# Current date: %s\n", now.Format("Mon Jan _2 15:04:05 2006"))
+	fmt.Fprintf(w, "# Overall: %s total, %s unique, %.2f QPS, %.2fx concurrency ________________\n",
+		ptCount(float64(len(d.global.queryTime))), ptCount(float64(len(d.fingerprints))), qps, concurrency)
+	if !d.first.IsZero() {
+		fmt.Fprintf(w, "# Time range: %s to %s\n", d.first.Format("2006-01-02T15:04:05"), d.last.Format("2006-01-02T15:04:05"))
+	}
+	writePTAttributes(w, &d.global, nil)
+
+	fmt.Fprintf(w, "\n# Profile\n")
+	fmt.Fprintf(w, "# Rank Query ID            Response time    Calls  R/Call V/M   Item\n")
+	fmt.Fprintf(w, "# ==== =================== ================ ===== ======= ===== ==========\n")
+	for i, fp := range fps {
+		s := computePTStats(fp.samples.queryTime)
+		var vm float64
+		if s.avg > 0 {
+			vm = s.stddev * s.stddev / s.avg
+		}
+		fmt.Fprintf(w, "# %4d 0x%016X %9.4f %5.1f%% %5d %7.4f %5.2f %s\n",
+			i+1, fp.hash, s.total, ptShare(s.total, globalTime), len(fp.samples.queryTime), s.avg, vm, ptItem(fp.fingerprint))
+	}
+
+	for i, fp := range fps {
+		s := computePTStats(fp.samples.queryTime)
+		qps, concurrency := ptRates(len(fp.samples.queryTime), s.total, fp.first, fp.last)
+		fmt.Fprintf(w, "\n# Query %d: %.2f QPS, %.2fx concurrency, ID 0x%016X ________________\n", i+1, qps, concurrency, fp.hash)
+		fmt.Fprintf(w, "# Response time: %s total, Calls: %d, R/Call: %s\n", ptTime(s.total), len(fp.samples.queryTime), ptTime(s.avg))
+		if !fp.first.IsZero() {
+			fmt.Fprintf(w, "# Time range: %s to %s\n", fp.first.Format("2006-01-02T15:04:05"), fp.last.Format("2006-01-02T15:04:05"))
+		}
+		writePTAttributes(w, &fp.samples, &d.global)
+		fmt.Fprintf(w, "# Databases    %s\n", ptValueCounts(fp.databases))
+		fmt.Fprintf(w, "# Users        %s\n", ptValueCounts(fp.users))
+		fmt.Fprintf(w, "# Fingerprint  %s\n", digestSQL(fp.fingerprint))
+		sql := strings.TrimRight(strings.TrimSpace(fp.example.SQL), ";")
+		fmt.Fprintf(w, "%s\\G\n", sql)
+	}
+}
+
+// 当前汇总周期内按 pt-query-digest 格式统计的慢查询
+var ptDigestPeriod = struct {
+	sync.Mutex
+	digest *ptDigest
+}{digest: newPTDigest()}
+
+// 把达到告警阈值的慢查询计入 --ptDigestOutput 报告
+func recordPTDigest(entry *SlowQueryEntry) {
+	ptDigestPeriod.Lock()
+	ptDigestPeriod.digest.add(entry)
+	ptDigestPeriod.Unlock()
+}
+
+// 取出当前周期的统计并开始新的周期
+func takePTDigest() *ptDigest {
+	ptDigestPeriod.Lock()
+	defer ptDigestPeriod.Unlock()
+	d := ptDigestPeriod.digest
+	ptDigestPeriod.digest = newPTDigest()
+	return d
+}
+
+// 把报告写入文件，先写临时文件再重命名，读取方不会读到写了一半的报告
+func writePTDigestFile(path string, d *ptDigest, now time.Time) error {
+	var buf strings.Builder
+	d.write(&buf, now)
+	if err := writeFileAtomic(path, []byte(buf.String())); err != nil {
+		return fmt.Errorf("无法写入 pt-query-digest 报告 %s: %w", path, err)
+	}
+	return nil
+}
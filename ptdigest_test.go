@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPTDigestReport(t *testing.T) {
+	d := newPTDigest()
+	for _, text := range []string{`
+# Time: 2024-01-15T14:00:00.000000Z
+# User@Host: app[app] @ localhost []  Id:    42
+# Query_time: 2.000000  Lock_time: 0.001000 Rows_sent: 1  Rows_examined: 50000
+use shop;
+SELECT * FROM orders WHERE id = 1;`, `
+# Time: 2024-01-15T14:10:00.000000Z
+# User@Host: app[app] @ localhost []  Id:    43
+# Query_time: 4.000000  Lock_time: 0.001000 Rows_sent: 1  Rows_examined: 50000
+use shop;
+SELECT * FROM orders WHERE id = 2;`, `
+# Time: 2024-01-15T14:20:00.000000Z
+# User@Host: etl[etl] @ localhost []  Id:    44
+# Query_time: 1.000000  Lock_time: 0.000000 Rows_sent: 0  Rows_examined: 0
+use analytics;
+UPDATE stats SET total = total + 1;`} {
+		entry, err := ParseLogLines(fixtureLines(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.add(entry)
+	}
+
+	var buf strings.Builder
+	d.write(&buf, time.Date(2024, 1, 15, 15, 0, 0, 0, time.UTC))
+	report := buf.String()
+	for _, want := range []string{
+		"# Overall: 3 total, 2 unique",
+		"# Time range: 2024-01-15T14:00:00 to 2024-01-15T14:20:00",
+		"# Profile\n",
+		"SELECT orders\n",
+		"UPDATE stats\n",
+		"# Query 1: ",
+		"# Response time: 6.00s total, Calls: 2, R/Call: 3.00s\n",
+		"# Count         67       2\n",
+		"# Databases    shop\n",
+		"SELECT * FROM orders WHERE id = 2\\G\n",
+		"# Query 2: ",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("报告中缺少 %q:\n%s", want, report)
+		}
+	}
+	if strings.Index(report, "SELECT orders") > strings.Index(report, "UPDATE stats") {
+		t.Errorf("Profile 应按总耗时排列")
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// classifySQL 返回的SQL类型及告警中显示的标记
+var sqlTypeBadges = map[string]string{
+	"SELECT":  "🔍 SELECT",
+	"INSERT":  "📥 INSERT",
+	"UPDATE":  "✏️ UPDATE",
+	"DELETE":  "🗑️ DELETE",
+	"REPLACE": "🔄 REPLACE",
+	"CALL":    "⚙️ CALL",
+	"OTHER":   "📄 OTHER",
+}
+
+var leadingKeywordPattern = regexp.MustCompile(`^[A-Za-z]+`)
+
+// WITH 子句之后的主语句，例如 WITH t AS (...) UPDATE ...
+var cteStatementPattern = regexp.MustCompile(`(?is)\)\s*(SELECT|INSERT|UPDATE|DELETE|REPLACE)\b`)
+
+// 按第一个关键字把SQL分为 SELECT、INSERT、UPDATE、DELETE、REPLACE、CALL 与 OTHER，忽略开头的注释与括号
+func classifySQL(sql string) string {
+	_, rest := splitQueryComment(sql)
+	rest = strings.TrimLeft(rest, "( \t\n")
+	keyword := strings.ToUpper(leadingKeywordPattern.FindString(rest))
+	if keyword == "WITH" {
+		if m := cteStatementPattern.FindStringSubmatch(rest); m != nil {
+			return strings.ToUpper(m[1])
+		}
+		return "SELECT"
+	}
+	if _, ok := sqlTypeBadges[keyword]; ok {
+		return keyword
+	}
+	return "OTHER"
+}
+
+// MongoDB 操作对应的SQL类型
+var mongoDBOperationTypes = map[string]string{
+	"find":          "SELECT",
+	"aggregate":     "SELECT",
+	"count":         "SELECT",
+	"distinct":      "SELECT",
+	"getMore":       "SELECT",
+	"query":         "SELECT", // 旧版本文本日志中的操作类型
+	"getmore":       "SELECT",
+	"insert":        "INSERT",
+	"update":        "UPDATE",
+	"findAndModify": "UPDATE",
+	"delete":        "DELETE",
+	"remove":        "DELETE",
+}
+
+// 日志条目的SQL类型，MongoDB 的日志按操作类型分类
+func queryType(entry *SlowQueryEntry) string {
+	if isMongoDBEntry(entry) {
+		if t, ok := mongoDBOperationTypes[entry.Operation]; ok {
+			return t
+		}
+		return "OTHER"
+	}
+	return classifySQL(entry.SQL)
+}
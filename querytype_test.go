@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestClassifySQL(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SELECT * FROM orders;", "SELECT"},
+		{"select * from orders;", "SELECT"},
+		{"/* app: payments */ UPDATE orders SET status = 1;", "UPDATE"},
+		{"(SELECT 1) UNION (SELECT 2);", "SELECT"},
+		{"INSERT INTO t VALUES (1);", "INSERT"},
+		{"DELETE FROM t WHERE id = 1;", "DELETE"},
+		{"REPLACE INTO t VALUES (1);", "REPLACE"},
+		{"CALL refresh_stats();", "CALL"},
+		{"WITH recent AS (SELECT id FROM orders) SELECT * FROM recent;", "SELECT"},
+		{"WITH stale AS (SELECT id FROM orders WHERE created < NOW()) DELETE FROM orders WHERE id IN (SELECT id FROM stale);", "DELETE"},
+		{"EXPLAIN SELECT * FROM orders;", "OTHER"},
+		{"ALTER TABLE orders ADD INDEX idx_status (status);", "OTHER"},
+		{"", "OTHER"},
+	}
+	for _, tt := range tests {
+		if got := classifySQL(tt.sql); got != tt.want {
+			t.Errorf("classifySQL(%q) = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+
+	if got := queryType(&SlowQueryEntry{Operation: "findAndModify", SQL: `{"_id":1}`}); got != "UPDATE" {
+		t.Errorf("MongoDB findAndModify = %q", got)
+	}
+}
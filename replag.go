@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 复制延迟较高时附加到告警的说明
+const replicationLagNote = "⚠️ 复制延迟较高，可能是查询变慢的原因之一"
+
+// 最近一次通过 pt-heartbeat 查询到的复制延迟，在 --lagCacheTTL 内复用
+var replicationLagCache = struct {
+	sync.Mutex
+	lag       time.Duration
+	err       error
+	checkedAt time.Time
+}{}
+
+// 查询 --heartbeatTable 的连接
+var heartbeatDB *sql.DB
+
+// 标识符加上反引号，用于拼接库名与表名
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// 计算复制延迟的SQL：当前时间与 pt-heartbeat 最近一次写入的 ts 之差，单位：微秒
+func heartbeatLagSQL() string {
+	now := "NOW(6)"
+	if heartbeatUTC {
+		now = "UTC_TIMESTAMP(6)"
+	}
+	return fmt.Sprintf("SELECT TIMESTAMPDIFF(MICROSECOND, MAX(ts), %s) FROM %s.%s", now, quoteIdentifier(heartbeatSchema), quoteIdentifier(heartbeatTable))
+}
+
+// 通过 pt-heartbeat 的心跳表查询复制延迟，测试中可以替换为模拟实现
+var queryReplicationLag = func() (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var micros sql.NullInt64
+	if err := heartbeatDB.QueryRowContext(ctx, heartbeatLagSQL()).Scan(&micros); err != nil {
+		return 0, fmt.Errorf("无法查询复制延迟: %w", err)
+	}
+	if !micros.Valid {
+		return 0, fmt.Errorf("心跳表 %s.%s 中没有记录", heartbeatSchema, heartbeatTable)
+	}
+	return time.Duration(micros.Int64) * time.Microsecond, nil
+}
+
+// 当前的复制延迟，--lagCacheTTL 内使用缓存的结果（包括查询失败）
+func replicationLag(now time.Time) (time.Duration, error) {
+	replicationLagCache.Lock()
+	defer replicationLagCache.Unlock()
+	if replicationLagCache.checkedAt.IsZero() || now.Sub(replicationLagCache.checkedAt) >= lagCacheTTL {
+		replicationLagCache.lag, replicationLagCache.err = queryReplicationLag()
+		replicationLagCache.checkedAt = now
+	}
+	return replicationLagCache.lag, replicationLagCache.err
+}
+
+// 告警前检查复制延迟，超过 --maxReplicationLag 时记录到告警中
+func checkReplicationLag(entry *SlowQueryEntry) {
+	if heartbeatTable == "" {
+		return
+	}
+	lag, err := replicationLag(time.Now())
+	if err != nil {
+		logf(levelDebug, "%v，跳过复制延迟检查", err)
+		return
+	}
+	if lag > maxReplicationLag {
+		entry.ReplicationLag = lag
+	}
+}
+
+// 复制延迟较高时附加到告警的说明
+func replicationLagNotes(entry *SlowQueryEntry) []string {
+	if entry.ReplicationLag <= 0 {
+		return nil
+	}
+	return []string{replicationLagNote}
+}
+
+// 连接 --mysqlDSN 用于查询 pt-heartbeat 的心跳表
+func openHeartbeatDB() error {
+	if mysqlDSN == "" {
+		return errors.New("使用 --heartbeatTable 时必须指定 --mysqlDSN")
+	}
+	db, err := sql.Open("mysql", mysqlDSN)
+	if err != nil {
+		return fmt.Errorf("--mysqlDSN 无效: %w", err)
+	}
+	registerShutdownHook(func() { db.Close() })
+	heartbeatDB = db
+	return nil
+}
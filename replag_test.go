@@ -0,0 +1,57 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestReplicationLag(t *testing.T) {
+	queries := 0
+	lag := 45 * time.Second
+	prevQuery, prevTable, prevMax, prevTTL := queryReplicationLag, heartbeatTable, maxReplicationLag, lagCacheTTL
+	t.Cleanup(func() {
+		queryReplicationLag, heartbeatTable, maxReplicationLag, lagCacheTTL = prevQuery, prevTable, prevMax, prevTTL
+		replicationLagCache.checkedAt = time.Time{}
+	})
+	queryReplicationLag = func() (time.Duration, error) {
+		queries++
+		return lag, nil
+	}
+	heartbeatTable, maxReplicationLag, lagCacheTTL = "heartbeat", 30*time.Second, 10*time.Second
+	replicationLagCache.checkedAt = time.Time{}
+
+	entry := &SlowQueryEntry{QueryTime: 12, SQL: "SELECT 1"}
+	checkReplicationLag(entry)
+	if entry.ReplicationLag != 45*time.Second {
+		t.Fatalf("ReplicationLag = %s", entry.ReplicationLag)
+	}
+	n := buildSlowQueryNotification(entry)
+	if !slices.Contains(n.Notes, replicationLagNote) {
+		t.Errorf("告警中缺少复制延迟提示: %v", n.Notes)
+	}
+	if !slices.ContainsFunc(n.Fields, func(f notificationField) bool { return f.Label == "复制延迟" && f.Value == "45s" }) {
+		t.Errorf("告警中缺少复制延迟字段: %+v", n.Fields)
+	}
+
+	lag = 5 * time.Second
+	now := time.Now()
+	if got, _ := replicationLag(now); got != 45*time.Second || queries != 1 {
+		t.Errorf("缓存时间内应复用查询结果: lag=%s queries=%d", got, queries)
+	}
+	if got, _ := replicationLag(now.Add(lagCacheTTL)); got != 5*time.Second || queries != 2 {
+		t.Errorf("缓存过期后应重新查询: lag=%s queries=%d", got, queries)
+	}
+	entry = &SlowQueryEntry{QueryTime: 12, SQL: "SELECT 1"}
+	checkReplicationLag(entry)
+	if n := buildSlowQueryNotification(entry); entry.ReplicationLag != 0 || len(n.Notes) != 0 {
+		t.Errorf("复制延迟未超过 --maxReplicationLag 时不应提示: %v", n.Notes)
+	}
+
+	prevSchema, prevUTC := heartbeatSchema, heartbeatUTC
+	t.Cleanup(func() { heartbeatSchema, heartbeatUTC = prevSchema, prevUTC })
+	heartbeatSchema, heartbeatTable, heartbeatUTC = "percona", "hb`x", true
+	if got := heartbeatLagSQL(); got != "SELECT TIMESTAMPDIFF(MICROSECOND, MAX(ts), UTC_TIMESTAMP(6)) FROM `percona`.`hb``x`" {
+		t.Errorf("heartbeatLagSQL = %s", got)
+	}
+}
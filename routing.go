@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// 按用户或数据库把告警发送到不同的Webhook地址
+type webhookRoutes struct {
+	users     map[string][]webhookTarget // 精确匹配的用户
+	userGlobs map[string][]webhookTarget // 带通配符的用户，例如 app_*
+	databases map[string][]webhookTarget
+}
+
+// 通过 --userWebhooks 与 --databaseWebhooks 配置的路由，nil 表示全部发送到 --webhookURL
+var alertRoutes *webhookRoutes
+
+// 解析路由配置：以 { 开头时视为JSON字符串，否则视为JSON文件路径，值为逗号分隔的Webhook地址
+func loadWebhookRoutes(spec string) (map[string][]webhookTarget, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+	data := []byte(spec)
+	if !strings.HasPrefix(spec, "{") {
+		content, err := os.ReadFile(spec)
+		if err != nil {
+			return nil, fmt.Errorf("无法读取Webhook路由配置文件 %s: %w", spec, err)
+		}
+		data = content
+	}
+
+	var urls map[string]string
+	if err := json.Unmarshal(data, &urls); err != nil {
+		return nil, fmt.Errorf("Webhook路由配置格式错误: %w", err)
+	}
+	routes := make(map[string][]webhookTarget, len(urls))
+	for key, value := range urls {
+		for _, spec := range strings.Split(value, ",") {
+			if spec = strings.TrimSpace(spec); spec == "" {
+				continue
+			}
+			target, err := parseWebhookTarget(spec)
+			if err != nil {
+				return nil, err
+			}
+			routes[key] = append(routes[key], target)
+		}
+	}
+	return routes, nil
+}
+
+// 解析 --userWebhooks 与 --databaseWebhooks
+func configureWebhookRoutes() error {
+	users, err := loadWebhookRoutes(userWebhooks)
+	if err != nil {
+		return fmt.Errorf("--userWebhooks 参数无效: %w", err)
+	}
+	databases, err := loadWebhookRoutes(databaseWebhooks)
+	if err != nil {
+		return fmt.Errorf("--databaseWebhooks 参数无效: %w", err)
+	}
+	if len(users) == 0 && len(databases) == 0 {
+		alertRoutes = nil
+		return nil
+	}
+
+	routes := &webhookRoutes{users: make(map[string][]webhookTarget), userGlobs: make(map[string][]webhookTarget), databases: databases}
+	for pattern, targets := range users {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("--userWebhooks 中的用户 %q 无效: %w", pattern, err)
+		}
+		if strings.ContainsAny(pattern, "*?[") {
+			routes.userGlobs[pattern] = targets
+		} else {
+			routes.users[pattern] = targets
+		}
+	}
+	alertRoutes = routes
+	return nil
+}
+
+// 返回告警的发送地址：精确匹配的用户 > 通配符匹配的用户 > 数据库 > 实例的 webhookURL > --webhookURL
+// 多个通配符都匹配时使用最长（最具体）的一个
+func routeTargets(entry *SlowQueryEntry) []webhookTarget {
+	if alertRoutes == nil {
+		return defaultTargets(entry)
+	}
+	if targets, ok := alertRoutes.users[entry.User]; ok {
+		return targets
+	}
+	var best string
+	for pattern := range alertRoutes.userGlobs {
+		if ok, _ := path.Match(pattern, entry.User); ok && (len(pattern) > len(best) || len(pattern) == len(best) && pattern < best) {
+			best = pattern
+		}
+	}
+	if best != "" {
+		return alertRoutes.userGlobs[best]
+	}
+	if targets, ok := alertRoutes.databases[entry.Database]; ok {
+		return targets
+	}
+	return defaultTargets(entry)
+}
+
+// 没有匹配的路由时的发送地址：实例设置了 webhookURL 时使用实例的地址
+func defaultTargets(entry *SlowQueryEntry) []webhookTarget {
+	if entry.Instance != nil && len(entry.Instance.targets) > 0 {
+		return entry.Instance.targets
+	}
+	return webhookDestinations
+}
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestRouteTargets(t *testing.T) {
+	prevUsers, prevDatabases, prevRoutes, prevDestinations := userWebhooks, databaseWebhooks, alertRoutes, webhookDestinations
+	t.Cleanup(func() {
+		userWebhooks, databaseWebhooks, alertRoutes, webhookDestinations = prevUsers, prevDatabases, prevRoutes, prevDestinations
+	})
+	webhookDestinations = []webhookTarget{{URL: "https://default"}}
+	userWebhooks = `{"app_user":"https://app-user","app_*":"https://app","a*":"https://a|slack"}`
+	databaseWebhooks = `{"analytics":"https://bi-1,https://bi-2"}`
+	if err := configureWebhookRoutes(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		user, database string
+		want           string
+	}{
+		{"app_user", "analytics", "https://app-user"}, // 精确匹配优先
+		{"app_worker", "analytics", "https://app"},    // 最长的通配符优先于数据库
+		{"admin", "shop", "https://a"},
+		{"etl", "analytics", "https://bi-1"},
+		{"etl", "shop", "https://default"},
+	}
+	for _, tt := range tests {
+		targets := routeTargets(&SlowQueryEntry{User: tt.user, Database: tt.database})
+		if len(targets) == 0 || targets[0].URL != tt.want {
+			t.Errorf("routeTargets(%s, %s) = %+v, want %s", tt.user, tt.database, targets, tt.want)
+		}
+	}
+	if targets := routeTargets(&SlowQueryEntry{User: "etl", Database: "analytics"}); len(targets) != 2 {
+		t.Errorf("数据库路由应包含 2 个地址，实际 %d 个", len(targets))
+	}
+	if targets := routeTargets(&SlowQueryEntry{User: "admin"}); targets[0].Format != webhookFormats["slack"] {
+		t.Errorf("路由地址应支持 url|格式")
+	}
+}
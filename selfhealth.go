@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 自身健康检查统计的时间范围
+const selfHealthWindow = 5 * time.Minute
+
+// 自身健康告警的阈值：Webhook发送失败率、日志监控协程重新启动次数
+const (
+	selfHealthMaxFailureRate = 0.5
+	selfHealthMaxRestarts    = 3
+)
+
+// 最近 selfHealthWindow 内的Webhook发送结果与日志监控协程重新启动时间
+type selfHealthStats struct {
+	sync.Mutex
+	deliveries []deliveryOutcome
+	restarts   []time.Time
+	lastAlert  time.Time
+}
+
+type deliveryOutcome struct {
+	at     time.Time
+	failed bool
+}
+
+var selfHealth selfHealthStats
+
+// 记录一次Webhook发送结果
+func recordWebhookOutcome(err error) {
+	selfHealth.Lock()
+	defer selfHealth.Unlock()
+	now := time.Now()
+	selfHealth.deliveries = append(selfHealth.deliveries, deliveryOutcome{at: now, failed: err != nil})
+	selfHealth.prune(now)
+}
+
+// 记录一次日志监控协程重新启动
+func recordTailRestart() {
+	selfHealth.Lock()
+	defer selfHealth.Unlock()
+	now := time.Now()
+	selfHealth.restarts = append(selfHealth.restarts, now)
+	selfHealth.prune(now)
+}
+
+// 丢弃超出统计范围的记录，调用方需持有锁
+func (s *selfHealthStats) prune(now time.Time) {
+	cutoff := now.Add(-selfHealthWindow)
+	i := 0
+	for i < len(s.deliveries) && s.deliveries[i].at.Before(cutoff) {
+		i++
+	}
+	s.deliveries = s.deliveries[i:]
+	i = 0
+	for i < len(s.restarts) && s.restarts[i].Before(cutoff) {
+		i++
+	}
+	s.restarts = s.restarts[i:]
+}
+
+// 检查自身健康状况，返回每个异常的说明
+func (s *selfHealthStats) problems(now time.Time) []string {
+	s.Lock()
+	defer s.Unlock()
+	s.prune(now)
+	var problems []string
+	if total := len(s.deliveries); total > 0 {
+		failed := 0
+		for _, d := range s.deliveries {
+			if d.failed {
+				failed++
+			}
+		}
+		if rate := float64(failed) / float64(total); rate > selfHealthMaxFailureRate {
+			problems = append(problems, fmt.Sprintf("最近 %s Webhook发送失败率 %.0f%%（%d/%d）", selfHealthWindow, rate*100, failed, total))
+		}
+	}
+	if len(s.restarts) > selfHealthMaxRestarts {
+		problems = append(problems, fmt.Sprintf("最近 %s 日志监控协程重新启动 %d 次", selfHealthWindow, len(s.restarts)))
+	}
+	return problems
+}
+
+// 发送自身健康告警的地址，与慢查询告警地址分开，避免告警地址故障时无法通知
+var selfAlertTarget *webhookTarget
+
+// 每隔 interval 检查一次自身健康状况，存在异常且不在冷却时间内时发送告警
+func runSelfHealthCheck(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		checkSelfHealth(time.Now())
+	}
+}
+
+// 检查一次自身健康状况并按需发送告警
+func checkSelfHealth(now time.Time) {
+	problems := selfHealth.problems(now)
+	if len(problems) == 0 {
+		return
+	}
+	selfHealth.Lock()
+	if !selfHealth.lastAlert.IsZero() && now.Sub(selfHealth.lastAlert) < selfAlertCooldown {
+		selfHealth.Unlock()
+		logf(levelDebug, "自身健康告警处于冷却时间内: %s", strings.Join(problems, "; "))
+		return
+	}
+	selfHealth.lastAlert = now
+	selfHealth.Unlock()
+
+	logf(levelWarn, "检测到自身运行异常: %s", strings.Join(problems, "; "))
+	if err := sendSelfAlert(problems); err != nil {
+		logf(levelError, "无法发送自身健康告警 [%s]: %v", selfAlertTarget.URL, err)
+	}
+}
+
+// 以纯文本发送自身健康告警，不经过告警路由、死信文件与失败率统计
+func sendSelfAlert(problems []string) error {
+	format := selfAlertTarget.Format
+	if format == nil {
+		format = activeWebhookFormat
+	}
+	title := "⚠️ mysql-slow-sql-webhook 运行异常"
+	text := title + "\n" + strings.Join(problems, "\n")
+	payload, err := marshalPayload(format.Payload(title, text))
+	if err != nil {
+		return err
+	}
+	return postWebhook(*selfAlertTarget, payload)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSelfHealthAlert(t *testing.T) {
+	var mu sync.Mutex
+	var alerts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		alerts = append(alerts, string(body))
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	reset := func() {
+		selfHealth.Lock()
+		selfHealth.deliveries, selfHealth.restarts, selfHealth.lastAlert = nil, nil, time.Time{}
+		selfHealth.Unlock()
+	}
+	prevTarget, prevCooldown := selfAlertTarget, selfAlertCooldown
+	t.Cleanup(func() {
+		selfAlertTarget, selfAlertCooldown = prevTarget, prevCooldown
+		reset()
+	})
+	selfAlertTarget = &webhookTarget{URL: server.URL, Format: webhookFormats["generic"], Timeout: 5 * time.Second}
+	selfAlertCooldown = time.Hour
+	reset()
+
+	recordWebhookOutcome(nil)
+	recordWebhookOutcome(errors.New("timeout"))
+	for range 3 {
+		recordTailRestart()
+	}
+	now := time.Now()
+	checkSelfHealth(now)
+	if len(alerts) != 0 {
+		t.Fatalf("失败率 50%%、重新启动 3 次不应告警: %v", alerts)
+	}
+
+	recordWebhookOutcome(errors.New("timeout"))
+	recordTailRestart()
+	checkSelfHealth(now)
+	if len(alerts) != 1 {
+		t.Fatalf("实际告警 %d 条", len(alerts))
+	}
+	for _, want := range []string{"Webhook发送失败率 67%（2/3）", "日志监控协程重新启动 4 次"} {
+		if !strings.Contains(alerts[0], want) {
+			t.Errorf("告警中缺少 %q: %s", want, alerts[0])
+		}
+	}
+
+	checkSelfHealth(now.Add(time.Minute))
+	if len(alerts) != 1 {
+		t.Errorf("冷却时间内不应重复告警")
+	}
+	if problems := selfHealth.problems(now.Add(selfHealthWindow + time.Minute)); len(problems) != 0 {
+		t.Errorf("超出统计范围的记录应被丢弃: %v", problems)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 连接数超过该值时清理窗口内没有慢查询的连接
+const sessionSweepSize = 1024
+
+// 连接在窗口内的一条慢查询
+type sessionQuery struct {
+	at          time.Time
+	fingerprint string
+}
+
+// 单个连接最近的慢查询
+type sessionWindow struct {
+	queries   []sessionQuery
+	lastAlert time.Time // 一个窗口内只告警一次
+}
+
+// 按连接ID统计的慢查询
+var sessionWindows = struct {
+	sync.Mutex
+	byConnection map[uint64]*sessionWindow
+}{byConnection: make(map[uint64]*sessionWindow)}
+
+// 一次会话模式告警的统计信息
+type sessionFinding struct {
+	ConnectionID uint64
+	Queries      int            // 窗口内的慢查询条数
+	Fingerprints map[string]int // 指纹 -> 窗口内出现次数
+}
+
+// 丢弃窗口之外的慢查询
+func (w *sessionWindow) prune(now time.Time) {
+	i := 0
+	for i < len(w.queries) && now.Sub(w.queries[i].at) >= sessionAlertWindow {
+		i++
+	}
+	w.queries = w.queries[i:]
+}
+
+// 记录连接的一条慢查询，窗口内达到 --sessionAlertCount 条时返回统计信息
+func detectSessionPattern(entry *SlowQueryEntry) (sessionFinding, bool) {
+	at := queryStartTime(entry)
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	sessionWindows.Lock()
+	defer sessionWindows.Unlock()
+	if len(sessionWindows.byConnection) > sessionSweepSize {
+		for id, w := range sessionWindows.byConnection {
+			if w.prune(at); len(w.queries) == 0 {
+				delete(sessionWindows.byConnection, id)
+			}
+		}
+	}
+	w, ok := sessionWindows.byConnection[entry.ConnectionID]
+	if !ok {
+		w = &sessionWindow{}
+		sessionWindows.byConnection[entry.ConnectionID] = w
+	}
+	w.prune(at)
+	w.queries = append(w.queries, sessionQuery{at: at, fingerprint: entry.Fingerprint})
+	if len(w.queries) < sessionAlertCount || at.Sub(w.lastAlert) < sessionAlertWindow {
+		return sessionFinding{}, false
+	}
+	w.lastAlert = at
+
+	finding := sessionFinding{ConnectionID: entry.ConnectionID, Queries: len(w.queries), Fingerprints: make(map[string]int)}
+	for _, q := range w.queries {
+		finding.Fingerprints[q.fingerprint]++
+	}
+	return finding, true
+}
+
+// 生成会话模式告警
+func buildSessionNotification(entry *SlowQueryEntry, finding sessionFinding) *notification {
+	fingerprints := make([]string, 0, len(finding.Fingerprints))
+	for fp := range finding.Fingerprints {
+		fingerprints = append(fingerprints, fp)
+	}
+	sort.Slice(fingerprints, func(i, j int) bool {
+		a, b := finding.Fingerprints[fingerprints[i]], finding.Fingerprints[fingerprints[j]]
+		if a != b {
+			return a > b
+		}
+		return fingerprints[i] < fingerprints[j]
+	})
+	table := notificationTable{Title: "窗口内的SQL指纹", Columns: []string{"Count", "Fingerprint"}}
+	for _, fp := range fingerprints {
+		table.Rows = append(table.Rows, []string{fmt.Sprintf("%d", finding.Fingerprints[fp]), digestSQL(fp)})
+	}
+
+	return &notification{
+		Title: "会话慢查询模式",
+		Fields: []notificationField{
+			{Label: "说明", Value: fmt.Sprintf("连接 %d 在最近 %s 内产生了 %d 条慢查询", finding.ConnectionID, sessionAlertWindow, finding.Queries), Highlight: true},
+			{Label: "数据库", Value: entry.Database},
+			{Label: "用户@主机", Value: entry.User + "@" + entry.Host},
+			{Label: "告警时间", Value: formatDisplayTime(time.Now())},
+		},
+		Tables: []notificationTable{table},
+		Labels: entryLabels(entry),
+	}
+}
+
+// 发送会话模式告警，测试中可以替换为模拟实现
+var sessionNotifier = func(targets []webhookTarget, entry *SlowQueryEntry, finding sessionFinding) (int, error) {
+	return deliverNotification(targets, buildSessionNotification(entry, finding))
+}
+
+// 检查并发送会话模式告警
+func checkSessionPattern(entry *SlowQueryEntry) {
+	finding, ok := detectSessionPattern(entry)
+	if !ok {
+		return
+	}
+	logf(levelInfo, "检测到会话慢查询模式: 连接 %d 最近 %s 内产生 %d 条慢查询", finding.ConnectionID, sessionAlertWindow, finding.Queries)
+	sessionNotifier(routeTargets(entry), entry, finding)
+}
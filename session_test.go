@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDetectSessionPattern(t *testing.T) {
+	prevCount, prevWindow := sessionAlertCount, sessionAlertWindow
+	t.Cleanup(func() {
+		sessionAlertCount, sessionAlertWindow = prevCount, prevWindow
+		sessionWindows.byConnection = make(map[uint64]*sessionWindow)
+	})
+	sessionAlertCount, sessionAlertWindow = 3, time.Minute
+
+	entry, err := ParseLogLines(fixtureLines(`
+# Time: 2024-03-10T08:00:00.000000Z
+# User@Host: app[app] @ localhost []  Id:    42
+# Query_time: 0.600000  Lock_time: 0.000000 Rows_sent: 1  Rows_examined: 1
+SELECT * FROM items WHERE id = 1;`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.ConnectionID != 42 {
+		t.Fatalf("ConnectionID = %d, want 42", entry.ConnectionID)
+	}
+
+	start := entry.Time
+	var findings []sessionFinding
+	for i := 0; i < 5; i++ {
+		e := *entry
+		e.Time = start.Add(time.Duration(i) * 10 * time.Second)
+		e.Fingerprint = fmt.Sprintf("select * from items%d where id = ?", i%2)
+		if finding, ok := detectSessionPattern(&e); ok {
+			findings = append(findings, finding)
+		}
+	}
+	// 一个窗口内只告警一次
+	if len(findings) != 1 || findings[0].Queries != 3 || len(findings[0].Fingerprints) != 2 {
+		t.Fatalf("findings = %+v", findings)
+	}
+
+	n := buildSessionNotification(entry, findings[0])
+	if !strings.Contains(n.Fields[0].Value, "连接 42 在最近 1m0s 内产生了 3 条慢查询") {
+		t.Errorf("说明 = %q", n.Fields[0].Value)
+	}
+	if rows := n.Tables[0].Rows; len(rows) != 2 || rows[0][0] != "2" {
+		t.Errorf("指纹表格 = %+v", rows)
+	}
+
+	// 窗口过后的慢查询重新计数
+	e := *entry
+	e.Time = start.Add(5 * time.Minute)
+	if _, ok := detectSessionPattern(&e); ok {
+		t.Errorf("窗口过后的第一条慢查询不应告警")
+	}
+}
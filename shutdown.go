@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// 退出前需要执行的清理函数
+var shutdownHooks struct {
+	sync.Mutex
+	hooks []func()
+}
+
+// 注册退出前执行的清理函数，按注册的逆序执行
+func registerShutdownHook(hook func()) {
+	shutdownHooks.Lock()
+	defer shutdownHooks.Unlock()
+	shutdownHooks.hooks = append(shutdownHooks.hooks, hook)
+}
+
+// 执行所有清理函数
+func runShutdownHooks() {
+	shutdownHooks.Lock()
+	hooks := shutdownHooks.hooks
+	shutdownHooks.hooks = nil
+	shutdownHooks.Unlock()
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i]()
+	}
+}
+
+// 监听退出信号，执行清理后退出
+func handleShutdownSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	sig := <-signals
+	logf(levelInfo, "收到退出信号 %s，正在退出...", sig)
+	runShutdownHooks()
+	os.Exit(0)
+}
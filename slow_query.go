@@ -0,0 +1,647 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 正则表达式，用于提取慢查询日志中的信息
+var queryStartPattern = regexp.MustCompile(`^# Time: (?:\d{4}-\d{2}-\d{2}|\d{6}\s).*$`)
+var queryTimePattern = regexp.MustCompile(`# Query_time:\s*(\d+\.\d+|\d+)\s*Lock_time:\s*(\d+\.\d+|\d+)\s*Rows_sent:\s*(\d+)\s*Rows_examined:\s*(\d+)`)
+var userHostPattern = regexp.MustCompile(`# User@Host:\s*(\S+)\s*\[\S+\]\s*@\s*(\S+)`)
+var databasePattern = regexp.MustCompile(`^#.*\bSchema:\s*(\S+)`)   // 匹配数据库名，兼容 MariaDB 的 # Thread_id: N  Schema: db
+var sqlQueryEndPattern = mustSQLQueryEndPattern(defaultSQLKeywords) // 由 --sqlKeywords 与 --sqlKeywordsExtra 在启动时重新生成
+var queryStartTimePattern = regexp.MustCompile(`^# Time:\s*(\S+(?:\s+\d{1,2}:\d{2}:\d{2}\S*)?)`)
+var setTimestampPattern = regexp.MustCompile(`(?i)^SET\s+timestamp\s*=\s*(\d+)(?:\.(\d{1,9}))?\s*;$`) // MySQL 8.0 起可能带微秒
+var queryIDPattern = regexp.MustCompile(`^#.*\bQuery_id:\s*(\d+)`)
+var hostnamePattern = regexp.MustCompile(`(?i)^#\s*host:\s*(\S+)`)                                // Percona 记录的客户端主机名：# host: web-server-1.internal
+var hostnameFieldPattern = regexp.MustCompile(`^#\s*Hostname:\s*(\S+)`)                           // ProxySQL、MaxScale 记录的应用主机名：# Hostname: app-server-1
+var qcHitPattern = regexp.MustCompile(`(?i)^#.*\bQC_hit:\s*(Yes|No)\b`)                           // MySQL 5.7 / Percona 开启查询缓存时记录
+var tmpTablesPattern = regexp.MustCompile(`^#.*\bTmp_tables:\s*(\d+)\s+Tmp_disk_tables:\s*(\d+)`) // Percona 记录的临时表数量
+var recLockWaitsPattern = regexp.MustCompile(`^#.*\bInnoDB_rec_lock_waits?:\s*(\d+(?:\.\d+)?)`)   // Percona 记录的行锁等待
+var queueWaitPattern = regexp.MustCompile(`(?i)^#.*\bInnoDB_queue_wait:\s*(\d+(?:\.\d+)?)`)
+var connectionIDPattern = regexp.MustCompile(`^#.*\b(?:Thread_id|Id):\s*(\d+)`)                                     // MariaDB 的 # Thread_id: N，MySQL 记录在 # User@Host: 行的 Id: N
+var adminCommandPattern = regexp.MustCompile(`(?i)^#\s*administrator command:\s*(\w+)`)                             // 客户端断开等管理命令，例如 # administrator command: Quit;
+var filesortPattern = regexp.MustCompile(`(?i)^#.*\bFilesort:\s*(Yes|No)\b(?:.*\bFilesort_on_disk:\s*(Yes|No)\b)?`) // Percona 记录的排序方式
+var mergePassesPattern = regexp.MustCompile(`^#.*\bMerge_passes:\s*(\d+)`)                                          // Percona 记录的外部排序合并次数
+var cpuTimePattern = regexp.MustCompile(`(?i)^#.*\bcpu_time:\s*(\d+(?:\.\d+)?)`)                                    // MySQL 8.0.14+ log_slow_extra 记录的CPU时间
+var elapsedPattern = regexp.MustCompile(`(?i)^#.*\belapsed:\s*(\d+(?:\.\d+)?)`)                                     // log_slow_extra 记录的实际耗时，innodb_queue_wait 由 queueWaitPattern 解析
+var rowsAffectedPattern = regexp.MustCompile(`^#.*\bRows_affected:\s*(\d+)`)                                        // MariaDB / Percona 记录的DML影响行数
+var bytesSentPattern = regexp.MustCompile(`^#.*\bBytes_sent:\s*(\d+)`)                                              // Percona 与 log_slow_extra 记录的发送字节数
+var useDatabasePattern = regexp.MustCompile("(?i)^use\\s+`?([^`;\\s]+)`?\\s*;$")
+
+// 各行正则的名称，用于 debug 日志中输出每一行命中的规则
+var namedLinePatterns = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"queryStart", queryStartPattern},
+	{"queryTime", queryTimePattern},
+	{"userHost", userHostPattern},
+	{"database", databasePattern},
+	{"queryID", queryIDPattern},
+	{"hostname", hostnamePattern},
+	{"appHostname", hostnameFieldPattern},
+	{"auroraVersion", auroraVersionPattern},
+	{"xid", xidPattern},
+	{"connectionID", connectionIDPattern},
+	{"adminCommand", adminCommandPattern},
+	{"qcHit", qcHitPattern},
+	{"tmpTables", tmpTablesPattern},
+	{"filesort", filesortPattern},
+	{"mergePasses", mergePassesPattern},
+	{"recLockWaits", recLockWaitsPattern},
+	{"queueWait", queueWaitPattern},
+	{"cpuTime", cpuTimePattern},
+	{"elapsed", elapsedPattern},
+	{"rowsAffected", rowsAffectedPattern},
+	{"setTimestamp", setTimestampPattern},
+	{"useDatabase", useDatabasePattern},
+	{"sqlQueryEnd", sqlQueryEndPattern},
+}
+
+// 日志与命令行参数中支持的时间格式
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999",
+	"2006-01-02 15:04:05.999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"060102 15:04:05", // MySQL 5.6 / MariaDB: 240310  8:15:42
+}
+
+// 解析时间字符串，未携带时区的按本地时间处理
+func parseTimestamp(value string) (time.Time, error) {
+	for _, layout := range timestampLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法解析时间: %s", value)
+}
+
+// 提取 # Time: 行中的时间，设置了 --mysqlVersion 时只接受该版本的时间格式
+func parseQueryStartTime(line string) (time.Time, bool) {
+	matches := queryStartTimePattern.FindStringSubmatch(line)
+	if matches == nil {
+		return time.Time{}, false
+	}
+	value := whitespacePattern.ReplaceAllString(matches[1], " ")
+	if activeMySQLVersion != nil {
+		return activeMySQLVersion.parseTime(value)
+	}
+	t, err := parseTimestamp(value)
+	return t, err == nil
+}
+
+// 解析 SET timestamp= 的秒数与小数部分
+func parseSetTimestamp(seconds, fraction string) (time.Time, bool) {
+	sec, err := strconv.ParseInt(seconds, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	var nsec int64
+	if fraction != "" {
+		nsec, _ = strconv.ParseInt((fraction + "000000000")[:9], 10, 64)
+	}
+	return time.Unix(sec, nsec), true
+}
+
+// 识别SQL语句的默认关键字
+var defaultSQLKeywords = []string{"SELECT", "UPDATE", "DELETE", "INSERT", "REPLACE", "CALL", "WITH", "EXPLAIN"}
+
+var sqlKeywordPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// 根据关键字列表生成识别SQL语句结束的正则
+func buildSQLQueryEndPattern(keywords []string) (*regexp.Regexp, error) {
+	if len(keywords) == 0 {
+		return nil, fmt.Errorf("SQL关键字列表不能为空")
+	}
+	for _, keyword := range keywords {
+		if !sqlKeywordPattern.MatchString(keyword) {
+			return nil, fmt.Errorf("SQL关键字 %q 无效，只能包含字母、数字和下划线且不能以数字开头", keyword)
+		}
+	}
+	return regexp.Compile(`(?is)^(` + strings.Join(keywords, "|") + `)\s+.*;$`)
+}
+
+func mustSQLQueryEndPattern(keywords []string) *regexp.Regexp {
+	pattern, err := buildSQLQueryEndPattern(keywords)
+	if err != nil {
+		panic(err)
+	}
+	return pattern
+}
+
+// 按 --sqlKeywords 与 --sqlKeywordsExtra 重新生成 sqlQueryEndPattern
+func configureSQLKeywords() error {
+	keywords := defaultSQLKeywords
+	if len(sqlKeywords) > 0 {
+		keywords = sqlKeywords
+	}
+	keywords = append(append([]string(nil), keywords...), sqlKeywordsExtra...)
+	pattern, err := buildSQLQueryEndPattern(keywords)
+	if err != nil {
+		return err
+	}
+	sqlQueryEndPattern = pattern
+	return nil
+}
+
+// 一条慢查询日志解析后的结果
+type SlowQueryEntry struct {
+	Time               time.Time           // # Time: 行记录的时间
+	Timestamp          time.Time           // SET timestamp= 记录的执行时间
+	QueryID            int64               // MySQL 8.0 的 # Query_id:，0 表示日志中没有记录
+	ConnectionID       uint64              // 连接ID，0 表示日志中没有记录
+	QueryTime          float64             // 查询时间，单位：秒
+	LockTime           float64             // 锁定时间，单位：秒
+	RowsSent           int                 // 发送的行数
+	RowsExamined       int                 // 扫描的行数
+	RowsAffected       int                 // # Rows_affected:，DML语句影响的行数
+	BytesSent          int64               // # Bytes_sent:，发送给客户端的字节数
+	Database           string              // 数据库名
+	DatabaseInferred   bool                // 数据库名来自 --defaultDatabase，而不是日志
+	User               string              // 用户
+	Host               string              // 主机
+	TmpTables          int                 // Percona 的 # Tmp_tables:，创建的临时表数量
+	TmpDiskTables      int                 // Percona 的 Tmp_disk_tables:，写入磁盘的临时表数量
+	Filesort           bool                // Percona 的 # Filesort: Yes
+	FilesortOnDisk     bool                // Percona 的 Filesort_on_disk: Yes，排序写入了磁盘
+	MergePasses        int                 // Percona 的 Merge_passes:，外部排序的合并次数，越大说明写入磁盘的排序越大
+	InnoDBRecLockWaits float64             // Percona 的 # InnoDB_rec_lock_waits:，行锁等待
+	InnoDBQueueWait    float64             // Percona 的 InnoDB_queue_wait:，等待进入 InnoDB 的时间，单位：秒
+	CPUTime            float64             // log_slow_extra 的 # cpu_time:，单位：秒
+	Elapsed            float64             // log_slow_extra 的 # elapsed:，单位：秒
+	QCHit              bool                // # QC_Hit: Yes，查询由查询缓存返回，慢是因为缓存锁竞争
+	IsAdminCommand     bool                // 只有 # administrator command: Quit/Connect/Sleep 的条目
+	ClientHostname     string              // Percona 记录的客户端主机名，与 Host 中的地址不同
+	AuroraVersion      string              // Aurora MySQL 的 # aurora_version:，--awsAurora 时没有记录的条目沿用最近一次的版本
+	XID                uint64              // Aurora MySQL 的 # xid:，事务ID
+	AppHostname        string              // ProxySQL、MaxScale 的 # Hostname:，发起查询的应用主机，日志中没有时使用 --appHostname
+	ReplicationLag     time.Duration       // 告警前通过 pt-heartbeat 查询到的复制延迟，只在超过 --maxReplicationLag 时记录
+	SQL                string              // SQL 语句，多行时以换行连接
+	Fingerprint        string              // 归一化后的SQL指纹，用于展示
+	Hash               uint64              // 指纹哈希，用于去重
+	QueryAnnotations   map[string]string   // SQL开头注释中的 key: value 元数据，例如ORM注入的 request_id
+	ExtraFields        map[string]string   // 通过 --enrichmentURL 获取的附加信息
+	ContextLines       []string            // 该条目之前的原始日志行，由 --alertContextLines 控制
+	Occurrence         *occurrenceInfo     // 首次告警前累计的出现次数，由 --minOccurrencesBeforeAlert 控制
+	Migration          *migrationInfo      // 迁移期间的告警信息，由 --migrationMode / --migrationFlagFile 控制
+	PlanChange         *planChange         // 与基线相比变差的执行计划，由 --baselineDB 控制
+	PlanHints          []string            // 根据 EXPLAIN 自动生成的优化建议，由 --baselineDB 控制
+	PlanAnalyze        analyzeResult       // EXPLAIN ANALYZE 的实际执行结果，由 --explainAnalyze 控制
+	Instance           *instanceConfig     // 该条目所属的实例，由 --instances 控制
+	SourceLabels       []notificationField // 日志行前缀中的标签，例如日志采集器添加的 pod=mysql-0
+	Operation          string              // MongoDB 的操作类型，例如 find、update、aggregate，为空表示MySQL日志
+	Collection         string              // MongoDB 的集合名
+	PlanSummary        string              // MongoDB 的 planSummary:，例如 COLLSCAN、IXSCAN { status: 1 }
+}
+
+// 告警阈值配置
+type thresholdConfig struct {
+	QueryTime     float64 // 查询时间阈值，单位：秒
+	RowsExamined  int     // 扫描行数阈值，0 表示不按扫描行数告警
+	TmpDiskTables int     // 磁盘临时表数量阈值，0 表示不按磁盘临时表告警
+	MergePasses   int     // 排序合并次数阈值，0 表示不按合并次数告警
+	RowsAffected  int     // DML影响行数阈值，0 表示不按影响行数告警
+	LockWaits     float64 // 行锁等待阈值，0 表示不按锁等待告警
+}
+
+// 不应作为慢查询告警的管理命令
+var adminIdleCommands = map[string]bool{"quit": true, "connect": true, "sleep": true}
+
+// 缺少 # Query_time: 行时返回的错误，说明这不是一条完整的慢查询日志
+var errMissingQueryTime = errors.New("日志条目缺少 # Query_time 信息")
+
+// 判断是否为慢查询日志的元数据（注释）行
+func isMetadataLine(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "#")
+}
+
+// 提取日志条目中的SQL语句，忽略 SET timestamp= 与 use db; 指令
+func extractSQL(lines []string) string {
+	var sqlLines []string
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || isMetadataLine(trimmed) ||
+			setTimestampPattern.MatchString(trimmed) || useDatabasePattern.MatchString(trimmed) {
+			continue
+		}
+		sqlLines = append(sqlLines, trimmed)
+	}
+	return strings.Join(sqlLines, "\n")
+}
+
+// 解析SQL开头注释中的 key: value 元数据，例如 /* app: payments, request_id: abc123 */
+// 也接受 key='value' 的写法；/*! */ 与 /*+ */ 是MySQL的版本注释与优化器提示，不解析
+func parseQueryComment(sql string) map[string]string {
+	annotations, _ := splitQueryComment(sql)
+	return annotations
+}
+
+// 解析SQL开头的注释，同时返回注释之后的SQL
+func splitQueryComment(sql string) (map[string]string, string) {
+	var annotations map[string]string
+	rest := strings.TrimSpace(sql)
+	for strings.HasPrefix(rest, "/*") {
+		end := strings.Index(rest, "*/")
+		if end < 0 {
+			break
+		}
+		body := rest[2:end]
+		rest = strings.TrimSpace(rest[end+2:])
+		if strings.HasPrefix(body, "!") || strings.HasPrefix(body, "+") {
+			continue
+		}
+		for _, pair := range strings.Split(body, ",") {
+			i := strings.IndexAny(pair, ":=")
+			if i < 0 {
+				continue
+			}
+			key := strings.TrimSpace(pair[:i])
+			value := strings.Trim(strings.TrimSpace(pair[i+1:]), `'"`)
+			if key == "" || value == "" {
+				continue
+			}
+			if annotations == nil {
+				annotations = make(map[string]string)
+			}
+			annotations[key] = value
+		}
+	}
+	return annotations, rest
+}
+
+// 输出一行日志命中的正则，仅在 debug 级别下调用
+func logLineMatches(line string) {
+	var matched []string
+	for _, p := range namedLinePatterns {
+		if p.pattern.MatchString(line) {
+			matched = append(matched, p.name)
+		}
+	}
+	if len(matched) == 0 {
+		matched = append(matched, "无")
+	}
+	logf(levelDebug, "解析日志行 %q，命中规则: %s", line, strings.Join(matched, ", "))
+}
+
+// 解析一条慢查询日志
+func ParseLogLines(lines []string) (*SlowQueryEntry, error) {
+	entry := &SlowQueryEntry{}
+	hasQueryTime := false
+	debug := logEnabled(levelDebug)
+	var schema, useDatabase string
+	var adminCommand, adminLine string
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if debug {
+			logLineMatches(trimmed)
+		}
+		if t, ok := parseQueryStartTime(trimmed); ok {
+			entry.Time = t
+		}
+		if matches := queryTimePattern.FindStringSubmatch(trimmed); matches != nil {
+			entry.QueryTime, _ = strconv.ParseFloat(matches[1], 64)
+			entry.LockTime, _ = strconv.ParseFloat(matches[2], 64)
+			entry.RowsSent, _ = strconv.Atoi(matches[3])
+			entry.RowsExamined, _ = strconv.Atoi(matches[4])
+			hasQueryTime = true
+		}
+		if matches := userHostPattern.FindStringSubmatch(trimmed); matches != nil {
+			entry.User = matches[1]
+			entry.Host = matches[2]
+			if awsAurora {
+				parseAuroraUserHost(entry, trimmed)
+			}
+		}
+		if matches := auroraVersionPattern.FindStringSubmatch(trimmed); matches != nil {
+			entry.AuroraVersion = matches[1]
+		}
+		if matches := xidPattern.FindStringSubmatch(trimmed); matches != nil {
+			entry.XID, _ = strconv.ParseUint(matches[1], 10, 64)
+		}
+		if matches := databasePattern.FindStringSubmatch(trimmed); matches != nil {
+			schema = matches[1]
+		}
+		if matches := queryIDPattern.FindStringSubmatch(trimmed); matches != nil {
+			entry.QueryID, _ = strconv.ParseInt(matches[1], 10, 64)
+		}
+		if matches := adminCommandPattern.FindStringSubmatch(trimmed); matches != nil {
+			adminCommand, adminLine = matches[1], trimmed
+		}
+		if matches := connectionIDPattern.FindStringSubmatch(trimmed); matches != nil {
+			entry.ConnectionID, _ = strconv.ParseUint(matches[1], 10, 64)
+		}
+		if matches := hostnamePattern.FindStringSubmatch(trimmed); matches != nil {
+			entry.ClientHostname = matches[1]
+		}
+		if matches := hostnameFieldPattern.FindStringSubmatch(trimmed); matches != nil {
+			entry.AppHostname = matches[1]
+		}
+		if matches := tmpTablesPattern.FindStringSubmatch(trimmed); matches != nil {
+			entry.TmpTables, _ = strconv.Atoi(matches[1])
+			entry.TmpDiskTables, _ = strconv.Atoi(matches[2])
+		}
+		if matches := recLockWaitsPattern.FindStringSubmatch(trimmed); matches != nil {
+			entry.InnoDBRecLockWaits, _ = strconv.ParseFloat(matches[1], 64)
+		}
+		if matches := queueWaitPattern.FindStringSubmatch(trimmed); matches != nil {
+			entry.InnoDBQueueWait, _ = strconv.ParseFloat(matches[1], 64)
+		}
+		if matches := rowsAffectedPattern.FindStringSubmatch(trimmed); matches != nil {
+			entry.RowsAffected, _ = strconv.Atoi(matches[1])
+		}
+		if matches := bytesSentPattern.FindStringSubmatch(trimmed); matches != nil {
+			entry.BytesSent, _ = strconv.ParseInt(matches[1], 10, 64)
+		}
+		if matches := cpuTimePattern.FindStringSubmatch(trimmed); matches != nil {
+			entry.CPUTime, _ = strconv.ParseFloat(matches[1], 64)
+		}
+		if matches := elapsedPattern.FindStringSubmatch(trimmed); matches != nil {
+			entry.Elapsed, _ = strconv.ParseFloat(matches[1], 64)
+		}
+		if matches := filesortPattern.FindStringSubmatch(trimmed); matches != nil {
+			entry.Filesort = strings.EqualFold(matches[1], "Yes")
+			entry.FilesortOnDisk = strings.EqualFold(matches[2], "Yes")
+		}
+		if matches := mergePassesPattern.FindStringSubmatch(trimmed); matches != nil {
+			entry.MergePasses, _ = strconv.Atoi(matches[1])
+		}
+		if matches := qcHitPattern.FindStringSubmatch(trimmed); matches != nil {
+			entry.QCHit = strings.EqualFold(matches[1], "Yes")
+		}
+		if matches := setTimestampPattern.FindStringSubmatch(trimmed); matches != nil {
+			if t, ok := parseSetTimestamp(matches[1], matches[2]); ok {
+				entry.Timestamp = t
+			}
+		}
+		if matches := useDatabasePattern.FindStringSubmatch(trimmed); matches != nil && useDatabase == "" {
+			useDatabase = matches[1]
+		}
+	}
+
+	// 数据库名优先使用 # Schema:，其次是 use db;，都没有时使用 --defaultDatabase
+	switch {
+	case schema != "":
+		entry.Database = schema
+	case useDatabase != "":
+		entry.Database = useDatabase
+	case defaultDatabase != "":
+		entry.Database = defaultDatabase
+		entry.DatabaseInferred = true
+	}
+
+	if !hasQueryTime {
+		return nil, &ParseError{Line: firstLine(lines), Err: errMissingQueryTime}
+	}
+
+	entry.SQL = extractSQL(lines)
+	// 只有管理命令、没有SQL的条目：查询时间是连接空闲的时间，不是SQL执行的时间
+	if entry.SQL == "" && adminCommand != "" {
+		entry.SQL = adminLine
+		entry.IsAdminCommand = adminIdleCommands[strings.ToLower(adminCommand)]
+	}
+	// 注释中的 request_id 等每次请求都不同，带注释时指纹只取注释之后的SQL
+	annotations, rest := splitQueryComment(entry.SQL)
+	entry.QueryAnnotations = annotations
+	if annotations != nil {
+		entry.Fingerprint = normalizeQuery(rest)
+	} else {
+		entry.Fingerprint = normalizeQuery(entry.SQL)
+	}
+	entry.Hash = computeQueryHash(entry.Fingerprint)
+	return entry, nil
+}
+
+// 慢查询日志格式的解析器，由 --inputFormat 选择
+type LogParser interface {
+	IsEntryStart(line string, current []string) bool  // 该行是否开始了一条新的日志条目
+	IsEntryComplete(line string, lines []string) bool // 加入该行后日志条目是否已完整
+	EntryTime(line string) (time.Time, bool)          // 该行记录的条目时间，用于 --startFrom / --readFrom / --readUntil
+	Parse(lines []string) (*SlowQueryEntry, error)    // 解析一条完整的日志条目
+}
+
+// MySQL / MariaDB / Percona 的多行慢查询日志
+type MySQLLogParser struct{}
+
+func (MySQLLogParser) IsEntryStart(line string, current []string) bool {
+	return isEntryStart(line, current)
+}
+
+func (MySQLLogParser) IsEntryComplete(line string, lines []string) bool {
+	return isEntryComplete(line, lines)
+}
+
+func (MySQLLogParser) EntryTime(line string) (time.Time, bool) {
+	return parseQueryStartTime(line)
+}
+
+func (MySQLLogParser) Parse(lines []string) (*SlowQueryEntry, error) {
+	if activeMySQLVersion != nil {
+		if reason := activeMySQLVersion.mismatch(lines); reason != "" {
+			return nil, &ParseError{Line: firstLine(lines), Err: &mysqlVersionError{Version: mysqlVersion, Reason: reason}}
+		}
+	}
+	return ParseLogLines(lines)
+}
+
+// --inputFormat 可选的日志格式
+var logParsers = map[string]LogParser{
+	"mysql":   MySQLLogParser{},
+	"mongodb": MongoDBLogParser{},
+	"pmm":     PMMLogParser{},
+}
+
+// 当前使用的日志解析器，由 --inputFormat 在启动时设置
+var logParser LogParser = MySQLLogParser{}
+
+// 按 --inputFormat 选择日志解析器，未设置时为 mysql，从 --pmmSocket 读取时固定为 pmm
+func configureInputFormat() error {
+	name := strings.ToLower(strings.TrimSpace(inputFormat))
+	if name == "" {
+		name = "mysql"
+	}
+	if pmmSocket != "" {
+		name = "pmm"
+	}
+	parser, ok := logParsers[name]
+	if !ok {
+		return fmt.Errorf("--inputFormat %q 无效，可选值: mysql, mongodb, pmm", inputFormat)
+	}
+	logParser = parser
+	return nil
+}
+
+// 判断是否达到告警条件
+func (e *SlowQueryEntry) Validate(threshold thresholdConfig) bool {
+	if threshold.RowsExamined > 0 && e.RowsExamined >= threshold.RowsExamined {
+		return true
+	}
+	if threshold.TmpDiskTables > 0 && e.TmpDiskTables >= threshold.TmpDiskTables {
+		return true
+	}
+	if threshold.RowsAffected > 0 && e.RowsAffected >= threshold.RowsAffected && isDML(e.SQL) {
+		return true
+	}
+	if threshold.MergePasses > 0 && e.MergePasses >= threshold.MergePasses {
+		return true
+	}
+	if threshold.LockWaits > 0 && e.InnoDBRecLockWaits >= threshold.LockWaits {
+		return true
+	}
+	return e.QueryTime >= threshold.QueryTime
+}
+
+// 日志条目是否早于 --maxEntryAge，没有记录时间的条目不算过期
+func isStaleEntry(entry *SlowQueryEntry, now time.Time) bool {
+	at := queryStartTime(entry)
+	return !at.IsZero() && now.Sub(at) > maxEntryAge
+}
+
+// 发送慢查询告警的函数，测试中可以替换为模拟实现
+var alertNotifier = sendWebhookNotification
+
+// 解析慢查询日志并判断是否是慢查询，contextLines 为该条目之前的原始日志行
+func processSlowQuery(logLines []string, contextLines []string) {
+	processInstanceSlowQuery(nil, nil, logLines, contextLines)
+}
+
+// 处理 --instances 中某个实例的日志条目，inst 为 nil 时使用全局配置
+// labels 为日志行前缀中的标签，由 --labelPrefixPattern 解析
+func processInstanceSlowQuery(inst *instanceConfig, labels []notificationField, logLines []string, contextLines []string) {
+	entry, err := logParser.Parse(logLines)
+	if err != nil {
+		reportError(err)
+		return
+	}
+	entry.ContextLines, entry.SourceLabels = contextLines, labels
+	if entry.AppHostname == "" {
+		entry.AppHostname = defaultAppHostname(inst)
+	}
+	if awsAurora {
+		fillAuroraVersion(entry)
+	}
+	if inst != nil {
+		// 不同实例的同一指纹分别冷却、分组
+		entry.Instance, entry.Hash = inst, computeQueryHash(inst.Name+":"+entry.Fingerprint)
+	}
+	logf(levelDebug, "日志条目解析结果: %+v", *entry)
+	if entry.IsAdminCommand && !alertOnAdminCommands {
+		logf(levelDebug, "跳过管理命令: %s", entry.SQL)
+		return
+	}
+	if maxEntryAge > 0 && isStaleEntry(entry, time.Now()) {
+		logf(levelDebug, "跳过过期的日志条目: %s", queryStartTime(entry).Format(time.RFC3339))
+		return
+	}
+
+	if alertOnNewFingerprint {
+		checkNewFingerprint(entry)
+	}
+	if anomalyDetection {
+		checkAnomaly(entry)
+	}
+	if fingerprintRPM > 0 {
+		checkHighFrequency(entry)
+	}
+	if cpuTimeRatioAlert > 0 {
+		checkCPUTime(entry)
+	}
+
+	threshold := instanceThresholdFor(entry.Instance, entry.Database)
+	if entry.Migration = checkMigration(entry); entry.Migration != nil {
+		threshold = migrationThresholdFor(threshold)
+	}
+	// 开启 --assumePrefiltered 时 MySQL 已经按 log_slow_filter 筛选过，日志中的每条查询都告警
+	alerting := assumePrefiltered || entry.Validate(threshold)
+	recordSlowQueryTier(entry, alerting)
+	if trackMinQueryTime > 0 && (alerting || entry.QueryTime >= trackMinQueryTime) {
+		recordTrackedQuery(entry, alerting)
+	}
+	if !alerting {
+		logf(levelDebug, "未达到告警阈值 %+v，不发送通知", threshold)
+		if alertOnFilesort && entry.Filesort {
+			checkFilesort(entry)
+		}
+		return
+	}
+	// 命中查询缓存的慢查询来自缓存锁竞争，优化SQL本身没有帮助
+	if entry.QCHit && !alertOnQCHit {
+		logf(levelDebug, "查询命中查询缓存，不发送通知")
+		return
+	}
+
+	if digestInterval > 0 {
+		recordDigest(entry)
+		recordLockContention(entry)
+		if ptDigestOutput != "" {
+			recordPTDigest(entry)
+		}
+	}
+	if topFrequent > 0 {
+		recordFrequentQuery(entry)
+	}
+	if escalationTarget != nil {
+		noteEscalationSeen(entry.Hash, time.Now())
+	}
+	if worstQueryFile != "" {
+		checkWorstQuery(entry)
+	}
+	if sessionAlertCount > 0 && entry.ConnectionID != 0 {
+		checkSessionPattern(entry)
+	}
+
+	if alertsSuppressedByConnCount() {
+		logf(levelDebug, "MySQL连接数过高，暂停发送慢查询告警")
+		return
+	}
+	if suppressedByAck(entry.Hash, time.Now()) {
+		logf(levelDebug, "指纹 %x 的告警已被确认，不发送通知", entry.Hash)
+		return
+	}
+
+	// 首次告警前等待指纹出现足够的次数
+	if minOccurrencesBeforeAlert > 1 {
+		info, ok := noteOccurrence(entry)
+		if !ok {
+			logf(levelDebug, "指纹 %x 出现次数未达到 --minOccurrencesBeforeAlert，不发送通知", entry.Hash)
+			return
+		}
+		entry.Occurrence = info
+	}
+
+	// 分组窗口结束时再按冷却时间判断是否发送
+	if groupingWindow > 0 {
+		addToAlertGroup(entry)
+		return
+	}
+	if batchGroupBy != "" {
+		addToAlertBatch(entry)
+		return
+	}
+
+	// 同一指纹在冷却期内不重复告警
+	if shouldSuppressByCooldown(entry.Hash, time.Now()) {
+		logf(levelDebug, "指纹 %x 处于冷却期内，不发送通知", entry.Hash)
+		return
+	}
+
+	// 发送 Webhook 通知
+	prepareAlert(entry)
+	alertNotifier(routeTargets(entry), entry)
+}
+
+// 发送告警前补充附加信息、执行计划变化、EXPLAIN ANALYZE 结果与复制延迟，单条、分组与合并告警共用
+func prepareAlert(entry *SlowQueryEntry) {
+	enrichEntry(entry)
+	checkPlanChange(entry)
+	checkExplainAnalyze(entry)
+	checkReplicationLag(entry)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+// 基准数据（Intel Xeon，go test -bench=. -benchmem）：
+//
+//	BenchmarkParseLogLines                   35480 ns/op    2408 B/op    32 allocs/op
+//	BenchmarkNormalizeQuery                  57287 ns/op    5272 B/op    22 allocs/op
+//	BenchmarkComputeQueryHash                   16 ns/op       0 B/op     0 allocs/op（见 fingerprint_test.go）
+//	BenchmarkProcessSlowQuery                46114 ns/op    2408 B/op    32 allocs/op
+//
+// 修改解析或通知逻辑时，请与以上数据对比，避免明显的性能退化。
+
+// 10 行的典型慢查询日志条目
+var benchEntryLines = fixtureLines(`
+# Time: 2024-03-10T08:15:42.123456Z
+# User@Host: app[app] @ db-client.internal [10.0.0.12]  Id:    42
+# Schema: shop  Last_errno: 0  Killed: 0
+# Query_time: 1.234567  Lock_time: 0.000123 Rows_sent: 10  Rows_examined: 50000
+# Bytes_sent: 1024
+use shop;
+SET timestamp=1710058542;
+SELECT o.id, o.total, c.name
+  FROM orders o JOIN customers c ON c.id = o.customer_id
+ WHERE o.status = 'pending' AND o.total > 100;`)
+
+// 约 500 个字符的 SELECT 语句
+var benchLongSelect = "SELECT o.id, o.order_no, o.total, o.status, o.created_at, c.id, c.name, c.email, c.phone, " +
+	"a.street, a.city, a.zip FROM orders o JOIN customers c ON c.id = o.customer_id " +
+	"LEFT JOIN addresses a ON a.id = o.address_id WHERE o.status IN ('pending', 'paid', 'shipped') " +
+	"AND o.total > 100.50 AND o.created_at >= '2024-01-01 00:00:00' AND c.email LIKE '%@example.com' " +
+	"AND o.id NOT IN (1, 2, 3, 4, 5, 6, 7, 8, 9, 10) ORDER BY o.created_at DESC, o.id DESC LIMIT 100 OFFSET 200;"
+
+func BenchmarkParseLogLines(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseLogLines(benchEntryLines); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkNormalizeQuery(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		normalizeQuery(benchLongSelect)
+	}
+}
+
+func BenchmarkPatterns(b *testing.B) {
+	patterns := []struct {
+		name     string
+		pattern  *regexp.Regexp
+		match    string
+		nonMatch string
+	}{
+		{"queryStart", queryStartPattern, "# Time: 2024-03-10T08:15:42.123456Z", "# Query_time: 1.0  Lock_time: 0.0 Rows_sent: 1  Rows_examined: 1"},
+		{"queryTime", queryTimePattern, "# Query_time: 1.234567  Lock_time: 0.000123 Rows_sent: 10  Rows_examined: 50000", "# Time: 2024-03-10T08:15:42.123456Z"},
+		{"userHost", userHostPattern, "# User@Host: app[app] @ db-client.internal [10.0.0.12]  Id:    42", "# Schema: shop"},
+		{"database", databasePattern, "# Schema: shop  Last_errno: 0  Killed: 0", "# User@Host: app[app] @ localhost []"},
+		{"sqlQueryEnd", sqlQueryEndPattern, "SELECT * FROM orders WHERE id = 1;", "SET timestamp=1710058542;"},
+	}
+
+	for _, p := range patterns {
+		b.Run(p.name+"/match", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				p.pattern.MatchString(p.match)
+			}
+		})
+		b.Run(p.name+"/nonMatch", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				p.pattern.MatchString(p.nonMatch)
+			}
+		})
+	}
+}
+
+func BenchmarkProcessSlowQuery(b *testing.B) {
+	prevNotifier, prevThreshold := alertNotifier, slowQueryThreshold
+	defer func() { alertNotifier, slowQueryThreshold = prevNotifier, prevThreshold }()
+
+	sent := 0
+	alertNotifier = func(targets []webhookTarget, entry *SlowQueryEntry) (int, error) {
+		sent++
+		return 1, nil
+	}
+	slowQueryThreshold = 0.5
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		processSlowQuery(benchEntryLines, nil)
+	}
+	b.StopTimer()
+	if sent != b.N {
+		b.Fatalf("期望发送 %d 次通知，实际 %d 次", b.N, sent)
+	}
+}
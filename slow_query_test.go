@@ -0,0 +1,739 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 把多行文本拆分为日志行，便于书写测试数据
+func fixtureLines(text string) []string {
+	return strings.Split(strings.TrimSpace(text), "\n")
+}
+
+type alertCase struct {
+	threshold thresholdConfig
+	want      bool
+}
+
+func TestParseLogLines(t *testing.T) {
+	cases := []struct {
+		name   string
+		log    string
+		want   SlowQueryEntry
+		alerts []alertCase
+	}{
+		{
+			name: "MySQL 8.0 标准格式",
+			log: `
+# Time: 2024-03-10T08:15:42.123456Z
+# User@Host: app[app] @  [10.0.0.12]  Id:    42
+# Query_time: 1.234567  Lock_time: 0.000123 Rows_sent: 10  Rows_examined: 50000
+use shop;
+SET timestamp=1710058542;
+SELECT * FROM orders WHERE status = 'pending';`,
+			want: SlowQueryEntry{
+				Time:         time.Date(2024, 3, 10, 8, 15, 42, 123456000, time.UTC),
+				Timestamp:    time.Unix(1710058542, 0),
+				QueryTime:    1.234567,
+				LockTime:     0.000123,
+				RowsSent:     10,
+				RowsExamined: 50000,
+				Database:     "shop",
+				User:         "app",
+				Host:         "[10.0.0.12]",
+				SQL:          "SELECT * FROM orders WHERE status = 'pending';",
+			},
+			alerts: []alertCase{
+				{thresholdConfig{QueryTime: 0.5}, true},
+				{thresholdConfig{QueryTime: 2}, false},
+				{thresholdConfig{QueryTime: 2, RowsExamined: 10000}, true},
+			},
+		},
+		{
+			name: "Percona 扩展格式",
+			log: `
+# Time: 2024-03-10T16:15:42.000001+08:00
+# User@Host: report[report] @ db-client.internal [10.0.0.5]  Id: 7
+# Schema: analytics  Last_errno: 0  Killed: 0
+# Query_time: 12.500000  Lock_time: 0.000200  Rows_sent: 1  Rows_examined: 8000000  Rows_affected: 0
+# Bytes_sent: 56  Tmp_tables: 1  Tmp_disk_tables: 1  Tmp_table_sizes: 4096
+# QC_Hit: No  Full_scan: Yes  Full_join: No  Tmp_table: Yes  Tmp_table_on_disk: Yes
+# Filesort: Yes  Filesort_on_disk: No  Merge_passes: 0
+SET timestamp=1710058542;
+SELECT day, COUNT(*) FROM events GROUP BY day;`,
+			want: SlowQueryEntry{
+				Time:         time.Date(2024, 3, 10, 8, 15, 42, 1000, time.UTC),
+				Timestamp:    time.Unix(1710058542, 0),
+				QueryTime:    12.5,
+				LockTime:     0.0002,
+				RowsSent:     1,
+				RowsExamined: 8000000,
+				Database:     "analytics",
+				User:         "report",
+				Host:         "db-client.internal",
+				SQL:          "SELECT day, COUNT(*) FROM events GROUP BY day;",
+			},
+			alerts: []alertCase{
+				{thresholdConfig{QueryTime: 30}, false},
+				{thresholdConfig{QueryTime: 30, RowsExamined: 5000000}, true},
+			},
+		},
+		{
+			name: "MariaDB 格式",
+			log: `
+# Time: 240310  8:15:42
+# User@Host: web[web] @ localhost []
+# Thread_id: 31  Schema: blog  QC_hit: No
+# Query_time: 0.800000  Lock_time: 0.000050  Rows_sent: 20  Rows_examined: 20000
+# Rows_affected: 0  Bytes_sent: 1024
+SET timestamp=1710058542;
+SELECT * FROM posts ORDER BY created_at DESC LIMIT 20;`,
+			want: SlowQueryEntry{
+				Time:         time.Date(2024, 3, 10, 8, 15, 42, 0, time.Local),
+				Timestamp:    time.Unix(1710058542, 0),
+				QueryTime:    0.8,
+				LockTime:     0.00005,
+				RowsSent:     20,
+				RowsExamined: 20000,
+				Database:     "blog",
+				User:         "web",
+				Host:         "localhost",
+				SQL:          "SELECT * FROM posts ORDER BY created_at DESC LIMIT 20;",
+			},
+			alerts: []alertCase{
+				{thresholdConfig{QueryTime: 0.5}, true},
+				{thresholdConfig{QueryTime: 1}, false},
+			},
+		},
+		{
+			name: "缺少 # Time: 行",
+			log: `
+# User@Host: app[app] @ localhost []  Id:    43
+# Query_time: 0.600000  Lock_time: 0.000000 Rows_sent: 0  Rows_examined: 1
+SET timestamp=1710058543;
+DELETE FROM sessions WHERE expired_at < NOW();`,
+			want: SlowQueryEntry{
+				Timestamp:    time.Unix(1710058543, 0),
+				QueryTime:    0.6,
+				RowsExamined: 1,
+				User:         "app",
+				Host:         "localhost",
+				SQL:          "DELETE FROM sessions WHERE expired_at < NOW();",
+			},
+			alerts: []alertCase{
+				{thresholdConfig{QueryTime: 0.5}, true},
+				{thresholdConfig{QueryTime: 0.6}, true},
+				{thresholdConfig{QueryTime: 0.7}, false},
+			},
+		},
+		{
+			name: "多行SQL",
+			log: `
+# Time: 2024-03-10T08:15:42.000000Z
+# User@Host: app[app] @ localhost []  Id:    44
+# Query_time: 3.000000  Lock_time: 0.001000 Rows_sent: 5  Rows_examined: 900
+SET timestamp=1710058542;
+SELECT o.id, c.name
+  FROM orders o
+  JOIN customers c ON c.id = o.customer_id
+ WHERE o.total > 100;`,
+			want: SlowQueryEntry{
+				Time:         time.Date(2024, 3, 10, 8, 15, 42, 0, time.UTC),
+				Timestamp:    time.Unix(1710058542, 0),
+				QueryTime:    3,
+				LockTime:     0.001,
+				RowsSent:     5,
+				RowsExamined: 900,
+				User:         "app",
+				Host:         "localhost",
+				SQL:          "SELECT o.id, c.name\nFROM orders o\nJOIN customers c ON c.id = o.customer_id\nWHERE o.total > 100;",
+			},
+			alerts: []alertCase{{thresholdConfig{QueryTime: 0.5}, true}},
+		},
+		{
+			name: "字符串中包含分号",
+			log: `
+# Time: 2024-03-10T08:15:42.000000Z
+# User@Host: app[app] @ localhost []  Id:    45
+# Query_time: 0.900000  Lock_time: 0.000000 Rows_sent: 0  Rows_examined: 10
+SET timestamp=1710058542;
+INSERT INTO notes (body) VALUES ('first; second');`,
+			want: SlowQueryEntry{
+				Time:         time.Date(2024, 3, 10, 8, 15, 42, 0, time.UTC),
+				Timestamp:    time.Unix(1710058542, 0),
+				QueryTime:    0.9,
+				RowsExamined: 10,
+				User:         "app",
+				Host:         "localhost",
+				SQL:          "INSERT INTO notes (body) VALUES ('first; second');",
+			},
+			alerts: []alertCase{{thresholdConfig{QueryTime: 1}, false}},
+		},
+		{
+			name: "SET 语句",
+			log: `
+# Time: 2024-03-10T08:15:42.000000Z
+# User@Host: app[app] @ localhost []  Id:    46
+# Query_time: 0.700000  Lock_time: 0.000000 Rows_sent: 0  Rows_examined: 0
+SET timestamp=1710058542;
+SET NAMES utf8mb4;`,
+			want: SlowQueryEntry{
+				Time:      time.Date(2024, 3, 10, 8, 15, 42, 0, time.UTC),
+				Timestamp: time.Unix(1710058542, 0),
+				QueryTime: 0.7,
+				User:      "app",
+				Host:      "localhost",
+				SQL:       "SET NAMES utf8mb4;",
+			},
+			alerts: []alertCase{{thresholdConfig{QueryTime: 0.5}, true}},
+		},
+		{
+			name: "USE 语句",
+			log: `
+# Time: 2024-03-10T08:15:42.000000Z
+# User@Host: app[app] @ localhost []  Id:    47
+# Query_time: 0.550000  Lock_time: 0.000000 Rows_sent: 0  Rows_examined: 0
+use inventory;
+SET timestamp=1710058542;`,
+			want: SlowQueryEntry{
+				Time:      time.Date(2024, 3, 10, 8, 15, 42, 0, time.UTC),
+				Timestamp: time.Unix(1710058542, 0),
+				QueryTime: 0.55,
+				Database:  "inventory",
+				User:      "app",
+				Host:      "localhost",
+			},
+			alerts: []alertCase{{thresholdConfig{QueryTime: 0.5}, true}},
+		},
+		{
+			name: "只有元数据没有SQL",
+			log: `
+# Time: 2024-03-10T08:15:42.000000Z
+# User@Host: app[app] @ localhost []  Id:    48
+# Schema: shop
+# Query_time: 0.100000  Lock_time: 0.000000 Rows_sent: 0  Rows_examined: 0`,
+			want: SlowQueryEntry{
+				Time:      time.Date(2024, 3, 10, 8, 15, 42, 0, time.UTC),
+				QueryTime: 0.1,
+				Database:  "shop",
+				User:      "app",
+				Host:      "localhost",
+			},
+			alerts: []alertCase{{thresholdConfig{QueryTime: 0.5}, false}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := ParseLogLines(fixtureLines(c.log))
+			if err != nil {
+				t.Fatalf("ParseLogLines 返回错误: %v", err)
+			}
+			assertEntry(t, got, &c.want)
+
+			for _, alert := range c.alerts {
+				if decision := got.Validate(alert.threshold); decision != alert.want {
+					t.Errorf("Validate(%+v) = %v, want %v", alert.threshold, decision, alert.want)
+				}
+			}
+		})
+	}
+}
+
+// 逐个字段比较解析结果
+func assertEntry(t *testing.T, got, want *SlowQueryEntry) {
+	t.Helper()
+	if !got.Time.Equal(want.Time) {
+		t.Errorf("Time = %v, want %v", got.Time, want.Time)
+	}
+	if !got.Timestamp.Equal(want.Timestamp) {
+		t.Errorf("Timestamp = %v, want %v", got.Timestamp, want.Timestamp)
+	}
+	if got.QueryTime != want.QueryTime {
+		t.Errorf("QueryTime = %v, want %v", got.QueryTime, want.QueryTime)
+	}
+	if got.LockTime != want.LockTime {
+		t.Errorf("LockTime = %v, want %v", got.LockTime, want.LockTime)
+	}
+	if got.RowsSent != want.RowsSent {
+		t.Errorf("RowsSent = %d, want %d", got.RowsSent, want.RowsSent)
+	}
+	if got.RowsExamined != want.RowsExamined {
+		t.Errorf("RowsExamined = %d, want %d", got.RowsExamined, want.RowsExamined)
+	}
+	if got.Database != want.Database {
+		t.Errorf("Database = %q, want %q", got.Database, want.Database)
+	}
+	if got.User != want.User {
+		t.Errorf("User = %q, want %q", got.User, want.User)
+	}
+	if got.Host != want.Host {
+		t.Errorf("Host = %q, want %q", got.Host, want.Host)
+	}
+	if got.SQL != want.SQL {
+		t.Errorf("SQL = %q, want %q", got.SQL, want.SQL)
+	}
+	if got.Fingerprint != normalizeQuery(want.SQL) {
+		t.Errorf("Fingerprint = %q, want %q", got.Fingerprint, normalizeQuery(want.SQL))
+	}
+	if got.Hash != computeQueryHash(got.Fingerprint) {
+		t.Errorf("Hash 与指纹不一致")
+	}
+}
+
+func TestParseLogLinesMissingQueryTime(t *testing.T) {
+	lines := fixtureLines(`
+# Time: 2024-03-10T08:15:42.000000Z
+# User@Host: app[app] @ localhost []  Id:    49
+SELECT 1;`)
+	if _, err := ParseLogLines(lines); !errors.Is(err, errMissingQueryTime) {
+		t.Fatalf("期望 errMissingQueryTime，实际为 %v", err)
+	}
+}
+
+func TestParseLogLinesPerconaHostname(t *testing.T) {
+	lines := fixtureLines(`
+# Time: 2024-03-10T08:15:42.000000Z
+# User@Host: app[app] @  [10.0.0.12]  Id:    49
+# host: web-server-1.internal
+# Query_time: 1.500000  Lock_time: 0.000100 Rows_sent: 1  Rows_examined: 100
+SELECT 1;`)
+	entry, err := ParseLogLines(lines)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if entry.Host != "[10.0.0.12]" {
+		t.Errorf("Host = %q, want %q", entry.Host, "[10.0.0.12]")
+	}
+	if entry.ClientHostname != "web-server-1.internal" {
+		t.Errorf("ClientHostname = %q, want %q", entry.ClientHostname, "web-server-1.internal")
+	}
+
+	fields := buildSlowQueryNotification(entry).Fields
+	if fields[5].Label != "客户端主机名" || fields[5].Value != "web-server-1.internal" {
+		t.Errorf("主机之后的字段 = %+v，期望客户端主机名", fields[5])
+	}
+}
+
+func TestParseLogLinesProxyHostname(t *testing.T) {
+	entry, err := ParseLogLines(fixtureLines(`
+# Time: 2024-03-10T08:15:42.000000Z
+# User@Host: app[app] @  [10.0.0.30]  Id:    49
+# Hostname: app-server-1
+# Query_time: 1.500000  Lock_time: 0.000100 Rows_sent: 1  Rows_examined: 100
+SELECT 1;`))
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if entry.AppHostname != "app-server-1" || entry.ClientHostname != "" {
+		t.Errorf("AppHostname = %q, ClientHostname = %q", entry.AppHostname, entry.ClientHostname)
+	}
+	field, ok := slowQueryField("appHostname", entry)
+	if !ok || field.Label != "应用主机 (Application Host)" || field.Value != "app-server-1" {
+		t.Errorf("appHostname 字段 = %+v, %v", field, ok)
+	}
+
+	var alerted []*SlowQueryEntry
+	prevNotifier, prevAppHostname := alertNotifier, appHostname
+	t.Cleanup(func() { alertNotifier, appHostname = prevNotifier, prevAppHostname })
+	alertNotifier = func(targets []webhookTarget, entry *SlowQueryEntry) (int, error) {
+		alerted = append(alerted, entry)
+		return 1, nil
+	}
+	appHostname = "billing-api"
+	processSlowQuery(fixtureLines(`
+# User@Host: app[app] @  [10.0.0.30]  Id:    49
+# Query_time: 1.500000  Lock_time: 0.000100 Rows_sent: 1  Rows_examined: 100
+SELECT 1;`), nil)
+	if len(alerted) != 1 || alerted[0].AppHostname != "billing-api" {
+		t.Errorf("日志中没有 # Hostname: 时应使用 --appHostname: %+v", alerted)
+	}
+}
+
+func TestParseLogLinesDatabasePriority(t *testing.T) {
+	old := defaultDatabase
+	defer func() { defaultDatabase = old }()
+	defaultDatabase = "fallback"
+
+	header := `
+# User@Host: app[app] @ localhost []  Id:    49
+# Query_time: 1.500000  Lock_time: 0.000100 Rows_sent: 1  Rows_examined: 100
+`
+	tests := []struct {
+		name     string
+		log      string
+		want     string
+		inferred bool
+	}{
+		{"Schema 优先于 use", "# Schema: shop" + header + "use analytics;\nSELECT 1;", "shop", false},
+		{"use", header + "use analytics;\nSELECT 1;", "analytics", false},
+		{"defaultDatabase", header + "SELECT 1;", "fallback", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := ParseLogLines(fixtureLines(tt.log))
+			if err != nil {
+				t.Fatalf("解析失败: %v", err)
+			}
+			if entry.Database != tt.want || entry.DatabaseInferred != tt.inferred {
+				t.Errorf("Database = %q, DatabaseInferred = %v, want %q, %v", entry.Database, entry.DatabaseInferred, tt.want, tt.inferred)
+			}
+			if notes := buildSlowQueryNotification(entry).Notes; (len(notes) > 0) != tt.inferred {
+				t.Errorf("脚注 = %q", notes)
+			}
+		})
+	}
+}
+
+func TestProcessSlowQueryQCHit(t *testing.T) {
+	var alerted []*SlowQueryEntry
+	prevNotifier, prevThreshold, prevAlertOnQCHit := alertNotifier, slowQueryThreshold, alertOnQCHit
+	t.Cleanup(func() {
+		alertNotifier, slowQueryThreshold, alertOnQCHit = prevNotifier, prevThreshold, prevAlertOnQCHit
+	})
+	alertNotifier = func(targets []webhookTarget, entry *SlowQueryEntry) (int, error) {
+		alerted = append(alerted, entry)
+		return 1, nil
+	}
+	slowQueryThreshold = 0.5
+
+	lines := fixtureLines(`
+# User@Host: app[app] @ localhost []  Id:    49
+# QC_Hit: Yes  Full_scan: No  Full_join: No  Tmp_table: No  Tmp_table_on_disk: No
+# Query_time: 2.000000  Lock_time: 1.900000 Rows_sent: 1  Rows_examined: 0
+SELECT * FROM qc_hit_test;`)
+	processSlowQuery(lines, nil)
+	if len(alerted) != 0 {
+		t.Fatalf("命中查询缓存的慢查询默认不应告警")
+	}
+
+	alertOnQCHit = true
+	processSlowQuery(lines, nil)
+	if len(alerted) != 1 || !alerted[0].QCHit {
+		t.Fatalf("--alertOnQCHit 时应告警，实际 %d 条", len(alerted))
+	}
+	if title := buildSlowQueryNotification(alerted[0]).Title; title != "慢查询警告 [QC Hit]" {
+		t.Errorf("Title = %q", title)
+	}
+}
+
+func TestConfigureSQLKeywords(t *testing.T) {
+	prevPattern, prevKeywords, prevExtra := sqlQueryEndPattern, sqlKeywords, sqlKeywordsExtra
+	t.Cleanup(func() {
+		sqlQueryEndPattern, sqlKeywords, sqlKeywordsExtra = prevPattern, prevKeywords, prevExtra
+	})
+
+	if !sqlQueryEndPattern.MatchString("CALL refresh_stats();") {
+		t.Errorf("默认关键字应包含 CALL")
+	}
+
+	sqlKeywordsExtra = []string{"LOAD", "TRUNCATE"}
+	if err := configureSQLKeywords(); err != nil {
+		t.Fatal(err)
+	}
+	for _, sql := range []string{"SELECT 1;", "LOAD DATA INFILE '/tmp/a.csv' INTO TABLE t;", "truncate table t;"} {
+		if !sqlQueryEndPattern.MatchString(sql) {
+			t.Errorf("--sqlKeywordsExtra 后应识别 %q", sql)
+		}
+	}
+
+	sqlKeywords, sqlKeywordsExtra = []string{"SELECT"}, nil
+	if err := configureSQLKeywords(); err != nil {
+		t.Fatal(err)
+	}
+	if sqlQueryEndPattern.MatchString("DELETE FROM t;") {
+		t.Errorf("--sqlKeywords 应替换默认关键字")
+	}
+
+	sqlKeywords = []string{"SELECT", "LOAD DATA"}
+	if err := configureSQLKeywords(); err == nil {
+		t.Errorf("期望无效关键字报错")
+	}
+}
+
+func TestParseLogLinesTmpTables(t *testing.T) {
+	lines := fixtureLines(`
+# User@Host: app[app] @ localhost []  Id:    49
+# Query_time: 0.200000  Lock_time: 0.000100 Rows_sent: 10  Rows_examined: 50000
+# Tmp_tables: 2  Tmp_disk_tables: 1  Tmp_table_sizes: 4194304
+# QC_Hit: No  Full_scan: Yes  Full_join: No  Tmp_table: Yes  Tmp_table_on_disk: Yes
+SELECT day, COUNT(*) FROM events GROUP BY day;`)
+	entry, err := ParseLogLines(lines)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if entry.TmpTables != 2 || entry.TmpDiskTables != 1 {
+		t.Errorf("TmpTables = %d, TmpDiskTables = %d, want 2, 1", entry.TmpTables, entry.TmpDiskTables)
+	}
+	if entry.Validate(thresholdConfig{QueryTime: 1}) {
+		t.Errorf("未设置磁盘临时表阈值时不应告警")
+	}
+	if !entry.Validate(thresholdConfig{QueryTime: 1, TmpDiskTables: 1}) {
+		t.Errorf("磁盘临时表达到阈值时应告警")
+	}
+
+	var found bool
+	for _, f := range buildSlowQueryNotification(entry).Fields {
+		if f.Label == "Tmp Tables" {
+			found = true
+			if f.Value != "⚠️ 2 (1 on disk)" {
+				t.Errorf("Tmp Tables = %q", f.Value)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("通知中缺少 Tmp Tables 字段")
+	}
+}
+
+func TestProcessSlowQueryAdminCommand(t *testing.T) {
+	var alerted []*SlowQueryEntry
+	prevNotifier, prevThreshold, prevAlertOnAdmin := alertNotifier, slowQueryThreshold, alertOnAdminCommands
+	t.Cleanup(func() {
+		alertNotifier, slowQueryThreshold, alertOnAdminCommands = prevNotifier, prevThreshold, prevAlertOnAdmin
+	})
+	alertNotifier = func(targets []webhookTarget, entry *SlowQueryEntry) (int, error) {
+		alerted = append(alerted, entry)
+		return 1, nil
+	}
+	slowQueryThreshold = 0.5
+
+	// 同一秒内的下一条日志省略了 # Time:，管理命令行应结束当前条目
+	lines := fixtureLines(`
+# Time: 2024-03-10T08:00:00.000000Z
+# User@Host: app[app] @ localhost []  Id:    42
+# Query_time: 3600.000000  Lock_time: 0.000000 Rows_sent: 0  Rows_examined: 0
+SET timestamp=1710057600;
+# administrator command: Quit;
+# User@Host: app[app] @ localhost []  Id:    43
+# Query_time: 2.000000  Lock_time: 0.000000 Rows_sent: 1  Rows_examined: 1
+SELECT * FROM admin_command_test;`)
+	entries := [][]string{lines[:5], lines[5:]}
+	if !isEntryComplete(lines[4], lines[:5]) {
+		t.Fatalf("管理命令行应结束当前日志条目")
+	}
+
+	entry, err := ParseLogLines(entries[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !entry.IsAdminCommand || entry.SQL != "# administrator command: Quit;" {
+		t.Errorf("IsAdminCommand = %v, SQL = %q", entry.IsAdminCommand, entry.SQL)
+	}
+
+	for _, e := range entries {
+		processSlowQuery(e, nil)
+	}
+	if len(alerted) != 1 || alerted[0].SQL != "SELECT * FROM admin_command_test;" {
+		t.Fatalf("管理命令默认不应告警，实际告警 %d 条", len(alerted))
+	}
+
+	alertOnAdminCommands = true
+	processSlowQuery(entries[0], nil)
+	if len(alerted) != 2 {
+		t.Errorf("--alertOnAdminCommands 时应告警")
+	}
+}
+
+// 按 tailSlowLog 的规则把日志文件拆分为日志条目
+func fixtureEntries(t *testing.T, path string) [][]string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("读取测试数据失败: %v", err)
+	}
+	var entries [][]string
+	var current []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			continue
+		}
+		if isEntryStart(line, current) {
+			if len(current) > 0 {
+				entries = append(entries, current)
+			}
+			current = []string{line}
+		} else {
+			current = append(current, line)
+		}
+		if isEntryComplete(line, current) {
+			entries = append(entries, current)
+			current = nil
+		}
+	}
+	if len(current) > 0 {
+		entries = append(entries, current)
+	}
+	return entries
+}
+
+func TestParseMySQL8032SlowLog(t *testing.T) {
+	entries := fixtureEntries(t, "testdata/mysql-8.0.32-slow.log")
+
+	// 文件开头的启动信息不是慢查询日志
+	if _, err := ParseLogLines(entries[0]); !errors.Is(err, errMissingQueryTime) {
+		t.Fatalf("文件头应返回 errMissingQueryTime，实际为 %v", err)
+	}
+
+	want := []struct {
+		queryID int64
+		entry   SlowQueryEntry
+	}{
+		{
+			queryID: 12345,
+			entry: SlowQueryEntry{
+				Time:         time.Date(2024, 3, 10, 8, 15, 42, 123456000, time.UTC),
+				Timestamp:    time.Unix(1710058540, 888889000),
+				QueryTime:    1.234567,
+				LockTime:     0.000123,
+				RowsSent:     10,
+				RowsExamined: 50000,
+				Database:     "shop",
+				User:         "app",
+				Host:         "[10.0.0.12]",
+				SQL:          "SELECT * FROM orders WHERE status = 'pending';",
+			},
+		},
+		{
+			queryID: 12399,
+			entry: SlowQueryEntry{
+				Time:         time.Date(2024, 3, 10, 8, 20, 5, 0, time.Local),
+				Timestamp:    time.Unix(1710058800, 500000000),
+				QueryTime:    4.5,
+				LockTime:     0.00001,
+				RowsSent:     1,
+				RowsExamined: 2000000,
+				Database:     "analytics",
+				User:         "report",
+				Host:         "db-client.internal",
+				SQL:          "SELECT day, COUNT(*)\nFROM events\nGROUP BY day;",
+			},
+		},
+	}
+
+	entries = entries[1:]
+	if len(entries) != len(want) {
+		t.Fatalf("期望 %d 条日志条目，实际为 %d 条", len(want), len(entries))
+	}
+	for i, w := range want {
+		got, err := ParseLogLines(entries[i])
+		if err != nil {
+			t.Fatalf("第 %d 条日志解析失败: %v", i+1, err)
+		}
+		assertEntry(t, got, &w.entry)
+		if got.QueryID != w.queryID {
+			t.Errorf("第 %d 条日志 QueryID = %d, want %d", i+1, got.QueryID, w.queryID)
+		}
+	}
+}
+
+func TestParseQueryComment(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want map[string]string
+	}{
+		{"/* app: payments, request_id: abc123, version: 1.2.3 */ SELECT 1", map[string]string{"app": "payments", "request_id": "abc123", "version": "1.2.3"}},
+		{"/*controller='users',action='show'*/ SELECT 1", map[string]string{"controller": "users", "action": "show"}},
+		{"/*+ MAX_EXECUTION_TIME(1000) */ /* app: web */ SELECT 1", map[string]string{"app": "web"}},
+		{"/*!40001 SQL_NO_CACHE */ SELECT 1", nil},
+		{"SELECT 1 /* app: web */", nil},
+		{"/* 普通注释 */ SELECT 1", nil},
+	}
+	for _, tt := range tests {
+		if got := parseQueryComment(tt.sql); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseQueryComment(%q) = %v, want %v", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestParseLogLinesQueryAnnotations(t *testing.T) {
+	parse := func(requestID string) *SlowQueryEntry {
+		entry, err := ParseLogLines(fixtureLines(`
+# User@Host: app[app] @ localhost []  Id:    49
+# Query_time: 2.000000  Lock_time: 0.000100 Rows_sent: 1  Rows_examined: 50000
+/* app: payments, request_id: ` + requestID + ` */ SELECT * FROM orders WHERE id = 1;`))
+		if err != nil {
+			t.Fatalf("解析失败: %v", err)
+		}
+		return entry
+	}
+	a, b := parse("abc123"), parse("def456")
+	if a.QueryAnnotations["app"] != "payments" || a.QueryAnnotations["request_id"] != "abc123" {
+		t.Errorf("QueryAnnotations = %v", a.QueryAnnotations)
+	}
+	if a.Hash != b.Hash {
+		t.Errorf("注释不同的同一查询应有相同的指纹: %q != %q", a.Fingerprint, b.Fingerprint)
+	}
+
+	fields := make(map[string]string)
+	for _, f := range buildSlowQueryNotification(a).Fields {
+		fields[f.Label] = f.Value
+	}
+	if fields["app"] != "payments" || fields["request_id"] != "abc123" {
+		t.Errorf("通知中缺少注释字段: %v", fields)
+	}
+}
+
+func TestParseLogLinesMergePasses(t *testing.T) {
+	prevMerge, prevThreshold := mergePassesThreshold, slowQueryThreshold
+	t.Cleanup(func() { mergePassesThreshold, slowQueryThreshold = prevMerge, prevThreshold })
+	mergePassesThreshold, slowQueryThreshold = 10, 1
+
+	entry, err := ParseLogLines(fixtureLines(`
+# User@Host: app[app] @ localhost []  Id:    49
+# Query_time: 0.300000  Lock_time: 0.000100 Rows_sent: 10  Rows_examined: 500000
+# Filesort: Yes  Filesort_on_disk: Yes  Merge_passes: 12
+SELECT * FROM events ORDER BY payload;`))
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if entry.MergePasses != 12 {
+		t.Errorf("MergePasses = %d, want 12", entry.MergePasses)
+	}
+	if entry.Validate(thresholdConfig{QueryTime: 1}) {
+		t.Errorf("未设置合并次数阈值时不应告警")
+	}
+	if !entry.Validate(thresholdFor(entry.Database)) {
+		t.Errorf("合并次数达到 --mergePassesThreshold 时应告警")
+	}
+
+	f, ok := slowQueryField("mergePasses", entry)
+	if !ok || f.Value != "12" || !f.Highlight {
+		t.Errorf("Merge Passes 字段 = %+v, %v", f, ok)
+	}
+	entry.MergePasses = 0
+	if _, ok := slowQueryField("mergePasses", entry); ok {
+		t.Errorf("合并次数为 0 时不应显示")
+	}
+}
+
+func TestProcessSlowQueryMaxEntryAge(t *testing.T) {
+	var alerted int
+	prevNotifier, prevAge := alertNotifier, maxEntryAge
+	t.Cleanup(func() { alertNotifier, maxEntryAge = prevNotifier, prevAge })
+	alertNotifier = func(targets []webhookTarget, entry *SlowQueryEntry) (int, error) {
+		alerted++
+		return 1, nil
+	}
+	maxEntryAge = time.Hour
+
+	entry := func(at time.Time) []string {
+		return fixtureLines(`
+# Time: ` + at.UTC().Format("2006-01-02T15:04:05.000000Z") + `
+# User@Host: app[app] @ localhost []  Id:    42
+# Query_time: 2.000000  Lock_time: 0.000000 Rows_sent: 1  Rows_examined: 1
+SELECT * FROM orders WHERE id = 1;`)
+	}
+	processSlowQuery(entry(time.Now().Add(-48*time.Hour)), nil)
+	if alerted != 0 {
+		t.Fatalf("超过 --maxEntryAge 的日志条目不应告警")
+	}
+	processSlowQuery(entry(time.Now().Add(-time.Minute)), nil)
+	if alerted != 1 {
+		t.Fatalf("--maxEntryAge 内的日志条目应告警，实际 %d 条", alerted)
+	}
+}
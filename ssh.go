@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// 远程读取时单行日志的最大长度
+const maxRemoteLineBytes = 16 * 1024 * 1024
+
+// 返回 --sshHost 对应的地址，未指定端口时使用 22
+func sshAddress() string {
+	if _, _, err := net.SplitHostPort(sshHost); err == nil {
+		return sshHost
+	}
+	return net.JoinHostPort(sshHost, "22")
+}
+
+// 返回默认的 known_hosts 文件路径
+func defaultKnownHostsFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".ssh", "known_hosts")
+}
+
+// 连接 --sshAgentSocket 指定的 SSH agent
+func dialSSHAgent() (agent.ExtendedAgent, error) {
+	conn, err := net.Dial("unix", sshAgentSocket)
+	if err != nil {
+		return nil, fmt.Errorf("无法连接SSH agent %s: %w", sshAgentSocket, err)
+	}
+	return agent.NewClient(conn), nil
+}
+
+// 根据命令行参数构建SSH客户端配置，agentClient 为 nil 表示不使用 SSH agent
+func buildSSHClientConfig(agentClient agent.ExtendedAgent) (*ssh.ClientConfig, error) {
+	var auths []ssh.AuthMethod
+	if sshKeyFile != "" {
+		key, err := os.ReadFile(sshKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("无法读取SSH私钥 %s: %w", sshKeyFile, err)
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("SSH私钥 %s 无效: %w", sshKeyFile, err)
+		}
+		auths = append(auths, ssh.PublicKeys(signer))
+	}
+	if agentClient != nil {
+		auths = append(auths, ssh.PublicKeysCallback(agentClient.Signers))
+	}
+	if len(auths) == 0 {
+		return nil, errors.New("使用 --sshHost 时必须指定 --sshKeyFile 或 --sshAgentSocket")
+	}
+
+	hostKeyCallback, err := knownhosts.New(sshKnownHosts)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取 known_hosts 文件 %s: %w", sshKnownHosts, err)
+	}
+
+	username := sshUser
+	if username == "" {
+		if current, err := user.Current(); err == nil {
+			username = current.Username
+		}
+	}
+	return &ssh.ClientConfig{
+		User:            username,
+		Auth:            auths,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         10 * time.Second,
+	}, nil
+}
+
+// 对 shell 参数加单引号
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// 远程执行的 tail 命令，fromStart 为 true 时从文件开头读取
+func remoteTailCommand(path string, fromStart bool) string {
+	lines := "0"
+	if fromStart {
+		lines = "+1"
+	}
+	return fmt.Sprintf("tail -n %s -F %s", lines, shellQuote(path))
+}
+
+// 定期发送 keepalive 请求，连接断开时关闭客户端使读取结束
+func keepSSHAlive(client *ssh.Client, done <-chan struct{}) {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if _, _, err := client.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+				client.Close()
+				return
+			}
+		}
+	}
+}
+
+// 通过SSH在远程主机上执行 tail -F，读取的日志与本地文件使用相同的处理逻辑
+func tailRemoteSlowLog(config *ssh.ClientConfig, agentClient agent.ExtendedAgent, firstRun bool) error {
+	client, err := ssh.Dial("tcp", sshAddress(), config)
+	if err != nil {
+		return fmt.Errorf("无法连接SSH主机 %s: %w", sshAddress(), err)
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("无法创建SSH会话: %w", err)
+	}
+	defer session.Close()
+
+	if agentClient != nil {
+		if err := agent.ForwardToAgent(client, agentClient); err != nil {
+			return fmt.Errorf("无法转发SSH agent: %w", err)
+		}
+		if err := agent.RequestAgentForwarding(session); err != nil {
+			return fmt.Errorf("无法转发SSH agent: %w", err)
+		}
+	}
+
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	fromStart := firstRun && (readHistory || !startFromTime.IsZero())
+	if err := session.Start(remoteTailCommand(slowLogFile, fromStart)); err != nil {
+		return fmt.Errorf("无法在远程主机上执行 tail: %w", err)
+	}
+	logf(levelInfo, "已连接SSH主机 %s，开始读取 %s", sshAddress(), slowLogFile)
+
+	done := make(chan struct{})
+	defer close(done)
+	go keepSSHAlive(client, done)
+
+	assembler := newEntryAssembler(firstRun)
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxRemoteLineBytes)
+	for scanner.Scan() {
+		assembler.feed(scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取远程日志失败: %w", err)
+	}
+	if err := session.Wait(); err != nil {
+		return fmt.Errorf("远程 tail 已退出: %w", err)
+	}
+	return errors.New("远程 tail 已退出")
+}
+
+// 持续读取远程主机上的慢查询日志，连接断开后按与本地文件相同的退避策略重新连接
+func watchRemoteSlowLog() error {
+	if isGlobPattern(slowLogFile) {
+		return errors.New("--sshHost 不支持通配符形式的 --slowLogFile")
+	}
+	var agentClient agent.ExtendedAgent
+	if sshAgentSocket != "" {
+		client, err := dialSSHAgent()
+		if err != nil {
+			return err
+		}
+		agentClient = client
+	}
+	config, err := buildSSHClientConfig(agentClient)
+	if err != nil {
+		return err
+	}
+
+	var backoff restartBackoff
+	for firstRun := true; ; firstRun = false {
+		started := time.Now()
+		err := tailRemoteSlowLog(config, agentClient, firstRun)
+		delay := backoff.next(time.Since(started))
+		logf(levelWarn, "%v，%s 后重新连接...", err, delay)
+		time.Sleep(delay)
+	}
+}
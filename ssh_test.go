@@ -0,0 +1,169 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"mysql-slow-sql-webhook/testutil"
+)
+
+// 生成 ed25519 密钥对
+func newTestSigner(t *testing.T) (ssh.Signer, []byte) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer, pem.EncodeToMemory(block)
+}
+
+// 启动一个模拟SSH服务：只接受指定公钥，执行任何命令时都输出日志文件内容后退出，返回监听地址与收到的命令
+func startTestSSHServer(t *testing.T, hostKey ssh.Signer, clientKey ssh.PublicKey, logFile string) (string, <-chan string) {
+	t.Helper()
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(clientKey.Marshal()) {
+				return nil, os.ErrPermission
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	commands := make(chan string, 1)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestSSHConn(conn, config, logFile, commands)
+		}
+	}()
+	return listener.Addr().String(), commands
+}
+
+func serveTestSSHConn(conn net.Conn, config *ssh.ServerConfig, logFile string, commands chan<- string) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer channel.Close()
+			for req := range channelRequests {
+				if req.Type != "exec" {
+					req.Reply(false, nil)
+					continue
+				}
+				var payload struct{ Command string }
+				ssh.Unmarshal(req.Payload, &payload)
+				req.Reply(true, nil)
+				commands <- payload.Command
+
+				data, _ := os.ReadFile(logFile)
+				channel.Write(data)
+				channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+				return
+			}
+		}()
+	}
+}
+
+func TestTailRemoteSlowLog(t *testing.T) {
+	server := testutil.NewMockWebhookServer(t)
+
+	hostKey, _ := newTestSigner(t)
+	clientKey, clientPEM := newTestSigner(t)
+	addr, commands := startTestSSHServer(t, hostKey, clientKey.PublicKey(), "testdata/slow.log")
+
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "id_ed25519")
+	knownHostsFile := filepath.Join(dir, "known_hosts")
+	if err := os.WriteFile(keyFile, clientPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	line := knownhosts.Line([]string{knownhosts.Normalize(addr)}, hostKey.PublicKey())
+	if err := os.WriteFile(knownHostsFile, []byte(line+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	prevHost, prevUser, prevKey, prevKnownHosts := sshHost, sshUser, sshKeyFile, sshKnownHosts
+	prevFile, prevHistory, prevThreshold, prevTargets := slowLogFile, readHistory, slowQueryThreshold, webhookDestinations
+	t.Cleanup(func() {
+		sshHost, sshUser, sshKeyFile, sshKnownHosts = prevHost, prevUser, prevKey, prevKnownHosts
+		slowLogFile, readHistory, slowQueryThreshold, webhookDestinations = prevFile, prevHistory, prevThreshold, prevTargets
+	})
+	sshHost, sshUser, sshKeyFile, sshKnownHosts = addr, "mysql", keyFile, knownHostsFile
+	slowLogFile = "/var/log/mysql/slow's.log"
+	readHistory = true
+	slowQueryThreshold = 0.5
+	webhookDestinations = []webhookTarget{{URL: server.WeChatURL(), Timeout: 5 * time.Second}}
+
+	config, err := buildSSHClientConfig(nil)
+	if err != nil {
+		t.Fatalf("buildSSHClientConfig: %v", err)
+	}
+	if err := tailRemoteSlowLog(config, nil, true); err == nil {
+		t.Fatal("远程 tail 退出后应返回错误以触发重连")
+	}
+
+	if got, want := <-commands, `tail -n +1 -F '/var/log/mysql/slow'\''s.log'`; got != want {
+		t.Errorf("远程命令 = %q, want %q", got, want)
+	}
+	if !server.WaitForN(2, 5*time.Second) {
+		t.Fatalf("期望收到 2 条通知，实际收到 %d 条", len(server.Received()))
+	}
+}
+
+func TestTailRemoteSlowLogRejectsUnknownHost(t *testing.T) {
+	hostKey, _ := newTestSigner(t)
+	clientKey, clientPEM := newTestSigner(t)
+	addr, _ := startTestSSHServer(t, hostKey, clientKey.PublicKey(), "testdata/slow.log")
+
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "id_ed25519")
+	knownHostsFile := filepath.Join(dir, "known_hosts")
+	os.WriteFile(keyFile, clientPEM, 0o600)
+	os.WriteFile(knownHostsFile, nil, 0o600)
+
+	prevHost, prevKey, prevKnownHosts := sshHost, sshKeyFile, sshKnownHosts
+	t.Cleanup(func() { sshHost, sshKeyFile, sshKnownHosts = prevHost, prevKey, prevKnownHosts })
+	sshHost, sshKeyFile, sshKnownHosts = addr, keyFile, knownHostsFile
+
+	config, err := buildSSHClientConfig(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tailRemoteSlowLog(config, nil, true); err == nil {
+		t.Fatal("known_hosts 中没有的主机应拒绝连接")
+	}
+}
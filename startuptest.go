@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// --startupTest 的退出码
+const (
+	startupExitOK          = 0
+	startupExitConfig      = 1 // 配置无效，或内置日志样例无法按当前配置解析
+	startupExitSlowLog     = 2 // 慢查询日志无法读取
+	startupExitUnreachable = 3 // Webhook 无法送达
+	startupExitAuth        = 4 // Webhook 鉴权失败：401/403，或返回内容中的错误码
+)
+
+// 每种日志格式的内置样例及其应解析出的字段
+type startupFixture struct {
+	lines []string
+	want  SlowQueryEntry
+}
+
+var startupFixtures = map[string]startupFixture{
+	"mysql": {
+		lines: []string{
+			"# Time: 2024-01-01T08:00:00.000000Z",
+			"# User@Host: app[app] @ localhost [127.0.0.1]  Id:    42",
+			"# Query_time: 2.500000  Lock_time: 0.000100 Rows_sent: 1  Rows_examined: 1000",
+			"use shop;",
+			"SET timestamp=1704096000;",
+			"SELECT * FROM orders WHERE id = 1;",
+		},
+		want: SlowQueryEntry{QueryTime: 2.5, RowsExamined: 1000, Database: "shop", User: "app", ConnectionID: 42, SQL: "SELECT * FROM orders WHERE id = 1;"},
+	},
+	"mongodb": {
+		lines: []string{`{"t":{"$date":"2024-01-01T10:00:00.000+08:00"},"s":"I","c":"COMMAND","ctx":"conn42","msg":"Slow query","attr":{"ns":"shop.orders","command":{"find":"orders","filter":{"status":"pending"}},"planSummary":"COLLSCAN","docsExamined":50000,"nreturned":10,"durationMillis":2500}}`},
+		want:  SlowQueryEntry{QueryTime: 2.5, RowsExamined: 50000, Database: "shop", ConnectionID: 42, SQL: `{"status":"pending"}`},
+	},
+	"pmm": {
+		lines: []string{`{"ts":"2024-01-01T08:00:00Z","schema":"shop","username":"app","thread_id":42,"query_time":2.5,"rows_examined":1000,"example":"SELECT * FROM orders WHERE id = 1;"}`},
+		want:  SlowQueryEntry{QueryTime: 2.5, RowsExamined: 1000, Database: "shop", User: "app", ConnectionID: 42, SQL: "SELECT * FROM orders WHERE id = 1;"},
+	},
+}
+
+// 按当前的日志格式与SQL关键字等配置解析内置样例，检查各字段是否正确
+func checkStartupFixture() error {
+	name := "mysql"
+	for n, parser := range logParsers {
+		if parser == logParser {
+			name = n
+		}
+	}
+	fixture := startupFixtures[name]
+	entry, err := logParser.Parse(fixture.lines)
+	if err != nil {
+		return fmt.Errorf("无法解析内置的 %s 日志样例: %w", name, err)
+	}
+	want := fixture.want
+	for _, c := range []struct {
+		field     string
+		got, want any
+	}{
+		{"Query_time", entry.QueryTime, want.QueryTime},
+		{"Rows_examined", entry.RowsExamined, want.RowsExamined},
+		{"数据库", entry.Database, want.Database},
+		{"用户", entry.User, want.User},
+		{"连接ID", entry.ConnectionID, want.ConnectionID},
+		{"SQL", entry.SQL, want.SQL},
+	} {
+		if c.got != c.want {
+			return fmt.Errorf("内置的 %s 日志样例中 %s 解析为 %v，应为 %v", name, c.field, c.got, c.want)
+		}
+	}
+	return nil
+}
+
+// 读取一个日志文件的开头，确认有读取权限
+func checkFileReadable(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Read(make([]byte, 1)); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// 检查要读取的慢查询日志，通过SSH读取时跳过
+func checkSlowLogReadable() error {
+	switch {
+	case pmmSocket != "":
+		conn, err := net.DialTimeout("unix", pmmSocket, 5*time.Second)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	case sshHost != "":
+		logf(levelInfo, "通过SSH读取远程日志，跳过慢查询日志检查")
+		return nil
+	case len(monitoredInstances) > 0:
+		for _, inst := range monitoredInstances {
+			if err := checkFileReadable(inst.SlowLogFile); err != nil {
+				return fmt.Errorf("实例 %s: %w", inst.Name, err)
+			}
+		}
+		return nil
+	case isGlobPattern(slowLogFile):
+		paths, err := filepath.Glob(slowLogFile)
+		if err != nil {
+			return err
+		}
+		if len(paths) == 0 {
+			return fmt.Errorf("没有与 %s 匹配的文件", slowLogFile)
+		}
+		for _, path := range paths {
+			if err := checkFileReadable(path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return checkFileReadable(slowLogFile)
+}
+
+// 是否是鉴权失败：HTTP 401/403，或 2xx 响应中限流以外的错误码（通常是 token 无效）
+func isWebhookAuthError(err error) bool {
+	var statusErr *webhookStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusUnauthorized || statusErr.StatusCode == http.StatusForbidden
+	}
+	var respErr *webhookResponseError
+	return errors.As(err, &respErr) && !respErr.retryable()
+}
+
+// 使用实际配置的地址与消息格式发送一条测试通知，所有地址都确认收到才算成功
+func sendStartupTestNotification() error {
+	targets := append(append([]webhookTarget(nil), webhookDestinations...), instanceTargets()...)
+	n := &notification{
+		Title: "启动检查 (startup test)",
+		Fields: []notificationField{
+			{Label: "说明", Value: "这是 --startupTest 发送的测试通知，收到说明告警可以正常送达"},
+			{Label: "时间", Value: formatDisplayTime(time.Now())},
+		},
+		Labels:   alertLabels,
+		Severity: "warn",
+	}
+	if _, err := sendNotification(activeWebhookFormat, targets, n); err != nil {
+		return err
+	}
+	logf(levelInfo, "测试通知已送达 %d 个地址", len(targets))
+	return nil
+}
+
+// 依次检查日志样例解析、慢查询日志与Webhook，返回第一个失败步骤的退出码
+func runStartupTest() (int, error) {
+	if err := checkStartupFixture(); err != nil {
+		return startupExitConfig, err
+	}
+	logf(levelInfo, "启动检查: 日志样例解析正确")
+	if err := checkSlowLogReadable(); err != nil {
+		return startupExitSlowLog, fmt.Errorf("慢查询日志无法读取: %w", err)
+	}
+	logf(levelInfo, "启动检查: 慢查询日志可以读取")
+	if err := sendStartupTestNotification(); err != nil {
+		if isWebhookAuthError(err) {
+			return startupExitAuth, fmt.Errorf("Webhook 鉴权失败: %w", err)
+		}
+		return startupExitUnreachable, fmt.Errorf("Webhook 无法送达: %w", err)
+	}
+	logf(levelInfo, "启动检查: Webhook 可以送达")
+	return startupExitOK, nil
+}
+
+// 执行 --startupTest 并按结果退出
+func exitWithStartupTest() {
+	code, err := runStartupTest()
+	if err != nil {
+		logf(levelError, "启动检查失败（退出码 %d）: %v", code, err)
+	} else {
+		logf(levelInfo, "启动检查通过")
+	}
+	os.Exit(code)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStartupFixtures(t *testing.T) {
+	prev := logParser
+	t.Cleanup(func() { logParser = prev })
+	for name, parser := range logParsers {
+		logParser = parser
+		if err := checkStartupFixture(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestRunStartupTest(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "slow.log")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	prevFile, prevTargets := slowLogFile, webhookDestinations
+	t.Cleanup(func() { slowLogFile, webhookDestinations = prevFile, prevTargets })
+	slowLogFile = path
+	webhookDestinations = []webhookTarget{{URL: server.URL, Timeout: 5 * time.Second}}
+
+	if code, err := runStartupTest(); code != startupExitOK || err != nil {
+		t.Fatalf("code = %d, err = %v", code, err)
+	}
+
+	status = http.StatusForbidden
+	if code, err := runStartupTest(); code != startupExitAuth {
+		t.Errorf("403 应返回 %d，实际 %d: %v", startupExitAuth, code, err)
+	}
+	status = http.StatusBadGateway
+	if code, err := runStartupTest(); code != startupExitUnreachable {
+		t.Errorf("502 应返回 %d，实际 %d: %v", startupExitUnreachable, code, err)
+	}
+
+	slowLogFile = filepath.Join(t.TempDir(), "missing.log")
+	if code, err := runStartupTest(); code != startupExitSlowLog {
+		t.Errorf("日志不存在时应返回 %d，实际 %d: %v", startupExitSlowLog, code, err)
+	}
+}
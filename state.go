@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// 持久化到 --stateFile 的运行状态，用于重启后继续处理
+type persistedState struct {
+	Offset          int64                `json:"offset"`                    // 慢查询日志中已处理完的位置
+	Cooldown        map[uint64]time.Time `json:"cooldown"`                  // 指纹哈希 -> 最近一次告警时间
+	ProcessedInodes []uint64             `json:"processedInodes,omitempty"` // 通配符模式下已读取过的轮转文件
+	Instances       map[string]int64     `json:"instances,omitempty"`       // --instances 中各实例已处理完的位置
+	Fingerprints    []uint64             `json:"fingerprints,omitempty"`    // --alertOnNewFingerprint 已出现过的指纹哈希
+}
+
+// 慢查询日志中已处理完的位置，由 tailSlowLog 更新
+var processedOffset atomic.Int64
+
+// 启动时从状态文件恢复的读取位置，-1 表示不恢复
+var resumeOffset int64 = -1
+
+// 读取状态文件，文件不存在时返回空状态
+func loadState(path string) (*persistedState, error) {
+	state := &persistedState{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("无法读取状态文件 %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("状态文件 %s 格式错误: %w", path, err)
+	}
+	return state, nil
+}
+
+// 写入状态文件，先写临时文件再重命名，避免退出过程中写坏原文件
+func saveState(path string, state *persistedState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("无法写入状态文件 %s: %w", path, err)
+	}
+	return nil
+}
+
+// 先写入同一目录下的临时文件再重命名，写入过程中退出不会留下不完整的文件
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// 恢复冷却缓存，丢弃已超过 --alertCooldown 的记录，返回恢复的条数
+func restoreCooldownCache(entries map[uint64]time.Time, now time.Time) int {
+	alertCooldownCache.Lock()
+	defer alertCooldownCache.Unlock()
+
+	restored := 0
+	for hash, last := range entries {
+		if now.Sub(last) >= alertCooldown {
+			continue
+		}
+		alertCooldownCache.lastAlert[hash] = last
+		restored++
+	}
+	return restored
+}
+
+// 复制当前冷却缓存中仍在冷却期内的记录
+func snapshotCooldownCache(now time.Time) map[uint64]time.Time {
+	alertCooldownCache.Lock()
+	defer alertCooldownCache.Unlock()
+
+	entries := make(map[uint64]time.Time, len(alertCooldownCache.lastAlert))
+	for hash, last := range alertCooldownCache.lastAlert {
+		if now.Sub(last) < alertCooldown {
+			entries[hash] = last
+		}
+	}
+	return entries
+}
+
+// 启动时加载状态文件：恢复冷却缓存，并在未指定 --readHistory / --startFrom 时从上次处理到的位置继续
+func restoreState(path string) error {
+	state, err := loadState(path)
+	if err != nil {
+		return err
+	}
+
+	if restored := restoreCooldownCache(state.Cooldown, time.Now()); restored > 0 {
+		logf(levelInfo, "已从状态文件恢复 %d 条告警冷却记录", restored)
+	}
+
+	restoreKnownFingerprints(state.Fingerprints)
+
+	if len(monitoredInstances) > 0 {
+		for _, inst := range monitoredInstances {
+			inst.resumeOffset = resumableOffset(inst.SlowLogFile, state.Instances[inst.Name])
+		}
+		return nil
+	}
+
+	// 通配符模式与远程读取时不记录读取位置
+	restoreGlobInodes(state.ProcessedInodes)
+	if isGlobPattern(slowLogFile) || sshHost != "" {
+		return nil
+	}
+	resumeOffset = resumableOffset(slowLogFile, state.Offset)
+	return nil
+}
+
+// 返回可以继续读取的位置，未指定 --readHistory / --startFrom 且日志文件没有轮转或被截断时恢复到 offset，否则返回 -1
+func resumableOffset(path string, offset int64) int64 {
+	if offset <= 0 || readHistory || startFrom != "" {
+		return -1
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Size() < offset {
+		logf(levelWarn, "慢查询日志文件 %s 已轮转或被截断，忽略状态文件中记录的位置 %d", path, offset)
+		return -1
+	}
+	logf(levelInfo, "从状态文件记录的位置 %d 继续读取慢查询日志 %s", offset, path)
+	return offset
+}
+
+// 退出时保存当前状态
+func flushState(path string) {
+	state := &persistedState{
+		Offset:          processedOffset.Load(),
+		Cooldown:        snapshotCooldownCache(time.Now()),
+		ProcessedInodes: processedGlobInodes(),
+	}
+	if alertOnNewFingerprint {
+		state.Fingerprints = knownFingerprintHashes()
+	}
+	if len(monitoredInstances) > 0 {
+		state.Instances = make(map[string]int64, len(monitoredInstances))
+		for _, inst := range monitoredInstances {
+			state.Instances[inst.Name] = inst.offset.Load()
+		}
+	}
+	if err := saveState(path, state); err != nil {
+		logf(levelError, "%v", err)
+		return
+	}
+	logf(levelInfo, "已保存状态文件 %s", path)
+}
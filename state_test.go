@@ -0,0 +1,59 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStateCooldownSurvivesRestart(t *testing.T) {
+	prevCooldown := alertCooldown
+	t.Cleanup(func() {
+		alertCooldown = prevCooldown
+		alertCooldownCache.lastAlert = make(map[uint64]time.Time)
+	})
+	alertCooldown = 10 * time.Minute
+	alertCooldownCache.lastAlert = make(map[uint64]time.Time)
+
+	now := time.Now()
+	path := filepath.Join(t.TempDir(), "state.json")
+	state := &persistedState{
+		Offset: 409,
+		Cooldown: map[uint64]time.Time{
+			1:              now.Add(-time.Minute),     // 仍在冷却期内
+			2:              now.Add(-time.Hour),       // 已过期
+			^uint64(0) - 1: now.Add(-5 * time.Minute), // 超出 int64 范围的哈希也能正确序列化
+		},
+	}
+	if err := saveState(path, state); err != nil {
+		t.Fatalf("saveState: %v", err)
+	}
+
+	loaded, err := loadState(path)
+	if err != nil {
+		t.Fatalf("loadState: %v", err)
+	}
+	if loaded.Offset != state.Offset {
+		t.Errorf("Offset = %d, want %d", loaded.Offset, state.Offset)
+	}
+	if restored := restoreCooldownCache(loaded.Cooldown, now); restored != 2 {
+		t.Fatalf("恢复了 %d 条冷却记录，期望 2 条", restored)
+	}
+
+	if !shouldSuppressByCooldown(1, now) || !shouldSuppressByCooldown(^uint64(0)-1, now) {
+		t.Error("重启前告警过的指纹应仍处于冷却期内")
+	}
+	if shouldSuppressByCooldown(2, now) {
+		t.Error("已超过冷却时间的记录不应恢复")
+	}
+}
+
+func TestLoadStateMissingFile(t *testing.T) {
+	state, err := loadState(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatalf("状态文件不存在时不应返回错误: %v", err)
+	}
+	if state.Offset != 0 || len(state.Cooldown) != 0 {
+		t.Errorf("期望空状态，实际为 %+v", state)
+	}
+}
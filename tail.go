@@ -0,0 +1,339 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hpcloud/tail"
+
+	"mysql-slow-sql-webhook/logline"
+)
+
+// 读取到的一行日志
+type LogLine = logline.Line
+
+// 日志行读取器，tailSlowLog 通过它读取日志，测试中可以替换为 testutil.NewMockLogLineReader
+type LogLineReader = logline.Reader
+
+// 通过 hpcloud/tail 跟踪日志文件的读取器
+type tailLineReader struct {
+	t     *tail.Tail
+	lines chan LogLine
+}
+
+func (r *tailLineReader) Lines() <-chan LogLine {
+	return r.lines
+}
+
+func (r *tailLineReader) Stop() {
+	r.t.Stop()
+}
+
+// 从 offset（按 whence 计算）开始跟踪日志文件，支持文件轮转
+func openTailLineReader(path string, offset int64, whence int) (LogLineReader, error) {
+	// tail 库自身的日志只在 debug 级别下输出
+	tailLogger := tail.DiscardingLogger
+	if logEnabled(levelDebug) {
+		tailLogger = logger
+	}
+
+	location := &tail.SeekInfo{Offset: offset, Whence: whence}
+	t, err := tail.TailFile(path, tail.Config{
+		Follow:    true,     // 实时跟踪文件变化
+		ReOpen:    true,     // 支持文件轮转
+		MustExist: true,     // 文件必须存在
+		Poll:      true,     // 使用轮询模式
+		Location:  location, // 开始读取的位置
+		Logger:    tailLogger,
+	})
+	if err != nil {
+		return nil, err
+	}
+	r := &tailLineReader{t: t, lines: make(chan LogLine)}
+	go func() {
+		defer close(r.lines)
+		for line := range t.Lines {
+			r.lines <- LogLine{Text: line.Text, Err: line.Err}
+		}
+	}()
+	return r, nil
+}
+
+// 打开日志行读取器，测试中可以替换为模拟实现
+var openLogLineReader = openTailLineReader
+
+// 判断某一行是否开始了一条新的日志条目：
+// # Time: 行，或者在已有SQL之后出现的 # User@Host: 行（同一秒内的多条日志可能省略 # Time:）
+func isEntryStart(line string, current []string) bool {
+	if queryStartPattern.MatchString(line) {
+		return true
+	}
+	return userHostPattern.MatchString(line) && extractSQL(current) != ""
+}
+
+// 判断加入当前行后日志条目是否已包含完整的SQL语句
+// # administrator command: 行本身就是条目的结尾
+func isEntryComplete(line string, lines []string) bool {
+	trimmed := strings.TrimSpace(line)
+	if adminCommandPattern.MatchString(trimmed) {
+		return true
+	}
+	if isMetadataLine(trimmed) || !strings.HasSuffix(trimmed, ";") {
+		return false
+	}
+	return sqlQueryEndPattern.MatchString(extractSQL(lines))
+}
+
+// 保存最近若干行原始日志的环形缓冲区，用于 --alertContextLines
+type lineRing struct {
+	lines []string
+	next  int
+	full  bool
+}
+
+func newLineRing(size int) *lineRing {
+	if size < 0 {
+		size = 0
+	}
+	return &lineRing{lines: make([]string, size)}
+}
+
+// 写入一行，缓冲区已满时覆盖最早的一行
+func (r *lineRing) push(line string) {
+	if len(r.lines) == 0 {
+		return
+	}
+	r.lines[r.next] = line
+	r.next = (r.next + 1) % len(r.lines)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// 按时间顺序返回缓冲区中的所有行
+func (r *lineRing) snapshot() []string {
+	if !r.full {
+		return append([]string(nil), r.lines[:r.next]...)
+	}
+	return append(append([]string(nil), r.lines[r.next:]...), r.lines[:r.next]...)
+}
+
+// 把逐行读取的日志组装为完整的日志条目并处理，本地文件与远程读取共用
+type entryAssembler struct {
+	skipping     bool                // 是否仍在跳过 --startFrom / --readFrom 之前的日志
+	stopped      bool                // 已到达 --readUntil，不再处理之后的日志
+	logLines     []string            // 当前日志条目的所有行
+	contextRing  *lineRing           // 最近的原始日志行
+	contextLines []string            // 当前日志条目之前的原始日志行
+	entryBytes   int                 // 当前日志条目的字节数
+	truncated    bool                // 当前日志条目超过大小限制已被截断，跳过剩余的行
+	instance     *instanceConfig     // --instances 中读取的实例，为 nil 时使用全局配置
+	labels       []notificationField // 当前日志条目第一行前缀中的标签
+}
+
+// firstRun 为 true 时按 --startFrom / --readFrom 跳过之前的日志条目
+func newEntryAssembler(firstRun bool) *entryAssembler {
+	return &entryAssembler{
+		skipping:    firstRun && !startFromTime.IsZero(),
+		contextRing: newLineRing(alertContextLines),
+	}
+}
+
+// 是否有尚未处理的日志行
+func (a *entryAssembler) pending() bool {
+	return len(a.logLines) > 0
+}
+
+// 处理一行日志，返回该行是否开始了一条新的日志条目
+func (a *entryAssembler) feed(line string) bool {
+	markLineRead(a.instance)
+	line, labels := stripLabelPrefix(line)
+	if line == "" || !matchesLabelSelector(labels) {
+		return false
+	}
+
+	if a.skipping {
+		entryTime, ok := logParser.EntryTime(line)
+		if !ok || entryTime.Before(startFromTime) {
+			return false
+		}
+		a.skipping = false
+		logf(levelInfo, "已定位到 %s 的日志条目，开始处理", entryTime.Format("2006-01-02 15:04:05"))
+	}
+
+	if a.stopped {
+		return false
+	}
+	if !readUntilTime.IsZero() {
+		if entryTime, ok := logParser.EntryTime(line); ok && !entryTime.Before(readUntilTime) {
+			if len(a.logLines) > 0 {
+				a.process()
+				a.logLines = nil
+			}
+			a.stopped = true
+			logf(levelInfo, "已到达 --readUntil 指定的时间 %s，之后的日志不再处理", readUntilTime.Format("2006-01-02 15:04:05"))
+			return false
+		}
+	}
+
+	// 截断后跳过该条目剩余的行，直到下一条日志条目的元数据行
+	if a.truncated {
+		if !queryStartPattern.MatchString(line) && !userHostPattern.MatchString(line) {
+			a.contextRing.push(line)
+			return false
+		}
+		a.truncated = false
+	}
+
+	started := logParser.IsEntryStart(line, a.logLines)
+	if started {
+		if len(a.logLines) > 0 {
+			a.process() // 处理当前完整日志条目
+		}
+		a.logLines = []string{line} // 初始化新的日志条目
+		a.entryBytes = len(line)
+		a.contextLines = a.contextRing.snapshot()
+		a.labels = labels
+	} else {
+		if len(a.logLines) == 0 {
+			a.contextLines = a.contextRing.snapshot()
+			a.entryBytes = 0
+			a.labels = labels
+		}
+		a.logLines = append(a.logLines, line)
+		a.entryBytes += len(line)
+	}
+	a.contextRing.push(line)
+
+	if logParser.IsEntryComplete(line, a.logLines) {
+		a.process()      // 处理完整的日志条目
+		a.logLines = nil // 清空已处理的日志
+	} else if a.exceedsLimits() {
+		logf(levelWarn, "日志条目超过 %d 行或 %d 字节，截断后处理", maxLinesPerEntry, maxBytesPerEntry)
+		a.process()
+		a.logLines = nil
+		a.truncated = true
+	}
+	return started
+}
+
+// 处理当前日志条目
+func (a *entryAssembler) process() {
+	processInstanceSlowQuery(a.instance, a.labels, a.logLines, a.contextLines)
+}
+
+// 当前日志条目是否超过 --maxLinesPerEntry 或 --maxBytesPerEntry，0 表示不限制
+func (a *entryAssembler) exceedsLimits() bool {
+	return (maxLinesPerEntry > 0 && len(a.logLines) >= maxLinesPerEntry) ||
+		(maxBytesPerEntry > 0 && a.entryBytes >= maxBytesPerEntry)
+}
+
+// 一次日志文件读取任务
+type tailJob struct {
+	path      string          // 日志文件路径
+	firstRun  bool            // 进程启动后的第一次读取，按 --readHistory / --startFrom / 状态文件决定读取位置
+	fromStart bool            // 从文件开头读取，用于通配符模式下启动后新发现的文件
+	instance  *instanceConfig // --instances 中读取的实例
+}
+
+// 重新打开日志文件或重新连接前的等待时间：连续失败时从 1 秒开始翻倍，最长 1 分钟
+type restartBackoff struct {
+	delay time.Duration
+}
+
+// 返回下一次重试前的等待时间，上一次运行超过 1 分钟时视为恢复正常，重新从 1 秒开始
+func (b *restartBackoff) next(ranFor time.Duration) time.Duration {
+	const maxDelay = time.Minute
+	if ranFor > maxDelay || b.delay == 0 {
+		b.delay = time.Second
+	} else if b.delay < maxDelay {
+		b.delay = min(b.delay*2, maxDelay)
+	}
+	return b.delay
+}
+
+// 持续读取一个日志文件，读取协程退出后自动重新启动
+func watchSlowLog(job tailJob) {
+	var wg sync.WaitGroup
+	restart := make(chan bool)
+
+	var backoff restartBackoff
+	for {
+		started := time.Now()
+		wg.Add(1)
+		go tailSlowLog(job, &wg, restart)
+		select {
+		case <-restart:
+			if job.instance == nil && isGlobPattern(slowLogFile) && !keepTailingGlobFile(job.path) {
+				return
+			}
+			recordTailRestart()
+			delay := backoff.next(time.Since(started))
+			logf(levelWarn, "日志监控协程退出，%s 后重新启动...", delay)
+			time.Sleep(delay)
+		}
+		job.firstRun, job.fromStart = false, false
+	}
+}
+
+// 实时读取MySQL慢查询日志，重新启动后始终从文件末尾继续
+func tailSlowLog(job tailJob, wg *sync.WaitGroup, restart chan bool) {
+	defer wg.Done()
+
+	// 只有单个日志文件时才在状态文件中记录读取位置，每个实例分别记录
+	trackOffset := job.instance != nil || !isGlobPattern(slowLogFile)
+	resume, processed := resumeOffset, &processedOffset
+	if job.instance != nil {
+		resume, processed = job.instance.resumeOffset, &job.instance.offset
+	}
+	storeOffset := func(offset int64) {
+		if trackOffset {
+			processed.Store(offset)
+		}
+	}
+
+	// offset 记录已读取到的位置，用于在状态文件中保存处理进度
+	var offset int64
+	seekOffset, whence := int64(0), io.SeekEnd
+	switch {
+	case job.fromStart || (job.firstRun && (readHistory || !startFromTime.IsZero())):
+		whence = io.SeekStart
+	case job.firstRun && trackOffset && resume >= 0:
+		seekOffset, whence = resume, io.SeekStart
+		offset = resume
+	default:
+		if info, err := os.Stat(job.path); err == nil {
+			offset = info.Size()
+		}
+	}
+	storeOffset(offset)
+
+	reader, err := openLogLineReader(job.path, seekOffset, whence)
+	if err != nil {
+		reportError(&TailError{Path: job.path, Op: "open", Err: err})
+		restart <- true
+		return
+	}
+
+	assembler := newEntryAssembler(job.firstRun)
+	assembler.instance = job.instance
+	for line := range reader.Lines() {
+		if line.Err != nil {
+			reportError(&TailError{Path: job.path, Op: "read", Err: line.Err})
+			continue
+		}
+		lineStart := offset
+		offset += int64(len(line.Text)) + 1
+
+		started := assembler.feed(line.Text)
+		switch {
+		case !assembler.pending():
+			storeOffset(offset)
+		case started:
+			storeOffset(lineStart)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"mysql-slow-sql-webhook/testutil"
+)
+
+func TestEntryAssemblerTruncatesLargeEntries(t *testing.T) {
+	var alerted []*SlowQueryEntry
+	prevNotifier, prevThreshold, prevMaxLines := alertNotifier, slowQueryThreshold, maxLinesPerEntry
+	t.Cleanup(func() {
+		alertNotifier, slowQueryThreshold, maxLinesPerEntry = prevNotifier, prevThreshold, prevMaxLines
+	})
+	alertNotifier = func(targets []webhookTarget, entry *SlowQueryEntry) (int, error) {
+		alerted = append(alerted, entry)
+		return 1, nil
+	}
+	slowQueryThreshold = 0.5
+	maxLinesPerEntry = 10
+
+	lines := []string{
+		"# Time: 2024-01-01T00:00:00.000000Z",
+		"# User@Host: app[app] @ localhost []  Id: 1",
+		"# Query_time: 60.0  Lock_time: 0.0 Rows_sent: 0  Rows_examined: 0",
+		"SET timestamp=1704067200;",
+		"INSERT INTO t VALUES",
+	}
+	for i := 0; i < 50; i++ {
+		lines = append(lines, fmt.Sprintf("(%d),", i))
+	}
+	lines = append(lines,
+		"(50);",
+		"# Time: 2024-01-01T00:00:01.000000Z",
+		"# User@Host: app[app] @ localhost []  Id: 1",
+		"# Query_time: 2.0  Lock_time: 0.0 Rows_sent: 1  Rows_examined: 1",
+		"SET timestamp=1704067201;",
+		"SELECT 1;",
+	)
+
+	assembler := newEntryAssembler(true)
+	for _, line := range lines {
+		assembler.feed(line)
+	}
+
+	if len(alerted) != 2 {
+		t.Fatalf("期望 2 条告警，实际 %d 条", len(alerted))
+	}
+	if alerted[0].QueryTime != 60 || strings.Contains(alerted[0].SQL, "(50);") {
+		t.Errorf("超大条目应截断后处理: %+v", alerted[0])
+	}
+	if alerted[1].SQL != "SELECT 1;" {
+		t.Errorf("截断后应正常处理下一条日志条目，SQL = %q", alerted[1].SQL)
+	}
+}
+
+func TestEntryAssemblerReadWindow(t *testing.T) {
+	var alerted []*SlowQueryEntry
+	prevNotifier, prevFrom, prevUntil := alertNotifier, startFromTime, readUntilTime
+	t.Cleanup(func() { alertNotifier, startFromTime, readUntilTime = prevNotifier, prevFrom, prevUntil })
+	alertNotifier = func(targets []webhookTarget, entry *SlowQueryEntry) (int, error) {
+		alerted = append(alerted, entry)
+		return 1, nil
+	}
+	var err error
+	if startFromTime, err = parseTimestamp("2024-01-15 14:00:00"); err != nil {
+		t.Fatal(err)
+	}
+	if readUntilTime, err = parseTimestamp("2024-01-15 15:00:00"); err != nil {
+		t.Fatal(err)
+	}
+
+	assembler := newEntryAssembler(true)
+	for i, at := range []string{"2024-01-15T13:59:59", "2024-01-15T14:00:00", "2024-01-15T14:59:59", "2024-01-15T15:00:00", "2024-01-15T16:00:00"} {
+		for _, line := range []string{
+			"# Time: " + at,
+			"# User@Host: app[app] @ localhost []  Id: 1",
+			"# Query_time: 2.0  Lock_time: 0.0 Rows_sent: 1  Rows_examined: 1",
+			fmt.Sprintf("SELECT %d;", i),
+		} {
+			assembler.feed(line)
+		}
+	}
+
+	var sqls []string
+	for _, entry := range alerted {
+		sqls = append(sqls, entry.SQL)
+	}
+	if got := strings.Join(sqls, " "); got != "SELECT 1; SELECT 2;" {
+		t.Errorf("只应处理 [--readFrom, --readUntil) 内的日志条目，实际: %s", got)
+	}
+}
+
+func TestTailSlowLogTracksOffset(t *testing.T) {
+	lines := []string{
+		"# Time: 2024-01-01T00:00:00.000000Z",
+		"# User@Host: app[app] @ localhost []  Id: 1",
+		"# Query_time: 0.1  Lock_time: 0.0 Rows_sent: 1  Rows_examined: 1",
+		"SELECT 1;",
+		"# Time: 2024-01-01T00:00:01.000000Z",
+		"# User@Host: app[app] @ localhost []  Id: 1",
+	}
+	prevOpen, prevFile, prevHistory := openLogLineReader, slowLogFile, readHistory
+	t.Cleanup(func() {
+		openLogLineReader, slowLogFile, readHistory = prevOpen, prevFile, prevHistory
+		processedOffset.Store(0)
+	})
+	openLogLineReader = func(path string, offset int64, whence int) (LogLineReader, error) {
+		return testutil.NewMockLogLineReader(lines), nil
+	}
+	slowLogFile, readHistory = "slow.log", true
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	tailSlowLog(tailJob{path: slowLogFile, firstRun: true}, &wg, make(chan bool, 1))
+
+	// 第二条日志条目尚未读取完整，读取位置停在它的开头
+	want := int64(len(strings.Join(lines[:4], "\n")) + 1)
+	if got := processedOffset.Load(); got != want {
+		t.Errorf("processedOffset = %d, want %d", got, want)
+	}
+}
+
+func TestTailSlowLogStartFrom(t *testing.T) {
+	var lines []string
+	for i, at := range []string{"2024-01-15T13:00:00", "2024-01-15T13:59:59", "2024-01-15T14:00:00", "2024-01-15T14:30:00"} {
+		lines = append(lines,
+			"# Time: "+at,
+			"# User@Host: app[app] @ localhost []  Id: 1",
+			"# Query_time: 2.0  Lock_time: 0.0 Rows_sent: 1  Rows_examined: 1",
+			fmt.Sprintf("SELECT %d;", i),
+		)
+	}
+	lines = append(lines, "# Time: 2024-01-15T15:00:00")
+
+	var alerted []string
+	var whences []int
+	prevOpen, prevNotifier, prevFile, prevHistory, prevFrom := openLogLineReader, alertNotifier, slowLogFile, readHistory, startFromTime
+	t.Cleanup(func() {
+		openLogLineReader, alertNotifier, slowLogFile, readHistory, startFromTime = prevOpen, prevNotifier, prevFile, prevHistory, prevFrom
+		processedOffset.Store(0)
+	})
+	openLogLineReader = func(path string, offset int64, whence int) (LogLineReader, error) {
+		whences = append(whences, whence)
+		return testutil.NewMockLogLineReader(lines), nil
+	}
+	alertNotifier = func(targets []webhookTarget, entry *SlowQueryEntry) (int, error) {
+		alerted = append(alerted, entry.SQL)
+		return 1, nil
+	}
+	slowLogFile, readHistory = "slow.log", false
+	var err error
+	if startFromTime, err = parseTimestamp("2024-01-15 14:00:00"); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	tailSlowLog(tailJob{path: slowLogFile, firstRun: true}, &wg, make(chan bool, 1))
+
+	// 指定 --startFrom 时即使没有 --readHistory 也要从文件开头读取
+	if len(whences) != 1 || whences[0] != io.SeekStart {
+		t.Errorf("whence = %v, want [%d]", whences, io.SeekStart)
+	}
+	if got := strings.Join(alerted, " "); got != "SELECT 2; SELECT 3;" {
+		t.Errorf("只应处理 --startFrom 之后的日志条目，实际: %s", got)
+	}
+}
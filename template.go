@@ -0,0 +1,135 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func init() {
+	httpMux.HandleFunc("GET /api/v1/template-status", handleTemplateStatus)
+}
+
+// 通过 --webhookTemplate 加载的消息模板
+type loadedTemplate struct {
+	tmpl     *template.Template
+	hash     string // 模板文件内容的 SHA-256
+	loadedAt time.Time
+}
+
+// 当前生效的消息模板，nil 表示使用消息格式自带的渲染
+var activeTemplate atomic.Pointer[loadedTemplate]
+
+// 模板中可用的函数
+var templateFuncs = template.FuncMap{
+	"join":            strings.Join,
+	"labels":          formatLabels,
+	"markdownTable":   markdownTable,
+	"alignedTable":    alignedTable,
+	"sqlLabel":        (*notification).sqlLabel,
+	"planChangeTitle": func() string { return planChangeTitle },
+	"contextTitle":    func() string { return contextTitle },
+}
+
+// 读取并解析消息模板，成功后替换当前模板
+func loadWebhookTemplate(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("无法读取消息模板 %s: %w", path, err)
+	}
+	tmpl, err := template.New(filepath.Base(path)).Funcs(templateFuncs).Parse(string(data))
+	if err != nil {
+		return fmt.Errorf("无法解析消息模板 %s: %w", path, err)
+	}
+	sum := sha256.Sum256(data)
+	activeTemplate.Store(&loadedTemplate{tmpl: tmpl, hash: hex.EncodeToString(sum[:]), loadedAt: time.Now()})
+	return nil
+}
+
+// 渲染消息文本：配置了 --webhookTemplate 或 --alertTemplateDir 时使用模板，否则使用消息格式自带的渲染
+func renderText(format *webhookFormat, n *notification) string {
+	var tmpl *template.Template
+	if loaded := activeTemplate.Load(); loaded != nil {
+		tmpl = loaded.tmpl
+	} else if set := activeTemplateSet.Load(); set != nil {
+		tmpl = set.lookup(format, n)
+	}
+	if tmpl == nil {
+		return format.Render(n)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, n); err != nil {
+		logf(levelError, "渲染消息模板失败，使用默认格式: %v", err)
+		return format.Render(n)
+	}
+	return b.String()
+}
+
+// 监听消息模板文件，修改后重新加载，解析失败时保留原模板
+// 编辑器通常通过重命名替换文件，因此监听所在目录
+func watchWebhookTemplate(path string) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logf(levelError, "无法监听消息模板 %s: %v", path, err)
+		return
+	}
+	defer watcher.Close()
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		logf(levelError, "无法监听消息模板 %s: %v", path, err)
+		return
+	}
+
+	var timer *time.Timer
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != filepath.Clean(path) || !event.Has(fsnotify.Write|fsnotify.Create) {
+				continue
+			}
+			if timer == nil {
+				timer = time.AfterFunc(configReloadDelay, func() {
+					if err := loadWebhookTemplate(path); err != nil {
+						logf(levelError, "%v，继续使用原模板", err)
+						return
+					}
+					logf(levelInfo, "消息模板 %s 已重新加载", path)
+				})
+			} else {
+				timer.Reset(configReloadDelay)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			logf(levelWarn, "监听消息模板出错: %v", err)
+		}
+	}
+}
+
+// GET /api/v1/template-status：当前消息模板的内容哈希与加载时间
+func handleTemplateStatus(w http.ResponseWriter, r *http.Request) {
+	loaded := activeTemplate.Load()
+	if loaded == nil {
+		http.Error(w, "未启用 --webhookTemplate", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"path":      webhookTemplate,
+		"sha256":    loaded.hash,
+		"loaded_at": loaded.loadedAt.Format(time.RFC3339),
+	})
+}
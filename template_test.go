@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWebhookTemplateReload(t *testing.T) {
+	defer activeTemplate.Store(nil)
+	path := filepath.Join(t.TempDir(), "alert.tmpl")
+	n := &notification{Title: "慢查询警告", SQL: "SELECT 1"}
+	format := webhookFormats["generic"]
+
+	if err := os.WriteFile(path, []byte("v1 {{.Title}}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadWebhookTemplate(path); err != nil {
+		t.Fatalf("加载模板失败: %v", err)
+	}
+	if got := renderText(format, n); got != "v1 慢查询警告" {
+		t.Fatalf("renderText = %q", got)
+	}
+	hash := activeTemplate.Load().hash
+
+	go watchWebhookTemplate(path)
+	time.Sleep(100 * time.Millisecond) // 等待开始监听
+
+	// 解析失败时保留原模板
+	if err := os.WriteFile(path, []byte("{{.Title"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * configReloadDelay)
+	if got := renderText(format, n); got != "v1 慢查询警告" {
+		t.Fatalf("模板无效时应保留原模板，renderText = %q", got)
+	}
+	if activeTemplate.Load().hash != hash {
+		t.Errorf("模板无效时不应更新哈希")
+	}
+
+	if err := os.WriteFile(path, []byte("v2 {{.SQL}}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for renderText(format, n) != "v2 SELECT 1" {
+		if time.Now().After(deadline) {
+			t.Fatalf("模板修改后没有重新加载，renderText = %q", renderText(format, n))
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
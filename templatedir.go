@@ -0,0 +1,146 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// 各消息格式自带的模板，与对应的 Render 输出一致，--alertTemplateDir 中没有对应文件时使用
+//
+//go:embed templates/*.tmpl
+var builtinTemplates embed.FS
+
+// 可按告警级别覆盖的模板名称，优先于消息格式的模板
+var severityTemplateNames = []string{"critical", "warn"}
+
+// --alertTemplateDir 中的模板，键为不含 .tmpl 的文件名
+type templateSet struct {
+	templates map[string]*template.Template
+	loadedAt  time.Time
+}
+
+// 当前生效的模板目录，nil 表示未启用 --alertTemplateDir
+var activeTemplateSet atomic.Pointer[templateSet]
+
+// 模板目录中可用的文件名，包括消息格式与告警级别
+func knownTemplateNames() map[string]bool {
+	names := make(map[string]bool, len(webhookFormats)+len(severityTemplateNames))
+	for name := range webhookFormats {
+		names[name] = true
+	}
+	for _, name := range severityTemplateNames {
+		names[name] = true
+	}
+	return names
+}
+
+// 解析模板目录，目录中的文件覆盖自带模板；未知文件只记录警告，解析失败时返回错误
+func loadAlertTemplateDir(dir string) error {
+	set := &templateSet{templates: make(map[string]*template.Template), loadedAt: time.Now()}
+	builtins, err := builtinTemplates.ReadDir("templates")
+	if err != nil {
+		return err
+	}
+	for _, file := range builtins {
+		data, err := builtinTemplates.ReadFile("templates/" + file.Name())
+		if err != nil {
+			return err
+		}
+		if err := set.add(file.Name(), data); err != nil {
+			return err
+		}
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("无法读取模板目录 %s: %w", dir, err)
+	}
+	known := knownTemplateNames()
+	for _, file := range files {
+		// Kubernetes ConfigMap 挂载的目录中有 ..data 等隐藏文件
+		if file.IsDir() || strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+		name, ok := strings.CutSuffix(file.Name(), ".tmpl")
+		if !ok || !known[name] {
+			logf(levelWarn, "模板目录中的文件 %s 无法对应消息格式或告警级别，已忽略", file.Name())
+			continue
+		}
+		path := filepath.Join(dir, file.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("无法读取消息模板 %s: %w", path, err)
+		}
+		if err := set.add(file.Name(), data); err != nil {
+			return fmt.Errorf("无法解析消息模板 %s: %w", path, err)
+		}
+	}
+	activeTemplateSet.Store(set)
+	return nil
+}
+
+// 解析一个模板文件加入模板集合
+func (s *templateSet) add(file string, data []byte) error {
+	tmpl, err := template.New(file).Funcs(templateFuncs).Parse(string(data))
+	if err != nil {
+		return err
+	}
+	s.templates[strings.TrimSuffix(file, ".tmpl")] = tmpl
+	return nil
+}
+
+// 按告警级别、消息格式的顺序查找模板
+func (s *templateSet) lookup(format *webhookFormat, n *notification) *template.Template {
+	if tmpl, ok := s.templates[n.Severity]; ok && n.Severity != "" {
+		return tmpl
+	}
+	return s.templates[format.Name]
+}
+
+// 监听模板目录，任一文件变化后重新加载整个目录，解析失败时保留原模板
+func watchAlertTemplateDir(dir string) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logf(levelError, "无法监听模板目录 %s: %v", dir, err)
+		return
+	}
+	defer watcher.Close()
+	if err := watcher.Add(dir); err != nil {
+		logf(levelError, "无法监听模板目录 %s: %v", dir, err)
+		return
+	}
+
+	var timer *time.Timer
+	for {
+		select {
+		case _, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if timer == nil {
+				timer = time.AfterFunc(configReloadDelay, func() {
+					if err := loadAlertTemplateDir(dir); err != nil {
+						logf(levelError, "%v，继续使用原模板", err)
+						return
+					}
+					logf(levelInfo, "模板目录 %s 已重新加载", dir)
+				})
+			} else {
+				timer.Reset(configReloadDelay)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			logf(levelWarn, "监听模板目录出错: %v", err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBuiltinTemplatesMatchRender(t *testing.T) {
+	defer activeTemplateSet.Store(nil)
+	if err := loadAlertTemplateDir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	set := activeTemplateSet.Load()
+	n := &notification{
+		Title:   "慢查询警告",
+		Fields:  []notificationField{{Label: "数据库", Value: "shop"}, {Label: "查询时间", Value: "12.5s", Highlight: true}},
+		Tables:  []notificationTable{{Title: "锁等待", Columns: []string{"线程", "状态"}, Rows: [][]string{{"42", "等待|锁"}}}},
+		SQL:     "SELECT * FROM orders",
+		Plan:    []notificationField{{Label: "原计划", Value: "ref"}, {Label: "现计划", Value: "ALL"}},
+		Context: []string{"# Time: 2024-01-01T00:00:00", "use shop;"},
+		Labels:  []notificationField{{Label: "env", Value: "production"}},
+		Notes:   []string{"提示一", "提示二"},
+	}
+	for _, n := range []*notification{n, {Title: "慢查询警告", SQL: "SELECT 1"}} {
+		for name, format := range webhookFormats {
+			tmpl := set.lookup(format, n)
+			if tmpl == nil {
+				t.Fatalf("%s 没有自带模板", name)
+			}
+			if got, want := renderText(format, n), format.Render(n); got != want {
+				t.Errorf("%s 自带模板与 Render 不一致:\n%q\n%q", name, got, want)
+			}
+		}
+	}
+}
+
+func TestAlertTemplateDir(t *testing.T) {
+	defer activeTemplateSet.Store(nil)
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("slack.tmpl", "slack {{.Title}}")
+	write("critical.tmpl", "critical {{.Title}}")
+	write("email.tmpl", "ignored")
+	if err := loadAlertTemplateDir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	warn := &notification{Title: "慢查询警告", SQL: "SELECT 1", Severity: "warn"}
+	critical := &notification{Title: "慢查询警告", SQL: "SELECT 1", Severity: "critical"}
+	if got := renderText(webhookFormats["slack"], warn); got != "slack 慢查询警告" {
+		t.Errorf("slack.tmpl 未生效: %q", got)
+	}
+	if got := renderText(webhookFormats["wechat"], warn); got != renderWeChatMarkdown(warn) {
+		t.Errorf("没有 wechat.tmpl 时应使用自带模板: %q", got)
+	}
+	if got := renderText(webhookFormats["wechat"], critical); got != "critical 慢查询警告" {
+		t.Errorf("critical.tmpl 应优先于消息格式的模板: %q", got)
+	}
+
+	go watchAlertTemplateDir(dir)
+	time.Sleep(100 * time.Millisecond) // 等待开始监听
+
+	// 解析失败时保留原模板
+	write("slack.tmpl", "{{.Title")
+	time.Sleep(2 * configReloadDelay)
+	if got := renderText(webhookFormats["slack"], warn); got != "slack 慢查询警告" {
+		t.Fatalf("模板无效时应保留原模板: %q", got)
+	}
+
+	write("slack.tmpl", "v2 {{.SQL}}")
+	deadline := time.Now().Add(5 * time.Second)
+	for renderText(webhookFormats["slack"], warn) != "v2 SELECT 1" {
+		if time.Now().After(deadline) {
+			t.Fatalf("模板目录修改后没有重新加载: %q", renderText(webhookFormats["slack"], warn))
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,38 @@
+package testutil
+
+import (
+	"sync"
+
+	"mysql-slow-sql-webhook/logline"
+)
+
+// 依次发送预先设置的日志行，发送完后关闭通道
+type MockLogLineReader struct {
+	lines    chan logline.Line
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// 创建模拟的日志行读取器
+func NewMockLogLineReader(lines []string) logline.Reader {
+	r := &MockLogLineReader{lines: make(chan logline.Line), stop: make(chan struct{})}
+	go func() {
+		defer close(r.lines)
+		for _, text := range lines {
+			select {
+			case r.lines <- logline.Line{Text: text}:
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+	return r
+}
+
+func (r *MockLogLineReader) Lines() <-chan logline.Line {
+	return r.lines
+}
+
+func (r *MockLogLineReader) Stop() {
+	r.stopOnce.Do(func() { close(r.stop) })
+}
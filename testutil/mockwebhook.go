@@ -0,0 +1,139 @@
+// Package testutil 提供测试中使用的模拟服务。
+package testutil
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 模拟服务支持的平台
+const (
+	PlatformWeChat = "wechat"
+	PlatformSlack  = "slack"
+)
+
+// 模拟服务收到的一次Webhook请求
+type WebhookPayload struct {
+	Platform string         // wechat 或 slack，根据请求路径判断
+	Method   string         // HTTP 方法
+	Path     string         // 请求路径
+	Header   http.Header    // 请求头
+	Raw      []byte         // 原始请求体
+	Body     map[string]any // 解析后的 JSON 请求体
+}
+
+// 模拟企业微信与 Slack Webhook 的 HTTP 服务
+type MockWebhookServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	received []WebhookPayload
+	notify   chan struct{}
+}
+
+// 启动模拟服务，测试结束时自动关闭
+//
+// 路径以 /slack 开头的请求按 Slack 协议返回 {"ok":true}，其它请求按企业微信协议返回 {"errcode":0}。
+func NewMockWebhookServer(t *testing.T) *MockWebhookServer {
+	t.Helper()
+	m := &MockWebhookServer{notify: make(chan struct{}, 1)}
+	m.Server = httptest.NewServer(http.HandlerFunc(m.handle))
+	t.Cleanup(m.Close)
+	return m
+}
+
+// 企业微信协议的地址
+func (m *MockWebhookServer) WeChatURL() string {
+	return m.URL + "/cgi-bin/webhook/send?key=test"
+}
+
+// Slack 协议的地址
+func (m *MockWebhookServer) SlackURL() string {
+	return m.URL + "/slack/services/T000/B000/XXX"
+}
+
+func (m *MockWebhookServer) handle(w http.ResponseWriter, r *http.Request) {
+	platform := PlatformWeChat
+	if strings.HasPrefix(r.URL.Path, "/slack") {
+		platform = PlatformSlack
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	raw, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var body map[string]any
+	if err := json.Unmarshal(raw, &body); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		if platform == PlatformSlack {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"ok":false,"error":"invalid_payload"}`))
+		} else {
+			w.Write([]byte(`{"errcode":40008,"errmsg":"invalid message type"}`))
+		}
+		return
+	}
+
+	m.mu.Lock()
+	m.received = append(m.received, WebhookPayload{
+		Platform: platform,
+		Method:   r.Method,
+		Path:     r.URL.Path,
+		Header:   r.Header.Clone(),
+		Raw:      raw,
+		Body:     body,
+	})
+	m.mu.Unlock()
+	select {
+	case m.notify <- struct{}{}:
+	default:
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if platform == PlatformSlack {
+		w.Write([]byte(`{"ok":true}`))
+	} else {
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}
+}
+
+// 返回目前为止收到的所有请求
+func (m *MockWebhookServer) Received() []WebhookPayload {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]WebhookPayload(nil), m.received...)
+}
+
+func (m *MockWebhookServer) count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.received)
+}
+
+// 等待至少收到 n 个请求，超时返回 false
+func (m *MockWebhookServer) WaitForN(n int, timeout time.Duration) bool {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for m.count() < n {
+		select {
+		case <-m.notify:
+		case <-deadline.C:
+			return m.count() >= n
+		}
+	}
+	return true
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// 单个数据库的阈值覆盖配置，未设置的字段沿用全局配置
+type databaseThreshold struct {
+	QueryTime    *float64 `json:"queryTime"`    // 查询时间阈值，单位：秒
+	RowsExamined int      `json:"rowsExamined"` // 扫描行数阈值，0 表示不按扫描行数告警
+}
+
+// 当前生效的数据库阈值表，可通过 SIGHUP 热加载
+var databaseThresholdTable atomic.Pointer[map[string]databaseThreshold]
+
+// 解析 --databaseThresholds 参数：以 { 开头时视为JSON字符串，否则视为JSON文件路径
+func loadDatabaseThresholds(spec string) (map[string]databaseThreshold, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return map[string]databaseThreshold{}, nil
+	}
+
+	data := []byte(spec)
+	if !strings.HasPrefix(spec, "{") {
+		content, err := os.ReadFile(spec)
+		if err != nil {
+			return nil, fmt.Errorf("无法读取数据库阈值配置文件 %s: %w", spec, err)
+		}
+		data = content
+	}
+
+	thresholds := make(map[string]databaseThreshold)
+	if err := json.Unmarshal(data, &thresholds); err != nil {
+		return nil, fmt.Errorf("数据库阈值配置格式错误: %w", err)
+	}
+	return thresholds, nil
+}
+
+// 重新加载数据库阈值配置，失败时保留原配置
+func reloadDatabaseThresholds() error {
+	thresholds, err := loadDatabaseThresholds(databaseThresholds)
+	if err != nil {
+		return err
+	}
+	databaseThresholdTable.Store(&thresholds)
+	return nil
+}
+
+// 返回指定数据库的告警阈值，优先使用该数据库的覆盖配置
+func thresholdFor(database string) thresholdConfig {
+	return instanceThresholdFor(nil, database)
+}
+
+// 返回实例中指定数据库的告警阈值：数据库的覆盖配置 > 实例的 slowQueryThreshold > 全局配置
+func instanceThresholdFor(inst *instanceConfig, database string) thresholdConfig {
+	threshold := thresholdConfig{QueryTime: slowQueryThreshold, TmpDiskTables: tmpDiskTablesThreshold, MergePasses: mergePassesThreshold, RowsAffected: rowsAffectedThreshold, LockWaits: lockWaitThreshold}
+	if inst != nil && inst.SlowQueryThreshold != nil {
+		threshold.QueryTime = *inst.SlowQueryThreshold
+	}
+	if table := databaseThresholdTable.Load(); table != nil {
+		if override, ok := (*table)[database]; ok {
+			if override.QueryTime != nil {
+				threshold.QueryTime = *override.QueryTime
+			}
+			threshold.RowsExamined = override.RowsExamined
+		}
+	}
+	return threshold
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestInstanceThresholdFor(t *testing.T) {
+	oldThreshold, oldTable := slowQueryThreshold, databaseThresholdTable.Load()
+	t.Cleanup(func() {
+		slowQueryThreshold = oldThreshold
+		databaseThresholdTable.Store(oldTable)
+	})
+	slowQueryThreshold = 2
+	thresholds, err := loadDatabaseThresholds(`{"analytics":{"queryTime":30,"rowsExamined":5000000},"reports":{"rowsExamined":1000}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	databaseThresholdTable.Store(&thresholds)
+	instanceThreshold := 5.0
+	inst := &instanceConfig{SlowQueryThreshold: &instanceThreshold}
+
+	for _, tt := range []struct {
+		name         string
+		inst         *instanceConfig
+		database     string
+		queryTime    float64
+		rowsExamined int
+	}{
+		{"全局阈值", nil, "orders", 2, 0},
+		{"数据库覆盖查询时间与扫描行数", nil, "analytics", 30, 5000000},
+		{"只覆盖扫描行数", nil, "reports", 2, 1000},
+		{"实例阈值", inst, "orders", 5, 0},
+		{"数据库覆盖优先于实例阈值", inst, "analytics", 30, 5000000},
+		{"未覆盖查询时间时沿用实例阈值", inst, "reports", 5, 1000},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			threshold := instanceThresholdFor(tt.inst, tt.database)
+			if threshold.QueryTime != tt.queryTime || threshold.RowsExamined != tt.rowsExamined {
+				t.Errorf("instanceThresholdFor(%s) = %+v, want queryTime %v rowsExamined %d", tt.database, threshold, tt.queryTime, tt.rowsExamined)
+			}
+		})
+	}
+}
+
+func TestDatabaseThresholdsReloadOnSIGHUP(t *testing.T) {
+	oldThreshold, oldSpec, oldTable, oldConfigDir := slowQueryThreshold, databaseThresholds, databaseThresholdTable.Load(), configDir
+	t.Cleanup(func() {
+		slowQueryThreshold, databaseThresholds, configDir = oldThreshold, oldSpec, oldConfigDir
+		databaseThresholdTable.Store(oldTable)
+	})
+	slowQueryThreshold, configDir = 2, ""
+	databaseThresholds = filepath.Join(t.TempDir(), "thresholds.json")
+	if err := os.WriteFile(databaseThresholds, []byte(`{"analytics":{"queryTime":30}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloadDatabaseThresholds(); err != nil {
+		t.Fatal(err)
+	}
+	if got := thresholdFor("analytics").QueryTime; got != 30 {
+		t.Fatalf("初始阈值 = %v", got)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	t.Cleanup(func() {
+		signal.Stop(signals)
+		close(signals)
+	})
+	go reloadOnSignals(signals)
+
+	if err := os.WriteFile(databaseThresholds, []byte(`{"analytics":{"queryTime":60}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for thresholdFor("analytics").QueryTime != 60 {
+		if time.Now().After(deadline) {
+			t.Fatalf("SIGHUP 后阈值 = %v, want 60", thresholdFor("analytics").QueryTime)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 配置文件格式错误时保留原配置
+	if err := os.WriteFile(databaseThresholds, []byte(`{bad`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reloadConfig()
+	if got := thresholdFor("analytics").QueryTime; got != 60 {
+		t.Errorf("格式错误后阈值 = %v, want 60", got)
+	}
+}
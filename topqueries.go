@@ -0,0 +1,119 @@
+package main
+
+import (
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+func init() {
+	httpMux.HandleFunc("GET /api/v1/frequent-queries", handleFrequentQueries)
+}
+
+// 一个指纹达到告警阈值的次数
+type frequentQuery struct {
+	Hash        string    `json:"hash"`
+	Fingerprint string    `json:"fingerprint"`
+	Database    string    `json:"database"`
+	Count       int       `json:"count"`
+	TotalTime   float64   `json:"total_time"`
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+// 启动以来（或上次汇总以来）各指纹的出现次数
+var frequentQueries = struct {
+	sync.Mutex
+	byHash map[uint64]*frequentQuery
+}{byHash: make(map[uint64]*frequentQuery)}
+
+// 记录一次达到告警阈值的慢查询
+func recordFrequentQuery(entry *SlowQueryEntry) {
+	frequentQueries.Lock()
+	defer frequentQueries.Unlock()
+	q, ok := frequentQueries.byHash[entry.Hash]
+	if !ok {
+		q = &frequentQuery{Hash: fmt.Sprintf("%016x", entry.Hash), Fingerprint: entry.Fingerprint, Database: entry.Database}
+		frequentQueries.byHash[entry.Hash] = q
+	}
+	q.Count++
+	q.TotalTime += entry.QueryTime
+	q.LastSeen = time.Now()
+}
+
+// 按出现次数排序的小顶堆，堆顶是当前前 N 名中次数最少的
+type frequentQueryHeap []frequentQuery
+
+func (h frequentQueryHeap) Len() int { return len(h) }
+func (h frequentQueryHeap) Less(i, j int) bool {
+	if h[i].Count != h[j].Count {
+		return h[i].Count < h[j].Count
+	}
+	return h[i].Hash > h[j].Hash
+}
+func (h frequentQueryHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *frequentQueryHeap) Push(x any)   { *h = append(*h, x.(frequentQuery)) }
+func (h *frequentQueryHeap) Pop() any {
+	old := *h
+	q := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return q
+}
+
+// 出现次数最多的 n 个指纹，按次数从高到低排列
+func topFrequentQueries(n int) []frequentQuery {
+	frequentQueries.Lock()
+	h := make(frequentQueryHeap, 0, n+1)
+	for _, q := range frequentQueries.byHash {
+		heap.Push(&h, *q)
+		if h.Len() > n {
+			heap.Pop(&h)
+		}
+	}
+	frequentQueries.Unlock()
+
+	top := []frequentQuery(h)
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Hash < top[j].Hash
+	})
+	return top
+}
+
+// 汇总结束后清空计数，由 --resetFrequentAfterDigest 控制
+func resetFrequentQueries() {
+	frequentQueries.Lock()
+	defer frequentQueries.Unlock()
+	frequentQueries.byHash = make(map[uint64]*frequentQuery)
+}
+
+// 汇总中的重复次数最多的慢查询表格
+func frequentQueriesTable(top []frequentQuery) notificationTable {
+	t := notificationTable{
+		Title:   "重复次数最多的慢查询",
+		Columns: []string{"Count", "Total Time", "Database", "Fingerprint"},
+	}
+	for _, q := range top {
+		database := q.Database
+		if database == "" {
+			database = "-"
+		}
+		t.Rows = append(t.Rows, []string{fmt.Sprintf("%d", q.Count), fmt.Sprintf("%.2fs", q.TotalTime), database, digestSQL(q.Fingerprint)})
+	}
+	return t
+}
+
+// GET /api/v1/frequent-queries：出现次数最多的 --topFrequent 个慢查询指纹
+func handleFrequentQueries(w http.ResponseWriter, r *http.Request) {
+	if topFrequent <= 0 {
+		http.Error(w, "未启用 --topFrequent", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"queries": topFrequentQueries(topFrequent)})
+}
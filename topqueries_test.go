@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTopFrequentQueries(t *testing.T) {
+	t.Cleanup(resetFrequentQueries)
+	resetFrequentQueries()
+
+	// 指纹 i 出现 i 次
+	for i := 1; i <= 20; i++ {
+		fingerprint := fmt.Sprintf("select * from t%d where id = ?", i)
+		entry := &SlowQueryEntry{Fingerprint: fingerprint, Hash: computeQueryHash(fingerprint), QueryTime: 0.5}
+		for j := 0; j < i; j++ {
+			recordFrequentQuery(entry)
+		}
+	}
+
+	top := topFrequentQueries(3)
+	if len(top) != 3 {
+		t.Fatalf("期望 3 个指纹，实际 %d 个", len(top))
+	}
+	for i, want := range []int{20, 19, 18} {
+		if top[i].Count != want {
+			t.Errorf("第 %d 名出现次数 = %d, want %d", i+1, top[i].Count, want)
+		}
+	}
+	if top[0].TotalTime != 10 || top[0].Fingerprint != "select * from t20 where id = ?" {
+		t.Errorf("第 1 名 = %+v", top[0])
+	}
+
+	table := frequentQueriesTable(top)
+	if len(table.Rows) != 3 || table.Rows[0][0] != "20" {
+		t.Errorf("汇总表格 = %+v", table.Rows)
+	}
+
+	resetFrequentQueries()
+	if top := topFrequentQueries(3); len(top) != 0 {
+		t.Errorf("清空后仍有 %d 个指纹", len(top))
+	}
+}
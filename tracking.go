@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 汇总中显示的跟踪指纹数
+const digestTopTracked = 10
+
+// 启用 --trackMinQueryTime 时单个指纹在一个汇总周期内的统计
+type trackedQuery struct {
+	fingerprint string
+	alerted     int     // 达到告警阈值的次数
+	below       int     // 未达到告警阈值但不低于 --trackMinQueryTime 的次数
+	belowTime   float64 // 未达到告警阈值的查询时间之和，单位：秒
+}
+
+// 当前汇总周期内跟踪的查询，按指纹哈希索引
+var trackedQueries = struct {
+	sync.Mutex
+	byHash map[uint64]*trackedQuery
+}{byHash: make(map[uint64]*trackedQuery)}
+
+// 记录不低于 --trackMinQueryTime 的查询：未达到告警阈值的写入历史数据库，并计入汇总
+func recordTrackedQuery(entry *SlowQueryEntry, alerting bool) {
+	if !alerting && history != nil {
+		if err := history.recordTrackedQuery(entry, time.Now()); err != nil {
+			logf(levelWarn, "记录查询历史失败: %v", err)
+		}
+	}
+	if digestInterval <= 0 {
+		return
+	}
+	trackedQueries.Lock()
+	defer trackedQueries.Unlock()
+	q, ok := trackedQueries.byHash[entry.Hash]
+	if !ok {
+		q = &trackedQuery{fingerprint: entry.Fingerprint}
+		trackedQueries.byHash[entry.Hash] = q
+	}
+	if alerting {
+		q.alerted++
+	} else {
+		q.below++
+		q.belowTime += entry.QueryTime
+	}
+}
+
+// 取出当前周期出现次数最多的指纹并重新统计
+func takeTrackedQueries() []*trackedQuery {
+	trackedQueries.Lock()
+	byHash := trackedQueries.byHash
+	trackedQueries.byHash = make(map[uint64]*trackedQuery)
+	trackedQueries.Unlock()
+
+	top := make([]*trackedQuery, 0, len(byHash))
+	for _, q := range byHash {
+		top = append(top, q)
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if a, b := top[i].alerted+top[i].below, top[j].alerted+top[j].below; a != b {
+			return a > b
+		}
+		return top[i].fingerprint < top[j].fingerprint
+	})
+	if len(top) > digestTopTracked {
+		top = top[:digestTopTracked]
+	}
+	return top
+}
+
+// 汇总中的跟踪指纹：同一指纹达到与未达到告警阈值的次数，用于判断告警是否只是偶发
+func trackedQueriesTable(top []*trackedQuery) notificationTable {
+	t := notificationTable{
+		Title:   fmt.Sprintf("出现次数最多的 %d 个跟踪指纹 (Tracked Queries)", digestTopTracked),
+		Columns: []string{"Alerted", "Below Threshold", "Mean Time Below", "Fingerprint"},
+	}
+	for _, q := range top {
+		mean := "-"
+		if q.below > 0 {
+			mean = fmt.Sprintf("%.2fs", q.belowTime/float64(q.below))
+		}
+		t.Rows = append(t.Rows, []string{fmt.Sprintf("%d", q.alerted), fmt.Sprintf("%d", q.below), mean, digestSQL(q.fingerprint)})
+	}
+	return t
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTrackMinQueryTime(t *testing.T) {
+	h, err := openHistory(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	var alerted int
+	prevHistory, prevNotifier, prevThreshold, prevTrack, prevDigest := history, alertNotifier, slowQueryThreshold, trackMinQueryTime, digestInterval
+	t.Cleanup(func() {
+		history, alertNotifier, slowQueryThreshold, trackMinQueryTime, digestInterval = prevHistory, prevNotifier, prevThreshold, prevTrack, prevDigest
+		takeDigest(time.Now())
+		takeTrackedQueries()
+	})
+	history = h
+	alertNotifier = func(targets []webhookTarget, entry *SlowQueryEntry) (int, error) {
+		alerted++
+		return 1, nil
+	}
+	slowQueryThreshold, trackMinQueryTime, digestInterval = 1, 0.1, time.Hour
+	takeTrackedQueries()
+
+	for _, queryTime := range []string{"0.500000", "0.050000", "0.300000", "2.000000"} {
+		processSlowQuery(fixtureLines(fmt.Sprintf(`
+# User@Host: app[app] @ localhost []  Id:    42
+# Query_time: %s  Lock_time: 0.000000 Rows_sent: 1  Rows_examined: 1
+SELECT * FROM orders WHERE id = 1;`, queryTime)), nil)
+	}
+	if alerted != 1 {
+		t.Fatalf("只有达到 --slowQueryThreshold 的查询应发送告警，实际 %d 条", alerted)
+	}
+
+	var tracked int
+	if err := h.db.QueryRow(`SELECT COUNT(*) FROM queries WHERE alerted = 0`).Scan(&tracked); err != nil {
+		t.Fatal(err)
+	}
+	if tracked != 2 {
+		t.Errorf("历史数据库中跟踪的查询 = %d, want 2", tracked)
+	}
+
+	top := takeTrackedQueries()
+	if len(top) != 1 || top[0].alerted != 1 || top[0].below != 2 {
+		t.Fatalf("tracked = %+v", top)
+	}
+	if row := trackedQueriesTable(top).Rows[0]; row[2] != "0.40s" {
+		t.Errorf("Mean Time Below = %s", row[2])
+	}
+}
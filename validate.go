@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// 启动时需要校验的配置，flag 标签为对应的命令行参数名称
+type Config struct {
+	WebhookURLs        []string `flag:"webhookURL" validate:"required,dive,url"`
+	SlowQueryThreshold float64  `flag:"slowQueryThreshold" validate:"min=0,max=3600"`
+	WebhookFormat      string   `flag:"webhookFormat" validate:"oneof=wechat slack teams feishu generic"`
+	SlowLogFile        string   `flag:"slowLogFile" validate:"omitempty,file"` // 通配符、--sshHost、--pmmSocket 与 --instances 时不检查
+	InstanceLogFiles   []string `flag:"instances" validate:"dive,file"`
+	AutoTunePercentile float64  `flag:"autoTunePercentile" validate:"gt=0,lte=100"`
+	AckCallbackURL     string   `flag:"ackCallbackURL" validate:"omitempty,url"`
+	HTTPAddr           string   `flag:"httpAddr" validate:"required_with=AckCallbackURL"`
+	OTelMetricsURL     string   `flag:"otelMetricsEndpoint" validate:"omitempty,url"`
+	SelfAlertURL       string   `flag:"selfAlertWebhookURL" validate:"omitempty,url"`
+}
+
+// 按命令行参数生成需要校验的配置
+func currentConfig() (*Config, error) {
+	targets, err := webhookTargets()
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{
+		WebhookURLs:        append(webhookTargetURLs(targets), instanceWebhookURLs()...),
+		SlowQueryThreshold: slowQueryThreshold,
+		WebhookFormat:      strings.ToLower(strings.TrimSpace(webhookFormatName)),
+		AutoTunePercentile: autoTunePercentile,
+		AckCallbackURL:     ackCallbackURL,
+		HTTPAddr:           httpAddr,
+		InstanceLogFiles:   instanceLogFiles(),
+		OTelMetricsURL:     otelMetricsEndpoint,
+	}
+	if selfAlertWebhookURL != "" {
+		target, err := parseWebhookTarget(selfAlertWebhookURL)
+		if err != nil {
+			return nil, err
+		}
+		cfg.SelfAlertURL = target.URL
+	}
+	if sshHost == "" && pmmSocket == "" && !isGlobPattern(slowLogFile) && len(monitoredInstances) == 0 {
+		cfg.SlowLogFile = slowLogFile
+	}
+	// --startupTest 时由启动检查读取日志文件，以退出码 2 报告
+	if startupTest {
+		cfg.SlowLogFile, cfg.InstanceLogFiles = "", nil
+	}
+	return cfg, nil
+}
+
+var configValidator = newConfigValidator()
+
+// 校验错误中使用 flag 标签作为字段名称
+func newConfigValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		return field.Tag.Get("flag")
+	})
+	return v
+}
+
+// 校验规则对应的说明
+func validationReason(e validator.FieldError) string {
+	switch e.Tag() {
+	case "required":
+		return "必须设置"
+	case "required_with":
+		return "使用 --ackCallbackURL 时必须设置"
+	case "url":
+		return fmt.Sprintf("%q 不是有效的URL", e.Value())
+	case "min", "gte":
+		return "不能小于 " + e.Param()
+	case "max", "lte":
+		return "不能大于 " + e.Param()
+	case "gt":
+		return "必须大于 " + e.Param()
+	case "oneof":
+		return fmt.Sprintf("%q 无效，可选值: %s", e.Value(), strings.ReplaceAll(e.Param(), " ", ", "))
+	case "file":
+		return fmt.Sprintf("文件 %q 不存在", e.Value())
+	}
+	return fmt.Sprintf("不满足 %s 规则", e.Tag())
+}
+
+// 校验配置，把所有无效的参数合并为一条错误信息
+func (c *Config) Validate() error {
+	err := configValidator.Struct(c)
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return err
+	}
+	lines := make([]string, 0, len(errs))
+	for _, e := range errs {
+		// WebhookURLs 中的单个地址显示为 webhookURL[1]
+		name := strings.TrimPrefix(e.Namespace(), "Config.")
+		lines = append(lines, fmt.Sprintf("  --%s: %s", name, validationReason(e)))
+	}
+	return fmt.Errorf("配置无效:\n%s", strings.Join(lines, "\n"))
+}
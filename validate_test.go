@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func validConfig(t *testing.T) *Config {
+	path := filepath.Join(t.TempDir(), "slow.log")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return &Config{
+		WebhookURLs:        []string{"https://example.com/hook"},
+		SlowQueryThreshold: 1,
+		WebhookFormat:      "wechat",
+		SlowLogFile:        path,
+		AutoTunePercentile: 95,
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	if err := validConfig(t).Validate(); err != nil {
+		t.Fatalf("valid config rejected: %v", err)
+	}
+
+	cfg := validConfig(t)
+	cfg.WebhookURLs = nil
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "--webhookURL: 必须设置") {
+		t.Errorf("missing webhook URL: got %v", err)
+	}
+
+	cfg = validConfig(t)
+	cfg.AckCallbackURL = "https://example.com/ack"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "--httpAddr: ") {
+		t.Errorf("ackCallbackURL without httpAddr: got %v", err)
+	}
+}
+
+func TestConfigValidateListsEveryField(t *testing.T) {
+	cfg := validConfig(t)
+	cfg.WebhookURLs = []string{"https://example.com/hook", "not a url"}
+	cfg.SlowQueryThreshold = 7200
+	cfg.WebhookFormat = "email"
+	cfg.SlowLogFile = filepath.Join(t.TempDir(), "missing.log")
+	cfg.AutoTunePercentile = 0
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("invalid config accepted")
+	}
+	for _, want := range []string{
+		`--webhookURL[1]: "not a url" 不是有效的URL`,
+		"--slowQueryThreshold: 不能大于 3600",
+		`--webhookFormat: "email" 无效，可选值: wechat, slack, teams, feishu, generic`,
+		"--slowLogFile: 文件",
+		"--autoTunePercentile: 必须大于 0",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error missing %q:\n%v", want, err)
+		}
+	}
+}
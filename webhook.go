@@ -0,0 +1,515 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"golang.org/x/net/http2"
+	"golang.org/x/sync/errgroup"
+)
+
+// 最大并发发送数
+const maxWebhookConcurrency = 10
+
+// Webhook 推送目标
+type webhookTarget struct {
+	URL     string
+	Format  *webhookFormat    // 该地址的消息格式，nil 表示使用 --webhookFormat
+	Timeout time.Duration     // 该地址的发送超时时间，0 表示使用 --webhookTimeout
+	Headers map[string]string // 该地址额外的请求头
+}
+
+// 启动时解析得到的所有推送目标
+var webhookDestinations []webhookTarget
+
+// 解析 url|format|timeout 格式的地址，format 与 timeout 部分均可选，顺序不限
+func parseWebhookTarget(spec string) (webhookTarget, error) {
+	parts := strings.Split(spec, "|")
+	target := webhookTarget{URL: strings.TrimSpace(parts[0])}
+	for _, part := range parts[1:] {
+		part = strings.TrimSpace(part)
+		if format, ok := webhookFormats[strings.ToLower(part)]; ok {
+			target.Format = format
+			continue
+		}
+		timeout, err := time.ParseDuration(part)
+		if err != nil {
+			return target, fmt.Errorf("无法解析Webhook地址 %q 中的 %q，应为消息格式（%s）或超时时间", spec, part, strings.Join(webhookFormatNames(), ", "))
+		}
+		target.Timeout = timeout
+	}
+	return target, nil
+}
+
+// 汇总 --webhookURL 与 --webhookURLs 配置的所有地址，去除空值和重复项
+// --webhookURL 中也可以用逗号分隔多个地址
+func webhookTargets() ([]webhookTarget, error) {
+	seen := make(map[string]bool)
+	var targets []webhookTarget
+	for _, spec := range append(strings.Split(webhookURL, ","), webhookURLs...) {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		target, err := parseWebhookTarget(spec)
+		if err != nil {
+			return nil, err
+		}
+		if target.URL == "" || seen[target.URL] {
+			continue
+		}
+		seen[target.URL] = true
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// 解析 "Name: Value" 格式的请求头
+func parseWebhookHeaders(specs []string) (map[string]string, error) {
+	headers := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		name, value, ok := strings.Cut(spec, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("无法解析请求头 %q，格式应为 Name: Value", spec)
+		}
+		headers[name] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
+
+// 返回目标地址的URL列表，用于日志输出
+func webhookTargetURLs(targets []webhookTarget) []string {
+	urls := make([]string, 0, len(targets))
+	for _, target := range targets {
+		urls = append(urls, target.URL)
+	}
+	return urls
+}
+
+// 计算实际的并发数：默认与地址数量相同，最大不超过 maxWebhookConcurrency
+func effectiveWebhookConcurrency(targetCount int) int {
+	concurrency := webhookConcurrency
+	if concurrency <= 0 {
+		concurrency = targetCount
+	}
+	if concurrency > maxWebhookConcurrency {
+		concurrency = maxWebhookConcurrency
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return concurrency
+}
+
+// 支持的Webhook请求方法
+var webhookMethods = []string{"POST", "PUT"}
+
+// 校验 --webhookMethod 参数，返回大写的方法名
+func parseWebhookMethod(name string) (string, error) {
+	method := strings.ToUpper(strings.TrimSpace(name))
+	for _, m := range webhookMethods {
+		if method == m {
+			return method, nil
+		}
+	}
+	return "", fmt.Errorf("不支持的Webhook请求方法 %q，可选值: %s", name, strings.Join(webhookMethods, ", "))
+}
+
+// 根据命令行参数构建Webhook请求使用的TLS配置
+func buildWebhookTLSConfig(skipVerify bool, caCertFile string) (*tls.Config, error) {
+	if !skipVerify && caCertFile == "" {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{InsecureSkipVerify: skipVerify}
+	if caCertFile != "" {
+		pem, err := os.ReadFile(caCertFile)
+		if err != nil {
+			return nil, fmt.Errorf("无法读取CA证书文件 %s: %w", caCertFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA证书文件 %s 中没有有效的PEM证书", caCertFile)
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
+
+// 所有Webhook请求共用的客户端，复用 keep-alive 连接，启动时按命令行参数重新创建
+var webhookClient = newWebhookClient(nil, 30*time.Second, false)
+
+// 创建Webhook客户端，每个地址最多保留 maxWebhookConcurrency 个空闲连接
+// enableHTTP2 为 true 时通过 TLS ALPN 协商 HTTP/2，服务端不支持时使用 HTTP/1.1
+func newWebhookClient(tlsConfig *tls.Config, keepAliveInterval time.Duration, enableHTTP2 bool) *resty.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: keepAliveInterval}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   maxWebhookConcurrency,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	if webhookClientSettings != nil {
+		webhookClientSettings.apply(transport)
+	}
+	if enableHTTP2 {
+		if err := http2.ConfigureTransport(transport); err != nil {
+			logf(levelWarn, "无法启用 HTTP/2，使用 HTTP/1.1: %v", err)
+		}
+	}
+	return resty.New().SetTransport(transport)
+}
+
+// Webhook 返回非 2xx 状态码时的错误
+type webhookStatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *webhookStatusError) Error() string {
+	return "HTTP " + e.Status
+}
+
+// 发送单个Webhook请求，返回内容表示发送失败（例如限流）时等待后重试一次
+func postWebhook(target webhookTarget, payload string) error {
+	err := postWebhookOnce(target, payload)
+	var respErr *webhookResponseError
+	if errors.As(err, &respErr) && respErr.retryable() {
+		logf(levelWarn, "Webhook [%s] %v，%s 后重试", target.URL, err, webhookResponseRetryDelay)
+		time.Sleep(webhookResponseRetryDelay)
+		err = postWebhookOnce(target, payload)
+	}
+	return err
+}
+
+// 发送一次Webhook请求，超时时间优先使用该地址单独配置的值
+func postWebhookOnce(target webhookTarget, payload string) error {
+	timeout := target.Timeout
+	if timeout <= 0 {
+		timeout = webhookTimeout
+	}
+	method := webhookMethod
+	if method == "" {
+		method = resty.MethodPost
+	}
+	format := target.Format
+	if format == nil {
+		format = activeWebhookFormat
+	}
+	requestURL, err := webhookRequestURL(target.URL, format)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req := webhookClient.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json")
+	if webhookAcceptHeader != "" {
+		req.SetHeader("Accept", webhookAcceptHeader)
+	}
+	resp, err := req.
+		SetHeaders(target.Headers).
+		SetBody(payload).
+		Execute(method, requestURL)
+	if err != nil {
+		return err
+	}
+	if resp.IsError() {
+		return &webhookStatusError{StatusCode: resp.StatusCode(), Status: resp.Status()}
+	}
+	return validateWebhookResponse(format, resp.Body())
+}
+
+// 设置了 --webhookFormatParam 时在地址后追加消息格式参数，例如 ?format=slack，供 Zapier、n8n 等平台按格式分流
+func webhookRequestURL(rawURL string, format *webhookFormat) (string, error) {
+	if webhookFormatParam == "" {
+		return rawURL, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set(webhookFormatParam, format.Name)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// 序列化消息体，不转义 <font> 等 HTML 字符
+func marshalPayload(v any) (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// 当前使用的消息格式
+var activeWebhookFormat = webhookFormats["wechat"]
+
+// 根据命令行参数设置消息格式、推送目标、备用地址与Webhook客户端，子命令发送通知前也需要调用
+func configureWebhooks() error {
+	format, err := lookupWebhookFormat(webhookFormatName)
+	if err != nil {
+		return err
+	}
+	activeWebhookFormat = format
+
+	if webhookTemplate != "" && alertTemplateDir != "" {
+		return fmt.Errorf("--webhookTemplate 与 --alertTemplateDir 不能同时使用")
+	}
+	if webhookTemplate != "" {
+		if err := loadWebhookTemplate(webhookTemplate); err != nil {
+			return err
+		}
+	}
+	if alertTemplateDir != "" {
+		if err := loadAlertTemplateDir(alertTemplateDir); err != nil {
+			return err
+		}
+	}
+
+	if webhookFallbackURL != "" {
+		fallbackFormat := activeWebhookFormat
+		if fallbackWebhookFormatName != "" {
+			if fallbackFormat, err = lookupWebhookFormat(fallbackWebhookFormatName); err != nil {
+				return err
+			}
+		}
+		headers, err := parseWebhookHeaders(fallbackWebhookHeaders)
+		if err != nil {
+			return err
+		}
+		fallbackDestination = &webhookTarget{URL: webhookFallbackURL, Headers: headers}
+		fallbackWebhookFormat = fallbackFormat
+	}
+
+	targets, err := webhookTargets()
+	if err != nil {
+		return err
+	}
+	webhookDestinations = targets
+	if err := configureWebhookRoutes(); err != nil {
+		return err
+	}
+
+	if escalationWebhookURL != "" && escalationAfter > 0 {
+		target, err := parseWebhookTarget(escalationWebhookURL)
+		if err != nil {
+			return err
+		}
+		escalationTarget = &target
+	}
+
+	if selfAlertWebhookURL != "" {
+		target, err := parseWebhookTarget(selfAlertWebhookURL)
+		if err != nil {
+			return err
+		}
+		selfAlertTarget = &target
+		for _, dest := range webhookDestinations {
+			if dest.URL == target.URL {
+				logf(levelWarn, "--selfAlertWebhookURL 与告警地址 %s 相同，该地址故障时将无法收到自身健康告警", target.URL)
+			}
+		}
+	}
+
+	method, err := parseWebhookMethod(webhookMethod)
+	if err != nil {
+		return err
+	}
+	webhookMethod = method
+
+	tlsConfig, err := buildWebhookTLSConfig(webhookTLSSkipVerify, webhookCACert)
+	if err != nil {
+		return err
+	}
+	webhookClientSettings = nil
+	if webhookClientConfig != "" {
+		if webhookClientSettings, err = loadWebhookClientConfig(webhookClientConfig); err != nil {
+			return err
+		}
+	}
+	webhookClient = newWebhookClient(tlsConfig, webhookKeepAliveInterval, webhookHTTP2)
+	return nil
+}
+
+// 备用推送目标及其消息格式，nil 表示未配置 --webhookFallbackURL
+var fallbackDestination *webhookTarget
+var fallbackWebhookFormat *webhookFormat
+
+// 通知未能送达任何地址时返回的错误
+var errUndelivered = errors.New("通知未能发送到任何Webhook地址")
+
+// 发送慢查询告警通知，返回发送的消息条数
+func sendWebhookNotification(targets []webhookTarget, entry *SlowQueryEntry) (int, error) {
+	return sendEntryNotification(targets, entry, buildSlowQueryNotification(entry))
+}
+
+// 发送由 entry 生成的告警：记录查询历史，同一指纹告警次数过多时升级，分组与合并告警也通过这里发送
+func sendEntryNotification(targets []webhookTarget, entry *SlowQueryEntry, n *notification) (int, error) {
+	n.HistoryID = recordAlertHistory(entry)
+	// 发送前确定是否升级，定时器发送分组与合并告警时，送达后不再读取全局配置
+	escalate := escalationTarget != nil && escalationDue(entry.Hash)
+	sent, err := deliverNotification(targets, n)
+	if escalate {
+		escalateNotification(n)
+	}
+	return sent, err
+}
+
+// 发送通知，未能送达任何地址时改为发送到备用地址，备用地址也失败时写入 --deadLetterFile
+func deliverNotification(targets []webhookTarget, n *notification) (int, error) {
+	sent, attempts, err := deliverWithFallback(targets, n)
+	writeAuditRecord(targets, n, sent, err)
+	if len(attempts) > 0 {
+		if deadLetterFile == "" {
+			logf(levelError, "通知未能送达，告警已丢失: %s", n.Title)
+		} else if werr := appendDeadLetter(deadLetterFile, n, attempts); werr != nil {
+			logf(levelError, "通知未能送达且无法写入死信文件，告警已丢失: %v", werr)
+		} else {
+			logf(levelWarn, "通知未能送达，已写入死信文件 %s", deadLetterFile)
+		}
+	}
+	return sent, err
+}
+
+// 依次发送到配置的地址与备用地址，通知未能送达时返回每次尝试的记录
+func deliverWithFallback(targets []webhookTarget, n *notification) (int, []deadLetterAttempt, error) {
+	started := time.Now()
+	sent, err := sendNotification(activeWebhookFormat, targets, n)
+	if !errors.Is(err, errUndelivered) {
+		return sent, nil, err
+	}
+	attempts := []deadLetterAttempt{newDeadLetterAttempt(started, targets, err)}
+	if fallbackDestination == nil {
+		return sent, attempts, err
+	}
+
+	logf(levelWarn, "%v，改为发送到备用地址 [%s]", errUndelivered, fallbackDestination.URL)
+	started = time.Now()
+	fallbackSent, fallbackErr := sendNotification(fallbackWebhookFormat, []webhookTarget{*fallbackDestination}, n)
+	if fallbackErr != nil {
+		attempts = append(attempts, newDeadLetterAttempt(started, []webhookTarget{*fallbackDestination}, fallbackErr))
+		return sent + fallbackSent, attempts, errors.Join(err, fallbackErr)
+	}
+	return sent + fallbackSent, nil, nil
+}
+
+// 使用同一消息格式的推送目标
+type webhookTargetGroup struct {
+	format  *webhookFormat
+	targets []webhookTarget
+}
+
+// 按消息格式对推送目标分组，未指定格式的地址使用 format，分组顺序与地址的配置顺序一致
+func groupTargetsByFormat(format *webhookFormat, targets []webhookTarget) []webhookTargetGroup {
+	var groups []webhookTargetGroup
+	index := make(map[*webhookFormat]int)
+	for _, target := range targets {
+		f := target.Format
+		if f == nil {
+			f = format
+		}
+		i, ok := index[f]
+		if !ok {
+			i = len(groups)
+			index[f] = i
+			groups = append(groups, webhookTargetGroup{format: f})
+		}
+		// 记录实际使用的格式，按该格式检查返回内容
+		target.Format = f
+		groups[i].targets = append(groups[i].targets, target)
+	}
+	return groups
+}
+
+// 按各地址的消息格式渲染并发送通知，内容超过大小限制时拆分为多条消息依次发送
+// 每种格式只渲染一次，返回至少送达一个地址的消息条数；没有任何一组地址完整收到通知时返回的错误包含 errUndelivered
+func sendNotification(format *webhookFormat, targets []webhookTarget, n *notification) (int, error) {
+	var sent int
+	var errs []error
+	undelivered := len(targets) > 0
+	for _, group := range groupTargetsByFormat(format, targets) {
+		payloads, err := buildPayloads(group.format, n)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		complete := true
+		for _, payload := range payloads {
+			delivered, err := deliverPayload(n, group.targets, payload)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			if delivered == 0 {
+				complete = false
+				continue
+			}
+			sent++
+		}
+		if complete {
+			undelivered = false
+		}
+	}
+	if undelivered {
+		errs = append([]error{errUndelivered}, errs...)
+	}
+	return sent, errors.Join(errs...)
+}
+
+// 并发推送到所有地址，返回发送成功的地址数与所有失败地址的汇总错误
+func deliverPayload(n *notification, targets []webhookTarget, payload string) (int, error) {
+	logf(levelDebug, "Webhook请求内容: %s", payload)
+
+	var mu sync.Mutex
+	var errs []error
+
+	var g errgroup.Group
+	g.SetLimit(effectiveWebhookConcurrency(len(targets)))
+	for _, target := range targets {
+		g.Go(func() error {
+			sentAt := time.Now()
+			webhookCallsActive.Inc()
+			err := postWebhook(target, payload)
+			webhookCallsActive.Dec()
+			recordDeliveryHistory(n.HistoryID, target, sentAt, err)
+			recordWebhookOutcome(err)
+			if err != nil {
+				derr := &DeliveryError{URL: target.URL, Err: err}
+				reportError(derr)
+				mu.Lock()
+				errs = append(errs, derr)
+				mu.Unlock()
+				return nil
+			}
+			logf(levelInfo, "Webhook通知已发送 [%s]", target.URL)
+			return nil
+		})
+	}
+	g.Wait()
+
+	return len(targets) - len(errs), errors.Join(errs...)
+}
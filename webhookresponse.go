@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// 返回内容为限流错误码时重试前的等待时间，企业微信等平台限流时返回 2xx 与错误码
+var webhookResponseRetryDelay = time.Second
+
+// Webhook 返回 2xx 状态码但返回内容表示发送失败时的错误
+type webhookResponseError struct {
+	Code    string
+	Message string
+}
+
+func (e *webhookResponseError) Error() string {
+	return fmt.Sprintf("返回错误 %s: %s", e.Code, e.Message)
+}
+
+// 限流或平台繁忙时返回的错误码，稍后重试可能成功；token 无效、关键词不匹配等错误码重试也不会成功
+var retryableWebhookCodes = map[string]bool{
+	"-1":     true, // 企业微信：系统繁忙
+	"45009":  true, // 企业微信：接口调用超过限制
+	"130101": true, // 钉钉：发送速度太快
+	"410100": true, // 钉钉：发送速度太快而限流
+	"9499":   true, // 飞书：请求过于频繁
+	"11232":  true, // 飞书：超出发送频率限制
+}
+
+// 是否是限流等重试可能成功的错误
+func (e *webhookResponseError) retryable() bool {
+	return retryableWebhookCodes[e.Code] || e.Message == "rate_limited"
+}
+
+// 企业微信、钉钉等平台的返回内容：{"errcode":0,"errmsg":"ok"}，Slack API 风格的平台返回 {"ok":false,"error":"..."}
+func validateJSONResponse(body []byte) error {
+	var resp struct {
+		ErrCode *int   `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+		OK      *bool  `json:"ok"`
+		Error   string `json:"error"`
+	}
+	// 返回内容不是JSON时无法判断，视为成功
+	if json.Unmarshal(body, &resp) != nil {
+		return nil
+	}
+	if resp.ErrCode != nil && *resp.ErrCode != 0 {
+		return &webhookResponseError{Code: fmt.Sprintf("%d", *resp.ErrCode), Message: resp.ErrMsg}
+	}
+	if resp.OK != nil && !*resp.OK {
+		return &webhookResponseError{Code: "ok=false", Message: resp.Error}
+	}
+	return nil
+}
+
+// Slack incoming webhook 成功时返回纯文本 ok
+func validateSlackResponse(body []byte) error {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 || string(body) == "ok" {
+		return nil
+	}
+	if body[0] == '{' {
+		return validateJSONResponse(body)
+	}
+	return &webhookResponseError{Code: "slack", Message: string(body)}
+}
+
+// Teams 成功时返回 1，目标渠道出错时仍返回 200 与错误说明
+func validateTeamsResponse(body []byte) error {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 || string(body) == "1" {
+		return nil
+	}
+	if bytes.Contains(body, []byte("failed")) {
+		return &webhookResponseError{Code: "teams", Message: string(body)}
+	}
+	return nil
+}
+
+// 飞书返回 {"code":0,"msg":"success"}，旧版本返回 {"StatusCode":0,"StatusMessage":"success"}
+func validateFeishuResponse(body []byte) error {
+	var resp struct {
+		Code          *int   `json:"code"`
+		Msg           string `json:"msg"`
+		StatusCode    *int   `json:"StatusCode"`
+		StatusMessage string `json:"StatusMessage"`
+	}
+	if json.Unmarshal(body, &resp) != nil {
+		return nil
+	}
+	if resp.Code != nil && *resp.Code != 0 {
+		return &webhookResponseError{Code: fmt.Sprintf("%d", *resp.Code), Message: resp.Msg}
+	}
+	if resp.StatusCode != nil && *resp.StatusCode != 0 {
+		return &webhookResponseError{Code: fmt.Sprintf("%d", *resp.StatusCode), Message: resp.StatusMessage}
+	}
+	return nil
+}
+
+// 按消息格式检查 2xx 响应的返回内容，未设置校验函数的格式按JSON中的 errcode 与 ok 判断
+func validateWebhookResponse(format *webhookFormat, body []byte) error {
+	if format != nil && format.ValidateResponse != nil {
+		return format.ValidateResponse(body)
+	}
+	return validateJSONResponse(body)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestValidateWebhookResponse(t *testing.T) {
+	for _, tt := range []struct {
+		format string
+		body   string
+		ok     bool
+	}{
+		{"wechat", `{"errcode":0,"errmsg":"ok"}`, true},
+		{"wechat", `{"errcode":93000,"errmsg":"invalid webhook url"}`, false},
+		{"generic", `{"errcode":300001,"errmsg":"token is not exist"}`, false}, // 钉钉
+		{"generic", `{"ok":false,"error":"channel_not_found"}`, false},
+		{"generic", `accepted`, true},
+		{"slack", `ok`, true},
+		{"slack", `invalid_token`, false},
+		{"teams", `1`, true},
+		{"teams", `Webhook message delivery failed with error: Microsoft Teams endpoint returned HTTP error 429`, false},
+		{"feishu", `{"code":0,"msg":"success"}`, true},
+		{"feishu", `{"code":19001,"msg":"param invalid: incoming webhook access token invalid"}`, false},
+		{"feishu", `{"StatusCode":0,"StatusMessage":"success"}`, true},
+	} {
+		err := validateWebhookResponse(webhookFormats[tt.format], []byte(tt.body))
+		if (err == nil) != tt.ok {
+			t.Errorf("%s %s: err = %v", tt.format, tt.body, err)
+		}
+	}
+}
+
+func TestPostWebhookRetriesErrorResponse(t *testing.T) {
+	var requests atomic.Int32
+	var alwaysFail atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 || alwaysFail.Load() {
+			w.Write([]byte(`{"errcode":45009,"errmsg":"api freq out of limit"}`))
+			return
+		}
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	defer server.Close()
+	prevDelay := webhookResponseRetryDelay
+	t.Cleanup(func() { webhookResponseRetryDelay = prevDelay })
+	webhookResponseRetryDelay = 0
+
+	target := webhookTarget{URL: server.URL, Format: webhookFormats["wechat"], Timeout: 5 * time.Second}
+	if err := postWebhook(target, `{}`); err != nil || requests.Load() != 2 {
+		t.Fatalf("返回错误码后应重试一次: err=%v requests=%d", err, requests.Load())
+	}
+
+	alwaysFail.Store(true)
+	var respErr *webhookResponseError
+	if err := postWebhook(target, `{}`); !errors.As(err, &respErr) || respErr.Code != "45009" {
+		t.Errorf("重试仍失败时应返回 webhookResponseError: %v", err)
+	}
+}
+
+func TestPostWebhookDoesNotRetryPermanentErrors(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(`{"errcode":300001,"errmsg":"token is not exist"}`))
+	}))
+	defer server.Close()
+	prevDelay := webhookResponseRetryDelay
+	t.Cleanup(func() { webhookResponseRetryDelay = prevDelay })
+	webhookResponseRetryDelay = time.Hour
+
+	// token 无效等错误重试也不会成功，应立即返回而不是等待 webhookResponseRetryDelay
+	target := webhookTarget{URL: server.URL, Format: webhookFormats["generic"], Timeout: 5 * time.Second}
+	err := postWebhook(target, `{}`)
+	if err == nil || requests.Load() != 1 {
+		t.Errorf("不可重试的错误码不应重试: err=%v requests=%d", err, requests.Load())
+	}
+	if !isWebhookAuthError(err) || isWebhookAuthError(&webhookResponseError{Code: "45009"}) {
+		t.Errorf("只有限流以外的错误码应视为鉴权失败: %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// 一个指纹出现过的最慢的一次查询
+type worstQuery struct {
+	Fingerprint string    `json:"fingerprint"`
+	QueryTime   float64   `json:"queryTime"`
+	SQL         string    `json:"sql"`
+	SeenAt      time.Time `json:"seenAt"`
+}
+
+// 各指纹的最慢记录，键为十六进制的指纹哈希，保存在 --worstQueryFile 中
+var worstQueries = struct {
+	sync.Mutex
+	byHash map[string]worstQuery
+}{byHash: make(map[string]worstQuery)}
+
+// 读取最慢记录文件，文件不存在时从空记录开始
+func loadWorstQueries(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("无法读取最慢记录文件 %s: %w", path, err)
+	}
+	records := make(map[string]worstQuery)
+	if err := json.Unmarshal(data, &records); err != nil {
+		return fmt.Errorf("最慢记录文件 %s 格式错误: %w", path, err)
+	}
+	worstQueries.Lock()
+	worstQueries.byHash = records
+	worstQueries.Unlock()
+	return nil
+}
+
+// 更新指纹的最慢记录并写入文件，刷新了已有记录时返回原记录
+// 第一次出现的指纹只记录，不返回原记录
+func updateWorstQuery(path string, entry *SlowQueryEntry, now time.Time) (worstQuery, bool) {
+	key := fmt.Sprintf("%016x", entry.Hash)
+	worstQueries.Lock()
+	defer worstQueries.Unlock()
+	previous, seen := worstQueries.byHash[key]
+	if seen && entry.QueryTime <= previous.QueryTime {
+		return worstQuery{}, false
+	}
+	worstQueries.byHash[key] = worstQuery{Fingerprint: entry.Fingerprint, QueryTime: entry.QueryTime, SQL: entry.SQL, SeenAt: now}
+
+	data, err := json.Marshal(worstQueries.byHash)
+	if err == nil {
+		err = writeFileAtomic(path, data)
+	}
+	if err != nil {
+		logf(levelWarn, "无法写入最慢记录文件 %s: %v", path, err)
+	}
+	return previous, seen
+}
+
+// 生成最慢记录刷新的通知
+func buildWorstQueryNotification(entry *SlowQueryEntry, previous worstQuery) *notification {
+	return &notification{
+		Title: "🏆 该查询的最慢记录已刷新 (New worst time)",
+		Fields: []notificationField{
+			{Label: "SQL指纹", Value: entry.Fingerprint},
+			{Label: "新的最慢时间", Value: fmt.Sprintf("%.2f 秒", entry.QueryTime), Highlight: true},
+			{Label: "原最慢时间", Value: fmt.Sprintf("%.2f 秒（%s）", previous.QueryTime, formatDisplayTime(previous.SeenAt))},
+			{Label: "数据库", Value: entry.Database},
+			{Label: "用户", Value: entry.User},
+			{Label: "告警时间", Value: formatDisplayTime(time.Now())},
+		},
+		SQL:         entry.SQL,
+		Labels:      entryLabels(entry),
+		Fingerprint: entry.Fingerprint,
+	}
+}
+
+// 发送最慢记录刷新的通知，测试中可以替换为模拟实现
+var worstQueryNotifier = func(targets []webhookTarget, entry *SlowQueryEntry, previous worstQuery) (int, error) {
+	return deliverNotification(targets, buildWorstQueryNotification(entry, previous))
+}
+
+// 检查慢查询是否刷新了该指纹的最慢记录
+func checkWorstQuery(entry *SlowQueryEntry) {
+	previous, ok := updateWorstQuery(worstQueryFile, entry, time.Now())
+	if !ok {
+		return
+	}
+	logf(levelInfo, "指纹 %x 的最慢记录从 %.2f 秒刷新为 %.2f 秒", entry.Hash, previous.QueryTime, entry.QueryTime)
+	worstQueryNotifier(routeTargets(entry), entry, previous)
+}
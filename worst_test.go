@@ -0,0 +1,39 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUpdateWorstQuery(t *testing.T) {
+	t.Cleanup(func() { worstQueries.byHash = make(map[string]worstQuery) })
+	worstQueries.byHash = make(map[string]worstQuery)
+	path := filepath.Join(t.TempDir(), "worst.json")
+	entry := &SlowQueryEntry{Fingerprint: "select * from t where id = ?", Hash: computeQueryHash("select * from t where id = ?")}
+	first := time.Date(2024, 3, 10, 8, 0, 0, 0, time.UTC)
+
+	entry.QueryTime = 2
+	if _, ok := updateWorstQuery(path, entry, first); ok {
+		t.Errorf("第一次出现的指纹不应发送通知")
+	}
+	entry.QueryTime = 1.5
+	if _, ok := updateWorstQuery(path, entry, first.Add(time.Minute)); ok {
+		t.Errorf("没有超过最慢记录时不应发送通知")
+	}
+	entry.QueryTime = 3
+	previous, ok := updateWorstQuery(path, entry, first.Add(2*time.Minute))
+	if !ok || previous.QueryTime != 2 || !previous.SeenAt.Equal(first) {
+		t.Fatalf("刷新最慢记录时应返回原记录，实际 %+v, %v", previous, ok)
+	}
+
+	// 重启后从文件恢复
+	worstQueries.byHash = make(map[string]worstQuery)
+	if err := loadWorstQueries(path); err != nil {
+		t.Fatal(err)
+	}
+	entry.QueryTime = 2.5
+	if _, ok := updateWorstQuery(path, entry, first.Add(3*time.Minute)); ok {
+		t.Errorf("恢复后的最慢记录应为 3 秒")
+	}
+}